@@ -9,6 +9,7 @@ import (
 	"canchas-api/internal/services"
 	"context"
 	"log"
+	"shared/auth"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -29,10 +30,23 @@ func main() {
 	reservaClient := clients.NewReservaClient()
 
 	canchaRepo := repositories.NewCanchaRepository(db)
+	// Pasar a precio base las canchas creadas antes de la política de precios
+	if err := canchaRepo.BackfillLegacyPricing(); err != nil {
+		log.Printf("Warning: failed to backfill legacy pricing: %v", err)
+	}
 	canchaService := services.NewCanchaService(canchaRepo, publisher, reservaClient)
 	canchaController := controllers.NewCanchaController(canchaService)
 
-	router := setupRouter(canchaController)
+	blackoutRepo := repositories.NewBlackoutRepository(db)
+	blackoutService := services.NewBlackoutService(blackoutRepo, canchaRepo, reservaClient)
+	blackoutController := controllers.NewBlackoutController(blackoutService)
+
+	availabilityService := services.NewAvailabilityService(canchaRepo, blackoutRepo, reservaClient)
+	availabilityController := controllers.NewAvailabilityController(availabilityService)
+
+	validator := auth.NewJWKSValidator(config.AppConfig.JWKSURL, 10*time.Minute)
+
+	router := setupRouter(canchaController, blackoutController, availabilityController, validator)
 
 	port := config.AppConfig.Port
 	log.Printf("Server starting on port %s", port)
@@ -68,7 +82,12 @@ func connectMongoDB() *mongo.Database {
 	return nil
 }
 
-func setupRouter(canchaController *controllers.CanchaController) *gin.Engine {
+func setupRouter(
+	canchaController *controllers.CanchaController,
+	blackoutController *controllers.BlackoutController,
+	availabilityController *controllers.AvailabilityController,
+	validator auth.Validator,
+) *gin.Engine {
 	router := gin.Default()
 	router.Use(corsMiddleware())
 
@@ -82,12 +101,26 @@ func setupRouter(canchaController *controllers.CanchaController) *gin.Engine {
 	// Rutas públicas (cualquiera puede ver canchas)
 	router.GET("/canchas", canchaController.GetAll)
 	router.GET("/canchas/:id", canchaController.GetByID)
+	router.GET("/canchas/:id/quote", canchaController.Quote) // reservas-api cotiza cada reserva con esta ruta
+	router.GET("/canchas/:id/availability", availabilityController.Get)
+
+	// Rutas protegidas: canchas:manage administra todas, canchas:manage_own solo las propias
+	manage := router.Group("/canchas")
+	manage.Use(auth.Middleware(validator), auth.RequireAnyPermission(auth.PermCanchasManage, auth.PermCanchasManageOwn))
+	{
+		manage.POST("", canchaController.Create)
+		manage.PUT("/:id", canchaController.Update)
+		manage.DELETE("/:id", canchaController.Delete)
+	}
 
-	// Rutas protegidas (SOLO ADMIN puede crear/editar/eliminar)
-	// Por ahora sin middleware, pero deberías añadirlo
-	router.POST("/canchas", canchaController.Create)       // TODO: Añadir AdminMiddleware
-	router.PUT("/canchas/:id", canchaController.Update)    // TODO: Añadir AdminMiddleware
-	router.DELETE("/canchas/:id", canchaController.Delete) // TODO: Añadir AdminMiddleware
+	// Cierres (feriados, mantenimiento, torneos): la consulta es pública, reservas-api la usa al reservar
+	router.GET("/blackouts", blackoutController.GetAll)
+	blackouts := router.Group("/blackouts")
+	blackouts.Use(auth.Middleware(validator), auth.RequireAnyPermission(auth.PermCanchasManage, auth.PermCanchasManageOwn))
+	{
+		blackouts.POST("", blackoutController.Create)
+		blackouts.DELETE("/:id", blackoutController.Delete)
+	}
 
 	log.Println("Routes configured successfully")
 	return router
@@ -16,6 +16,8 @@ type Config struct {
 	RabbitMQQueue    string
 	UsersAPIURL      string
 	ReservasAPIURL   string
+	JWKSURL          string
+	ServiceToken     string // secreto compartido para llamar a las rutas internas de reservas-api
 }
 
 var AppConfig *Config
@@ -36,6 +38,8 @@ func LoadConfig() {
 		RabbitMQQueue:    getEnv("RABBITMQ_QUEUE", "canchas_queue"),
 		UsersAPIURL:      getEnv("USERS_API_URL", "http://localhost:8080"),
 		ReservasAPIURL:   getEnv("RESERVAS_API_URL", "http://localhost:8082"),
+		JWKSURL:          getEnv("JWKS_URL", "http://localhost:8080/.well-known/jwks.json"),
+		ServiceToken:     os.Getenv("SERVICE_TOKEN"),
 	}
 
 	log.Println("Configuration loaded successfully")
@@ -1,34 +1,66 @@
 package clients
 
 import (
+	"bytes"
 	"canchas-api/config"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"shared/auth"
 	"time"
 )
 
 type ReservaClient interface {
 	DeleteByCanchaID(canchaID string) error
+	FindConflicts(req ConflictsRequest) (*ConflictsResponse, error)
 }
 
 type reservaClient struct {
 	httpClient *http.Client
 	baseURL    string
+	token      string
+}
+
+// ConflictsRequest pide a reservas-api las reservas activas que se superponen con un cierre
+type ConflictsRequest struct {
+	CanchaIDs []string  `json:"cancha_ids"` // vacío = todas las canchas
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Cancel    bool      `json:"-"` // cancelar las reservas encontradas (usa POST /reservas/conflicts/cancel)
+}
+
+// ConflictingReserva es una reserva que se superpone con un cierre
+type ConflictingReserva struct {
+	ID        string `json:"id"`
+	CanchaID  string `json:"cancha_id"`
+	UserID    uint   `json:"user_id"`
+	Date      string `json:"date"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	Status    string `json:"status"`
+}
+
+type ConflictsResponse struct {
+	Reservas  []ConflictingReserva `json:"reservas"`
+	Cancelled int                  `json:"cancelled"`
 }
 
 func NewReservaClient() ReservaClient {
 	return &reservaClient{
 		httpClient: &http.Client{Timeout: 10 * time.Second},
 		baseURL:    config.AppConfig.ReservasAPIURL,
+		token:      config.AppConfig.ServiceToken,
 	}
 }
 
+// DeleteByCanchaID borra las reservas de una cancha eliminada; se autentica con el token de servicio
 func (c *reservaClient) DeleteByCanchaID(canchaID string) error {
 	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/reservas/cancha/%s", c.baseURL, canchaID), nil)
 	if err != nil {
 		return fmt.Errorf("failed to build request to reservas-api: %w", err)
 	}
+	req.Header.Set(auth.ServiceTokenHeader, c.token)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -43,3 +75,41 @@ func (c *reservaClient) DeleteByCanchaID(canchaID string) error {
 
 	return nil
 }
+
+// FindConflicts lista (y opcionalmente cancela) las reservas que chocan con un cierre.
+// Se autentica con el token de servicio: la ruta de solo lectura nunca cancela.
+func (c *reservaClient) FindConflicts(conflictsReq ConflictsRequest) (*ConflictsResponse, error) {
+	body, err := json.Marshal(conflictsReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request to reservas-api: %w", err)
+	}
+
+	path := "/reservas/conflicts"
+	if conflictsReq.Cancel {
+		path = "/reservas/conflicts/cancel"
+	}
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request to reservas-api: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(auth.ServiceTokenHeader, c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call reservas-api: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("reservas-api returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var conflicts ConflictsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&conflicts); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &conflicts, nil
+}
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"canchas-api/internal/dto"
+	"canchas-api/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AvailabilityController struct {
+	service services.AvailabilityService
+}
+
+func NewAvailabilityController(service services.AvailabilityService) *AvailabilityController {
+	return &AvailabilityController{service: service}
+}
+
+// Get retorna la grilla de turnos reservables de una cancha, día por día
+// GET /canchas/:id/availability?from=2025-11-15&to=2025-11-21
+func (ctrl *AvailabilityController) Get(c *gin.Context) {
+	availability, err := ctrl.service.Get(c.Param("id"), c.Query("from"), c.Query("to"))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidAvailability) {
+			statusCode = http.StatusBadRequest
+		}
+		if err.Error() == "cancha not found" || err.Error() == "invalid ID format" {
+			statusCode = http.StatusNotFound
+		}
+
+		c.JSON(statusCode, dto.ErrorResponse{
+			Error:   "Failed to get availability",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, availability)
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"canchas-api/internal/dto"
+	"canchas-api/internal/services"
+	"errors"
+	"net/http"
+	"shared/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BlackoutController struct {
+	service services.BlackoutService
+}
+
+func NewBlackoutController(service services.BlackoutService) *BlackoutController {
+	return &BlackoutController{service: service}
+}
+
+// Create crea un cierre para una cancha o para todo el predio
+// POST /blackouts
+func (ctrl *BlackoutController) Create(c *gin.Context) {
+	var req dto.CreateBlackoutRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	claims, _ := auth.ClaimsFromContext(c)
+	blackout, err := ctrl.service.Create(&req, claims)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidBlackout) {
+			statusCode = http.StatusBadRequest
+		}
+		if err.Error() == "cancha not found" || err.Error() == "invalid ID format" {
+			statusCode = http.StatusNotFound
+		}
+		if err.Error() == "forbidden" {
+			statusCode = http.StatusForbidden
+		}
+
+		c.JSON(statusCode, dto.ErrorResponse{
+			Error:   "Failed to create blackout",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, blackout)
+}
+
+// GetAll lista los cierres, opcionalmente de una cancha (incluye los de todo el predio) y en un rango
+// GET /blackouts?cancha_id=&from=&to=
+func (ctrl *BlackoutController) GetAll(c *gin.Context) {
+	blackouts, err := ctrl.service.List(c.Query("cancha_id"), c.Query("from"), c.Query("to"))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidBlackout) {
+			statusCode = http.StatusBadRequest
+		}
+
+		c.JSON(statusCode, dto.ErrorResponse{
+			Error:   "Failed to get blackouts",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, blackouts)
+}
+
+// Delete elimina un cierre
+// DELETE /blackouts/:id
+func (ctrl *BlackoutController) Delete(c *gin.Context) {
+	id := c.Param("id")
+
+	claims, _ := auth.ClaimsFromContext(c)
+	if err := ctrl.service.Delete(id, claims); err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "blackout not found" || err.Error() == "cancha not found" || err.Error() == "invalid ID format" {
+			statusCode = http.StatusNotFound
+		}
+		if err.Error() == "forbidden" {
+			statusCode = http.StatusForbidden
+		}
+
+		c.JSON(statusCode, dto.ErrorResponse{
+			Error:   "Failed to delete blackout",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Blackout deleted successfully",
+	})
+}
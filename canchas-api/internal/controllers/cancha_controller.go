@@ -3,7 +3,10 @@ package controllers
 import (
 	"canchas-api/internal/dto"
 	"canchas-api/internal/services"
+	"errors"
 	"net/http"
+	"shared/auth"
+	"shared/schedule"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,7 +19,7 @@ func NewCanchaController(service services.CanchaService) *CanchaController {
 	return &CanchaController{service: service}
 }
 
-// Create maneja la creación de una cancha (SOLO ADMIN)
+// Create maneja la creación de una cancha (requiere canchas:manage o canchas:manage_own)
 // POST /canchas
 func (ctrl *CanchaController) Create(c *gin.Context) {
 	var req dto.CreateCanchaRequest
@@ -29,12 +32,16 @@ func (ctrl *CanchaController) Create(c *gin.Context) {
 		return
 	}
 
-	cancha, err := ctrl.service.Create(&req)
+	claims, _ := auth.ClaimsFromContext(c)
+	cancha, err := ctrl.service.Create(&req, claims)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
 		if err.Error() == "Ya existe una cancha con ese número y de ese tipo." || err.Error() == "Ya existe una cancha con ese nombre." {
 			statusCode = http.StatusConflict // 409
 		}
+		if errors.Is(err, services.ErrInvalidSchedule) || errors.Is(err, services.ErrInvalidPricing) {
+			statusCode = http.StatusBadRequest
+		}
 
 		c.JSON(statusCode, dto.ErrorResponse{
 			Error:   "Failed to create cancha",
@@ -83,7 +90,34 @@ func (ctrl *CanchaController) GetAll(c *gin.Context) {
 	c.JSON(http.StatusOK, canchas)
 }
 
-// Update actualiza una cancha existente (SOLO ADMIN)
+// Quote cotiza un turno de la cancha con su política de precios
+// GET /canchas/:id/quote?date=2025-11-15&start_time=18:00&member=true
+func (ctrl *CanchaController) Quote(c *gin.Context) {
+	id := c.Param("id")
+	member := c.Query("member") == "true"
+
+	quote, err := ctrl.service.Quote(id, c.Query("date"), c.Query("start_time"), member)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		var slotErr *schedule.SlotError
+		if err.Error() == "cancha not found" || err.Error() == "invalid ID format" {
+			statusCode = http.StatusNotFound
+		}
+		if errors.Is(err, services.ErrInvalidQuote) || errors.As(err, &slotErr) {
+			statusCode = http.StatusBadRequest
+		}
+
+		c.JSON(statusCode, dto.ErrorResponse{
+			Error:   "Failed to quote cancha",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, quote)
+}
+
+// Update actualiza una cancha existente (canchas:manage o su encargado)
 // PUT /canchas/:id
 func (ctrl *CanchaController) Update(c *gin.Context) {
 	id := c.Param("id")
@@ -97,15 +131,22 @@ func (ctrl *CanchaController) Update(c *gin.Context) {
 		return
 	}
 
-	cancha, err := ctrl.service.Update(id, &req)
+	claims, _ := auth.ClaimsFromContext(c)
+	cancha, err := ctrl.service.Update(id, &req, claims)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
 		if err.Error() == "cancha not found" || err.Error() == "invalid ID format" {
 			statusCode = http.StatusNotFound
 		}
+		if err.Error() == "forbidden" {
+			statusCode = http.StatusForbidden
+		}
 		if err.Error() == "Ya existe una cancha con ese número y de ese tipo." || err.Error() == "Ya existe una cancha con ese nombre." {
 			statusCode = http.StatusConflict // 409
 		}
+		if errors.Is(err, services.ErrInvalidSchedule) || errors.Is(err, services.ErrInvalidPricing) {
+			statusCode = http.StatusBadRequest
+		}
 
 		c.JSON(statusCode, dto.ErrorResponse{
 			Error:   "Failed to update cancha",
@@ -117,16 +158,20 @@ func (ctrl *CanchaController) Update(c *gin.Context) {
 	c.JSON(http.StatusOK, cancha)
 }
 
-// Delete elimina una cancha (SOLO ADMIN)
+// Delete elimina una cancha (canchas:manage o su encargado)
 // DELETE /canchas/:id
 func (ctrl *CanchaController) Delete(c *gin.Context) {
 	id := c.Param("id")
 
-	if err := ctrl.service.Delete(id); err != nil {
+	claims, _ := auth.ClaimsFromContext(c)
+	if err := ctrl.service.Delete(id, claims); err != nil {
 		statusCode := http.StatusInternalServerError
 		if err.Error() == "cancha not found" || err.Error() == "invalid ID format" {
 			statusCode = http.StatusNotFound
 		}
+		if err.Error() == "forbidden" {
+			statusCode = http.StatusForbidden
+		}
 
 		c.JSON(statusCode, dto.ErrorResponse{
 			Error:   "Failed to delete cancha",
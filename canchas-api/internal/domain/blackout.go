@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Blackout es un cierre programado (feriado, mantenimiento, torneo) en el que no se puede reservar.
+// Las horas son de reloj, igual que las de las reservas: se guardan en UTC sin convertir zona horaria.
+type Blackout struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	CanchaID  string             `bson:"cancha_id" json:"cancha_id"` // vacío = cierra todo el predio
+	Start     time.Time          `bson:"start" json:"start"`
+	End       time.Time          `bson:"end" json:"end"`
+	Reason    string             `bson:"reason" json:"reason"`
+	CreatedBy uint               `bson:"created_by" json:"created_by"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// IsVenueWide indica si el cierre aplica a todas las canchas
+func (b Blackout) IsVenueWide() bool {
+	return b.CanchaID == ""
+}
+
+// CollectionName retorna el nombre de la colección en MongoDB
+func (Blackout) CollectionName() string {
+	return "blackouts"
+}
@@ -1,6 +1,8 @@
 package domain
 
 import (
+	"canchas-api/internal/pricing"
+	"shared/schedule"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -12,13 +14,31 @@ type Cancha struct {
 	Type        string             `bson:"type" json:"type"` // "futbol", "tenis", "basquet", "paddle", "voley"
 	Description string             `bson:"description" json:"description"`
 	Number      int                `bson:"number" json:"number"`
-	Price       float64            `bson:"price" json:"price"`
+	Price       float64            `bson:"price" json:"price"` // Precio base de un turno, sin recargos
 	Capacity    int                `bson:"capacity" json:"capacity"`
 	Available   bool               `bson:"available" json:"available"`
 	ImageURL    string             `bson:"image_url" json:"image_url"`
-	// ❌ ELIMINAR: OwnerID     uint               `bson:"owner_id" json:"owner_id"`
-	CreatedAt time.Time `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+	ManagerID   uint               `bson:"manager_id,omitempty" json:"manager_id,omitempty"` // Usuario venue_manager que la administra (0 = sin encargado)
+	Schedule    *schedule.Schedule `bson:"schedule,omitempty" json:"schedule,omitempty"`     // Horario semanal (nil = horario por defecto de su tipo)
+	Pricing     *pricing.Rules     `bson:"pricing,omitempty" json:"pricing,omitempty"`       // Política de precios (nil = solo los recargos por defecto; las altas la guardan siempre)
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// EffectiveSchedule retorna el horario configurado o, si no tiene, el horario por defecto de su tipo
+func (c Cancha) EffectiveSchedule() schedule.Schedule {
+	if c.Schedule != nil {
+		return *c.Schedule
+	}
+	return schedule.Default(c.Type)
+}
+
+// EffectivePricing retorna la política de precios configurada o, si no tiene, los recargos por defecto
+func (c Cancha) EffectivePricing() pricing.Rules {
+	if c.Pricing != nil {
+		return *c.Pricing
+	}
+	return pricing.Default(c.Capacity)
 }
 
 // CollectionName retorna el nombre de la colección en MongoDB
@@ -0,0 +1,30 @@
+package dto
+
+// Estados de un turno en la grilla de disponibilidad
+const (
+	SlotFree   = "free"   // se puede reservar
+	SlotHeld   = "held"   // retenido por otro usuario mientras paga o confirma
+	SlotBooked = "booked" // reservado
+)
+
+// AvailabilitySlot - turno reservable de la grilla de disponibilidad
+type AvailabilitySlot struct {
+	StartTime string `json:"start_time"` // Formato: "18:00"
+	EndTime   string `json:"end_time"`
+	Status    string `json:"status"` // "free", "held" o "booked"
+}
+
+// AvailabilityDay - turnos de una fecha de reserva (los de después de medianoche pertenecen a la fecha anterior)
+type AvailabilityDay struct {
+	Date  string             `json:"date"` // Formato: "2025-11-15"
+	Slots []AvailabilitySlot `json:"slots"`
+}
+
+// AvailabilityResponse - DTO con la grilla de disponibilidad de una cancha entre dos fechas
+type AvailabilityResponse struct {
+	CanchaID    string            `json:"cancha_id"`
+	From        string            `json:"from"`
+	To          string            `json:"to"`
+	SlotMinutes int               `json:"slot_minutes"`
+	Days        []AvailabilityDay `json:"days"`
+}
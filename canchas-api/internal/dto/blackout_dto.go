@@ -0,0 +1,53 @@
+package dto
+
+import (
+	"time"
+)
+
+// BlackoutTimeLayout es el formato de las horas de un cierre: "2025-12-24T18:00"
+const BlackoutTimeLayout = "2006-01-02T15:04"
+
+// CreateBlackoutRequest - DTO para crear un cierre (requiere canchas:manage o ser encargado de la cancha)
+type CreateBlackoutRequest struct {
+	CanchaID        string `json:"cancha_id"` // vacío = todo el predio (solo canchas:manage)
+	Start           string `json:"start" binding:"required"`
+	End             string `json:"end" binding:"required"`
+	Reason          string `json:"reason" binding:"required,min=3"`
+	CancelConflicts bool   `json:"cancel_conflicts"` // cancelar las reservas que se superponen
+}
+
+// BlackoutResponse - DTO para respuesta de cierre
+type BlackoutResponse struct {
+	ID        string    `json:"id"`
+	CanchaID  string    `json:"cancha_id,omitempty"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Reason    string    `json:"reason"`
+	CreatedBy uint      `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BlackoutsListResponse - DTO para lista de cierres
+type BlackoutsListResponse struct {
+	Blackouts []BlackoutResponse `json:"blackouts"`
+	Total     int64              `json:"total"`
+}
+
+// ConflictingReserva - reserva que se superpone con un cierre
+type ConflictingReserva struct {
+	ID        string `json:"id"`
+	CanchaID  string `json:"cancha_id"`
+	UserID    uint   `json:"user_id"`
+	Date      string `json:"date"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	Status    string `json:"status"`
+}
+
+// CreateBlackoutResponse - DTO con el cierre creado y las reservas con las que choca
+type CreateBlackoutResponse struct {
+	Blackout       BlackoutResponse     `json:"blackout"`
+	Conflicts      []ConflictingReserva `json:"conflicts"`
+	Cancelled      int                  `json:"cancelled"`
+	ConflictsError string               `json:"conflicts_error,omitempty"` // reservas-api no respondió: el cierre igual quedó creado
+}
@@ -1,47 +1,55 @@
 package dto
 
 import (
+	"canchas-api/internal/pricing"
+	"shared/schedule"
 	"time"
 )
 
-// CreateCanchaRequest - DTO para crear una cancha (SOLO ADMIN)
+// CreateCanchaRequest - DTO para crear una cancha (requiere canchas:manage o canchas:manage_own)
 type CreateCanchaRequest struct {
-	Name        string  `json:"name" binding:"required,min=3"`
-	Type        string  `json:"type" binding:"required,oneof=futbol tenis basquet paddle voley"`
-	Description string  `json:"description" binding:"required"`
-	Number      int     `json:"number" binding:"required,gt=0"`
-	Price       float64 `json:"price" binding:"required,gt=0"`
-	Capacity    int     `json:"capacity" binding:"required,gt=0"`
-	Available   bool    `json:"available"`
-	ImageURL    string  `json:"image_url"`
+	Name        string             `json:"name" binding:"required,min=3"`
+	Type        string             `json:"type" binding:"required,oneof=futbol tenis basquet paddle voley"`
+	Description string             `json:"description" binding:"required"`
+	Number      int                `json:"number" binding:"required,gt=0"`
+	Price       float64            `json:"price" binding:"required,gt=0"`
+	Capacity    int                `json:"capacity" binding:"required,gt=0"`
+	Available   bool               `json:"available"`
+	ImageURL    string             `json:"image_url"`
+	Schedule    *schedule.Schedule `json:"schedule"` // opcional: sin horario se usa el de su tipo
+	Pricing     *pricing.Rules     `json:"pricing"`  // opcional: sin política solo se aplican los recargos por defecto
 }
 
-// UpdateCanchaRequest - DTO para actualizar una cancha (SOLO ADMIN)
+// UpdateCanchaRequest - DTO para actualizar una cancha (requiere canchas:manage o ser su encargado)
 type UpdateCanchaRequest struct {
-	Name        string  `json:"name" binding:"omitempty,min=3"`
-	Type        string  `json:"type" binding:"omitempty,oneof=futbol tenis basquet paddle voley"`
-	Description string  `json:"description"`
-	Number      int     `json:"number"`
-	Price       float64 `json:"price" binding:"omitempty,gt=0"`
-	Capacity    int     `json:"capacity" binding:"omitempty,gt=0"`
-	Available   *bool   `json:"available"` // Pointer para permitir false
-	ImageURL    string  `json:"image_url"`
+	Name        string             `json:"name" binding:"omitempty,min=3"`
+	Type        string             `json:"type" binding:"omitempty,oneof=futbol tenis basquet paddle voley"`
+	Description string             `json:"description"`
+	Number      int                `json:"number"`
+	Price       float64            `json:"price" binding:"omitempty,gt=0"`
+	Capacity    int                `json:"capacity" binding:"omitempty,gt=0"`
+	Available   *bool              `json:"available"` // Pointer para permitir false
+	ImageURL    string             `json:"image_url"`
+	Schedule    *schedule.Schedule `json:"schedule"`
+	Pricing     *pricing.Rules     `json:"pricing"`
 }
 
 // CanchaResponse - DTO para respuesta de cancha
 type CanchaResponse struct {
-	ID          string  `json:"id"`
-	Name        string  `json:"name"`
-	Type        string  `json:"type"`
-	Description string  `json:"description"`
-	Number      int     `json:"number"`
-	Price       float64 `json:"price"`
-	Capacity    int     `json:"capacity"`
-	Available   bool    `json:"available"`
-	ImageURL    string  `json:"image_url"`
-	// ❌ ELIMINAR: OwnerID     uint      `json:"owner_id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Type        string            `json:"type"`
+	Description string            `json:"description"`
+	Number      int               `json:"number"`
+	Price       float64           `json:"price"`
+	Capacity    int               `json:"capacity"`
+	Available   bool              `json:"available"`
+	ImageURL    string            `json:"image_url"`
+	ManagerID   uint              `json:"manager_id,omitempty"`
+	Schedule    schedule.Schedule `json:"schedule"`
+	Pricing     pricing.Rules     `json:"pricing"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
 }
 
 // CanchasListResponse - DTO para lista de canchas
@@ -49,3 +57,15 @@ type CanchasListResponse struct {
 	Canchas []CanchaResponse `json:"canchas"`
 	Total   int64            `json:"total"`
 }
+
+// QuoteResponse - DTO con el precio detallado de un turno
+type QuoteResponse struct {
+	CanchaID  string         `json:"cancha_id"`
+	Date      string         `json:"date"`
+	StartTime string         `json:"start_time"`
+	EndTime   string         `json:"end_time"`
+	Duration  int            `json:"duration"`
+	Items     []pricing.Item `json:"items"`
+	Total     float64        `json:"total"`
+	Member    bool           `json:"member"`
+}
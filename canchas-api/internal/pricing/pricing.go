@@ -0,0 +1,248 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"shared/schedule"
+	"time"
+)
+
+// Tipos de ítem de una cotización, en el orden en que se aplican
+const (
+	ItemBase      = "base"      // precio del turno según su duración
+	ItemRule      = "rule"      // ajuste por día u horario (hora pico, fin de semana)
+	ItemDiscount  = "discount"  // descuento de socio
+	ItemSurcharge = "surcharge" // recargos fijos (impuesto, mantenimiento)
+)
+
+const (
+	taxPercent        = 5
+	maintenanceFee    = 8.0
+	maintenanceFeeBig = 12.0 // canchas de más de 15 jugadores
+)
+
+// DurationPrice fija el precio de un turno de una duración concreta
+type DurationPrice struct {
+	Minutes int     `bson:"minutes" json:"minutes"`
+	Price   float64 `bson:"price" json:"price"`
+}
+
+// Rule ajusta el precio base en ciertos días y horarios. Aplican todas las reglas que coincidan.
+// From y To se comparan con el inicio del turno; un rango que cruza medianoche (20:00 a 02:00) es válido.
+type Rule struct {
+	Name     string         `bson:"name" json:"name"`
+	Weekdays []time.Weekday `bson:"weekdays,omitempty" json:"weekdays,omitempty"` // vacío = todos los días
+	From     string         `bson:"from,omitempty" json:"from,omitempty"`         // HH:MM, vacío = todo el día
+	To       string         `bson:"to,omitempty" json:"to,omitempty"`             // HH:MM exclusivo
+	Percent  float64        `bson:"percent" json:"percent"`                       // +20 = 20% más caro, -10 = 10% más barato
+	Amount   float64        `bson:"amount" json:"amount"`                         // ajuste fijo
+}
+
+// Surcharge es un recargo que se suma al final (sobre el precio ya ajustado y descontado)
+type Surcharge struct {
+	Name    string  `bson:"name" json:"name"`
+	Percent float64 `bson:"percent" json:"percent"`
+	Amount  float64 `bson:"amount" json:"amount"`
+}
+
+// Rules es la política de precios de una cancha
+type Rules struct {
+	DurationPrices        []DurationPrice `bson:"duration_prices,omitempty" json:"duration_prices,omitempty"`
+	Rules                 []Rule          `bson:"rules,omitempty" json:"rules,omitempty"`
+	Surcharges            []Surcharge     `bson:"surcharges,omitempty" json:"surcharges,omitempty"`
+	MemberDiscountPercent float64         `bson:"member_discount_percent" json:"member_discount_percent"`
+}
+
+// Item es una línea de la cotización
+type Item struct {
+	Kind   string  `json:"kind"`
+	Name   string  `json:"name"`
+	Amount float64 `json:"amount"`
+}
+
+// Quote es el precio detallado de un turno
+type Quote struct {
+	Items  []Item  `json:"items"`
+	Total  float64 `json:"total"`
+	Member bool    `json:"member"`
+}
+
+// Default retorna los recargos que antes se sumaban al precio de la cancha en cada alta o edición:
+// 5% de impuesto y un fijo de mantenimiento que depende de la capacidad
+func Default(capacity int) Rules {
+	return Rules{
+		Surcharges: []Surcharge{
+			{Name: "Impuesto", Percent: taxPercent},
+			{Name: "Mantenimiento", Amount: maintenance(capacity)},
+		},
+	}
+}
+
+// Legacy recupera el precio base de una cancha guardada antes de que existiera la política de precios,
+// cuando el impuesto y el mantenimiento ya venían sumados en su precio, y retorna la política por defecto
+// que los vuelve a sumar al cotizar. Si el precio no alcanza a cubrir los recargos se deja como está, sin recargos.
+func Legacy(price float64, capacity int) (float64, Rules) {
+	base := roundCents((price - maintenance(capacity)) / (1 + taxPercent/100.0))
+	if base <= 0 {
+		return price, Rules{}
+	}
+	return base, Default(capacity)
+}
+
+// maintenance retorna el recargo fijo de mantenimiento según la capacidad de la cancha
+func maintenance(capacity int) float64 {
+	if capacity > 15 {
+		return maintenanceFeeBig
+	}
+	return maintenanceFee
+}
+
+// Validate verifica que la política sea coherente
+func (r Rules) Validate() error {
+	seen := map[int]bool{}
+	for _, dp := range r.DurationPrices {
+		if dp.Minutes <= 0 || dp.Price <= 0 {
+			return errors.New("duration_prices need positive minutes and price")
+		}
+		if seen[dp.Minutes] {
+			return fmt.Errorf("duration %d appears more than once", dp.Minutes)
+		}
+		seen[dp.Minutes] = true
+	}
+
+	for _, rule := range r.Rules {
+		if rule.Name == "" {
+			return errors.New("every rule needs a name")
+		}
+		for _, wd := range rule.Weekdays {
+			if wd < time.Sunday || wd > time.Saturday {
+				return fmt.Errorf("%s: invalid weekday: %d", rule.Name, wd)
+			}
+		}
+		if (rule.From == "") != (rule.To == "") {
+			return fmt.Errorf("%s: from and to go together", rule.Name)
+		}
+		if rule.From != "" {
+			if _, _, err := rule.window(); err != nil {
+				return fmt.Errorf("%s: %v", rule.Name, err)
+			}
+		}
+		if rule.Percent <= -100 {
+			return fmt.Errorf("%s: percent must be greater than -100", rule.Name)
+		}
+	}
+
+	for _, s := range r.Surcharges {
+		if s.Name == "" {
+			return errors.New("every surcharge needs a name")
+		}
+		if s.Percent < 0 || s.Amount < 0 {
+			return fmt.Errorf("%s: surcharges cannot be negative", s.Name)
+		}
+	}
+
+	if r.MemberDiscountPercent < 0 || r.MemberDiscountPercent > 100 {
+		return errors.New("member_discount_percent must be between 0 and 100")
+	}
+	return nil
+}
+
+// Quote cotiza un turno de duration minutos que empieza a startTime en la fecha date.
+// basePrice es el precio de un turno de slotMinutes; otras duraciones se prorratean salvo que tengan precio propio.
+// Orden: base, reglas de día/horario, descuento de socio y al final los recargos. El total nunca es negativo.
+func (r Rules) Quote(basePrice float64, slotMinutes int, date time.Time, startTime string, duration int, member bool) (Quote, error) {
+	start, err := schedule.Minutes(startTime)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	base := basePrice
+	if slotMinutes > 0 && duration != slotMinutes {
+		base = basePrice * float64(duration) / float64(slotMinutes)
+	}
+	for _, dp := range r.DurationPrices {
+		if dp.Minutes == duration {
+			base = dp.Price
+		}
+	}
+
+	quote := Quote{Member: member}
+	quote.add(ItemBase, fmt.Sprintf("Turno de %d minutos", duration), base)
+	subtotal := base
+
+	for _, rule := range r.Rules {
+		if !rule.matches(date.Weekday(), start) {
+			continue
+		}
+		adjustment := base*rule.Percent/100 + rule.Amount
+		subtotal += quote.add(ItemRule, rule.Name, adjustment)
+	}
+	if subtotal < 0 {
+		subtotal = 0
+	}
+
+	if member && r.MemberDiscountPercent > 0 {
+		subtotal += quote.add(ItemDiscount, "Descuento socio", -subtotal*r.MemberDiscountPercent/100)
+	}
+
+	total := subtotal
+	for _, s := range r.Surcharges {
+		total += quote.add(ItemSurcharge, s.Name, subtotal*s.Percent/100+s.Amount)
+	}
+
+	quote.Total = roundCents(math.Max(total, 0))
+	return quote, nil
+}
+
+// add agrega una línea redondeada a centavos y retorna el monto agregado
+func (q *Quote) add(kind, name string, amount float64) float64 {
+	amount = roundCents(amount)
+	q.Items = append(q.Items, Item{Kind: kind, Name: name, Amount: amount})
+	return amount
+}
+
+// matches indica si la regla aplica a un turno que empieza en start (minutos desde la medianoche de la fecha)
+func (rule Rule) matches(weekday time.Weekday, start int) bool {
+	if len(rule.Weekdays) > 0 {
+		found := false
+		for _, wd := range rule.Weekdays {
+			if wd == weekday {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if rule.From == "" {
+		return true
+	}
+
+	from, to, err := rule.window()
+	if err != nil {
+		return false
+	}
+	return start >= from && start < to
+}
+
+// window retorna el rango horario de la regla en minutos desde la medianoche de la fecha de reserva
+func (rule Rule) window() (int, int, error) {
+	from, err := schedule.Minutes(rule.From)
+	if err != nil {
+		return 0, 0, err
+	}
+	to, err := schedule.Minutes(rule.To)
+	if err != nil {
+		return 0, 0, err
+	}
+	if to <= from {
+		return 0, 0, errors.New("to must be after from")
+	}
+	return from, to, nil
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
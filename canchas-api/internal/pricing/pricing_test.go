@@ -0,0 +1,81 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+)
+
+// monday es un lunes cualquiera
+var monday = time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+func TestQuoteAppliesRulesDiscountAndSurcharges(t *testing.T) {
+	rules := Rules{
+		Rules: []Rule{
+			{Name: "Hora pico", From: "19:00", To: "01:00", Percent: 20},
+			{Name: "Fin de semana", Weekdays: []time.Weekday{time.Saturday, time.Sunday}, Amount: 5},
+		},
+		Surcharges:            []Surcharge{{Name: "Impuesto", Percent: 10}},
+		MemberDiscountPercent: 50,
+	}
+	if err := rules.Validate(); err != nil {
+		t.Fatalf("política válida rechazada: %v", err)
+	}
+
+	// Lunes 10:00: sin reglas, solo el impuesto
+	quote, err := rules.Quote(100, 60, monday, "10:00", 60, false)
+	if err != nil || quote.Total != 110 || len(quote.Items) != 2 {
+		t.Fatalf("se esperaba 100 + 10%% = 110, llegó %+v, error: %v", quote, err)
+	}
+
+	// Sábado a las 00:30: hora pico (cruza medianoche) y fin de semana, con descuento de socio
+	saturday := monday.AddDate(0, 0, 5)
+	quote, err = rules.Quote(100, 60, saturday, "00:30", 60, true)
+	if err != nil {
+		t.Fatalf("se esperaba sin error, llegó %v", err)
+	}
+	// (100 + 20 + 5) * 50% = 62.5; + 10% = 68.75
+	if quote.Total != 68.75 || !quote.Member {
+		t.Fatalf("se esperaba 68.75, llegó %+v", quote)
+	}
+	kinds := []string{ItemBase, ItemRule, ItemRule, ItemDiscount, ItemSurcharge}
+	for i, kind := range kinds {
+		if quote.Items[i].Kind != kind {
+			t.Fatalf("ítem %d: se esperaba %s, llegó %+v", i, kind, quote.Items)
+		}
+	}
+}
+
+func TestQuoteUsesDurationPrices(t *testing.T) {
+	rules := Rules{DurationPrices: []DurationPrice{{Minutes: 120, Price: 150}}}
+
+	// 90 minutos con un precio base por turno de 60: se prorratea
+	if quote, _ := rules.Quote(100, 60, monday, "10:00", 90, false); quote.Total != 150 {
+		t.Fatalf("se esperaba 150 prorrateado, llegó %+v", quote)
+	}
+	// 120 minutos tiene precio propio
+	if quote, _ := rules.Quote(100, 60, monday, "10:00", 120, false); quote.Total != 150 {
+		t.Fatalf("se esperaba el precio propio de 150, llegó %+v", quote)
+	}
+
+	// Los recargos por defecto reproducen el precio de antes: 10 + 5% + 8
+	if quote, _ := Default(5).Quote(10, 60, monday, "10:00", 60, false); quote.Total != 18.5 {
+		t.Fatalf("se esperaba 18.5 con los recargos por defecto, llegó %+v", quote)
+	}
+}
+
+func TestValidateRejectsInconsistentRules(t *testing.T) {
+	invalid := []Rules{
+		{DurationPrices: []DurationPrice{{Minutes: 60, Price: 10}, {Minutes: 60, Price: 12}}},
+		{Rules: []Rule{{Name: "Sin fin", From: "19:00"}}},
+		{Rules: []Rule{{Name: "Al revés", From: "20:00", To: "19:00"}}},
+		{Rules: []Rule{{Name: "Gratis", Percent: -100}}},
+		{Rules: []Rule{{Percent: 10}}},
+		{Surcharges: []Surcharge{{Name: "Negativo", Amount: -1}}},
+		{MemberDiscountPercent: 120},
+	}
+	for i, r := range invalid {
+		if err := r.Validate(); err == nil {
+			t.Fatalf("caso %d: se esperaba error para %+v", i, r)
+		}
+	}
+}
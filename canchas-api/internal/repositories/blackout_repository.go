@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"canchas-api/internal/domain"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type BlackoutRepository interface {
+	Create(blackout *domain.Blackout) error
+	GetByID(id string) (*domain.Blackout, error)
+	Find(canchaID string, from, to time.Time) ([]domain.Blackout, error)
+	Delete(id string) error
+}
+
+type blackoutRepository struct {
+	collection *mongo.Collection
+}
+
+func NewBlackoutRepository(db *mongo.Database) BlackoutRepository {
+	coll := db.Collection(domain.Blackout{}.CollectionName())
+
+	// Índice para buscar los cierres de una cancha por rango
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	indexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "cancha_id", Value: 1},
+			{Key: "start", Value: 1},
+		},
+	}
+	if _, err := coll.Indexes().CreateOne(ctx, indexModel); err != nil {
+		log.Printf("Warning: failed to create index on blackout.cancha_id+start: %v", err)
+	}
+
+	return &blackoutRepository{collection: coll}
+}
+
+func (r *blackoutRepository) Create(blackout *domain.Blackout) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	blackout.ID = primitive.NewObjectID()
+	blackout.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, blackout)
+	return err
+}
+
+func (r *blackoutRepository) GetByID(id string) (*domain.Blackout, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid ID format")
+	}
+
+	var blackout domain.Blackout
+	err = r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&blackout)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("blackout not found")
+		}
+		return nil, err
+	}
+
+	return &blackout, nil
+}
+
+// Find devuelve los cierres que se superponen con [from, to), ordenados por inicio.
+// Con canchaID incluye los de esa cancha y los de todo el predio; vacío devuelve todos.
+// Un from o to en cero no limita ese extremo.
+func (r *blackoutRepository) Find(canchaID string, from, to time.Time) ([]domain.Blackout, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if canchaID != "" {
+		filter["cancha_id"] = bson.M{"$in": []string{canchaID, ""}}
+	}
+	if !from.IsZero() {
+		filter["end"] = bson.M{"$gt": from}
+	}
+	if !to.IsZero() {
+		filter["start"] = bson.M{"$lt": to}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "start", Value: 1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	blackouts := []domain.Blackout{}
+	if err := cursor.All(ctx, &blackouts); err != nil {
+		return nil, err
+	}
+
+	return blackouts, nil
+}
+
+func (r *blackoutRepository) Delete(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid ID format")
+	}
+
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objectID})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return errors.New("blackout not found")
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"canchas-api/internal/domain"
+	"canchas-api/internal/pricing"
 	"context"
 	"errors"
 	"log"
@@ -22,6 +23,7 @@ type CanchaRepository interface {
 	GetByName(name string) (*domain.Cancha, error)
 	Update(id string, cancha *domain.Cancha) error
 	Delete(id string) error
+	BackfillLegacyPricing() error
 	// ❌ ELIMINAR: GetByOwnerID(ownerID uint) ([]domain.Cancha, error)
 }
 
@@ -152,6 +154,8 @@ func (r *canchaRepository) Update(id string, cancha *domain.Cancha) error {
 			"capacity":    cancha.Capacity,
 			"available":   cancha.Available,
 			"image_url":   cancha.ImageURL,
+			"schedule":    cancha.Schedule,
+			"pricing":     cancha.Pricing,
 			"updated_at":  cancha.UpdatedAt,
 		},
 	}
@@ -226,3 +230,34 @@ func (r *canchaRepository) GetByName(name string) (*domain.Cancha, error) {
 }
 
 // ❌ ELIMINAR método GetByOwnerID
+
+// BackfillLegacyPricing pasa las canchas guardadas sin política de precios (anteriores a que existiera)
+// a su precio base con los recargos por defecto, que antes se sumaban al precio guardado.
+// Las altas nuevas siempre guardan su política, así que correrlo en cada arranque no repite el ajuste.
+func (r *canchaRepository) BackfillLegacyPricing() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	// pricing: null coincide tanto con el campo ausente como con null
+	legacy := bson.M{"pricing": nil}
+	cursor, err := r.collection.Find(ctx, legacy)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var canchas []domain.Cancha
+	if err := cursor.All(ctx, &canchas); err != nil {
+		return err
+	}
+
+	for _, cancha := range canchas {
+		base, rules := pricing.Legacy(cancha.Price, cancha.Capacity)
+		filter := bson.M{"_id": cancha.ID, "pricing": nil}
+		update := bson.M{"$set": bson.M{"price": base, "pricing": rules}}
+		if _, err := r.collection.UpdateOne(ctx, filter, update); err != nil {
+			log.Printf("Warning: failed to backfill pricing of cancha %s: %v", cancha.ID.Hex(), err)
+		}
+	}
+	return nil
+}
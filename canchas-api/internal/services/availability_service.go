@@ -0,0 +1,189 @@
+package services
+
+import (
+	"canchas-api/internal/clients"
+	"canchas-api/internal/domain"
+	"canchas-api/internal/dto"
+	"canchas-api/internal/repositories"
+	"errors"
+	"fmt"
+	"shared/schedule"
+	"time"
+)
+
+// MaxAvailabilityDays limita el rango de fechas de una consulta de disponibilidad
+const MaxAvailabilityDays = 31
+
+// ErrInvalidAvailability indica que el rango de fechas pedido no es válido
+var ErrInvalidAvailability = errors.New("invalid availability request")
+
+type AvailabilityService interface {
+	Get(canchaID, from, to string) (*dto.AvailabilityResponse, error)
+}
+
+type availabilityService struct {
+	canchaRepo    repositories.CanchaRepository
+	blackoutRepo  repositories.BlackoutRepository
+	reservaClient clients.ReservaClient
+}
+
+// NewAvailabilityService crea una nueva instancia del servicio de disponibilidad
+func NewAvailabilityService(
+	canchaRepo repositories.CanchaRepository,
+	blackoutRepo repositories.BlackoutRepository,
+	reservaClient clients.ReservaClient,
+) AvailabilityService {
+	return &availabilityService{
+		canchaRepo:    canchaRepo,
+		blackoutRepo:  blackoutRepo,
+		reservaClient: reservaClient,
+	}
+}
+
+// busyInterval es el lapso que ocupa una reserva activa
+type busyInterval struct {
+	start, end time.Time
+	held       bool
+}
+
+// Get arma la grilla de turnos reservables de la cancha entre from y to (inclusive, to vacío = from).
+// Los turnos salen del horario de la cancha; se omiten los que ya empezaron y los que caen en un cierre,
+// y el resto se marca libre, retenido o reservado según las reservas activas de reservas-api.
+func (s *availabilityService) Get(canchaID, from, to string) (*dto.AvailabilityResponse, error) {
+	fromDate, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return nil, fmt.Errorf("%w: from must have format YYYY-MM-DD", ErrInvalidAvailability)
+	}
+	toDate := fromDate
+	if to != "" {
+		if toDate, err = time.Parse("2006-01-02", to); err != nil {
+			return nil, fmt.Errorf("%w: to must have format YYYY-MM-DD", ErrInvalidAvailability)
+		}
+	}
+	if toDate.Before(fromDate) {
+		return nil, fmt.Errorf("%w: to must not be before from", ErrInvalidAvailability)
+	}
+	if days := int(toDate.Sub(fromDate).Hours()/24) + 1; days > MaxAvailabilityDays {
+		return nil, fmt.Errorf("%w: at most %d days can be requested", ErrInvalidAvailability, MaxAvailabilityDays)
+	}
+
+	cancha, err := s.canchaRepo.GetByID(canchaID)
+	if err != nil {
+		return nil, err
+	}
+
+	// El día de reservas termina en el corte del día siguiente: los turnos de madrugada son del día anterior
+	rangeStart := fromDate.Add(schedule.DayStartMinutes * time.Minute)
+	rangeEnd := toDate.AddDate(0, 0, 1).Add(schedule.DayStartMinutes * time.Minute)
+
+	blackouts, err := s.blackoutRepo.Find(canchaID, rangeStart, rangeEnd)
+	if err != nil {
+		return nil, err
+	}
+	busy, err := s.busyIntervals(canchaID, rangeStart, rangeEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	sched := cancha.EffectiveSchedule()
+	now := time.Now()
+	resp := &dto.AvailabilityResponse{
+		CanchaID:    canchaID,
+		From:        fromDate.Format("2006-01-02"),
+		To:          toDate.Format("2006-01-02"),
+		SlotMinutes: sched.SlotMinutes,
+		Days:        []dto.AvailabilityDay{},
+	}
+
+	for date := fromDate; !date.After(toDate); date = date.AddDate(0, 0, 1) {
+		day := dto.AvailabilityDay{Date: date.Format("2006-01-02"), Slots: []dto.AvailabilitySlot{}}
+		for _, slot := range sched.Slots(date) {
+			start, end, err := slotInterval(date, slot.Start, slot.Duration)
+			if err != nil {
+				return nil, err
+			}
+			if !start.After(now) || inBlackout(blackouts, start, end) {
+				continue
+			}
+			day.Slots = append(day.Slots, dto.AvailabilitySlot{
+				StartTime: slot.Start,
+				EndTime:   slot.End,
+				Status:    slotStatus(busy, start, end),
+			})
+		}
+		resp.Days = append(resp.Days, day)
+	}
+
+	return resp, nil
+}
+
+// busyIntervals obtiene de reservas-api los lapsos ocupados por reservas activas de la cancha
+// (reutiliza la consulta de conflictos de los cierres, sin cancelar nada)
+func (s *availabilityService) busyIntervals(canchaID string, from, to time.Time) ([]busyInterval, error) {
+	conflicts, err := s.reservaClient.FindConflicts(clients.ConflictsRequest{CanchaIDs: []string{canchaID}, Start: from, End: to})
+	if err != nil {
+		return nil, fmt.Errorf("error checking reservas: %w", err)
+	}
+
+	busy := make([]busyInterval, 0, len(conflicts.Reservas))
+	for _, reserva := range conflicts.Reservas {
+		date, err := time.Parse("2006-01-02", reserva.Date)
+		if err != nil {
+			continue
+		}
+		startMinutes, err := schedule.Minutes(reserva.StartTime)
+		if err != nil {
+			continue
+		}
+		endMinutes, err := schedule.Minutes(reserva.EndTime)
+		if err != nil {
+			continue
+		}
+		if endMinutes <= startMinutes {
+			// Termina justo en el corte del día siguiente
+			endMinutes += schedule.MinutesPerDay
+		}
+
+		start, end, err := slotInterval(date, reserva.StartTime, endMinutes-startMinutes)
+		if err != nil {
+			continue
+		}
+		busy = append(busy, busyInterval{start: start, end: end, held: reserva.Status == "pending"})
+	}
+	return busy, nil
+}
+
+// slotInterval retorna el inicio y fin absolutos de un turno en la fecha de reserva date
+func slotInterval(date time.Time, startTime string, duration int) (time.Time, time.Time, error) {
+	startMinutes, err := schedule.Minutes(startTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	start := date.Add(time.Duration(startMinutes) * time.Minute)
+	return start, start.Add(time.Duration(duration) * time.Minute), nil
+}
+
+// inBlackout indica si el turno se superpone con algún cierre
+func inBlackout(blackouts []domain.Blackout, start, end time.Time) bool {
+	for _, blackout := range blackouts {
+		if start.Before(blackout.End) && blackout.Start.Before(end) {
+			return true
+		}
+	}
+	return false
+}
+
+// slotStatus marca el turno como reservado si lo pisa una reserva confirmada, retenido si solo lo pisan turnos retenidos
+func slotStatus(busy []busyInterval, start, end time.Time) string {
+	status := dto.SlotFree
+	for _, interval := range busy {
+		if !start.Before(interval.end) || !interval.start.Before(end) {
+			continue
+		}
+		if !interval.held {
+			return dto.SlotBooked
+		}
+		status = dto.SlotHeld
+	}
+	return status
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"canchas-api/internal/clients"
+	"canchas-api/internal/domain"
+	"canchas-api/internal/dto"
+	"shared/schedule"
+)
+
+func TestAvailabilityMarksSlotsAndSkipsBlackouts(t *testing.T) {
+	canchaRepo := newMockRepo()
+	blackoutRepo := newMockBlackoutRepo()
+	reservaCli := &mockReservaClient{}
+
+	cancha, err := NewCanchaService(canchaRepo, &mockPublisher{}, reservaCli).Create(&dto.CreateCanchaRequest{
+		Name: "Central", Type: "futbol", Number: 1, Price: 10, Capacity: 10,
+		Schedule: &schedule.Schedule{
+			Days:        []schedule.Day{{Weekday: time.Tuesday, Open: "18:00", Close: "00:00"}, {Weekday: time.Wednesday, Open: "18:00", Close: "00:00"}},
+			SlotMinutes: 60,
+		},
+	}, adminClaims)
+	if err != nil {
+		t.Fatalf("no se pudo crear la cancha: %v", err)
+	}
+
+	tuesday := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 7)
+	for tuesday.Weekday() != time.Tuesday {
+		tuesday = tuesday.AddDate(0, 0, 1)
+	}
+	wednesday := tuesday.AddDate(0, 0, 1)
+	date := tuesday.Format("2006-01-02")
+
+	// Martes: 19:00 reservado, 20:00 retenido, 22:00-23:30 cerrado por mantenimiento
+	reservaCli.conflicts = &clients.ConflictsResponse{Reservas: []clients.ConflictingReserva{
+		{ID: "r1", CanchaID: cancha.ID, Date: date, StartTime: "19:00", EndTime: "20:00", Status: "confirmed"},
+		{ID: "r2", CanchaID: cancha.ID, Date: date, StartTime: "20:00", EndTime: "21:00", Status: "pending"},
+	}}
+	_ = blackoutRepo.Create(&domain.Blackout{
+		CanchaID: cancha.ID,
+		Start:    tuesday.Add(22 * time.Hour),
+		End:      tuesday.Add(23*time.Hour + 30*time.Minute),
+		Reason:   "Mantenimiento",
+	})
+
+	svc := NewAvailabilityService(canchaRepo, blackoutRepo, reservaCli)
+	resp, err := svc.Get(cancha.ID, date, wednesday.Format("2006-01-02"))
+	if err != nil {
+		t.Fatalf("se esperaba la grilla, llegó %v", err)
+	}
+	if len(resp.Days) != 2 || resp.SlotMinutes != 60 {
+		t.Fatalf("se esperaban dos días con turnos de 60 minutos, llegó %+v", resp)
+	}
+
+	want := map[string]string{"18:00": dto.SlotFree, "19:00": dto.SlotBooked, "20:00": dto.SlotHeld, "21:00": dto.SlotFree}
+	slots := resp.Days[0].Slots
+	if len(slots) != len(want) {
+		t.Fatalf("el martes debe tener %d turnos (22:00 y 23:00 caen en el cierre), llegó %+v", len(want), slots)
+	}
+	for _, slot := range slots {
+		if want[slot.StartTime] != slot.Status {
+			t.Fatalf("turno %s: se esperaba %q, llegó %q", slot.StartTime, want[slot.StartTime], slot.Status)
+		}
+	}
+	if len(resp.Days[1].Slots) != 6 || resp.Days[1].Slots[5].EndTime != "00:00" {
+		t.Fatalf("el miércoles debe tener sus 6 turnos libres, llegó %+v", resp.Days[1].Slots)
+	}
+
+	sent := reservaCli.conflictReqs[0]
+	if sent.Cancel || len(sent.CanchaIDs) != 1 || sent.CanchaIDs[0] != cancha.ID || !sent.End.Equal(wednesday.AddDate(0, 0, 1).Add(6*time.Hour)) {
+		t.Fatalf("la consulta a reservas-api debe cubrir hasta el corte del día siguiente sin cancelar, llegó %+v", sent)
+	}
+}
+
+func TestAvailabilityValidatesRange(t *testing.T) {
+	svc := NewAvailabilityService(newMockRepo(), newMockBlackoutRepo(), &mockReservaClient{})
+
+	cases := [][2]string{
+		{"", ""},
+		{"2026-13-01", ""},
+		{"2026-11-10", "2026-11-09"},
+		{"2026-11-01", "2026-12-02"},
+	}
+	for _, c := range cases {
+		if _, err := svc.Get("c1", c[0], c[1]); !errors.Is(err, ErrInvalidAvailability) {
+			t.Fatalf("from=%q to=%q: se esperaba ErrInvalidAvailability, llegó %v", c[0], c[1], err)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"canchas-api/internal/clients"
+	"canchas-api/internal/domain"
+	"canchas-api/internal/dto"
+	"canchas-api/internal/repositories"
+	"errors"
+	"fmt"
+	"log"
+	"shared/auth"
+	"strings"
+	"time"
+)
+
+type BlackoutService interface {
+	Create(req *dto.CreateBlackoutRequest, claims *auth.Claims) (*dto.CreateBlackoutResponse, error)
+	List(canchaID, from, to string) (*dto.BlackoutsListResponse, error)
+	Delete(id string, claims *auth.Claims) error
+}
+
+// ErrInvalidBlackout indica que el rango o los filtros de un cierre no son válidos
+var ErrInvalidBlackout = errors.New("invalid blackout")
+
+type blackoutService struct {
+	repo          repositories.BlackoutRepository
+	canchaRepo    repositories.CanchaRepository
+	reservaClient clients.ReservaClient
+}
+
+// NewBlackoutService crea una nueva instancia del servicio de cierres
+func NewBlackoutService(
+	repo repositories.BlackoutRepository,
+	canchaRepo repositories.CanchaRepository,
+	reservaClient clients.ReservaClient,
+) BlackoutService {
+	return &blackoutService{
+		repo:          repo,
+		canchaRepo:    canchaRepo,
+		reservaClient: reservaClient,
+	}
+}
+
+// Create crea un cierre para una cancha o para todo el predio e informa las reservas con las que choca.
+// Con CancelConflicts además las cancela. El cierre se guarda antes de consultar reservas-api,
+// así ninguna reserva nueva puede colarse entre la consulta y la creación.
+func (s *blackoutService) Create(req *dto.CreateBlackoutRequest, claims *auth.Claims) (*dto.CreateBlackoutResponse, error) {
+	start, err := parseBlackoutTime(req.Start)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseBlackoutTime(req.End)
+	if err != nil {
+		return nil, err
+	}
+	if !end.After(start) {
+		return nil, fmt.Errorf("%w: end must be after start", ErrInvalidBlackout)
+	}
+
+	canchaID := strings.TrimSpace(req.CanchaID)
+	if err := s.authorize(canchaID, claims); err != nil {
+		return nil, err
+	}
+
+	blackout := &domain.Blackout{
+		CanchaID: canchaID,
+		Start:    start,
+		End:      end,
+		Reason:   strings.TrimSpace(req.Reason),
+	}
+	if claims != nil {
+		blackout.CreatedBy = claims.UserID
+	}
+
+	if err := s.repo.Create(blackout); err != nil {
+		return nil, err
+	}
+
+	resp := &dto.CreateBlackoutResponse{
+		Blackout:  *blackoutToResponse(blackout),
+		Conflicts: []dto.ConflictingReserva{},
+	}
+
+	conflictsReq := clients.ConflictsRequest{Start: start, End: end, Cancel: req.CancelConflicts}
+	if !blackout.IsVenueWide() {
+		conflictsReq.CanchaIDs = []string{canchaID}
+	}
+	conflicts, err := s.reservaClient.FindConflicts(conflictsReq)
+	if err != nil {
+		log.Printf("Warning: failed to check reservas for blackout %s: %v", blackout.ID.Hex(), err)
+		resp.ConflictsError = err.Error()
+		return resp, nil
+	}
+
+	for _, r := range conflicts.Reservas {
+		resp.Conflicts = append(resp.Conflicts, dto.ConflictingReserva{
+			ID:        r.ID,
+			CanchaID:  r.CanchaID,
+			UserID:    r.UserID,
+			Date:      r.Date,
+			StartTime: r.StartTime,
+			EndTime:   r.EndTime,
+			Status:    r.Status,
+		})
+	}
+	resp.Cancelled = conflicts.Cancelled
+
+	return resp, nil
+}
+
+// List lista los cierres que se superponen con [from, to).
+// Con canchaID incluye los de esa cancha y los de todo el predio.
+func (s *blackoutService) List(canchaID, from, to string) (*dto.BlackoutsListResponse, error) {
+	var fromTime, toTime time.Time
+	var err error
+	if from != "" {
+		if fromTime, err = parseBlackoutTime(from); err != nil {
+			return nil, err
+		}
+	}
+	if to != "" {
+		if toTime, err = parseBlackoutTime(to); err != nil {
+			return nil, err
+		}
+	}
+
+	blackouts, err := s.repo.Find(canchaID, fromTime, toTime)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.BlackoutResponse, len(blackouts))
+	for i := range blackouts {
+		responses[i] = *blackoutToResponse(&blackouts[i])
+	}
+
+	return &dto.BlackoutsListResponse{
+		Blackouts: responses,
+		Total:     int64(len(blackouts)),
+	}, nil
+}
+
+// Delete elimina un cierre (canchas:manage o el encargado de la cancha)
+func (s *blackoutService) Delete(id string, claims *auth.Claims) error {
+	blackout, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.authorize(blackout.CanchaID, claims); err != nil {
+		// Los cierres de una cancha ya borrada solo los limpia canchas:manage
+		if err.Error() != "cancha not found" || !claims.HasPermission(auth.PermCanchasManage) {
+			return err
+		}
+	}
+
+	return s.repo.Delete(id)
+}
+
+// authorize verifica que el usuario pueda cerrar la cancha indicada;
+// un cierre de todo el predio requiere canchas:manage
+func (s *blackoutService) authorize(canchaID string, claims *auth.Claims) error {
+	if claims == nil {
+		return errors.New("forbidden")
+	}
+	if canchaID == "" {
+		if !claims.HasPermission(auth.PermCanchasManage) {
+			return errors.New("forbidden")
+		}
+		return nil
+	}
+
+	cancha, err := s.canchaRepo.GetByID(canchaID)
+	if err != nil {
+		return err
+	}
+	if !canManage(cancha, claims) {
+		return errors.New("forbidden")
+	}
+	return nil
+}
+
+// parseBlackoutTime interpreta una hora de cierre con formato "2025-12-24T18:00"
+func parseBlackoutTime(value string) (time.Time, error) {
+	parsed, err := time.Parse(dto.BlackoutTimeLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q must have format YYYY-MM-DDTHH:MM", ErrInvalidBlackout, value)
+	}
+	return parsed, nil
+}
+
+// blackoutToResponse convierte un Blackout del dominio a BlackoutResponse DTO
+func blackoutToResponse(blackout *domain.Blackout) *dto.BlackoutResponse {
+	return &dto.BlackoutResponse{
+		ID:        blackout.ID.Hex(),
+		CanchaID:  blackout.CanchaID,
+		Start:     blackout.Start,
+		End:       blackout.End,
+		Reason:    blackout.Reason,
+		CreatedBy: blackout.CreatedBy,
+		CreatedAt: blackout.CreatedAt,
+	}
+}
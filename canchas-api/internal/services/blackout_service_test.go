@@ -0,0 +1,133 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"canchas-api/internal/clients"
+	"canchas-api/internal/domain"
+	"canchas-api/internal/dto"
+	"shared/auth"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// mockBlackoutRepository guarda los cierres en memoria
+type mockBlackoutRepository struct {
+	blackouts map[string]*domain.Blackout
+}
+
+func newMockBlackoutRepo() *mockBlackoutRepository {
+	return &mockBlackoutRepository{blackouts: map[string]*domain.Blackout{}}
+}
+
+func (m *mockBlackoutRepository) Create(b *domain.Blackout) error {
+	b.ID = primitive.NewObjectID()
+	b.CreatedAt = time.Now()
+	m.blackouts[b.ID.Hex()] = b
+	return nil
+}
+
+func (m *mockBlackoutRepository) GetByID(id string) (*domain.Blackout, error) {
+	b, ok := m.blackouts[id]
+	if !ok {
+		return nil, errors.New("blackout not found")
+	}
+	return b, nil
+}
+
+func (m *mockBlackoutRepository) Find(canchaID string, from, to time.Time) ([]domain.Blackout, error) {
+	var found []domain.Blackout
+	for _, b := range m.blackouts {
+		if canchaID != "" && b.CanchaID != canchaID && !b.IsVenueWide() {
+			continue
+		}
+		if (!from.IsZero() && !b.End.After(from)) || (!to.IsZero() && !b.Start.Before(to)) {
+			continue
+		}
+		found = append(found, *b)
+	}
+	return found, nil
+}
+
+func (m *mockBlackoutRepository) Delete(id string) error {
+	delete(m.blackouts, id)
+	return nil
+}
+
+func TestCreateBlackoutReportsAndCancelsConflicts(t *testing.T) {
+	canchaRepo := newMockRepo()
+	reservaCli := &mockReservaClient{conflicts: &clients.ConflictsResponse{
+		Reservas:  []clients.ConflictingReserva{{ID: "r1", CanchaID: "c1", Date: "2026-12-24", StartTime: "20:00", EndTime: "21:00", Status: "cancelled"}},
+		Cancelled: 1,
+	}}
+	svc := NewBlackoutService(newMockBlackoutRepo(), canchaRepo, reservaCli)
+
+	cancha, err := NewCanchaService(canchaRepo, &mockPublisher{}, reservaCli).Create(&dto.CreateCanchaRequest{Name: "Central", Type: "futbol", Number: 1, Price: 10, Capacity: 10}, adminClaims)
+	if err != nil {
+		t.Fatalf("no se pudo crear la cancha: %v", err)
+	}
+
+	resp, err := svc.Create(&dto.CreateBlackoutRequest{CanchaID: cancha.ID, Start: "2026-12-24T18:00", End: "2026-12-25T06:00", Reason: "Nochebuena", CancelConflicts: true}, adminClaims)
+	if err != nil {
+		t.Fatalf("se esperaba sin error, llegó %v", err)
+	}
+	if len(resp.Conflicts) != 1 || resp.Cancelled != 1 || resp.Blackout.Reason != "Nochebuena" {
+		t.Fatalf("se esperaba una reserva en conflicto cancelada, llegó %+v", resp)
+	}
+	sent := reservaCli.conflictReqs[0]
+	if !sent.Cancel || len(sent.CanchaIDs) != 1 || sent.CanchaIDs[0] != cancha.ID || sent.Start.Hour() != 18 {
+		t.Fatalf("pedido de conflictos inesperado: %+v", sent)
+	}
+
+	// Sin respuesta de reservas-api el cierre igual queda creado
+	reservaCli.err = errors.New("reservas-api down")
+	resp, err = svc.Create(&dto.CreateBlackoutRequest{Start: "2026-01-01T00:00", End: "2026-01-02T00:00", Reason: "Año nuevo"}, adminClaims)
+	if err != nil || resp.ConflictsError == "" || resp.Blackout.CanchaID != "" {
+		t.Fatalf("se esperaba el cierre de todo el predio con conflicts_error, llegó %+v, error: %v", resp, err)
+	}
+	if len(reservaCli.conflictReqs[1].CanchaIDs) != 0 {
+		t.Fatalf("un cierre de todo el predio no debería filtrar canchas: %+v", reservaCli.conflictReqs[1])
+	}
+
+	list, err := svc.List(cancha.ID, "2026-12-24T00:00", "2026-12-25T00:00")
+	if err != nil || list.Total != 1 {
+		t.Fatalf("se esperaba solo el cierre de Nochebuena en el rango, llegó %+v, error: %v", list, err)
+	}
+}
+
+func TestBlackoutValidationAndPermissions(t *testing.T) {
+	canchaRepo := newMockRepo()
+	svc := NewBlackoutService(newMockBlackoutRepo(), canchaRepo, &mockReservaClient{})
+	manager := &auth.Claims{UserID: 7, Role: auth.RoleVenueManager, Permissions: []string{auth.PermCanchasManageOwn}}
+
+	own, err := NewCanchaService(canchaRepo, &mockPublisher{}, &mockReservaClient{}).Create(&dto.CreateCanchaRequest{Name: "Propia", Type: "tenis", Number: 1, Price: 10, Capacity: 2}, manager)
+	if err != nil {
+		t.Fatalf("no se pudo crear la cancha: %v", err)
+	}
+
+	if _, err := svc.Create(&dto.CreateBlackoutRequest{CanchaID: own.ID, Start: "2026-05-01T10:00", End: "2026-05-01T09:00", Reason: "Al revés"}, manager); !errors.Is(err, ErrInvalidBlackout) {
+		t.Fatalf("se esperaba ErrInvalidBlackout por rango invertido, llegó %v", err)
+	}
+	if _, err := svc.Create(&dto.CreateBlackoutRequest{CanchaID: own.ID, Start: "2026-05-01", End: "2026-05-02", Reason: "Sin hora"}, manager); !errors.Is(err, ErrInvalidBlackout) {
+		t.Fatalf("se esperaba ErrInvalidBlackout por formato, llegó %v", err)
+	}
+
+	created, err := svc.Create(&dto.CreateBlackoutRequest{CanchaID: own.ID, Start: "2026-05-01T10:00", End: "2026-05-01T14:00", Reason: "Resembrado"}, manager)
+	if err != nil {
+		t.Fatalf("el encargado debería poder cerrar su cancha: %v", err)
+	}
+
+	// Un encargado no puede cerrar todo el predio ni tocar cierres de canchas ajenas
+	if _, err := svc.Create(&dto.CreateBlackoutRequest{Start: "2026-05-01T10:00", End: "2026-05-01T14:00", Reason: "Todo"}, manager); err == nil || err.Error() != "forbidden" {
+		t.Fatalf("se esperaba forbidden para un cierre del predio, llegó %v", err)
+	}
+	other := &auth.Claims{UserID: 8, Role: auth.RoleVenueManager, Permissions: []string{auth.PermCanchasManageOwn}}
+	if err := svc.Delete(created.Blackout.ID, other); err == nil || err.Error() != "forbidden" {
+		t.Fatalf("se esperaba forbidden al borrar un cierre ajeno, llegó %v", err)
+	}
+	if err := svc.Delete(created.Blackout.ID, manager); err != nil {
+		t.Fatalf("el encargado debería poder borrar su cierre: %v", err)
+	}
+}
@@ -5,22 +5,35 @@ import (
 	"canchas-api/internal/domain"
 	"canchas-api/internal/dto"
 	"canchas-api/internal/messaging"
+	"canchas-api/internal/pricing"
 	"canchas-api/internal/repositories"
 	"errors"
+	"fmt"
 	"log"
+	"reflect"
+	"shared/auth"
 	"strings"
-	"sync"
 	"time"
 )
 
 type CanchaService interface {
-	Create(req *dto.CreateCanchaRequest) (*dto.CanchaResponse, error)
+	Create(req *dto.CreateCanchaRequest, claims *auth.Claims) (*dto.CanchaResponse, error)
 	GetByID(id string) (*dto.CanchaResponse, error)
 	GetAll() (*dto.CanchasListResponse, error)
-	Update(id string, req *dto.UpdateCanchaRequest) (*dto.CanchaResponse, error)
-	Delete(id string) error
+	Update(id string, req *dto.UpdateCanchaRequest, claims *auth.Claims) (*dto.CanchaResponse, error)
+	Delete(id string, claims *auth.Claims) error
+	Quote(id, date, startTime string, member bool) (*dto.QuoteResponse, error)
 }
 
+// ErrInvalidSchedule indica que el horario enviado no es coherente
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// ErrInvalidPricing indica que la política de precios enviada no es coherente
+var ErrInvalidPricing = errors.New("invalid pricing")
+
+// ErrInvalidQuote indica que el turno a cotizar no es válido
+var ErrInvalidQuote = errors.New("invalid quote request")
+
 type canchaService struct {
 	repo          repositories.CanchaRepository
 	publisher     messaging.RabbitMQPublisher
@@ -40,8 +53,8 @@ func NewCanchaService(
 	}
 }
 
-// Create crea una nueva cancha
-func (s *canchaService) Create(req *dto.CreateCanchaRequest) (*dto.CanchaResponse, error) {
+// Create crea una nueva cancha. Si la crea un encargado (canchas:manage_own) queda a su cargo.
+func (s *canchaService) Create(req *dto.CreateCanchaRequest, claims *auth.Claims) (*dto.CanchaResponse, error) {
 	// Validación de negocio: unicidad por número+tipo
 
 	// Validar que no exista otra cancha con el mismo número y tipo
@@ -66,17 +79,37 @@ func (s *canchaService) Create(req *dto.CreateCanchaRequest) (*dto.CanchaRespons
 		}
 	}
 
+	if req.Schedule != nil {
+		if err := req.Schedule.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+		}
+	}
+	if req.Pricing != nil {
+		if err := req.Pricing.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPricing, err)
+		}
+	}
+
 	// Crear la cancha (sin location/address)
 	cancha := &domain.Cancha{
 		Name:        req.Name,
 		Type:        req.Type,
 		Description: req.Description,
 		Number:      req.Number,
-		Price:       s.calculatePriceConcurrent(req.Price, req.Capacity),
+		Price:       req.Price,
 		Capacity:    req.Capacity,
 		Available:   req.Available,
 		ImageURL:    req.ImageURL,
-		// TODO ELIMINAR: OwnerID:     req.OwnerID,
+		Schedule:    req.Schedule,
+		Pricing:     req.Pricing,
+	}
+	// Sin política se guardan los recargos por defecto, así las canchas sin "pricing" son solo las anteriores a la política
+	if cancha.Pricing == nil {
+		defaults := pricing.Default(req.Capacity)
+		cancha.Pricing = &defaults
+	}
+	if claims != nil && !claims.HasPermission(auth.PermCanchasManage) {
+		cancha.ManagerID = claims.UserID
 	}
 
 	if err := s.repo.Create(cancha); err != nil {
@@ -126,9 +159,9 @@ func (s *canchaService) GetAll() (*dto.CanchasListResponse, error) {
 	}, nil
 }
 
-// Update actualiza una cancha existente
-func (s *canchaService) Update(id string, req *dto.UpdateCanchaRequest) (*dto.CanchaResponse, error) {
-	existing, err := s.repo.GetByID(id)
+// Update actualiza una cancha existente (canchas:manage o su encargado)
+func (s *canchaService) Update(id string, req *dto.UpdateCanchaRequest, claims *auth.Claims) (*dto.CanchaResponse, error) {
+	existing, err := s.getManaged(id, claims)
 	if err != nil {
 		return nil, err
 	}
@@ -182,16 +215,31 @@ func (s *canchaService) Update(id string, req *dto.UpdateCanchaRequest) (*dto.Ca
 		existing.Price = req.Price
 	}
 	if req.Capacity > 0 {
+		// Con los recargos por defecto el mantenimiento sigue a la capacidad
+		if req.Pricing == nil && existing.Pricing != nil && reflect.DeepEqual(*existing.Pricing, pricing.Default(existing.Capacity)) {
+			defaults := pricing.Default(req.Capacity)
+			existing.Pricing = &defaults
+		}
 		existing.Capacity = req.Capacity
 	}
-	// Recalcular precio final con concurrencia
-	existing.Price = s.calculatePriceConcurrent(existing.Price, existing.Capacity)
 	if req.Available != nil {
 		existing.Available = *req.Available
 	}
 	if req.ImageURL != "" {
 		existing.ImageURL = req.ImageURL
 	}
+	if req.Schedule != nil {
+		if err := req.Schedule.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+		}
+		existing.Schedule = req.Schedule
+	}
+	if req.Pricing != nil {
+		if err := req.Pricing.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPricing, err)
+		}
+		existing.Pricing = req.Pricing
+	}
 
 	if err := s.repo.Update(id, existing); err != nil {
 		return nil, err
@@ -211,9 +259,9 @@ func (s *canchaService) Update(id string, req *dto.UpdateCanchaRequest) (*dto.Ca
 	return s.domainToResponse(existing), nil
 }
 
-// Delete elimina una cancha
-func (s *canchaService) Delete(id string) error {
-	cancha, err := s.repo.GetByID(id)
+// Delete elimina una cancha (canchas:manage o su encargado)
+func (s *canchaService) Delete(id string, claims *auth.Claims) error {
+	cancha, err := s.getManaged(id, claims)
 	if err != nil {
 		return err
 	}
@@ -242,6 +290,65 @@ func (s *canchaService) Delete(id string) error {
 	return nil
 }
 
+// Quote cotiza un turno de la cancha: valida el turno contra su horario y aplica su política de precios.
+// member indica si quien reserva tiene tarifa de socio.
+func (s *canchaService) Quote(id, date, startTime string, member bool) (*dto.QuoteResponse, error) {
+	cancha, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return nil, fmt.Errorf("%w: date must have format YYYY-MM-DD", ErrInvalidQuote)
+	}
+
+	sched := cancha.EffectiveSchedule()
+	slot, err := sched.Slot(day, startTime, "")
+	if err != nil {
+		return nil, err
+	}
+
+	quote, err := cancha.EffectivePricing().Quote(cancha.Price, sched.SlotMinutes, day, slot.Start, slot.Duration, member)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuote, err)
+	}
+
+	return &dto.QuoteResponse{
+		CanchaID:  id,
+		Date:      date,
+		StartTime: slot.Start,
+		EndTime:   slot.End,
+		Duration:  slot.Duration,
+		Items:     quote.Items,
+		Total:     quote.Total,
+		Member:    quote.Member,
+	}, nil
+}
+
+// getManaged obtiene una cancha verificando que el usuario pueda administrarla:
+// con canchas:manage cualquiera, con canchas:manage_own solo las que tiene a cargo
+func (s *canchaService) getManaged(id string, claims *auth.Claims) (*domain.Cancha, error) {
+	cancha, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canManage(cancha, claims) {
+		return nil, errors.New("forbidden")
+	}
+
+	return cancha, nil
+}
+
+// canManage indica si el usuario puede administrar la cancha
+func canManage(cancha *domain.Cancha, claims *auth.Claims) bool {
+	if claims.HasPermission(auth.PermCanchasManage) {
+		return true
+	}
+	return claims.HasPermission(auth.PermCanchasManageOwn) && cancha.ManagerID != 0 && cancha.ManagerID == claims.UserID
+}
+
 // domainToResponse convierte una Cancha del dominio a CanchaResponse DTO
 func (s *canchaService) domainToResponse(cancha *domain.Cancha) *dto.CanchaResponse {
 	return &dto.CanchaResponse{
@@ -254,40 +361,10 @@ func (s *canchaService) domainToResponse(cancha *domain.Cancha) *dto.CanchaRespo
 		Capacity:    cancha.Capacity,
 		Available:   cancha.Available,
 		ImageURL:    cancha.ImageURL,
+		ManagerID:   cancha.ManagerID,
+		Schedule:    cancha.EffectiveSchedule(),
+		Pricing:     cancha.EffectivePricing(),
 		CreatedAt:   cancha.CreatedAt,
 		UpdatedAt:   cancha.UpdatedAt,
 	}
 }
-
-// calculatePriceConcurrent suma recargos en paralelo (impuesto + mantenimiento) y retorna el precio final.
-func (s *canchaService) calculatePriceConcurrent(base float64, capacity int) float64 {
-	var wg sync.WaitGroup
-	contribs := make(chan float64, 2)
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		contribs <- base * 0.05
-	}()
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		fee := 8.0
-		if capacity > 15 {
-			fee = 12.0
-		}
-		contribs <- fee
-	}()
-
-	go func() {
-		wg.Wait()
-		close(contribs)
-	}()
-
-	total := base
-	for c := range contribs {
-		total += c
-	}
-	return total
-}
@@ -5,9 +5,13 @@ import (
 	"testing"
 	"time"
 
+	"canchas-api/internal/clients"
 	"canchas-api/internal/domain"
 	"canchas-api/internal/dto"
 	"canchas-api/internal/messaging"
+	"canchas-api/internal/pricing"
+	"shared/auth"
+	"shared/schedule"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -61,6 +65,17 @@ func (m *mockCanchaRepository) GetByName(name string) (*domain.Cancha, error) {
 func (m *mockCanchaRepository) Update(id string, cancha *domain.Cancha) error { return nil }
 func (m *mockCanchaRepository) Delete(id string) error                        { return nil }
 
+// BackfillLegacyPricing hace lo mismo que el repositorio de Mongo con las canchas sin política
+func (m *mockCanchaRepository) BackfillLegacyPricing() error {
+	for _, c := range m.canchas {
+		if c.Pricing == nil {
+			base, rules := pricing.Legacy(c.Price, c.Capacity)
+			c.Price, c.Pricing = base, &rules
+		}
+	}
+	return nil
+}
+
 // mockPublisher guarda eventos publicados para verificar que se emitan.
 type mockPublisher struct {
 	events []messaging.Event
@@ -74,10 +89,33 @@ func (m *mockPublisher) PublishEvent(e messaging.Event) error {
 func (m *mockPublisher) Close() error { return nil }
 
 // mockReservaClient cumple la interfaz y permite extender tests sin llamar a reservas reales.
-type mockReservaClient struct{}
+type mockReservaClient struct {
+	conflicts    *clients.ConflictsResponse
+	err          error
+	conflictReqs []clients.ConflictsRequest
+}
 
 func (m *mockReservaClient) DeleteByCanchaID(id string) error { return nil }
 
+func (m *mockReservaClient) FindConflicts(req clients.ConflictsRequest) (*clients.ConflictsResponse, error) {
+	m.conflictReqs = append(m.conflictReqs, req)
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.conflicts == nil {
+		return &clients.ConflictsResponse{}, nil
+	}
+	return m.conflicts, nil
+}
+
+// adminClaims administra todas las canchas
+var adminClaims = &auth.Claims{
+	UserID:      1,
+	Role:        auth.RoleAdmin,
+	AMR:         []string{auth.AMRPassword, auth.AMROTP},
+	Permissions: []string{auth.PermCanchasManage},
+}
+
 func TestCreateCancha_Success(t *testing.T) {
 	// Caso feliz: crea cancha nueva y emite evento create
 	repo := newMockRepo()
@@ -95,16 +133,20 @@ func TestCreateCancha_Success(t *testing.T) {
 		Available: true,
 	}
 
-	resp, err := svc.Create(req)
+	resp, err := svc.Create(req, adminClaims)
 	if err != nil {
 		t.Fatalf("se esperaba sin error, llegó %v", err)
 	}
 	if resp.Name != req.Name || resp.Type != req.Type || resp.Number != req.Number {
 		t.Fatalf("la respuesta no coincide con la solicitud: %+v", resp)
 	}
-	// Precio esperado: base 10 + 5% + fee 8 = 18.5
-	if resp.Price != 18.5 {
-		t.Fatalf("precio final incorrecto, got %v", resp.Price)
+	// El precio guardado es el base; los recargos van en la cotización: 10 + 5% + fee 8 = 18.5
+	if resp.Price != 10 {
+		t.Fatalf("precio base incorrecto, got %v", resp.Price)
+	}
+	quote, err := svc.Quote(resp.ID, "2026-03-02", "10:00", false)
+	if err != nil || quote.Total != 18.5 {
+		t.Fatalf("precio final incorrecto, got %+v, error: %v", quote, err)
 	}
 	if len(pub.events) != 1 || pub.events[0].Type != "create" {
 		t.Fatalf("se esperaba un evento create, llegaron %+v", pub.events)
@@ -132,7 +174,7 @@ func TestCreateCancha_DuplicateNumberAndType(t *testing.T) {
 		Capacity:    5,
 	}
 
-	if _, err := svc.Create(req); err == nil {
+	if _, err := svc.Create(req, adminClaims); err == nil {
 		t.Fatalf("se esperaba error de número/tipo duplicado, llegó nil")
 	}
 }
@@ -157,7 +199,166 @@ func TestCreateCancha_DuplicateName(t *testing.T) {
 		Capacity:    4,
 	}
 
-	if _, err := svc.Create(req); err == nil {
+	if _, err := svc.Create(req, adminClaims); err == nil {
 		t.Fatalf("se esperaba error de nombre duplicado, llegó nil")
 	}
 }
+
+func TestVenueManagerOnlyManagesOwnCanchas(t *testing.T) {
+	repo := newMockRepo()
+	svc := NewCanchaService(repo, &mockPublisher{}, &mockReservaClient{})
+	manager := &auth.Claims{UserID: 7, Role: auth.RoleVenueManager, Permissions: []string{auth.PermCanchasManageOwn}}
+	other := &auth.Claims{UserID: 8, Role: auth.RoleVenueManager, Permissions: []string{auth.PermCanchasManageOwn}}
+
+	own, err := svc.Create(&dto.CreateCanchaRequest{Name: "Propia", Type: "tenis", Number: 1, Price: 10, Capacity: 2}, manager)
+	if err != nil {
+		t.Fatalf("se esperaba sin error, llegó %v", err)
+	}
+	if own.ManagerID != manager.UserID {
+		t.Fatalf("la cancha debería quedar a cargo de quien la creó, got %d", own.ManagerID)
+	}
+
+	global, _ := svc.Create(&dto.CreateCanchaRequest{Name: "Del club", Type: "tenis", Number: 2, Price: 10, Capacity: 2}, adminClaims)
+	if global.ManagerID != 0 {
+		t.Fatalf("una cancha creada con canchas:manage no tiene encargado, got %d", global.ManagerID)
+	}
+
+	if _, err := svc.Update(own.ID, &dto.UpdateCanchaRequest{Description: "nueva"}, manager); err != nil {
+		t.Fatalf("el encargado debería poder editar su cancha, llegó %v", err)
+	}
+	if _, err := svc.Update(own.ID, &dto.UpdateCanchaRequest{Description: "ajena"}, other); err == nil || err.Error() != "forbidden" {
+		t.Fatalf("se esperaba forbidden para otro encargado, llegó %v", err)
+	}
+	if err := svc.Delete(global.ID, manager); err == nil || err.Error() != "forbidden" {
+		t.Fatalf("se esperaba forbidden sobre una cancha sin encargado, llegó %v", err)
+	}
+	if err := svc.Delete(own.ID, adminClaims); err != nil {
+		t.Fatalf("canchas:manage debería poder eliminar cualquier cancha, llegó %v", err)
+	}
+}
+
+func TestCanchaScheduleDefaultsAndValidation(t *testing.T) {
+	repo := newMockRepo()
+	svc := NewCanchaService(repo, &mockPublisher{}, &mockReservaClient{})
+
+	// Sin horario se responde el horario por defecto de su tipo
+	legacy, err := svc.Create(&dto.CreateCanchaRequest{Name: "Sin horario", Type: "tenis", Number: 1, Price: 10, Capacity: 2}, adminClaims)
+	if err != nil {
+		t.Fatalf("se esperaba sin error, llegó %v", err)
+	}
+	if legacy.Schedule.SlotMinutes != 90 || len(legacy.Schedule.Days) != 7 || legacy.Schedule.Days[0].Open != "10:00" {
+		t.Fatalf("se esperaba el horario por defecto de tenis, llegó %+v", legacy.Schedule)
+	}
+
+	invalid := &schedule.Schedule{SlotMinutes: 60, Days: []schedule.Day{{Weekday: time.Monday, Open: "20:00", Close: "20:30"}}}
+	if _, err := svc.Create(&dto.CreateCanchaRequest{Name: "Mal horario", Type: "futbol", Number: 2, Price: 10, Capacity: 2, Schedule: invalid}, adminClaims); !errors.Is(err, ErrInvalidSchedule) {
+		t.Fatalf("se esperaba ErrInvalidSchedule, llegó %v", err)
+	}
+
+	weekdays := &schedule.Schedule{
+		SlotMinutes:  60,
+		AlignMinutes: 30,
+		Days:         []schedule.Day{{Weekday: time.Monday, Open: "08:00", Close: "23:00"}},
+	}
+	updated, err := svc.Update(legacy.ID, &dto.UpdateCanchaRequest{Schedule: weekdays}, adminClaims)
+	if err != nil {
+		t.Fatalf("se esperaba sin error al cambiar el horario, llegó %v", err)
+	}
+	if updated.Schedule.SlotMinutes != 60 || len(updated.Schedule.Days) != 1 || repo.canchas[legacy.ID].Schedule == nil {
+		t.Fatalf("el horario nuevo debería guardarse, llegó %+v", updated.Schedule)
+	}
+}
+
+func TestUpdateDoesNotCompoundPriceAndQuotesPricing(t *testing.T) {
+	repo := newMockRepo()
+	svc := NewCanchaService(repo, &mockPublisher{}, &mockReservaClient{})
+
+	created, err := svc.Create(&dto.CreateCanchaRequest{Name: "Pico", Type: "futbol", Number: 1, Price: 100, Capacity: 10}, adminClaims)
+	if err != nil {
+		t.Fatalf("se esperaba sin error, llegó %v", err)
+	}
+
+	// Editar varias veces sin tocar el precio no debe sumarle recargos
+	for i := 0; i < 3; i++ {
+		if _, err := svc.Update(created.ID, &dto.UpdateCanchaRequest{Description: "otra"}, adminClaims); err != nil {
+			t.Fatalf("se esperaba sin error, llegó %v", err)
+		}
+	}
+	if repo.canchas[created.ID].Price != 100 {
+		t.Fatalf("el precio base no debería cambiar, llegó %v", repo.canchas[created.ID].Price)
+	}
+
+	invalid := &pricing.Rules{MemberDiscountPercent: 150}
+	if _, err := svc.Update(created.ID, &dto.UpdateCanchaRequest{Pricing: invalid}, adminClaims); !errors.Is(err, ErrInvalidPricing) {
+		t.Fatalf("se esperaba ErrInvalidPricing, llegó %v", err)
+	}
+
+	peak := &pricing.Rules{
+		Rules:                 []pricing.Rule{{Name: "Hora pico", From: "19:00", To: "23:00", Percent: 50}},
+		MemberDiscountPercent: 10,
+	}
+	if _, err := svc.Update(created.ID, &dto.UpdateCanchaRequest{Pricing: peak}, adminClaims); err != nil {
+		t.Fatalf("se esperaba sin error, llegó %v", err)
+	}
+	quote, err := svc.Quote(created.ID, "2026-03-02", "20:00", true)
+	if err != nil || quote.Total != 135 || quote.EndTime != "21:00" {
+		t.Fatalf("se esperaba (100 + 50%%) - 10%% = 135, llegó %+v, error: %v", quote, err)
+	}
+
+	var slotErr *schedule.SlotError
+	if _, err := svc.Quote(created.ID, "2026-03-02", "20:30", false); !errors.As(err, &slotErr) {
+		t.Fatalf("se esperaba SlotError para un turno desalineado, llegó %v", err)
+	}
+	if _, err := svc.Quote(created.ID, "02/03/2026", "20:00", false); !errors.Is(err, ErrInvalidQuote) {
+		t.Fatalf("se esperaba ErrInvalidQuote por la fecha, llegó %v", err)
+	}
+}
+
+func TestLegacyCanchaQuotesItsStoredPriceAfterBackfill(t *testing.T) {
+	repo := newMockRepo()
+	svc := NewCanchaService(repo, &mockPublisher{}, &mockReservaClient{})
+
+	// Canchas guardadas antes de la política: el precio ya traía el 5% y el mantenimiento (8, o 12 con más de 15 jugadores)
+	small := &domain.Cancha{Name: "Vieja", Type: "futbol", Number: 1, Price: 100 + 5 + 8, Capacity: 10}
+	big := &domain.Cancha{Name: "Vieja grande", Type: "futbol", Number: 2, Price: 33.33 + 1.6665 + 12, Capacity: 22}
+	repo.Create(small)
+	repo.Create(big)
+
+	if err := repo.BackfillLegacyPricing(); err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	if small.Price != 100 || big.Price != 33.33 {
+		t.Fatalf("se esperaba recuperar los precios base 100 y 33.33, llegó %v y %v", small.Price, big.Price)
+	}
+	quote, err := svc.Quote(small.ID.Hex(), "2026-03-02", "10:00", false)
+	if err != nil || quote.Total != 113 {
+		t.Fatalf("la cancha vieja debe cotizar lo mismo que cobraba, 113, llegó %+v, error: %v", quote, err)
+	}
+	if quote, _ := svc.Quote(big.ID.Hex(), "2026-03-02", "10:00", false); quote.Total != 47 {
+		t.Fatalf("la cancha vieja grande debe cotizar 47, llegó %+v", quote)
+	}
+
+	// Un segundo arranque no vuelve a descontar los recargos
+	repo.BackfillLegacyPricing()
+	if small.Price != 100 {
+		t.Fatalf("el backfill no debe repetirse, llegó %v", small.Price)
+	}
+
+	// Las altas nuevas guardan su política, así el backfill no las toca
+	created, err := svc.Create(&dto.CreateCanchaRequest{Name: "Nueva", Type: "futbol", Number: 3, Price: 100, Capacity: 10}, adminClaims)
+	if err != nil {
+		t.Fatalf("se esperaba sin error, llegó %v", err)
+	}
+	repo.BackfillLegacyPricing()
+	if repo.canchas[created.ID].Price != 100 || repo.canchas[created.ID].Pricing == nil {
+		t.Fatalf("una cancha nueva no es vieja, llegó %+v", repo.canchas[created.ID])
+	}
+
+	// Con los recargos por defecto, el mantenimiento sigue a la capacidad
+	if _, err := svc.Update(created.ID, &dto.UpdateCanchaRequest{Capacity: 20}, adminClaims); err != nil {
+		t.Fatalf("se esperaba sin error, llegó %v", err)
+	}
+	if quote, _ := svc.Quote(created.ID, "2026-03-02", "10:00", false); quote.Total != 117 {
+		t.Fatalf("se esperaba 100 + 5%% + 12 = 117, llegó %+v", quote)
+	}
+}
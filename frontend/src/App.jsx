@@ -12,6 +12,7 @@ import CanchaDetails from './pages/CanchaDetails';
 import Congrats from './pages/Congrats';
 import MisReservas from './pages/MisReservas';
 import Admin from './pages/Admin';
+import OidcCallback from './pages/OidcCallback';
 
 import './App.css';
 
@@ -25,6 +26,7 @@ function App() {
             {/* Rutas públicas */}
             <Route path="/login" element={<Login />} />
             <Route path="/register" element={<Register />} />
+            <Route path="/auth/:provider/callback" element={<OidcCallback />} />
             <Route path="/" element={<Home />} />
             <Route path="/cancha/:id" element={<CanchaDetails />} />
 
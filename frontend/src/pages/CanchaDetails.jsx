@@ -4,21 +4,10 @@ import { useAuth } from '../context/AuthContext';
 import canchaService from '../services/canchaService';
 import reservaService from '../services/reservaService';
 
-const PADDEL_TYPES = ['padel', 'tenis', 'paddle'];
-const START_MINUTES = 10 * 60;
-const END_MINUTES = 26 * 60;
+// Corte del día de reservas: los turnos antes de las 06:00 son la madrugada del día anterior
+const DAY_START_MINUTES = 6 * 60;
 const MINUTES_IN_DAY = 24 * 60;
 
-const isExtendedSlot = (type = '') =>
-  PADDEL_TYPES.includes(type.toLowerCase());
-
-const minutesToLabel = (minutes) => {
-  const normalized = ((minutes % MINUTES_IN_DAY) + MINUTES_IN_DAY) % MINUTES_IN_DAY;
-  const hours = Math.floor(normalized / 60);
-  const mins = normalized % 60;
-  return `${hours.toString().padStart(2, '0')}:${mins.toString().padStart(2, '0')}`;
-};
-
 const parseTimeToMinutes = (timeStr) => {
   if (!timeStr) return 0;
   const [hours, minutes] = timeStr.split(':').map(Number);
@@ -27,30 +16,12 @@ const parseTimeToMinutes = (timeStr) => {
 
 const normalizeMinutesForRange = (timeStr) => {
   let minutes = parseTimeToMinutes(timeStr);
-  if (minutes < START_MINUTES) {
+  if (minutes < DAY_START_MINUTES) {
     minutes += MINUTES_IN_DAY;
   }
   return minutes;
 };
 
-const generateSlots = (type = '') => {
-  const duration = isExtendedSlot(type) ? 90 : 60;
-  const slots = [];
-  for (let current = START_MINUTES; current + duration <= END_MINUTES; current += duration) {
-    const start = minutesToLabel(current);
-    const end = minutesToLabel(current + duration);
-    slots.push({
-      key: `${start}-${end}`,
-      start,
-      end,
-      duration,
-      normalizedStart: current,
-      normalizedEnd: current + duration,
-    });
-  }
-  return slots;
-};
-
 const CanchaDetails = () => {
   const { id } = useParams();
   const navigate = useNavigate();
@@ -68,9 +39,10 @@ const CanchaDetails = () => {
   });
   const [reservaLoading, setReservaLoading] = useState(false);
   const [reservaError, setReservaError] = useState('');
-  const [bookedSlots, setBookedSlots] = useState([]);
+  const [daySlots, setDaySlots] = useState([]);
   const [slotsLoading, setSlotsLoading] = useState(false);
   const [slotsError, setSlotsError] = useState('');
+  const [quote, setQuote] = useState(null);
 
   useEffect(() => {
     fetchCancha();
@@ -87,65 +59,45 @@ const CanchaDetails = () => {
     }
   };
 
-  const slotOptions = useMemo(() => {
-    if (!cancha) return [];
-    return generateSlots(cancha.type || '');
-  }, [cancha]);
-
+  // Turnos del día según la grilla de disponibilidad de canchas-api (free, held o booked)
   const slotsWithStatus = useMemo(
     () =>
-      slotOptions.map((slot) => {
-        const isBooked = bookedSlots.some(
-          (booked) => booked.normalizedStart === slot.normalizedStart
-        );
-        const isSelected =
-          reservaData.start_time === slot.start &&
-          reservaData.end_time === slot.end;
-        return { ...slot, isBooked, isSelected };
-      }),
-    [slotOptions, bookedSlots, reservaData.start_time, reservaData.end_time]
+      daySlots.map((slot) => ({
+        key: `${slot.start_time}-${slot.end_time}`,
+        start: slot.start_time,
+        end: slot.end_time,
+        isBooked: slot.status !== 'free',
+        isHeld: slot.status === 'held',
+        isSelected:
+          reservaData.start_time === slot.start_time &&
+          reservaData.end_time === slot.end_time,
+      })),
+    [daySlots, reservaData.start_time, reservaData.end_time]
   );
 
   useEffect(() => {
     if (!reservaData.date || !id) {
-      setBookedSlots([]);
+      setDaySlots([]);
       setSlotsError('');
       return;
     }
 
-    const loadBookedSlots = async () => {
+    const loadAvailability = async () => {
       setSlotsLoading(true);
       setSlotsError('');
       try {
-        const data = await reservaService.getReservasByCanchaId(id);
-        const reservas = data?.reservas || [];
-        const sameDay = reservas.filter(
-          (item) => item.date === reservaData.date && item.status !== 'cancelled'
-        );
-        const normalized = sameDay.map((item) => {
-          const normalizedStart = normalizeMinutesForRange(item.start_time);
-          const normalizedEnd = normalizeMinutesForRange(item.end_time);
-          return {
-            start: item.start_time,
-            end: item.end_time,
-            normalizedStart,
-            normalizedEnd:
-              normalizedEnd <= normalizedStart
-                ? normalizedEnd + MINUTES_IN_DAY
-                : normalizedEnd,
-          };
-        });
-        setBookedSlots(normalized);
+        const data = await canchaService.getAvailability(id, reservaData.date);
+        setDaySlots(data?.days?.[0]?.slots || []);
       } catch (err) {
-        console.error('Error fetching booked slots:', err);
-        setBookedSlots([]);
+        console.error('Error fetching availability:', err);
+        setDaySlots([]);
         setSlotsError('No se pudieron cargar los turnos. Intenta nuevamente.');
       } finally {
         setSlotsLoading(false);
       }
     };
 
-    loadBookedSlots();
+    loadAvailability();
   }, [id, reservaData.date]);
 
   const handleReservaChange = (e) => {
@@ -191,7 +143,6 @@ const CanchaDetails = () => {
     try {
       const payload = {
         cancha_id: id,
-        user_id: user.id,
         date: reservaData.date,
         start_time: reservaData.start_time,
         end_time: reservaData.end_time,
@@ -227,19 +178,22 @@ const CanchaDetails = () => {
     return 0;
   };
 
-  const calculatePrice = () => {
-    const duration = calculateDuration();
-    if (duration > 0 && cancha) {
-      const hours = duration / 60;
-      return (cancha.price * hours).toFixed(2);
-    }
-    return 0;
-  };
-
   const isFormReady = Boolean(
     reservaData.date && reservaData.start_time && reservaData.end_time
   );
 
+  // El precio lo calcula canchas-api: reglas de horario, recargos y descuento de socio
+  useEffect(() => {
+    if (!isFormReady) {
+      setQuote(null);
+      return;
+    }
+    canchaService
+      .getQuote(id, reservaData.date, reservaData.start_time, user?.role === 'member')
+      .then(setQuote)
+      .catch(() => setQuote(null));
+  }, [id, isFormReady, reservaData.date, reservaData.start_time, user]);
+
   if (loading) {
     return <div style={styles.loading}>Cargando...</div>;
   }
@@ -310,11 +264,11 @@ const CanchaDetails = () => {
           </div>
 
           <div style={styles.priceSection}>
-            <span style={styles.priceLabel}>Precio por turno:</span>
+            <span style={styles.priceLabel}>Precio base por turno:</span>
             <span style={styles.price}>${cancha.price}</span>
           </div>
           <p style={styles.priceNote}>
-            El precio mostrado incluye 5% de impuestos y un fee de mantenimiento.
+            El total final depende del día y horario e incluye impuestos y recargos.
           </p>
         </div>
 
@@ -381,6 +335,15 @@ const CanchaDetails = () => {
                         ></span>
                         Ocupado
                       </span>
+                      <span style={styles.legendItem}>
+                        <span
+                          style={{
+                            ...styles.legendDot,
+                            backgroundColor: '#f39c12',
+                          }}
+                        ></span>
+                        Retenido
+                      </span>
                       <span style={styles.legendItem}>
                         <span
                           style={{
@@ -394,7 +357,7 @@ const CanchaDetails = () => {
 
                     {slotsWithStatus.length === 0 ? (
                       <div style={styles.slotInfo}>
-                        No hay turnos configurados para esta cancha.
+                        No hay turnos disponibles este día.
                       </div>
                     ) : (
                       <div style={styles.slotGrid}>
@@ -407,6 +370,7 @@ const CanchaDetails = () => {
                             style={{
                               ...styles.slotButton,
                               ...(slot.isBooked ? styles.slotButtonBooked : {}),
+                              ...(slot.isHeld ? styles.slotButtonHeld : {}),
                               ...(slot.isSelected ? styles.slotButtonSelected : {}),
                             }}
                           >
@@ -431,13 +395,15 @@ const CanchaDetails = () => {
                     <span>Duración:</span>
                     <span>{calculateDuration()} minutos</span>
                   </div>
-                  <div style={styles.summaryRow}>
-                    <span>Precio por turno:</span>
-                    <span>${cancha.price}</span>
-                  </div>
+                  {(quote?.items || []).map((item) => (
+                    <div key={`${item.kind}-${item.name}`} style={styles.summaryRow}>
+                      <span>{item.name}:</span>
+                      <span>${item.amount.toFixed(2)}</span>
+                    </div>
+                  ))}
                   <div style={{ ...styles.summaryRow, ...styles.summaryTotal }}>
                     <span>Total:</span>
-                    <span>${calculatePrice()}</span>
+                    <span>{quote ? `$${quote.total.toFixed(2)}` : '-'}</span>
                   </div>
                 </div>
               )}
@@ -713,6 +679,11 @@ const styles = {
     borderColor: '#ecf0f1',
     cursor: 'not-allowed',
   },
+  slotButtonHeld: {
+    backgroundColor: '#fdf2e0',
+    color: '#e67e22',
+    borderColor: '#f8c471',
+  },
   slotInfo: {
     backgroundColor: '#f8f9fa',
     padding: '0.75rem',
@@ -1,5 +1,5 @@
-import { useState } from 'react';
-import { useNavigate, Link } from 'react-router-dom';
+import { useEffect, useState } from 'react';
+import { useNavigate, useLocation, Link } from 'react-router-dom';
 import { useAuth } from '../context/AuthContext';
 import authService from '../services/authService';
 
@@ -8,12 +8,30 @@ const Login = () => {
     login: '',
     password: '',
   });
+  const location = useLocation();
+  // El login externo con 2FA vuelve acá con el challenge ya emitido
+  const [challengeToken, setChallengeToken] = useState(location.state?.challengeToken || '');
+  const [providers, setProviders] = useState([]);
+  const [code, setCode] = useState('');
   const [error, setError] = useState('');
   const [loading, setLoading] = useState(false);
 
   const navigate = useNavigate();
   const { login } = useAuth();
 
+  useEffect(() => {
+    authService.getOidcProviders().then(setProviders).catch(() => setProviders([]));
+  }, []);
+
+  const handleProviderLogin = async (provider) => {
+    setError('');
+    try {
+      window.location.href = await authService.oidcAuthorize(provider);
+    } catch (err) {
+      setError(err.response?.data?.message || 'Error al iniciar sesión');
+    }
+  };
+
   const handleChange = (e) => {
     setFormData({
       ...formData,
@@ -27,7 +45,16 @@ const Login = () => {
     setLoading(true);
 
     try {
-      const response = await authService.login(formData.login, formData.password);
+      const response = challengeToken
+        ? await authService.loginTwoFactor(challengeToken, code)
+        : await authService.login(formData.login, formData.password);
+
+      // Con 2FA activo se pide el código de la app antes de entregar el token
+      if (response.two_factor_required) {
+        setChallengeToken(response.challenge_token);
+        return;
+      }
+
       login(response.user, response.token);
       navigate('/');
     } catch (err) {
@@ -45,37 +72,67 @@ const Login = () => {
         {error && <div style={styles.error}>{error}</div>}
 
         <form onSubmit={handleSubmit} style={styles.form}>
-          <div style={styles.formGroup}>
-            <label style={styles.label}>Usuario o Email</label>
-            <input
-              type="text"
-              name="login"
-              value={formData.login}
-              onChange={handleChange}
-              required
-              style={styles.input}
-              placeholder="Ingresa tu usuario o email"
-            />
-          </div>
-
-          <div style={styles.formGroup}>
-            <label style={styles.label}>Contraseña</label>
-            <input
-              type="password"
-              name="password"
-              value={formData.password}
-              onChange={handleChange}
-              required
-              style={styles.input}
-              placeholder="Ingresa tu contraseña"
-            />
-          </div>
+          {challengeToken ? (
+            <div style={styles.formGroup}>
+              <label style={styles.label}>Código de verificación</label>
+              <input
+                type="text"
+                name="code"
+                value={code}
+                onChange={(e) => setCode(e.target.value)}
+                required
+                autoFocus
+                autoComplete="one-time-code"
+                style={styles.input}
+                placeholder="Código de 6 dígitos o de recuperación"
+              />
+            </div>
+          ) : (
+            <>
+              <div style={styles.formGroup}>
+                <label style={styles.label}>Usuario o Email</label>
+                <input
+                  type="text"
+                  name="login"
+                  value={formData.login}
+                  onChange={handleChange}
+                  required
+                  style={styles.input}
+                  placeholder="Ingresa tu usuario o email"
+                />
+              </div>
+
+              <div style={styles.formGroup}>
+                <label style={styles.label}>Contraseña</label>
+                <input
+                  type="password"
+                  name="password"
+                  value={formData.password}
+                  onChange={handleChange}
+                  required
+                  style={styles.input}
+                  placeholder="Ingresa tu contraseña"
+                />
+              </div>
+            </>
+          )}
 
           <button type="submit" disabled={loading} style={styles.button}>
             {loading ? 'Ingresando...' : 'Ingresar'}
           </button>
         </form>
 
+        {!challengeToken && providers.map((provider) => (
+          <button
+            key={provider}
+            type="button"
+            onClick={() => handleProviderLogin(provider)}
+            style={styles.providerButton}
+          >
+            Ingresar con {provider}
+          </button>
+        ))}
+
         <p style={styles.footer}>
           ¿No tienes cuenta? <Link to="/register" style={styles.link}>Regístrate aquí</Link>
         </p>
@@ -145,6 +202,17 @@ const styles = {
     cursor: 'pointer',
     marginTop: '0.5rem',
   },
+  providerButton: {
+    width: '100%',
+    backgroundColor: '#fff',
+    color: '#2c3e50',
+    padding: '0.75rem',
+    border: '1px solid #ddd',
+    borderRadius: '4px',
+    fontSize: '1rem',
+    cursor: 'pointer',
+    marginTop: '0.75rem',
+  },
   footer: {
     textAlign: 'center',
     marginTop: '1.5rem',
@@ -0,0 +1,69 @@
+import { useEffect, useRef, useState } from 'react';
+import { useNavigate, useParams, useSearchParams, Link } from 'react-router-dom';
+import { useAuth } from '../context/AuthContext';
+import authService from '../services/authService';
+
+// Redirect URI de los proveedores OIDC: canjea el code y el state en users-api
+const OidcCallback = () => {
+  const { provider } = useParams();
+  const [searchParams] = useSearchParams();
+  const [error, setError] = useState('');
+  const navigate = useNavigate();
+  const { login } = useAuth();
+  // En modo estricto React monta dos veces y el state solo se puede canjear una
+  const started = useRef(false);
+
+  useEffect(() => {
+    if (started.current) return;
+    started.current = true;
+
+    const code = searchParams.get('code');
+    const state = searchParams.get('state');
+    if (!code || !state) {
+      setError(searchParams.get('error_description') || 'El proveedor no completó el inicio de sesión');
+      return;
+    }
+
+    authService
+      .oidcCallback(provider, code, state)
+      .then((response) => {
+        if (response.two_factor_required) {
+          navigate('/login', { replace: true, state: { challengeToken: response.challenge_token } });
+          return;
+        }
+        login(response.user, response.token);
+        navigate('/', { replace: true });
+      })
+      .catch((err) => setError(err.response?.data?.message || 'Error al iniciar sesión'));
+  }, [provider, searchParams, login, navigate]);
+
+  return (
+    <div style={styles.container}>
+      {error ? (
+        <div style={styles.error}>
+          {error} — <Link to="/login">volver al login</Link>
+        </div>
+      ) : (
+        <p>Iniciando sesión...</p>
+      )}
+    </div>
+  );
+};
+
+const styles = {
+  container: {
+    minHeight: '60vh',
+    display: 'flex',
+    alignItems: 'center',
+    justifyContent: 'center',
+    padding: '1rem',
+  },
+  error: {
+    backgroundColor: '#fee',
+    color: '#c00',
+    padding: '0.75rem',
+    borderRadius: '4px',
+  },
+};
+
+export default OidcCallback;
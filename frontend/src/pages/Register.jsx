@@ -2,6 +2,17 @@ import { useState } from 'react';
 import { useNavigate, Link } from 'react-router-dom';
 import authService from '../services/authService';
 
+// Motivos de rechazo de la política de contraseñas de users-api
+const passwordReasons = {
+  too_short: 'es demasiado corta',
+  too_long: 'es demasiado larga',
+  missing_lowercase: 'le falta una minúscula',
+  missing_uppercase: 'le falta una mayúscula',
+  missing_digit: 'le falta un número',
+  missing_symbol: 'le falta un símbolo',
+  breached: 'es demasiado común o apareció en una filtración',
+};
+
 const Register = () => {
   const [formData, setFormData] = useState({
     username: '',
@@ -31,7 +42,12 @@ const Register = () => {
       await authService.register(formData);
       navigate('/login', { state: { message: 'Registro exitoso. Por favor inicia sesión.' } });
     } catch (err) {
-      setError(err.response?.data?.message || 'Error al registrarse');
+      const reasons = err.response?.data?.reasons;
+      if (reasons?.length) {
+        setError('La contraseña ' + reasons.map((r) => passwordReasons[r.code] || r.message).join(', '));
+      } else {
+        setError(err.response?.data?.message || 'Error al registrarse');
+      }
     } finally {
       setLoading(false);
     }
@@ -106,9 +122,9 @@ const Register = () => {
               value={formData.password}
               onChange={handleChange}
               required
-              minLength={8}
+              minLength={10}
               style={styles.input}
-              placeholder="Mínimo 8 caracteres"
+              placeholder="Mínimo 10 caracteres, con mayúsculas, minúsculas y números"
             />
           </div>
 
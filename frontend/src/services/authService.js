@@ -12,6 +12,36 @@ const authService = {
     return response.data;
   },
 
+  // Segundo paso del login cuando el usuario tiene 2FA
+  loginTwoFactor: async (challengeToken, code) => {
+    const response = await axios.post(`${API_URL}/users/login/2fa`, {
+      challenge_token: challengeToken,
+      code,
+    });
+    return response.data;
+  },
+
+  // Proveedores externos (OIDC) habilitados en el backend
+  getOidcProviders: async () => {
+    const response = await axios.get(`${API_URL}/users/oidc/providers`);
+    return response.data.providers || [];
+  },
+
+  // Inicia el login externo: el backend retorna la URL del proveedor
+  oidcAuthorize: async (provider) => {
+    const response = await axios.get(`${API_URL}/users/oidc/${provider}/authorize`);
+    return response.data.authorization_url;
+  },
+
+  // Completa el login externo con el code y el state que devolvió el proveedor
+  oidcCallback: async (provider, code, state) => {
+    const response = await axios.post(`${API_URL}/users/oidc/${provider}/callback`, {
+      code,
+      state,
+    });
+    return response.data;
+  },
+
   // Register
   register: async (userData) => {
     const response = await axios.post(`${API_URL}/users/register`, userData);
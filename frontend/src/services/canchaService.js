@@ -15,6 +15,22 @@ const canchaService = {
     return response.data;
   },
 
+  // Cotizar un turno con la política de precios de la cancha
+  getQuote: async (id, date, startTime, member = false) => {
+    const response = await axios.get(`${API_URL}/canchas/${id}/quote`, {
+      params: { date, start_time: startTime, member },
+    });
+    return response.data;
+  },
+
+  // Grilla de turnos reservables entre dos fechas (to vacío = solo from)
+  getAvailability: async (id, from, to = '') => {
+    const response = await axios.get(`${API_URL}/canchas/${id}/availability`, {
+      params: { from, ...(to ? { to } : {}) },
+    });
+    return response.data;
+  },
+
   // Crear cancha (solo admin)
   createCancha: async (canchaData, token) => {
     const response = await axios.post(`${API_URL}/canchas`, canchaData, {
@@ -5,10 +5,12 @@ import (
 	"log"
 	"reservas-api/config"
 	"reservas-api/internal/clients"
+	"reservas-api/internal/consumers"
 	"reservas-api/internal/controllers"
 	"reservas-api/internal/messaging"
 	"reservas-api/internal/repositories"
 	"reservas-api/internal/services"
+	"shared/auth"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -31,20 +33,41 @@ func main() {
 	defer publisher.Close()
 
 	// Inicializar clientes HTTP
-	userClient := clients.NewUserClient()
 	canchaClient := clients.NewCanchaClient()
 
 	// Inicializar repositorios
 	reservaRepo := repositories.NewReservaRepository(db)
+	waitlistRepo := repositories.NewWaitlistRepository(db)
+
+	// Bloquear los turnos de las reservas activas creadas antes de que existieran los bloqueos
+	if err := reservaRepo.BackfillSlotLocks(time.Now().Truncate(24*time.Hour).AddDate(0, 0, -1)); err != nil {
+		log.Printf("Warning: failed to backfill slot locks: %v", err)
+	}
 
 	// Inicializar servicios
-	reservaService := services.NewReservaService(reservaRepo, userClient, canchaClient, publisher)
+	reservaService := services.NewReservaService(reservaRepo, waitlistRepo, canchaClient, publisher)
+
+	// Vencer periódicamente los turnos retenidos que no se confirmaron a tiempo
+	stopReaper := services.StartHoldReaper(reservaService, time.Duration(config.AppConfig.HoldReapSeconds)*time.Second)
+	defer stopReaper()
+
+	// Escuchar eventos user.* para cancelar o anonimizar reservas de usuarios dados de baja
+	userConsumer, err := consumers.NewUserEventsConsumer(config.AppConfig.RabbitMQURL, reservaService)
+	if err != nil {
+		log.Fatalf("Failed to connect user events consumer: %v", err)
+	}
+	defer userConsumer.Close()
+	if err := userConsumer.Listen(config.AppConfig.UsersExchange, config.AppConfig.UsersQueue); err != nil {
+		log.Fatalf("Failed to listen for user events: %v", err)
+	}
 
 	// Inicializar controladores
 	reservaController := controllers.NewReservaController(reservaService)
 
 	// Configurar Gin
-	router := setupRouter(reservaController)
+	// Validación local de tokens con las claves públicas de users-api (JWKS cacheado)
+	validator := auth.NewJWKSValidator(config.AppConfig.JWKSURL, 10*time.Minute)
+	router := setupRouter(reservaController, validator)
 
 	// Iniciar servidor
 	port := config.AppConfig.Port
@@ -84,7 +107,7 @@ func connectMongoDB() *mongo.Database {
 }
 
 // setupRouter configura las rutas de la API
-func setupRouter(reservaController *controllers.ReservaController) *gin.Engine {
+func setupRouter(reservaController *controllers.ReservaController, validator auth.Validator) *gin.Engine {
 	router := gin.Default()
 
 	// Middleware CORS
@@ -98,17 +121,42 @@ func setupRouter(reservaController *controllers.ReservaController) *gin.Engine {
 		})
 	})
 
-	// Rutas de reservas
-	reservas := router.Group("/reservas")
+	// Rutas públicas de reservas
+	public := router.Group("/reservas")
+	{
+		public.GET("/cancha/:cancha_id", reservaController.GetByCanchaID)
+	}
+
+	// Rutas internas: canchas-api con el token de servicio, o un usuario con canchas:manage
+	internal := router.Group("/reservas")
+	internal.Use(auth.ServiceOrPermission(config.AppConfig.ServiceToken, validator, auth.PermCanchasManage))
+	{
+		internal.DELETE("/cancha/:cancha_id", reservaController.DeleteByCanchaID) // Al borrar una cancha
+		internal.POST("/conflicts", reservaController.FindConflicts)              // Solo lectura: al crear un cierre y al armar la disponibilidad
+		internal.POST("/conflicts/cancel", reservaController.CancelConflicts)     // Al crear un cierre con cancel_conflicts
+	}
+
+	// Rutas protegidas (requieren autenticación)
+	protected := router.Group("/reservas")
+	protected.Use(auth.Middleware(validator))
 	{
-		reservas.POST("", reservaController.Create)
-		reservas.GET("", reservaController.GetAll)
-		reservas.GET("/:id", reservaController.GetByID)
-		reservas.PUT("/:id", reservaController.Update)
-		reservas.DELETE("/:id", reservaController.Cancel)
-		reservas.GET("/user/:user_id", reservaController.GetByUserID)
-		reservas.GET("/cancha/:cancha_id", reservaController.GetByCanchaID)
-		reservas.DELETE("/cancha/:cancha_id", reservaController.DeleteByCanchaID)
+		protected.POST("", reservaController.Create)
+		protected.POST("/recurring", reservaController.CreateRecurring)
+		protected.POST("/waitlist", reservaController.JoinWaitlist)
+		protected.GET("/waitlist", reservaController.GetWaitlists)
+		protected.DELETE("/waitlist/:id", reservaController.LeaveWaitlist)
+		protected.GET("/series/:series_id", reservaController.GetSeries)
+		protected.PUT("/series/:series_id", reservaController.UpdateSeries)
+		protected.DELETE("/series/:series_id", reservaController.CancelSeries)
+		protected.GET("/:id", reservaController.GetByID)
+		protected.PUT("/:id", reservaController.Update)
+		protected.DELETE("/:id", reservaController.Cancel)
+		protected.GET("/user/:user_id", auth.RequireSelfOrPermission("user_id", auth.PermReservasReadAll), reservaController.GetByUserID)
+		protected.POST("/:id/confirm", reservaController.Confirm) // Recepción, o el dueño de un turno retenido
+
+		// Recepción
+		protected.POST("/:id/check-in", auth.RequirePermission(auth.PermReservasCheckIn), reservaController.CheckIn)
+		protected.GET("", auth.RequirePermission(auth.PermReservasReadAll), reservaController.GetAll)
 	}
 
 	log.Println("Routes configured successfully")
@@ -5,12 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"reservas-api/config"
+	"shared/schedule"
+	"strconv"
 	"time"
 )
 
 type CanchaClient interface {
 	ValidateCancha(canchaID string) (bool, *CanchaResponse, error)
+	GetBlackouts(canchaID string, from, to time.Time) ([]BlackoutResponse, error)
+	GetQuote(canchaID string, date time.Time, startTime string, member bool) (*QuoteResponse, error)
 }
 
 type canchaClient struct {
@@ -19,18 +24,61 @@ type canchaClient struct {
 }
 
 type CanchaResponse struct {
-	ID          string  `json:"id"`
-	Name        string  `json:"name"`
-	Type        string  `json:"type"`
-	Description string  `json:"description"`
-	Location    string  `json:"location"`
-	Address     string  `json:"address"`
-	Price       float64 `json:"price"`
-	Capacity    int     `json:"capacity"`
-	Available   bool    `json:"available"`
-	ImageURL    string  `json:"image_url"`
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Type        string             `json:"type"`
+	Description string             `json:"description"`
+	Location    string             `json:"location"`
+	Address     string             `json:"address"`
+	Price       float64            `json:"price"`
+	Capacity    int                `json:"capacity"`
+	Available   bool               `json:"available"`
+	ImageURL    string             `json:"image_url"`
+	Schedule    *schedule.Schedule `json:"schedule"`
 }
 
+// EffectiveSchedule retorna el horario de la cancha, o el horario por defecto de su tipo
+// si canchas-api no lo informa
+func (c *CanchaResponse) EffectiveSchedule() schedule.Schedule {
+	if c.Schedule != nil {
+		return *c.Schedule
+	}
+	return schedule.Default(c.Type)
+}
+
+// BlackoutResponse es un cierre de la cancha o de todo el predio (CanchaID vacío)
+type BlackoutResponse struct {
+	ID       string    `json:"id"`
+	CanchaID string    `json:"cancha_id"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Reason   string    `json:"reason"`
+}
+
+type blackoutsListResponse struct {
+	Blackouts []BlackoutResponse `json:"blackouts"`
+}
+
+// QuoteItem es una línea de la cotización de canchas-api
+type QuoteItem struct {
+	Kind   string  `json:"kind"` // "base", "rule", "discount" o "surcharge"
+	Name   string  `json:"name"`
+	Amount float64 `json:"amount"`
+}
+
+// QuoteResponse es el precio detallado de un turno según la política de precios de la cancha
+type QuoteResponse struct {
+	StartTime string      `json:"start_time"`
+	EndTime   string      `json:"end_time"`
+	Duration  int         `json:"duration"`
+	Items     []QuoteItem `json:"items"`
+	Total     float64     `json:"total"`
+	Member    bool        `json:"member"`
+}
+
+// blackoutTimeLayout es el formato de hora que acepta canchas-api para filtrar cierres
+const blackoutTimeLayout = "2006-01-02T15:04"
+
 // NewCanchaClient crea una nueva instancia del cliente HTTP para canchas-api
 func NewCanchaClient() CanchaClient {
 	return &canchaClient{
@@ -71,3 +119,53 @@ func (c *canchaClient) ValidateCancha(canchaID string) (bool, *CanchaResponse, e
 
 	return true, &cancha, nil
 }
+
+// GetBlackouts obtiene los cierres de la cancha (y los de todo el predio) que se superponen con [from, to)
+func (c *canchaClient) GetBlackouts(canchaID string, from, to time.Time) ([]BlackoutResponse, error) {
+	query := url.Values{}
+	query.Set("cancha_id", canchaID)
+	query.Set("from", from.Format(blackoutTimeLayout))
+	query.Set("to", to.Format(blackoutTimeLayout))
+
+	resp, err := c.httpClient.Get(fmt.Sprintf("%s/blackouts?%s", c.baseURL, query.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("error calling canchas-api: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("canchas-api returned status: %d", resp.StatusCode)
+	}
+
+	var list blackoutsListResponse
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return list.Blackouts, nil
+}
+
+// GetQuote pide a canchas-api el precio de un turno; member aplica la tarifa de socio
+func (c *canchaClient) GetQuote(canchaID string, date time.Time, startTime string, member bool) (*QuoteResponse, error) {
+	query := url.Values{}
+	query.Set("date", date.Format("2006-01-02"))
+	query.Set("start_time", startTime)
+	query.Set("member", strconv.FormatBool(member))
+
+	resp, err := c.httpClient.Get(fmt.Sprintf("%s/canchas/%s/quote?%s", c.baseURL, canchaID, query.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("error calling canchas-api: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("canchas-api returned status: %d", resp.StatusCode)
+	}
+
+	var quote QuoteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&quote); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &quote, nil
+}
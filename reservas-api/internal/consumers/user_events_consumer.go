@@ -0,0 +1,139 @@
+package consumers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"reservas-api/internal/services"
+	"strconv"
+
+	"github.com/streadway/amqp"
+)
+
+type UserEventsConsumer struct {
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	service services.ReservaService
+}
+
+// NewUserEventsConsumer inicializa la conexión y el canal
+func NewUserEventsConsumer(rabbitURL string, reservaService services.ReservaService) (*UserEventsConsumer, error) {
+	conn, err := amqp.Dial(rabbitURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open RabbitMQ channel: %w", err)
+	}
+
+	return &UserEventsConsumer{
+		conn:    conn,
+		channel: ch,
+		service: reservaService,
+	}, nil
+}
+
+// Listen suscribe el consumidor a los eventos user.* de users-api
+func (c *UserEventsConsumer) Listen(exchangeName, queueName string) error {
+	err := c.channel.ExchangeDeclare(
+		exchangeName, // nombre del exchange
+		"topic",      // tipo de exchange
+		true,         // durable
+		false,        // auto-deleted
+		false,        // internal
+		false,        // no-wait
+		nil,          // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare exchange: %w", err)
+	}
+
+	q, err := c.channel.QueueDeclare(
+		queueName, // nombre de la cola
+		true,      // durable
+		false,     // delete when unused
+		false,     // exclusive
+		false,     // no-wait
+		nil,       // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare queue: %w", err)
+	}
+
+	err = c.channel.QueueBind(
+		q.Name,
+		"user.*",     // routing key (pattern de mensajes)
+		exchangeName, // exchange
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to bind queue: %w", err)
+	}
+
+	msgs, err := c.channel.Consume(
+		q.Name, // cola
+		"",     // consumer tag
+		false,  // auto-ack desactivado para no perder eventos si Mongo falla
+		false,  // exclusive
+		false,  // no-local
+		false,  // no-wait
+		nil,    // args
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register consumer: %w", err)
+	}
+
+	log.Printf("[RabbitMQ] Listening on queue: %s (exchange: %s)", q.Name, exchangeName)
+
+	go func() {
+		for d := range msgs {
+			log.Printf("[RabbitMQ] Received message: %s", d.RoutingKey)
+
+			var event struct {
+				Type     string `json:"type"`
+				Entity   string `json:"entity"`
+				EntityID string `json:"entity_id"`
+			}
+
+			if err := json.Unmarshal(d.Body, &event); err != nil {
+				log.Printf("[RabbitMQ] Error decoding message: %v", err)
+				_ = d.Nack(false, false) // mensaje inválido: no tiene sentido reencolarlo
+				continue
+			}
+
+			userID, err := strconv.ParseUint(event.EntityID, 10, 32)
+			if event.Entity != "user" || err != nil {
+				_ = d.Ack(false)
+				continue
+			}
+
+			if err := c.service.HandleUserEvent(event.Type, uint(userID)); err != nil {
+				log.Printf("[Reservas] Failed to process user event (%s): %v. Requeueing...", event.Type, err)
+				if err := d.Nack(false, true); err != nil {
+					log.Printf("[Reservas] Failed to nack message: %v", err)
+				}
+				continue
+			}
+
+			if err := d.Ack(false); err != nil {
+				log.Printf("[Reservas] Failed to ack message: %v", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Close cierra la conexión y canal
+func (c *UserEventsConsumer) Close() {
+	if c.channel != nil {
+		_ = c.channel.Close()
+	}
+	if c.conn != nil {
+		_ = c.conn.Close()
+	}
+}
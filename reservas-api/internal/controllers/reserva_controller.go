@@ -1,10 +1,15 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"reservas-api/internal/dto"
 	"reservas-api/internal/services"
+	"reservas-api/internal/utils"
+	"shared/auth"
+	"shared/schedule"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -31,14 +36,17 @@ func (ctrl *ReservaController) Create(c *gin.Context) {
 		return
 	}
 
-	token := c.GetHeader("Authorization")
-	reserva, err := ctrl.service.Create(&req, token)
+	claims, _ := auth.ClaimsFromContext(c)
+	reserva, err := ctrl.service.Create(&req, claims)
 
 	if err != nil {
 		statusCode := http.StatusInternalServerError
+		var slotErr *schedule.SlotError
 		if err.Error() == "user validation failed" ||
 			err.Error() == "cancha validation failed" ||
-			err.Error() == "cancha not available for the selected time slot" {
+			err.Error() == "cancha not available for the selected time slot" ||
+			errors.Is(err, services.ErrCanchaClosed) ||
+			errors.As(err, &slotErr) {
 			statusCode = http.StatusBadRequest
 		}
 
@@ -57,11 +65,14 @@ func (ctrl *ReservaController) Create(c *gin.Context) {
 func (ctrl *ReservaController) GetByID(c *gin.Context) {
 	id := c.Param("id")
 
-	reserva, err := ctrl.service.GetByID(id)
+	claims, _ := auth.ClaimsFromContext(c)
+	reserva, err := ctrl.service.GetByID(id, claims)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
 		if err.Error() == "reserva not found" || err.Error() == "invalid ID format" {
 			statusCode = http.StatusNotFound
+		} else if err.Error() == "forbidden" {
+			statusCode = http.StatusForbidden
 		}
 
 		c.JSON(statusCode, dto.ErrorResponse{
@@ -131,7 +142,7 @@ func (ctrl *ReservaController) GetByCanchaID(c *gin.Context) {
 	c.JSON(http.StatusOK, reservas)
 }
 
-// DeleteByCanchaID elimina todas las reservas de una cancha
+// DeleteByCanchaID elimina todas las reservas de una cancha (canchas-api o canchas:manage)
 // DELETE /reservas/cancha/:cancha_id
 func (ctrl *ReservaController) DeleteByCanchaID(c *gin.Context) {
 	canchaID := c.Param("cancha_id")
@@ -151,6 +162,46 @@ func (ctrl *ReservaController) DeleteByCanchaID(c *gin.Context) {
 	})
 }
 
+// FindConflicts lista las reservas activas que chocan con un cierre, sin modificarlas
+// POST /reservas/conflicts
+func (ctrl *ReservaController) FindConflicts(c *gin.Context) {
+	ctrl.conflicts(c, false)
+}
+
+// CancelConflicts cancela las reservas que chocan con un cierre y todavía no empezaron
+// POST /reservas/conflicts/cancel
+func (ctrl *ReservaController) CancelConflicts(c *gin.Context) {
+	ctrl.conflicts(c, true)
+}
+
+// conflicts resuelve FindConflicts y CancelConflicts
+func (ctrl *ReservaController) conflicts(c *gin.Context, cancel bool) {
+	var req dto.ConflictsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	conflicts, err := ctrl.service.FindConflicts(&req, cancel)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "end must be after start" {
+			statusCode = http.StatusBadRequest
+		}
+
+		c.JSON(statusCode, dto.ErrorResponse{
+			Error:   "Failed to find conflicts",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, conflicts)
+}
+
 // Update actualiza una reserva existente
 // PUT /reservas/:id
 func (ctrl *ReservaController) Update(c *gin.Context) {
@@ -165,13 +216,23 @@ func (ctrl *ReservaController) Update(c *gin.Context) {
 		return
 	}
 
-	reserva, err := ctrl.service.Update(id, &req)
+	claims, _ := auth.ClaimsFromContext(c)
+	reserva, err := ctrl.service.Update(id, &req, claims)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
+		var slotErr *schedule.SlotError
 		if err.Error() == "reserva not found" || err.Error() == "invalid ID format" {
 			statusCode = http.StatusNotFound
+		} else if err.Error() == "forbidden" {
+			statusCode = http.StatusForbidden
 		} else if err.Error() == "cannot update a cancelled reservation" ||
-			err.Error() == "cancha not available for the selected time slot" {
+			err.Error() == "cannot confirm a cancelled reservation" ||
+			err.Error() == "cancha not available for the selected time slot" ||
+			errors.Is(err, services.ErrStatusChange) ||
+			errors.Is(err, services.ErrWaitlistInactive) ||
+			errors.Is(err, services.ErrHoldExpired) ||
+			errors.Is(err, services.ErrCanchaClosed) ||
+			errors.As(err, &slotErr) {
 			statusCode = http.StatusBadRequest
 		}
 
@@ -190,11 +251,14 @@ func (ctrl *ReservaController) Update(c *gin.Context) {
 func (ctrl *ReservaController) Cancel(c *gin.Context) {
 	id := c.Param("id")
 
-	if err := ctrl.service.Cancel(id); err != nil {
+	claims, _ := auth.ClaimsFromContext(c)
+	if err := ctrl.service.Cancel(id, claims); err != nil {
 		statusCode := http.StatusInternalServerError
 		if err.Error() == "reserva not found" || err.Error() == "invalid ID format" {
 			statusCode = http.StatusNotFound
-		} else if err.Error() == "reservation already cancelled" {
+		} else if err.Error() == "forbidden" {
+			statusCode = http.StatusForbidden
+		} else if err.Error() == "reservation already cancelled" || errors.Is(err, services.ErrHoldExpired) {
 			statusCode = http.StatusBadRequest
 		}
 
@@ -209,3 +273,239 @@ func (ctrl *ReservaController) Cancel(c *gin.Context) {
 		"message": "Reserva cancelled successfully",
 	})
 }
+
+// Confirm confirma una reserva pendiente (requiere reservas:confirm, o ser el dueño de un turno retenido)
+// POST /reservas/:id/confirm
+func (ctrl *ReservaController) Confirm(c *gin.Context) {
+	claims, _ := auth.ClaimsFromContext(c)
+	reserva, err := ctrl.service.Confirm(c.Param("id"), claims)
+	if err != nil {
+		c.JSON(reservaStateStatus(err), dto.ErrorResponse{
+			Error:   "Failed to confirm reserva",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, reserva)
+}
+
+// CheckIn registra la llegada del jugador (requiere reservas:check_in)
+// POST /reservas/:id/check-in
+func (ctrl *ReservaController) CheckIn(c *gin.Context) {
+	reserva, err := ctrl.service.CheckIn(c.Param("id"))
+	if err != nil {
+		c.JSON(reservaStateStatus(err), dto.ErrorResponse{
+			Error:   "Failed to check in reserva",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, reserva)
+}
+
+// CreateRecurring crea una serie de reservas semanales
+// POST /reservas/recurring
+func (ctrl *ReservaController) CreateRecurring(c *gin.Context) {
+	var req dto.CreateRecurringRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	claims, _ := auth.ClaimsFromContext(c)
+	series, err := ctrl.service.CreateRecurring(&req, claims)
+	if err != nil {
+		c.JSON(seriesStatus(err), dto.ErrorResponse{
+			Error:   "Failed to create recurring reservas",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, series)
+}
+
+// GetSeries obtiene las reservas de una serie
+// GET /reservas/series/:series_id
+func (ctrl *ReservaController) GetSeries(c *gin.Context) {
+	claims, _ := auth.ClaimsFromContext(c)
+	series, err := ctrl.service.GetSeries(c.Param("series_id"), claims)
+	if err != nil {
+		c.JSON(seriesStatus(err), dto.ErrorResponse{
+			Error:   "Failed to get series",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+// UpdateSeries cambia el horario de las reservas restantes de una serie
+// PUT /reservas/series/:series_id
+func (ctrl *ReservaController) UpdateSeries(c *gin.Context) {
+	var req dto.UpdateSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	claims, _ := auth.ClaimsFromContext(c)
+	series, err := ctrl.service.UpdateSeries(c.Param("series_id"), &req, claims)
+	if err != nil {
+		c.JSON(seriesStatus(err), dto.ErrorResponse{
+			Error:   "Failed to update series",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+// CancelSeries cancela las reservas restantes de una serie (desde ?from=YYYY-MM-DD si se indica)
+// DELETE /reservas/series/:series_id
+func (ctrl *ReservaController) CancelSeries(c *gin.Context) {
+	claims, _ := auth.ClaimsFromContext(c)
+	cancelled, err := ctrl.service.CancelSeries(c.Param("series_id"), c.Query("from"), claims)
+	if err != nil {
+		c.JSON(seriesStatus(err), dto.ErrorResponse{
+			Error:   "Failed to cancel series",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Series cancelled successfully",
+		"cancelled": cancelled,
+	})
+}
+
+// seriesStatus mapea los errores de las series recurrentes a un código HTTP
+func seriesStatus(err error) int {
+	var slotErr *schedule.SlotError
+	switch {
+	case err.Error() == "series not found":
+		return http.StatusNotFound
+	case err.Error() == "forbidden":
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrSeriesConflicts):
+		return http.StatusConflict
+	case err.Error() == "user validation failed" ||
+		strings.HasPrefix(err.Error(), "cancha validation failed") ||
+		strings.HasPrefix(err.Error(), "invalid date format") ||
+		err.Error() == "cannot make reservations for past dates" ||
+		errors.Is(err, utils.ErrInvalidRecurrence) ||
+		errors.As(err, &slotErr):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// JoinWaitlist anota al usuario en la lista de espera de un turno ocupado
+// POST /reservas/waitlist
+func (ctrl *ReservaController) JoinWaitlist(c *gin.Context) {
+	var req dto.JoinWaitlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	claims, _ := auth.ClaimsFromContext(c)
+	entry, err := ctrl.service.JoinWaitlist(&req, claims)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		var slotErr *schedule.SlotError
+		if errors.Is(err, services.ErrSlotAvailable) || errors.Is(err, services.ErrAlreadyWaiting) {
+			statusCode = http.StatusConflict
+		} else if err.Error() == "user validation failed" ||
+			strings.HasPrefix(err.Error(), "cancha validation failed") ||
+			strings.HasPrefix(err.Error(), "invalid date format") ||
+			err.Error() == "cannot make reservations for past dates" ||
+			errors.Is(err, services.ErrCanchaClosed) ||
+			errors.As(err, &slotErr) {
+			statusCode = http.StatusBadRequest
+		}
+
+		c.JSON(statusCode, dto.ErrorResponse{
+			Error:   "Failed to join waitlist",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// GetWaitlists lista las entradas de la lista de espera del usuario
+// GET /reservas/waitlist
+func (ctrl *ReservaController) GetWaitlists(c *gin.Context) {
+	claims, _ := auth.ClaimsFromContext(c)
+	entries, err := ctrl.service.GetWaitlists(claims)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error:   "Failed to get waitlists",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// LeaveWaitlist saca al usuario de una lista de espera (si tenía el turno ofrecido lo rechaza)
+// DELETE /reservas/waitlist/:id
+func (ctrl *ReservaController) LeaveWaitlist(c *gin.Context) {
+	claims, _ := auth.ClaimsFromContext(c)
+	if err := ctrl.service.LeaveWaitlist(c.Param("id"), claims); err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "waitlist entry not found" || err.Error() == "invalid ID format" {
+			statusCode = http.StatusNotFound
+		} else if err.Error() == "forbidden" {
+			statusCode = http.StatusForbidden
+		} else if errors.Is(err, services.ErrWaitlistInactive) {
+			statusCode = http.StatusConflict
+		}
+
+		c.JSON(statusCode, dto.ErrorResponse{
+			Error:   "Failed to leave waitlist",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Left waitlist successfully",
+	})
+}
+
+// reservaStateStatus mapea los errores de confirmación y check-in a un código HTTP
+func reservaStateStatus(err error) int {
+	if errors.Is(err, services.ErrHoldExpired) || errors.Is(err, services.ErrWaitlistInactive) {
+		return http.StatusConflict
+	}
+	switch err.Error() {
+	case "reserva not found", "invalid ID format":
+		return http.StatusNotFound
+	case "forbidden":
+		return http.StatusForbidden
+	case "cannot confirm a cancelled reservation", "reservation already confirmed",
+		"only confirmed reservations can be checked in", "reservation already checked in":
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -7,19 +7,36 @@ import (
 )
 
 type Reserva struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	CanchaID   string             `bson:"cancha_id" json:"cancha_id"`     // ID de la cancha (string ObjectID)
-	UserID     uint               `bson:"user_id" json:"user_id"`         // ID del usuario (MySQL)
-	Date       time.Time          `bson:"date" json:"date"`               // Fecha de la reserva (YYYY-MM-DD)
-	StartTime  string             `bson:"start_time" json:"start_time"`   // Hora inicio (HH:MM)
-	EndTime    string             `bson:"end_time" json:"end_time"`       // Hora fin (HH:MM)
-	Duration   int                `bson:"duration" json:"duration"`       // Duración en minutos
-	Status     string             `bson:"status" json:"status"`           // "pending", "confirmed", "cancelled"
-	TotalPrice float64            `bson:"total_price" json:"total_price"` // Precio total calculado
-	CanchaName string             `bson:"cancha_name" json:"cancha_name"` // Nombre de la cancha (cache)
-	UserName   string             `bson:"user_name" json:"user_name"`     // Nombre del usuario (cache)
-	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	CanchaID      string             `bson:"cancha_id" json:"cancha_id"`                                 // ID de la cancha (string ObjectID)
+	UserID        uint               `bson:"user_id" json:"user_id"`                                     // ID del usuario (MySQL)
+	Date          time.Time          `bson:"date" json:"date"`                                           // Fecha de la reserva (YYYY-MM-DD)
+	StartTime     string             `bson:"start_time" json:"start_time"`                               // Hora inicio (HH:MM)
+	EndTime       string             `bson:"end_time" json:"end_time"`                                   // Hora fin (HH:MM)
+	Duration      int                `bson:"duration" json:"duration"`                                   // Duración en minutos
+	Status        string             `bson:"status" json:"status"`                                       // "pending", "confirmed", "cancelled", "expired"
+	TotalPrice    float64            `bson:"total_price" json:"total_price"`                             // Precio total calculado
+	PriceItems    []PriceItem        `bson:"price_items,omitempty" json:"price_items,omitempty"`         // Desglose de la cotización de canchas-api
+	MemberPrice   bool               `bson:"member_price" json:"member_price"`                           // Se cotizó con tarifa de socio
+	CanchaName    string             `bson:"cancha_name" json:"cancha_name"`                             // Nombre de la cancha (cache)
+	UserName      string             `bson:"user_name" json:"user_name"`                                 // Nombre del usuario (cache)
+	SeriesID      string             `bson:"series_id,omitempty" json:"series_id,omitempty"`             // Serie recurrente a la que pertenece
+	CheckedInAt   *time.Time         `bson:"checked_in_at,omitempty" json:"checked_in_at,omitempty"`     // Llegada registrada por recepción
+	HoldExpiresAt *time.Time         `bson:"hold_expires_at,omitempty" json:"hold_expires_at,omitempty"` // Vencimiento del turno retenido mientras se paga o confirma
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// PriceItem es una línea del desglose del precio de una reserva
+type PriceItem struct {
+	Kind   string  `bson:"kind" json:"kind"` // "base", "rule", "discount" o "surcharge"
+	Name   string  `bson:"name" json:"name"`
+	Amount float64 `bson:"amount" json:"amount"`
+}
+
+// HoldExpired indica si la reserva es un turno retenido (pending) cuyo plazo ya venció
+func (r *Reserva) HoldExpired(now time.Time) bool {
+	return r.Status == "pending" && r.HoldExpiresAt != nil && !r.HoldExpiresAt.After(now)
 }
 
 // CollectionName retorna el nombre de la colección en MongoDB
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Estados de una entrada de la lista de espera
+const (
+	WaitlistWaiting  = "waiting"  // esperando que se libere el turno
+	WaitlistOffered  = "offered"  // se le retuvo el turno liberado y tiene que confirmarlo
+	WaitlistAccepted = "accepted" // confirmó el turno ofrecido
+	WaitlistExpired  = "expired"  // no confirmó el turno ofrecido a tiempo
+	WaitlistLeft     = "left"     // salió de la lista (o rechazó el turno ofrecido)
+)
+
+// WaitlistEntry es un usuario esperando que se libere un turno de una cancha.
+// Las entradas se atienden por orden de llegada (FIFO).
+type WaitlistEntry struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	CanchaID    string             `bson:"cancha_id" json:"cancha_id"`
+	CanchaName  string             `bson:"cancha_name" json:"cancha_name"` // Nombre de la cancha (cache)
+	UserID      uint               `bson:"user_id" json:"user_id"`
+	UserName    string             `bson:"user_name" json:"user_name"` // Nombre del usuario (cache)
+	Date        time.Time          `bson:"date" json:"date"`           // Fecha de la reserva (YYYY-MM-DD)
+	StartTime   string             `bson:"start_time" json:"start_time"`
+	EndTime     string             `bson:"end_time" json:"end_time"`
+	Duration    int                `bson:"duration" json:"duration"`
+	MemberPrice bool               `bson:"member_price" json:"member_price"`                 // La oferta se cotiza con tarifa de socio
+	Status      string             `bson:"status" json:"status"`                             // "waiting", "offered", "accepted", "expired", "left"
+	ReservaID   string             `bson:"reserva_id,omitempty" json:"reserva_id,omitempty"` // Turno retenido que se le ofreció
+	OfferedAt   *time.Time         `bson:"offered_at,omitempty" json:"offered_at,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// CollectionName retorna el nombre de la colección en MongoDB
+func (WaitlistEntry) CollectionName() string {
+	return "waitlist"
+}
@@ -4,13 +4,14 @@ import (
 	"time"
 )
 
-// CreateReservaRequest - DTO para crear una reserva
+// CreateReservaRequest - DTO para crear una reserva.
+// El usuario se toma siempre de los claims del token, nunca del body.
 type CreateReservaRequest struct {
 	CanchaID  string `json:"cancha_id" binding:"required"`
-	UserID    uint   `json:"user_id" binding:"required"`
 	Date      string `json:"date" binding:"required"`             // Formato: "2025-11-15"
 	StartTime string `json:"start_time" binding:"required,len=5"` // Formato: "18:00"
 	EndTime   string `json:"end_time" binding:"required,len=5"`   // Formato: "19:00"
+	Hold      bool   `json:"hold"`                                // Retener el turno como pending hasta confirmarlo
 }
 
 // UpdateReservaRequest - DTO para actualizar una reserva
@@ -21,21 +22,73 @@ type UpdateReservaRequest struct {
 	Status    string `json:"status" binding:"omitempty,oneof=pending confirmed cancelled"`
 }
 
+// RecurrenceRule - DTO con la regla de repetición de una serie (al estilo RRULE semanal)
+type RecurrenceRule struct {
+	Frequency string `json:"frequency" binding:"required,oneof=weekly"`
+	Interval  int    `json:"interval" binding:"omitempty,min=1"` // cada cuántas semanas (1 por defecto)
+	Until     string `json:"until"`                              // Formato: "2025-12-31", inclusive
+	Count     int    `json:"count" binding:"omitempty,min=1"`
+}
+
+// CreateRecurringRequest - DTO para crear una serie de reservas semanales.
+// Sin SkipConflicts se crean todas o ninguna; con SkipConflicts se saltean las que no se pueden reservar.
+type CreateRecurringRequest struct {
+	CanchaID      string         `json:"cancha_id" binding:"required"`
+	Date          string         `json:"date" binding:"required"` // Primera fecha de la serie: "2025-11-18"
+	StartTime     string         `json:"start_time" binding:"required,len=5"`
+	EndTime       string         `json:"end_time" binding:"required,len=5"`
+	Recurrence    RecurrenceRule `json:"recurrence" binding:"required"`
+	SkipConflicts bool           `json:"skip_conflicts"`
+}
+
+// UpdateSeriesRequest - DTO para cambiar el horario de una serie.
+// Aplica a las reservas activas que todavía no empezaron, desde From si se indica.
+type UpdateSeriesRequest struct {
+	From      string `json:"from"` // Formato: "2025-11-25" (vacío = toda la serie)
+	StartTime string `json:"start_time" binding:"required,len=5"`
+	EndTime   string `json:"end_time" binding:"required,len=5"`
+}
+
+// SkippedOccurrence - fecha de una serie que no se reservó o no se modificó, con el motivo
+type SkippedOccurrence struct {
+	Date   string `json:"date"`
+	Reason string `json:"reason"`
+}
+
+// SeriesResponse - DTO con las reservas de una serie
+type SeriesResponse struct {
+	SeriesID string              `json:"series_id"`
+	Reservas []ReservaResponse   `json:"reservas"`
+	Skipped  []SkippedOccurrence `json:"skipped"`
+}
+
 // ReservaResponse - DTO para respuesta de reserva
 type ReservaResponse struct {
-	ID         string    `json:"id"`
-	CanchaID   string    `json:"cancha_id"`
-	CanchaName string    `json:"cancha_name"`
-	UserID     uint      `json:"user_id"`
-	UserName   string    `json:"user_name"`
-	Date       string    `json:"date"` // Formato: "2025-11-15"
-	StartTime  string    `json:"start_time"`
-	EndTime    string    `json:"end_time"`
-	Duration   int       `json:"duration"`
-	Status     string    `json:"status"`
-	TotalPrice float64   `json:"total_price"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID            string      `json:"id"`
+	CanchaID      string      `json:"cancha_id"`
+	CanchaName    string      `json:"cancha_name"`
+	UserID        uint        `json:"user_id"`
+	UserName      string      `json:"user_name"`
+	Date          string      `json:"date"` // Formato: "2025-11-15"
+	StartTime     string      `json:"start_time"`
+	EndTime       string      `json:"end_time"`
+	Duration      int         `json:"duration"`
+	Status        string      `json:"status"`
+	TotalPrice    float64     `json:"total_price"`
+	PriceItems    []PriceItem `json:"price_items"`
+	MemberPrice   bool        `json:"member_price"`
+	SeriesID      string      `json:"series_id,omitempty"`
+	CheckedInAt   *time.Time  `json:"checked_in_at,omitempty"`
+	HoldExpiresAt *time.Time  `json:"hold_expires_at,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
+
+// PriceItem - línea del desglose del precio de una reserva
+type PriceItem struct {
+	Kind   string  `json:"kind"`
+	Name   string  `json:"name"`
+	Amount float64 `json:"amount"`
 }
 
 // ReservasListResponse - DTO para lista de reservas
@@ -44,6 +97,63 @@ type ReservasListResponse struct {
 	Total    int64             `json:"total"`
 }
 
+// ConflictsRequest - DTO con el que canchas-api pide las reservas que chocan con un cierre
+type ConflictsRequest struct {
+	CanchaIDs []string  `json:"cancha_ids"` // vacío = todas las canchas
+	Start     time.Time `json:"start" binding:"required"`
+	End       time.Time `json:"end" binding:"required"`
+}
+
+// ConflictsResponse - DTO con las reservas activas que se superponen con un cierre
+type ConflictsResponse struct {
+	Reservas  []ReservaResponse `json:"reservas"`
+	Cancelled int               `json:"cancelled"`
+}
+
+// JoinWaitlistRequest - DTO para anotarse en la lista de espera de un turno ocupado
+type JoinWaitlistRequest struct {
+	CanchaID  string `json:"cancha_id" binding:"required"`
+	Date      string `json:"date" binding:"required"` // Formato: "2025-11-15"
+	StartTime string `json:"start_time" binding:"required,len=5"`
+	EndTime   string `json:"end_time" binding:"required,len=5"`
+}
+
+// WaitlistEntryResponse - DTO para respuesta de una entrada de la lista de espera
+type WaitlistEntryResponse struct {
+	ID            string     `json:"id"`
+	CanchaID      string     `json:"cancha_id"`
+	CanchaName    string     `json:"cancha_name"`
+	UserID        uint       `json:"user_id"`
+	Date          string     `json:"date"`
+	StartTime     string     `json:"start_time"`
+	EndTime       string     `json:"end_time"`
+	Status        string     `json:"status"`
+	Position      int        `json:"position,omitempty"`        // lugar en la fila mientras espera (1 = el próximo)
+	ReservaID     string     `json:"reserva_id,omitempty"`      // turno retenido que se le ofreció
+	HoldExpiresAt *time.Time `json:"hold_expires_at,omitempty"` // hasta cuándo puede confirmar el turno ofrecido
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// WaitlistListResponse - DTO para lista de entradas de la lista de espera
+type WaitlistListResponse struct {
+	Entries []WaitlistEntryResponse `json:"entries"`
+	Total   int64                   `json:"total"`
+}
+
+// WaitlistOfferEvent - datos del evento reserva.waitlist_offer: se liberó el turno que esperaba el usuario
+// y se le retuvo hasta HoldExpiresAt (users-api le avisa por email)
+type WaitlistOfferEvent struct {
+	EntryID       string    `json:"entry_id"`
+	UserID        uint      `json:"user_id"`
+	CanchaID      string    `json:"cancha_id"`
+	CanchaName    string    `json:"cancha_name"`
+	Date          string    `json:"date"`
+	StartTime     string    `json:"start_time"`
+	EndTime       string    `json:"end_time"`
+	ReservaID     string    `json:"reserva_id"`
+	HoldExpiresAt time.Time `json:"hold_expires_at"`
+}
+
 // ValidationResult - Resultado de validación concurrente
 type ValidationResult struct {
 	Valid   bool
@@ -3,6 +3,7 @@ package repositories
 import (
 	"context"
 	"errors"
+	"log"
 	"reservas-api/internal/domain"
 	"reservas-api/internal/utils"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type ReservaRepository interface {
@@ -17,25 +19,66 @@ type ReservaRepository interface {
 	GetByID(id string) (*domain.Reserva, error)
 	GetAll() ([]domain.Reserva, error)
 	GetByUserID(userID uint) ([]domain.Reserva, error)
+	GetActiveByUserIDFrom(userID uint, from time.Time) ([]domain.Reserva, error)
+	AnonymizeUser(userID uint, userName string) (int64, error)
 	GetByCanchaID(canchaID string) ([]domain.Reserva, error)
+	GetBySeriesID(seriesID string) ([]domain.Reserva, error)
+	GetActiveByCanchasBetween(canchaIDs []string, from, to time.Time) ([]domain.Reserva, error)
 	DeleteByCanchaID(canchaID string) (int64, error)
 	Update(id string, reserva *domain.Reserva) error
 	Delete(id string) error
+	Purge(id string) error
 	CheckAvailability(canchaID string, date time.Time, startTime, endTime string) (bool, error)
+	BackfillSlotLocks(from time.Time) error
+	ExpireHolds(now time.Time) ([]domain.Reserva, error)
+}
+
+// inactiveStatuses son los estados de las reservas que ya no ocupan su turno
+var inactiveStatuses = bson.A{"cancelled", "expired"}
+
+// activeFilter agrega a filter las condiciones de una reserva que ocupa su turno:
+// ni cancelada ni vencida, y si es un turno retenido que todavía no haya vencido su plazo
+func activeFilter(filter bson.M, now time.Time) bson.M {
+	filter["status"] = bson.M{"$nin": inactiveStatuses}
+	filter["$nor"] = bson.A{bson.M{"status": "pending", "hold_expires_at": bson.M{"$lte": now}}}
+	return filter
 }
 
 type reservaRepository struct {
 	collection *mongo.Collection
+	locks      *mongo.Collection
 }
 
 // NewReservaRepository crea una nueva instancia del repositorio
 func NewReservaRepository(db *mongo.Database) ReservaRepository {
+	locks := db.Collection(slotLocksCollectionName())
+	ensureSlotLockIndexes(locks)
+
+	collection := db.Collection(domain.Reserva{}.CollectionName())
+	ensureSeriesIndex(collection)
+
 	return &reservaRepository{
-		collection: db.Collection(domain.Reserva{}.CollectionName()),
+		collection: collection,
+		locks:      locks,
 	}
 }
 
-// Create crea una nueva reserva en MongoDB
+// ensureSeriesIndex crea el índice con el que se buscan las reservas de una serie recurrente
+func ensureSeriesIndex(coll *mongo.Collection) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "series_id", Value: 1}, {Key: "date", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	}
+	if _, err := coll.Indexes().CreateOne(ctx, index); err != nil {
+		log.Printf("Warning: failed to create series index: %v", err)
+	}
+}
+
+// Create crea una nueva reserva en MongoDB si ninguna otra reserva activa ocupa su turno.
+// Primero toma los bloqueos del turno y recién después inserta la reserva; retorna ErrSlotUnavailable si llegó tarde.
 func (r *reservaRepository) Create(reserva *domain.Reserva) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -44,8 +87,19 @@ func (r *reservaRepository) Create(reserva *domain.Reserva) error {
 	reserva.CreatedAt = time.Now()
 	reserva.UpdatedAt = time.Now()
 
-	_, err := r.collection.InsertOne(ctx, reserva)
-	return err
+	if reserva.Status != "cancelled" && reserva.Status != "expired" {
+		if err := r.acquireSlotLocks(ctx, reserva); err != nil {
+			return err
+		}
+	}
+
+	if _, err := r.collection.InsertOne(ctx, reserva); err != nil {
+		if releaseErr := r.releaseSlotLocks(ctx, reserva.ID); releaseErr != nil {
+			log.Printf("Warning: failed to release slot locks of reserva %s: %v", reserva.ID.Hex(), releaseErr)
+		}
+		return err
+	}
+	return nil
 }
 
 // GetByID obtiene una reserva por su ID
@@ -108,6 +162,49 @@ func (r *reservaRepository) GetByUserID(userID uint) ([]domain.Reserva, error) {
 	return reservas, nil
 }
 
+// GetActiveByUserIDFrom obtiene las reservas activas de un usuario con fecha desde from
+func (r *reservaRepository) GetActiveByUserIDFrom(userID uint, from time.Time) ([]domain.Reserva, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := activeFilter(bson.M{
+		"user_id": userID,
+		"date":    bson.M{"$gte": from},
+	}, time.Now())
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var reservas []domain.Reserva
+	if err := cursor.All(ctx, &reservas); err != nil {
+		return nil, err
+	}
+
+	return reservas, nil
+}
+
+// AnonymizeUser reemplaza el nombre cacheado del usuario en todas sus reservas
+func (r *reservaRepository) AnonymizeUser(userID uint, userName string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{
+			"user_name":  userName,
+			"updated_at": time.Now(),
+		},
+	}
+
+	result, err := r.collection.UpdateMany(ctx, bson.M{"user_id": userID}, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
 // GetByCanchaID obtiene todas las reservas de una cancha
 func (r *reservaRepository) GetByCanchaID(canchaID string) ([]domain.Reserva, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -127,6 +224,51 @@ func (r *reservaRepository) GetByCanchaID(canchaID string) ([]domain.Reserva, er
 	return reservas, nil
 }
 
+// GetBySeriesID obtiene las reservas de una serie recurrente ordenadas por fecha
+func (r *reservaRepository) GetBySeriesID(seriesID string) ([]domain.Reserva, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"series_id": seriesID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var reservas []domain.Reserva
+	if err := cursor.All(ctx, &reservas); err != nil {
+		return nil, err
+	}
+
+	return reservas, nil
+}
+
+// GetActiveByCanchasBetween obtiene las reservas activas de las canchas indicadas
+// (todas si canchaIDs está vacío) con fecha entre from y to inclusive
+func (r *reservaRepository) GetActiveByCanchasBetween(canchaIDs []string, from, to time.Time) ([]domain.Reserva, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := activeFilter(bson.M{"date": bson.M{"$gte": from, "$lte": to}}, time.Now())
+	if len(canchaIDs) > 0 {
+		filter["cancha_id"] = bson.M{"$in": canchaIDs}
+	}
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var reservas []domain.Reserva
+	if err := cursor.All(ctx, &reservas); err != nil {
+		return nil, err
+	}
+
+	return reservas, nil
+}
+
 // DeleteByCanchaID elimina todas las reservas asociadas a una cancha
 func (r *reservaRepository) DeleteByCanchaID(canchaID string) (int64, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -136,6 +278,9 @@ func (r *reservaRepository) DeleteByCanchaID(canchaID string) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
+	if _, err := r.locks.DeleteMany(ctx, bson.M{"cancha_id": canchaID}); err != nil {
+		return result.DeletedCount, err
+	}
 	return result.DeletedCount, nil
 }
 
@@ -150,28 +295,43 @@ func (r *reservaRepository) Update(id string, reserva *domain.Reserva) error {
 	}
 
 	reserva.UpdatedAt = time.Now()
+	reserva.ID = objectID
+
+	// Mover los bloqueos al nuevo turno antes de guardarlo (o soltarlos si se cancela o vence)
+	if reserva.Status == "cancelled" || reserva.Status == "expired" {
+		if err := r.releaseSlotLocks(ctx, objectID); err != nil {
+			return err
+		}
+	} else if err := r.acquireSlotLocks(ctx, reserva); err != nil {
+		return err
+	}
 
 	update := bson.M{
 		"$set": bson.M{
-			"date":        reserva.Date,
-			"start_time":  reserva.StartTime,
-			"end_time":    reserva.EndTime,
-			"duration":    reserva.Duration,
-			"status":      reserva.Status,
-			"total_price": reserva.TotalPrice,
-			"updated_at":  reserva.UpdatedAt,
+			"date":            reserva.Date,
+			"start_time":      reserva.StartTime,
+			"end_time":        reserva.EndTime,
+			"duration":        reserva.Duration,
+			"status":          reserva.Status,
+			"total_price":     reserva.TotalPrice,
+			"price_items":     reserva.PriceItems,
+			"member_price":    reserva.MemberPrice,
+			"checked_in_at":   reserva.CheckedInAt,
+			"hold_expires_at": reserva.HoldExpiresAt,
+			"updated_at":      reserva.UpdatedAt,
 		},
 	}
 
 	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objectID}, update)
+	if err == nil && result.MatchedCount == 0 {
+		err = errors.New("reserva not found")
+	}
 	if err != nil {
+		// Los bloqueos ya se movieron: volverlos al turno que sigue guardado
+		r.restoreSlotLocks(objectID)
 		return err
 	}
 
-	if result.MatchedCount == 0 {
-		return errors.New("reserva not found")
-	}
-
 	return nil
 }
 
@@ -202,19 +362,84 @@ func (r *reservaRepository) Delete(id string) error {
 		return errors.New("reserva not found")
 	}
 
-	return nil
+	return r.releaseSlotLocks(ctx, objectID)
+}
+
+// Purge elimina definitivamente una reserva y suelta sus bloqueos.
+// A diferencia de Delete no la deja cancelada: deshace reservas que no debieron llegar a existir.
+func (r *reservaRepository) Purge(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid ID format")
+	}
+
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objectID})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return errors.New("reserva not found")
+	}
+
+	return r.releaseSlotLocks(ctx, objectID)
+}
+
+// ExpireHolds vence los turnos retenidos cuyo plazo terminó antes de now y suelta sus bloqueos.
+// Retorna las reservas que venció esta llamada (otra instancia o una confirmación pueden haber ganado).
+func (r *reservaRepository) ExpireHolds(now time.Time) ([]domain.Reserva, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{"status": "pending", "hold_expires_at": bson.M{"$lte": now}}
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var stale []domain.Reserva
+	if err := cursor.All(ctx, &stale); err != nil {
+		return nil, err
+	}
+
+	var expired []domain.Reserva
+	for _, reserva := range stale {
+		// La condición se repite en el update para no vencer una reserva confirmada mientras tanto
+		result, err := r.collection.UpdateOne(ctx,
+			bson.M{"_id": reserva.ID, "status": "pending", "hold_expires_at": bson.M{"$lte": now}},
+			bson.M{"$set": bson.M{"status": "expired", "updated_at": now}},
+		)
+		if err != nil {
+			return expired, err
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+		if err := r.releaseSlotLocks(ctx, reserva.ID); err != nil {
+			log.Printf("Warning: failed to release slot locks of expired reserva %s: %v", reserva.ID.Hex(), err)
+		}
+
+		reserva.Status = "expired"
+		reserva.UpdatedAt = now
+		expired = append(expired, reserva)
+	}
+	return expired, nil
 }
 
-// CheckAvailability verifica si hay conflicto de horarios para una cancha en una fecha específica
+// CheckAvailability verifica si hay conflicto de horarios para una cancha en una fecha específica.
+// Los turnos retenidos vigentes cuentan como ocupados; los vencidos no.
 func (r *reservaRepository) CheckAvailability(canchaID string, date time.Time, startTime, endTime string) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	filter := bson.M{
+	filter := activeFilter(bson.M{
 		"cancha_id": canchaID,
 		"date":      date,
-		"status":    bson.M{"$ne": "cancelled"},
-	}
+	}, time.Now())
 
 	cursor, err := r.collection.Find(ctx, filter)
 	if err != nil {
@@ -230,6 +455,10 @@ func (r *reservaRepository) CheckAvailability(canchaID string, date time.Time, s
 	if err != nil {
 		return false, err
 	}
+	if requestedEnd <= requestedStart {
+		// El turno termina justo en el corte del día (06:00 del día siguiente)
+		requestedEnd += 24 * 60
+	}
 
 	for cursor.Next(ctx) {
 		var existing domain.Reserva
@@ -249,6 +478,9 @@ func (r *reservaRepository) CheckAvailability(canchaID string, date time.Time, s
 				return false, err
 			}
 			existingEnd = normalizedEnd
+			if existingEnd <= existingStart {
+				existingEnd += 24 * 60
+			}
 		}
 
 		if utils.IntervalsOverlap(requestedStart, requestedEnd, existingStart, existingEnd) {
@@ -0,0 +1,267 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"reservas-api/internal/domain"
+	"reservas-api/internal/utils"
+	"shared/schedule"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrSlotUnavailable indica que otra reserva activa ocupa parte del turno
+var ErrSlotUnavailable = errors.New("cancha not available for the selected time slot")
+
+// slotLockRetention es cuánto se conservan los bloqueos de turnos ya pasados antes de que Mongo los borre
+const slotLockRetention = 7 * 24 * time.Hour
+
+// slotLock bloquea una celda de schedule.Granularity minutos de una cancha para una reserva.
+// El índice único (cancha_id, at) garantiza que dos reservas activas nunca compartan una celda,
+// aunque dos pedidos concurrentes pasen a la vez por la validación del servicio.
+type slotLock struct {
+	CanchaID  string             `bson:"cancha_id"`
+	At        time.Time          `bson:"at"` // inicio de la celda (fecha de la reserva + minutos del turno)
+	ReservaID primitive.ObjectID `bson:"reserva_id"`
+	ExpiresAt *time.Time         `bson:"expires_at,omitempty"` // vencimiento si la reserva es un turno retenido
+}
+
+func slotLocksCollectionName() string {
+	return "slot_locks"
+}
+
+// ensureSlotLockIndexes crea el índice único que hace atómica la reserva y el TTL que limpia bloqueos viejos
+func ensureSlotLockIndexes(coll *mongo.Collection) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "cancha_id", Value: 1}, {Key: "at", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "reserva_id", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(slotLockRetention.Seconds())),
+		},
+	}
+	if _, err := coll.Indexes().CreateMany(ctx, indexes); err != nil {
+		log.Printf("Warning: failed to create slot lock indexes: %v", err)
+	}
+}
+
+// slotCells retorna las celdas que ocupa la reserva
+func slotCells(reserva *domain.Reserva) ([]time.Time, error) {
+	duration := reserva.Duration
+	if duration == 0 {
+		start, err := utils.NormalizeSlotMinutes(reserva.StartTime)
+		if err != nil {
+			return nil, err
+		}
+		end, err := utils.NormalizeSlotMinutes(reserva.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		if end <= start {
+			end += 24 * 60
+		}
+		duration = end - start
+	}
+
+	start, end, err := utils.SlotInterval(reserva.Date, reserva.StartTime, duration)
+	if err != nil {
+		return nil, err
+	}
+
+	step := schedule.Granularity * time.Minute
+	var cells []time.Time
+	for at := start.Truncate(step); at.Before(end); at = at.Add(step) {
+		cells = append(cells, at.UTC())
+	}
+	return cells, nil
+}
+
+// lockExpiry retorna el vencimiento que llevan los bloqueos de la reserva (nil si no es un turno retenido)
+func lockExpiry(reserva *domain.Reserva) *time.Time {
+	if reserva.Status != "pending" {
+		return nil
+	}
+	return reserva.HoldExpiresAt
+}
+
+// acquireSlotLocks toma las celdas de la reserva que todavía no tiene.
+// Las celdas de turnos retenidos ya vencidos se liberan aunque el reaper todavía no haya pasado.
+// Si alguna está tomada por otra reserva deshace lo insertado y retorna ErrSlotUnavailable.
+func (r *reservaRepository) acquireSlotLocks(ctx context.Context, reserva *domain.Reserva) error {
+	cells, err := slotCells(reserva)
+	if err != nil {
+		return err
+	}
+
+	held, err := r.heldCells(ctx, reserva.ID)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := lockExpiry(reserva)
+	var docs []interface{}
+	var missing []time.Time
+	wanted := make(map[time.Time]bool, len(cells))
+	for _, at := range cells {
+		wanted[at] = true
+		if !held[at] {
+			docs = append(docs, slotLock{CanchaID: reserva.CanchaID, At: at, ReservaID: reserva.ID, ExpiresAt: expiresAt})
+			missing = append(missing, at)
+		}
+	}
+
+	if len(docs) > 0 {
+		err := r.insertSlotLocks(ctx, reserva.ID, docs, missing)
+		if errors.Is(err, ErrSlotUnavailable) {
+			// Reintentar una vez si alguna celda era de un turno retenido que ya venció
+			freed, freeErr := r.releaseExpiredHolds(ctx, reserva.CanchaID, missing)
+			if freeErr != nil {
+				return freeErr
+			}
+			if freed > 0 {
+				err = r.insertSlotLocks(ctx, reserva.ID, docs, missing)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Las celdas que ya tenía pasan a vencer con la reserva (o dejan de vencer si se confirmó)
+	if len(held) > 0 {
+		update := bson.M{"$unset": bson.M{"expires_at": ""}}
+		if expiresAt != nil {
+			update = bson.M{"$set": bson.M{"expires_at": expiresAt}}
+		}
+		if _, err := r.locks.UpdateMany(ctx, bson.M{"reserva_id": reserva.ID}, update); err != nil {
+			return err
+		}
+	}
+
+	// Soltar las celdas que dejó de ocupar (cambió de horario o de fecha)
+	var stale []time.Time
+	for at := range held {
+		if !wanted[at] {
+			stale = append(stale, at)
+		}
+	}
+	if len(stale) > 0 {
+		if _, err := r.locks.DeleteMany(ctx, bson.M{"reserva_id": reserva.ID, "at": bson.M{"$in": stale}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertSlotLocks inserta las celdas nuevas de la reserva; si falla deshace las que llegó a insertar
+func (r *reservaRepository) insertSlotLocks(ctx context.Context, reservaID primitive.ObjectID, docs []interface{}, cells []time.Time) error {
+	_, err := r.locks.InsertMany(ctx, docs)
+	if err == nil {
+		return nil
+	}
+
+	// Deshacer solo las celdas nuevas: las que ya tenía siguen siendo suyas
+	if _, cleanupErr := r.locks.DeleteMany(ctx, bson.M{"reserva_id": reservaID, "at": bson.M{"$in": cells}}); cleanupErr != nil {
+		log.Printf("Warning: failed to roll back slot locks of reserva %s: %v", reservaID.Hex(), cleanupErr)
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrSlotUnavailable
+	}
+	return err
+}
+
+// releaseExpiredHolds borra los bloqueos vencidos de turnos retenidos en las celdas indicadas
+func (r *reservaRepository) releaseExpiredHolds(ctx context.Context, canchaID string, cells []time.Time) (int64, error) {
+	result, err := r.locks.DeleteMany(ctx, bson.M{
+		"cancha_id":  canchaID,
+		"at":         bson.M{"$in": cells},
+		"expires_at": bson.M{"$lte": time.Now()},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// heldCells retorna las celdas que ya tiene tomadas la reserva
+func (r *reservaRepository) heldCells(ctx context.Context, reservaID primitive.ObjectID) (map[time.Time]bool, error) {
+	cursor, err := r.locks.Find(ctx, bson.M{"reserva_id": reservaID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var locks []slotLock
+	if err := cursor.All(ctx, &locks); err != nil {
+		return nil, err
+	}
+
+	held := make(map[time.Time]bool, len(locks))
+	for _, lock := range locks {
+		held[lock.At.UTC()] = true
+	}
+	return held, nil
+}
+
+// releaseSlotLocks suelta todas las celdas de la reserva
+func (r *reservaRepository) releaseSlotLocks(ctx context.Context, reservaID primitive.ObjectID) error {
+	_, err := r.locks.DeleteMany(ctx, bson.M{"reserva_id": reservaID})
+	return err
+}
+
+// restoreSlotLocks deja los bloqueos como corresponden a la reserva guardada, después de que
+// Update los movió o soltó y no pudo guardar el cambio. Si la reserva no existe suelta todo.
+// Usa su propio contexto porque el de Update puede haber vencido.
+func (r *reservaRepository) restoreSlotLocks(reservaID primitive.ObjectID) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var stored domain.Reserva
+	err := r.collection.FindOne(ctx, bson.M{"_id": reservaID}).Decode(&stored)
+	switch {
+	case err == mongo.ErrNoDocuments || (err == nil && (stored.Status == "cancelled" || stored.Status == "expired")):
+		err = r.releaseSlotLocks(ctx, reservaID)
+	case err == nil:
+		err = r.acquireSlotLocks(ctx, &stored)
+	}
+	if err != nil {
+		log.Printf("Warning: failed to restore slot locks of reserva %s: %v", reservaID.Hex(), err)
+	}
+}
+
+// BackfillSlotLocks crea los bloqueos de las reservas activas desde from que no los tengan
+// (reservas creadas antes de que existieran). Si dos reservas viejas se superponen,
+// se registra y la segunda queda sin bloquear sus celdas en conflicto.
+func (r *reservaRepository) BackfillSlotLocks(from time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	cursor, err := r.collection.Find(ctx, activeFilter(bson.M{"date": bson.M{"$gte": from}}, time.Now()))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var reservas []domain.Reserva
+	if err := cursor.All(ctx, &reservas); err != nil {
+		return err
+	}
+
+	for i := range reservas {
+		if err := r.acquireSlotLocks(ctx, &reservas[i]); err != nil {
+			log.Printf("Warning: failed to backfill slot locks of reserva %s: %v", reservas[i].ID.Hex(), err)
+		}
+	}
+	return nil
+}
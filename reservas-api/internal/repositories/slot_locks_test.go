@@ -0,0 +1,338 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"reservas-api/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestSlotCells(t *testing.T) {
+	date := time.Date(2030, 3, 5, 0, 0, 0, 0, time.UTC)
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2030, 3, 5+day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name    string
+		reserva domain.Reserva
+		first   time.Time
+		last    time.Time
+		count   int
+	}{
+		{"turno de una hora", domain.Reserva{StartTime: "18:00", Duration: 60}, at(0, 18, 0), at(0, 18, 55), 12},
+		{"turno de 90 minutos", domain.Reserva{StartTime: "18:30", Duration: 90}, at(0, 18, 30), at(0, 19, 55), 18},
+		{"inicio fuera de la grilla", domain.Reserva{StartTime: "18:02", Duration: 5}, at(0, 18, 0), at(0, 18, 5), 2},
+		{"duración desde el horario de fin", domain.Reserva{StartTime: "20:00", EndTime: "21:30"}, at(0, 20, 0), at(0, 21, 25), 18},
+		{"cruza la medianoche", domain.Reserva{StartTime: "23:30", EndTime: "00:30"}, at(0, 23, 30), at(1, 0, 25), 12},
+		{"madrugada del día siguiente", domain.Reserva{StartTime: "01:00", Duration: 60}, at(1, 1, 0), at(1, 1, 55), 12},
+		{"cruza el corte del día", domain.Reserva{StartTime: "05:30", EndTime: "06:30"}, at(1, 5, 30), at(1, 6, 25), 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.reserva.Date = date
+			cells, err := slotCells(&tt.reserva)
+			if err != nil {
+				t.Fatalf("no se esperaba error, llegó: %v", err)
+			}
+			if len(cells) != tt.count || !cells[0].Equal(tt.first) || !cells[len(cells)-1].Equal(tt.last) {
+				t.Fatalf("se esperaban %d celdas de %v a %v, llegaron %d: %v", tt.count, tt.first, tt.last, len(cells), cells)
+			}
+			for i := 1; i < len(cells); i++ {
+				if cells[i].Sub(cells[i-1]) != 5*time.Minute {
+					t.Fatalf("las celdas deben ser consecutivas de 5 minutos, llegó: %v", cells)
+				}
+			}
+		})
+	}
+
+	if _, err := slotCells(&domain.Reserva{Date: date, StartTime: "25:00", Duration: 60}); err == nil {
+		t.Fatalf("un horario inválido debe fallar")
+	}
+	if _, err := slotCells(&domain.Reserva{Date: date, StartTime: "18:00", EndTime: "mañana"}); err == nil {
+		t.Fatalf("un horario de fin inválido debe fallar")
+	}
+}
+
+func TestLockExpiryOnlyForPendingHolds(t *testing.T) {
+	expires := time.Now().Add(time.Minute)
+	if got := lockExpiry(&domain.Reserva{Status: "pending", HoldExpiresAt: &expires}); got == nil || !got.Equal(expires) {
+		t.Fatalf("un turno retenido debe vencer con la reserva, llegó: %v", got)
+	}
+	if got := lockExpiry(&domain.Reserva{Status: "confirmed", HoldExpiresAt: &expires}); got != nil {
+		t.Fatalf("una reserva confirmada no vence, llegó: %v", got)
+	}
+}
+
+// newMongoTestRepository usa una base descartable en el Mongo de MONGO_TEST_URI.
+// Sin la variable el test se saltea: la exclusión depende del índice único real, que el mock no reproduce.
+func newMongoTestRepository(t *testing.T) *reservaRepository {
+	t.Helper()
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI no está definida")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("no se pudo conectar a MongoDB: %v", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatalf("MongoDB no responde: %v", err)
+	}
+
+	db := client.Database("reservas_test_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = db.Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+	return NewReservaRepository(db).(*reservaRepository)
+}
+
+// heldCount retorna cuántas celdas tiene tomadas la reserva
+func heldCount(t *testing.T, repo *reservaRepository, reservaID primitive.ObjectID) int {
+	t.Helper()
+	held, err := repo.heldCells(context.Background(), reservaID)
+	if err != nil {
+		t.Fatalf("no se pudieron leer los bloqueos: %v", err)
+	}
+	return len(held)
+}
+
+// createConcurrently crea en paralelo una reserva de 60 minutos por cada inicio y cuenta ganadoras y perdedoras
+func createConcurrently(t *testing.T, repo *reservaRepository, canchaID string, starts []string) (won, lost int) {
+	t.Helper()
+	date := time.Date(2030, 3, 5, 0, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for i, start := range starts {
+		wg.Add(1)
+		go func(userID uint, start string) {
+			defer wg.Done()
+			err := repo.Create(&domain.Reserva{CanchaID: canchaID, UserID: userID, Date: date, StartTime: start, Duration: 60, Status: "confirmed"})
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				won++
+			case errors.Is(err, ErrSlotUnavailable):
+				lost++
+			default:
+				t.Errorf("error inesperado: %v", err)
+			}
+		}(uint(i+1), start)
+	}
+	wg.Wait()
+	return won, lost
+}
+
+func TestMongoConcurrentCreatesOnlyOneWins(t *testing.T) {
+	repo := newMongoTestRepository(t)
+	ctx := context.Background()
+
+	// Mismo turno: todas compiten por la primera celda, así que exactamente una gana
+	same := make([]string, 40)
+	for i := range same {
+		same[i] = "18:00"
+	}
+	if won, lost := createConcurrently(t, repo, "c1", same); won != 1 || lost != len(same)-1 {
+		t.Fatalf("se esperaba exactamente una reserva ganadora, ganaron %d y perdieron %d", won, lost)
+	}
+
+	// Turnos que se superponen todos entre sí sin ser idénticos: las celdas que una perdedora
+	// llegó a insertar pueden hacer perder a otra, pero nunca pueden ganar dos
+	var overlapping []string
+	for i := 0; i < 10; i++ {
+		overlapping = append(overlapping, "18:00", "18:15", "18:30", "18:45")
+	}
+	won, _ := createConcurrently(t, repo, "c2", overlapping)
+	if won > 1 {
+		t.Fatalf("dos turnos superpuestos no pueden ganar a la vez, ganaron %d", won)
+	}
+
+	for canchaID, winners := range map[string]int{"c1": 1, "c2": won} {
+		if n, _ := repo.collection.CountDocuments(ctx, bson.M{"cancha_id": canchaID}); n != int64(winners) {
+			t.Fatalf("se esperaban %d reservas guardadas en %s, hay %d", winners, canchaID, n)
+		}
+		// Las perdedoras deshicieron sus celdas: solo quedan las de la ganadora
+		if n, _ := repo.locks.CountDocuments(ctx, bson.M{"cancha_id": canchaID}); n != int64(12*winners) {
+			t.Fatalf("se esperaban solo las celdas de la ganadora en %s, hay %d", canchaID, n)
+		}
+	}
+}
+
+func TestMongoInsertSlotLocksRollsBackOnConflict(t *testing.T) {
+	repo := newMongoTestRepository(t)
+	date := time.Date(2030, 3, 5, 0, 0, 0, 0, time.UTC)
+
+	taken := &domain.Reserva{CanchaID: "c1", Date: date, StartTime: "20:00", Duration: 60, Status: "confirmed"}
+	if err := repo.Create(taken); err != nil {
+		t.Fatalf("no se pudo crear la reserva: %v", err)
+	}
+
+	// Las celdas de 19:00 a 19:55 se insertan antes de chocar a las 20:00 y deben deshacerse
+	late := &domain.Reserva{CanchaID: "c1", Date: date, StartTime: "19:00", Duration: 90, Status: "confirmed"}
+	if err := repo.Create(late); !errors.Is(err, ErrSlotUnavailable) {
+		t.Fatalf("se esperaba ErrSlotUnavailable, llegó: %v", err)
+	}
+	if n := heldCount(t, repo, late.ID); n != 0 {
+		t.Fatalf("la reserva que perdió no debe conservar celdas, tiene %d", n)
+	}
+	if _, err := repo.GetByID(late.ID.Hex()); err == nil {
+		t.Fatalf("la reserva que perdió no debe guardarse")
+	}
+
+	fits := &domain.Reserva{CanchaID: "c1", Date: date, StartTime: "19:00", Duration: 60, Status: "confirmed"}
+	if err := repo.Create(fits); err != nil {
+		t.Fatalf("las celdas deshechas deben quedar libres, llegó: %v", err)
+	}
+}
+
+func TestMongoUpdateKeepsHeldCellsWhenTheMoveFails(t *testing.T) {
+	repo := newMongoTestRepository(t)
+	date := time.Date(2030, 3, 5, 0, 0, 0, 0, time.UTC)
+
+	taken := &domain.Reserva{CanchaID: "c1", Date: date, StartTime: "20:00", Duration: 60, Status: "confirmed"}
+	moving := &domain.Reserva{CanchaID: "c1", Date: date, StartTime: "18:00", Duration: 60, Status: "confirmed"}
+	for _, reserva := range []*domain.Reserva{taken, moving} {
+		if err := repo.Create(reserva); err != nil {
+			t.Fatalf("no se pudo crear la reserva: %v", err)
+		}
+	}
+
+	// Estirarla hasta las 20:30 choca con la otra: la reserva conserva sus celdas de antes
+	moved := *moving
+	moved.StartTime, moved.Duration = "18:30", 120
+	if err := repo.Update(moving.ID.Hex(), &moved); !errors.Is(err, ErrSlotUnavailable) {
+		t.Fatalf("se esperaba ErrSlotUnavailable, llegó: %v", err)
+	}
+	held, _ := repo.heldCells(context.Background(), moving.ID)
+	if len(held) != 12 || !held[date.Add(18*time.Hour)] || held[date.Add(19*time.Hour)] {
+		t.Fatalf("la reserva debe conservar sus celdas de 18:00 a 18:55, tiene %v", held)
+	}
+
+	// Moverla a un turno libre suelta las celdas que dejó
+	moved.StartTime, moved.Duration = "18:30", 60
+	if err := repo.Update(moving.ID.Hex(), &moved); err != nil {
+		t.Fatalf("se esperaba la reserva movida, llegó: %v", err)
+	}
+	held, _ = repo.heldCells(context.Background(), moving.ID)
+	if len(held) != 12 || held[date.Add(18*time.Hour)] || !held[date.Add(19*time.Hour+25*time.Minute)] {
+		t.Fatalf("la reserva debe tener solo sus celdas de 18:30 a 19:25, tiene %v", held)
+	}
+}
+
+func TestMongoExpiredHoldIsReleasedOnRetry(t *testing.T) {
+	repo := newMongoTestRepository(t)
+	ctx := context.Background()
+	date := time.Date(2030, 3, 5, 0, 0, 0, 0, time.UTC)
+
+	expires := time.Now().Add(time.Minute)
+	hold := &domain.Reserva{CanchaID: "c1", Date: date, StartTime: "18:00", Duration: 60, Status: "pending", HoldExpiresAt: &expires}
+	if err := repo.Create(hold); err != nil {
+		t.Fatalf("no se pudo crear el turno retenido: %v", err)
+	}
+	if err := repo.Create(&domain.Reserva{CanchaID: "c1", Date: date, StartTime: "18:30", Duration: 60, Status: "confirmed"}); !errors.Is(err, ErrSlotUnavailable) {
+		t.Fatalf("un turno retenido vigente debe bloquear, llegó: %v", err)
+	}
+
+	// Vence el plazo sin que haya pasado el reaper: el segundo intento libera las celdas vencidas
+	past := time.Now().Add(-time.Minute)
+	if _, err := repo.locks.UpdateMany(ctx, bson.M{"reserva_id": hold.ID}, bson.M{"$set": bson.M{"expires_at": past}}); err != nil {
+		t.Fatalf("no se pudo vencer el bloqueo: %v", err)
+	}
+	if _, err := repo.collection.UpdateOne(ctx, bson.M{"_id": hold.ID}, bson.M{"$set": bson.M{"hold_expires_at": past}}); err != nil {
+		t.Fatalf("no se pudo vencer el turno: %v", err)
+	}
+
+	taker := &domain.Reserva{CanchaID: "c1", Date: date, StartTime: "18:30", Duration: 60, Status: "confirmed"}
+	if err := repo.Create(taker); err != nil {
+		t.Fatalf("las celdas del turno vencido deben liberarse, llegó: %v", err)
+	}
+	if n := heldCount(t, repo, taker.ID); n != 12 {
+		t.Fatalf("la nueva reserva debe tener sus 12 celdas, tiene %d", n)
+	}
+	// Solo se liberaron las celdas en conflicto; las de 18:00 a 18:25 siguen siendo del turno vencido
+	if n := heldCount(t, repo, hold.ID); n != 6 {
+		t.Fatalf("el turno vencido debe conservar solo las celdas que nadie pidió, tiene %d", n)
+	}
+
+	// El reaper vence el turno y suelta lo que le quedaba sin tocar las celdas de la otra reserva
+	expired, err := repo.ExpireHolds(time.Now())
+	if err != nil || len(expired) != 1 || expired[0].ID != hold.ID {
+		t.Fatalf("se esperaba vencido el turno retenido, llegó: %+v, %v", expired, err)
+	}
+	if heldCount(t, repo, hold.ID) != 0 || heldCount(t, repo, taker.ID) != 12 {
+		t.Fatalf("el reaper debe soltar solo las celdas del turno vencido")
+	}
+}
+
+func TestMongoPurgeRemovesTheReservaAndItsCells(t *testing.T) {
+	repo := newMongoTestRepository(t)
+	date := time.Date(2030, 3, 5, 0, 0, 0, 0, time.UTC)
+
+	reserva := &domain.Reserva{CanchaID: "c1", Date: date, StartTime: "18:00", Duration: 60, Status: "confirmed"}
+	if err := repo.Create(reserva); err != nil {
+		t.Fatalf("no se pudo crear la reserva: %v", err)
+	}
+	if err := repo.Purge(reserva.ID.Hex()); err != nil {
+		t.Fatalf("no se pudo borrar la reserva: %v", err)
+	}
+	if _, err := repo.GetByID(reserva.ID.Hex()); err == nil {
+		t.Fatalf("la reserva borrada no debe quedar, ni siquiera cancelada")
+	}
+	if n := heldCount(t, repo, reserva.ID); n != 0 {
+		t.Fatalf("la reserva borrada no debe conservar celdas, tiene %d", n)
+	}
+	if err := repo.Purge(reserva.ID.Hex()); err == nil || err.Error() != "reserva not found" {
+		t.Fatalf("se esperaba reserva not found, llegó: %v", err)
+	}
+}
+
+func TestMongoUpdateRestoresCellsWhenTheWriteFails(t *testing.T) {
+	repo := newMongoTestRepository(t)
+	ctx := context.Background()
+	date := time.Date(2030, 3, 5, 0, 0, 0, 0, time.UTC)
+
+	reserva := &domain.Reserva{CanchaID: "c1", Date: date, StartTime: "18:00", Duration: 60, Status: "confirmed"}
+	if err := repo.Create(reserva); err != nil {
+		t.Fatalf("no se pudo crear la reserva: %v", err)
+	}
+
+	// Los bloqueos ya se movieron a las 19:00 cuando falla el guardado: vuelven al turno guardado
+	moved := *reserva
+	moved.StartTime = "19:00"
+	if err := repo.acquireSlotLocks(ctx, &moved); err != nil {
+		t.Fatalf("no se pudieron mover los bloqueos: %v", err)
+	}
+	repo.restoreSlotLocks(reserva.ID)
+	held, _ := repo.heldCells(ctx, reserva.ID)
+	if len(held) != 12 || !held[date.Add(18*time.Hour)] || held[date.Add(19*time.Hour)] {
+		t.Fatalf("la reserva debe recuperar sus celdas de 18:00 a 18:55, tiene %v", held)
+	}
+
+	// Si la reserva ya no existe, Update no deja celdas tomadas
+	ghost := &domain.Reserva{CanchaID: "c1", Date: date, StartTime: "21:00", Duration: 60, Status: "confirmed"}
+	ghost.ID = primitive.NewObjectID()
+	if err := repo.Update(ghost.ID.Hex(), ghost); err == nil || err.Error() != "reserva not found" {
+		t.Fatalf("se esperaba reserva not found, llegó: %v", err)
+	}
+	if n := heldCount(t, repo, ghost.ID); n != 0 {
+		t.Fatalf("una reserva inexistente no debe conservar celdas, tiene %d", n)
+	}
+}
@@ -0,0 +1,198 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"reservas-api/internal/domain"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrAlreadyWaiting indica que el usuario ya espera (o tiene ofrecido) ese turno
+var ErrAlreadyWaiting = errors.New("already on the waitlist for this slot")
+
+type WaitlistRepository interface {
+	Create(entry *domain.WaitlistEntry) error
+	GetByID(id string) (*domain.WaitlistEntry, error)
+	GetByUserID(userID uint) ([]domain.WaitlistEntry, error)
+	GetByReservaID(reservaID string) (*domain.WaitlistEntry, error)
+	FindWaiting(canchaID string, date time.Time) ([]domain.WaitlistEntry, error)
+	MarkOffered(id primitive.ObjectID, reservaID string, at time.Time) (bool, error)
+	SetStatus(id primitive.ObjectID, from []string, status string) (bool, error)
+	LeaveAllByUser(userID uint, userName string) (int64, error)
+}
+
+type waitlistRepository struct {
+	collection *mongo.Collection
+}
+
+// NewWaitlistRepository crea una nueva instancia del repositorio de la lista de espera
+func NewWaitlistRepository(db *mongo.Database) WaitlistRepository {
+	collection := db.Collection(domain.WaitlistEntry{}.CollectionName())
+	ensureWaitlistIndexes(collection)
+	return &waitlistRepository{collection: collection}
+}
+
+// ensureWaitlistIndexes crea los índices con los que se busca la fila de un turno y las entradas de un usuario.
+// El índice único parcial impide que un usuario espere dos veces el mismo turno mientras la entrada siga activa.
+func ensureWaitlistIndexes(coll *mongo.Collection) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	active := bson.M{"status": bson.M{"$in": bson.A{domain.WaitlistWaiting, domain.WaitlistOffered}}}
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "cancha_id", Value: 1}, {Key: "date", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "reserva_id", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "cancha_id", Value: 1}, {Key: "date", Value: 1}, {Key: "start_time", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(active),
+		},
+	}
+	if _, err := coll.Indexes().CreateMany(ctx, indexes); err != nil {
+		log.Printf("Warning: failed to create waitlist indexes: %v", err)
+	}
+}
+
+// Create agrega una entrada a la lista de espera.
+// Retorna ErrAlreadyWaiting si el usuario ya tiene una entrada activa para ese turno.
+func (r *waitlistRepository) Create(entry *domain.WaitlistEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	entry.ID = primitive.NewObjectID()
+	entry.CreatedAt = time.Now()
+	entry.UpdatedAt = entry.CreatedAt
+
+	_, err := r.collection.InsertOne(ctx, entry)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAlreadyWaiting
+	}
+	return err
+}
+
+// GetByID obtiene una entrada por su ID
+func (r *waitlistRepository) GetByID(id string) (*domain.WaitlistEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid ID format")
+	}
+
+	var entry domain.WaitlistEntry
+	if err := r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&entry); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("waitlist entry not found")
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetByUserID obtiene las entradas de un usuario, las más nuevas primero
+func (r *waitlistRepository) GetByUserID(userID uint) ([]domain.WaitlistEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	return r.find(ctx, bson.M{"user_id": userID}, opts)
+}
+
+// GetByReservaID obtiene la entrada a la que se le ofreció la reserva indicada
+func (r *waitlistRepository) GetByReservaID(reservaID string) (*domain.WaitlistEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var entry domain.WaitlistEntry
+	if err := r.collection.FindOne(ctx, bson.M{"reserva_id": reservaID}).Decode(&entry); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("waitlist entry not found")
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// FindWaiting obtiene las entradas que esperan un turno de la cancha en la fecha, por orden de llegada
+func (r *waitlistRepository) FindWaiting(canchaID string, date time.Time) ([]domain.WaitlistEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"cancha_id": canchaID, "date": date, "status": domain.WaitlistWaiting}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	return r.find(ctx, filter, opts)
+}
+
+// MarkOffered registra la oferta si la entrada sigue esperando.
+// Retorna false si otra liberación del turno ya se la ofreció o el usuario salió de la lista.
+func (r *waitlistRepository) MarkOffered(id primitive.ObjectID, reservaID string, at time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": domain.WaitlistWaiting},
+		bson.M{"$set": bson.M{"status": domain.WaitlistOffered, "reserva_id": reservaID, "offered_at": at, "updated_at": at}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// SetStatus cambia el estado de la entrada si está en alguno de los estados from
+func (r *waitlistRepository) SetStatus(id primitive.ObjectID, from []string, status string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": bson.M{"$in": from}},
+		bson.M{"$set": bson.M{"status": status, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// LeaveAllByUser saca al usuario de todas las listas en las que espera.
+// Si userName no está vacío además reemplaza el nombre cacheado en todas sus entradas.
+func (r *waitlistRepository) LeaveAllByUser(userID uint, userName string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := r.collection.UpdateMany(ctx,
+		bson.M{"user_id": userID, "status": domain.WaitlistWaiting},
+		bson.M{"$set": bson.M{"status": domain.WaitlistLeft, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	if userName != "" {
+		if _, err := r.collection.UpdateMany(ctx, bson.M{"user_id": userID}, bson.M{"$set": bson.M{"user_name": userName}}); err != nil {
+			return result.ModifiedCount, err
+		}
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *waitlistRepository) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]domain.WaitlistEntry, error) {
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []domain.WaitlistEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"log"
+	"time"
+)
+
+// StartHoldReaper vence cada interval los turnos retenidos cuyo plazo terminó.
+// Retorna una función que detiene el reaper.
+func StartHoldReaper(service ReservaService, interval time.Duration) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				expired, err := service.ExpireHolds()
+				if err != nil {
+					log.Printf("Warning: failed to expire reservation holds: %v", err)
+				}
+				if expired > 0 {
+					log.Printf("Expired %d reservation holds", expired)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
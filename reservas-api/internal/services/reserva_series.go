@@ -0,0 +1,255 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"reservas-api/internal/domain"
+	"reservas-api/internal/dto"
+	"reservas-api/internal/repositories"
+	"reservas-api/internal/utils"
+	"shared/auth"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrSeriesConflicts indica que alguna fecha de una serie no se puede reservar y no se pidió saltearla
+var ErrSeriesConflicts = errors.New("some occurrences of the series are not available")
+
+// CreateRecurring crea una serie de reservas semanales en el mismo turno.
+// Cada fecha se valida contra el horario, los cierres y la disponibilidad de la cancha;
+// sin SkipConflicts se crean todas o ninguna.
+func (s *reservaService) CreateRecurring(req *dto.CreateRecurringRequest, claims *auth.Claims) (*dto.SeriesResponse, error) {
+	if claims == nil {
+		return nil, errors.New("user validation failed")
+	}
+
+	first, err := utils.ParseDate(req.Date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date format: %w", err)
+	}
+	if first.Before(time.Now().Truncate(24 * time.Hour)) {
+		return nil, errors.New("cannot make reservations for past dates")
+	}
+
+	rule := utils.Recurrence{
+		Frequency: req.Recurrence.Frequency,
+		Interval:  req.Recurrence.Interval,
+		Count:     req.Recurrence.Count,
+	}
+	if req.Recurrence.Until != "" {
+		if rule.Until, err = utils.ParseDate(req.Recurrence.Until); err != nil {
+			return nil, fmt.Errorf("%w: invalid until date", utils.ErrInvalidRecurrence)
+		}
+	}
+	dates, err := utils.Occurrences(first, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	valid, cancha, err := s.canchaClient.ValidateCancha(req.CanchaID)
+	if err != nil || !valid {
+		return nil, fmt.Errorf("cancha validation failed: %v", err)
+	}
+
+	seriesID := primitive.NewObjectID().Hex()
+	member := claims.HasPermission(auth.PermReservasMember)
+	resp := &dto.SeriesResponse{SeriesID: seriesID, Reservas: []dto.ReservaResponse{}, Skipped: []dto.SkippedOccurrence{}}
+
+	// Validar todas las fechas antes de crear ninguna
+	var reservas []*domain.Reserva
+	for _, date := range dates {
+		startTime, endTime, duration, err := utils.EnsureValidSlot(cancha.EffectiveSchedule(), date, req.StartTime, req.EndTime)
+		if err == nil {
+			err = s.checkBlackouts(req.CanchaID, date, startTime, duration)
+		}
+		if err == nil {
+			var available bool
+			if available, err = s.repo.CheckAvailability(req.CanchaID, date, startTime, endTime); err != nil {
+				return nil, err
+			}
+			if !available {
+				err = repositories.ErrSlotUnavailable
+			}
+		}
+		if err != nil {
+			resp.Skipped = append(resp.Skipped, dto.SkippedOccurrence{Date: utils.FormatDate(date), Reason: err.Error()})
+			continue
+		}
+
+		quote, err := s.canchaClient.GetQuote(req.CanchaID, date, startTime, member)
+		if err != nil {
+			return nil, fmt.Errorf("error quoting price: %w", err)
+		}
+
+		reserva := &domain.Reserva{
+			CanchaID:   req.CanchaID,
+			UserID:     claims.UserID,
+			Date:       date,
+			StartTime:  startTime,
+			EndTime:    endTime,
+			Duration:   duration,
+			Status:     "confirmed",
+			CanchaName: cancha.Name,
+			UserName:   claims.Username,
+			SeriesID:   seriesID,
+		}
+		applyQuote(reserva, quote)
+		reservas = append(reservas, reserva)
+	}
+
+	if len(resp.Skipped) > 0 && !req.SkipConflicts {
+		return nil, seriesConflicts(resp.Skipped)
+	}
+	if len(reservas) == 0 {
+		return nil, fmt.Errorf("%w: no occurrence can be booked", ErrSeriesConflicts)
+	}
+
+	// Crear las reservas: los bloqueos del repositorio siguen siendo la garantía final
+	// si otro pedido tomó una fecha entre la validación y la creación
+	var created []*domain.Reserva
+	for _, reserva := range reservas {
+		err := s.repo.Create(reserva)
+		if err == nil {
+			created = append(created, reserva)
+			continue
+		}
+
+		if errors.Is(err, repositories.ErrSlotUnavailable) && req.SkipConflicts {
+			resp.Skipped = append(resp.Skipped, dto.SkippedOccurrence{Date: utils.FormatDate(reserva.Date), Reason: err.Error()})
+			continue
+		}
+
+		s.rollbackSeries(created)
+		if errors.Is(err, repositories.ErrSlotUnavailable) {
+			return nil, seriesConflicts([]dto.SkippedOccurrence{{Date: utils.FormatDate(reserva.Date), Reason: err.Error()}})
+		}
+		return nil, err
+	}
+	if len(created) == 0 {
+		return nil, fmt.Errorf("%w: no occurrence can be booked", ErrSeriesConflicts)
+	}
+
+	for _, reserva := range created {
+		s.publishReservaEvent("create", reserva)
+		resp.Reservas = append(resp.Reservas, *s.domainToResponse(reserva))
+	}
+	return resp, nil
+}
+
+// GetSeries obtiene las reservas de una serie (solo su dueño o quien puede ver todas)
+func (s *reservaService) GetSeries(seriesID string, claims *auth.Claims) (*dto.SeriesResponse, error) {
+	reservas, err := s.getOwnedSeries(seriesID, claims, auth.PermReservasReadAll)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dto.SeriesResponse{SeriesID: seriesID, Reservas: []dto.ReservaResponse{}, Skipped: []dto.SkippedOccurrence{}}
+	for i := range reservas {
+		resp.Reservas = append(resp.Reservas, *s.domainToResponse(&reservas[i]))
+	}
+	return resp, nil
+}
+
+// UpdateSeries cambia el horario de las reservas de una serie que todavía no empezaron (desde From si se indica).
+// Cada reserva se revalida como en Update; las que no se pueden mover se informan en Skipped.
+func (s *reservaService) UpdateSeries(seriesID string, req *dto.UpdateSeriesRequest, claims *auth.Claims) (*dto.SeriesResponse, error) {
+	reservas, err := s.remainingOccurrences(seriesID, req.From, claims, auth.PermReservasUpdateAny)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dto.SeriesResponse{SeriesID: seriesID, Reservas: []dto.ReservaResponse{}, Skipped: []dto.SkippedOccurrence{}}
+	for _, reserva := range reservas {
+		updated, err := s.Update(reserva.ID.Hex(), &dto.UpdateReservaRequest{StartTime: req.StartTime, EndTime: req.EndTime}, claims)
+		if err != nil {
+			resp.Skipped = append(resp.Skipped, dto.SkippedOccurrence{Date: utils.FormatDate(reserva.Date), Reason: err.Error()})
+			continue
+		}
+		resp.Reservas = append(resp.Reservas, *updated)
+	}
+	return resp, nil
+}
+
+// CancelSeries cancela las reservas de una serie que todavía no empezaron (desde from si se indica)
+// y retorna cuántas canceló
+func (s *reservaService) CancelSeries(seriesID, from string, claims *auth.Claims) (int, error) {
+	reservas, err := s.remainingOccurrences(seriesID, from, claims, auth.PermReservasCancelAny)
+	if err != nil {
+		return 0, err
+	}
+
+	cancelled := 0
+	for i := range reservas {
+		reserva := &reservas[i]
+		if err := s.repo.Delete(reserva.ID.Hex()); err != nil {
+			return cancelled, err
+		}
+		reserva.Status = "cancelled"
+		cancelled++
+		s.publishReservaEvent("cancel", reserva)
+		s.offerFreedSlot(reserva)
+	}
+	return cancelled, nil
+}
+
+// remainingOccurrences retorna las reservas activas de la serie que todavía no empezaron, con fecha desde from
+func (s *reservaService) remainingOccurrences(seriesID, from string, claims *auth.Claims, permission string) ([]domain.Reserva, error) {
+	reservas, err := s.getOwnedSeries(seriesID, claims, permission)
+	if err != nil {
+		return nil, err
+	}
+
+	var fromDate time.Time
+	if from != "" {
+		if fromDate, err = utils.ParseDate(from); err != nil {
+			return nil, fmt.Errorf("invalid date format: %w", err)
+		}
+	}
+
+	now := time.Now()
+	var remaining []domain.Reserva
+	for _, reserva := range reservas {
+		if reserva.Status == "cancelled" || reserva.Status == "expired" || reserva.Date.Before(fromDate) || hasStarted(&reserva, now) {
+			continue
+		}
+		remaining = append(remaining, reserva)
+	}
+	return remaining, nil
+}
+
+// getOwnedSeries obtiene las reservas de una serie verificando que el usuario sea su dueño o tenga el permiso indicado
+func (s *reservaService) getOwnedSeries(seriesID string, claims *auth.Claims, permission string) ([]domain.Reserva, error) {
+	reservas, err := s.repo.GetBySeriesID(seriesID)
+	if err != nil {
+		return nil, err
+	}
+	if len(reservas) == 0 {
+		return nil, errors.New("series not found")
+	}
+
+	if !auth.IsSelfOrHasPermission(claims, reservas[0].UserID, permission) {
+		return nil, errors.New("forbidden")
+	}
+	return reservas, nil
+}
+
+// rollbackSeries borra las reservas ya creadas de una serie que no se pudo crear completa.
+// Se borran en lugar de cancelarse: la serie nunca existió y no deben quedar en el historial del usuario.
+func (s *reservaService) rollbackSeries(created []*domain.Reserva) {
+	for _, reserva := range created {
+		if err := s.repo.Purge(reserva.ID.Hex()); err != nil {
+			println("Warning: failed to roll back series reservation:", err.Error())
+		}
+	}
+}
+
+// seriesConflicts arma el error con las fechas de la serie que no se pueden reservar
+func seriesConflicts(skipped []dto.SkippedOccurrence) error {
+	details := make([]string, len(skipped))
+	for i, occurrence := range skipped {
+		details[i] = fmt.Sprintf("%s (%s)", occurrence.Date, occurrence.Reason)
+	}
+	return fmt.Errorf("%w: %s", ErrSeriesConflicts, strings.Join(details, ", "))
+}
@@ -3,75 +3,107 @@ package services
 import (
 	"errors"
 	"fmt"
+	"reservas-api/config"
 	"reservas-api/internal/clients"
 	"reservas-api/internal/domain"
 	"reservas-api/internal/dto"
 	"reservas-api/internal/messaging"
 	"reservas-api/internal/repositories"
 	"reservas-api/internal/utils"
+	"shared/auth"
 	"time"
 )
 
 type ReservaService interface {
-	Create(req *dto.CreateReservaRequest, token string) (*dto.ReservaResponse, error)
-	GetByID(id string) (*dto.ReservaResponse, error)
+	Create(req *dto.CreateReservaRequest, claims *auth.Claims) (*dto.ReservaResponse, error)
+	GetByID(id string, claims *auth.Claims) (*dto.ReservaResponse, error)
 	GetAll() (*dto.ReservasListResponse, error)
 	GetByUserID(userID uint) (*dto.ReservasListResponse, error)
 	GetByCanchaID(canchaID string) (*dto.ReservasListResponse, error)
-	Update(id string, req *dto.UpdateReservaRequest) (*dto.ReservaResponse, error)
-	Cancel(id string) error
+	Update(id string, req *dto.UpdateReservaRequest, claims *auth.Claims) (*dto.ReservaResponse, error)
+	Cancel(id string, claims *auth.Claims) error
+	Confirm(id string, claims *auth.Claims) (*dto.ReservaResponse, error)
+	CheckIn(id string) (*dto.ReservaResponse, error)
 	DeleteByCanchaID(canchaID string) (int64, error)
+	HandleUserEvent(eventType string, userID uint) error
+	FindConflicts(req *dto.ConflictsRequest, cancel bool) (*dto.ConflictsResponse, error)
+	ExpireHolds() (int, error)
+	CreateRecurring(req *dto.CreateRecurringRequest, claims *auth.Claims) (*dto.SeriesResponse, error)
+	GetSeries(seriesID string, claims *auth.Claims) (*dto.SeriesResponse, error)
+	UpdateSeries(seriesID string, req *dto.UpdateSeriesRequest, claims *auth.Claims) (*dto.SeriesResponse, error)
+	CancelSeries(seriesID, from string, claims *auth.Claims) (int, error)
+	JoinWaitlist(req *dto.JoinWaitlistRequest, claims *auth.Claims) (*dto.WaitlistEntryResponse, error)
+	GetWaitlists(claims *auth.Claims) (*dto.WaitlistListResponse, error)
+	LeaveWaitlist(id string, claims *auth.Claims) error
 }
 
+// ErrCanchaClosed indica que el turno cae dentro de un cierre (feriado, mantenimiento, torneo) de la cancha
+var ErrCanchaClosed = errors.New("cancha closed for the selected time slot")
+
+// ErrHoldExpired indica que venció el plazo del turno retenido antes de confirmarlo
+var ErrHoldExpired = errors.New("reservation hold expired")
+
+// ErrStatusChange indica un cambio de estado que Update no admite: solo se confirma o se cancela,
+// y sin mover la reserva en el mismo pedido
+var ErrStatusChange = errors.New("status can only be changed to confirmed or cancelled, without other changes")
+
+// defaultHoldDuration es cuánto se retiene un turno pendiente si la configuración no lo indica
+const defaultHoldDuration = 10 * time.Minute
+
+// Tipos de eventos user.* publicados por users-api
+const (
+	userEventDeactivated = "deactivated"
+	userEventDeleted     = "deleted"
+	userEventErased      = "erased"
+)
+
+// erasedUserName reemplaza el nombre cacheado de un usuario que pidió el borrado de sus datos
+const erasedUserName = "Usuario eliminado"
+
 type reservaService struct {
 	repo         repositories.ReservaRepository
-	userClient   clients.UserClient
+	waitlist     repositories.WaitlistRepository
 	canchaClient clients.CanchaClient
 	publisher    messaging.RabbitMQPublisher
+	holdDuration time.Duration
 }
 
 // NewReservaService crea una nueva instancia del servicio
 func NewReservaService(
 	repo repositories.ReservaRepository,
-	userClient clients.UserClient,
+	waitlist repositories.WaitlistRepository,
 	canchaClient clients.CanchaClient,
 	publisher messaging.RabbitMQPublisher,
 ) ReservaService {
+	holdDuration := defaultHoldDuration
+	if config.AppConfig != nil && config.AppConfig.HoldMinutes > 0 {
+		holdDuration = time.Duration(config.AppConfig.HoldMinutes) * time.Minute
+	}
+
 	return &reservaService{
 		repo:         repo,
-		userClient:   userClient,
+		waitlist:     waitlist,
 		canchaClient: canchaClient,
 		publisher:    publisher,
+		holdDuration: holdDuration,
 	}
 }
 
-// Create crea una nueva reserva con validación concurrente
-func (s *reservaService) Create(req *dto.CreateReservaRequest, token string) (*dto.ReservaResponse, error) {
+// Create crea una nueva reserva con validación concurrente.
+// El usuario ya fue autenticado localmente con su token, por lo que no se consulta a users-api.
+func (s *reservaService) Create(req *dto.CreateReservaRequest, claims *auth.Claims) (*dto.ReservaResponse, error) {
+	if claims == nil {
+		return nil, errors.New("user validation failed")
+	}
+
 	// Variables para almacenar resultados de las validaciones
-	var userData *clients.UserResponse
 	var canchaData *clients.CanchaResponse
-	var totalPrice float64
 	var date time.Time
 	var duration int
 
 	// 🚀 CÁLCULO CONCURRENTE: Preparar validaciones
 	validations := []utils.ConcurrentValidation{
-		// Validación 1: Usuario existe
-		{
-			Name: "user_validation",
-			Function: func() dto.ValidationResult {
-				valid, user, err := s.userClient.ValidateUser(req.UserID, token)
-				if err != nil || !valid {
-					return dto.ValidationResult{
-						Valid:   false,
-						Message: fmt.Sprintf("user validation failed: %v", err),
-					}
-				}
-				userData = user
-				return dto.ValidationResult{Valid: true, Data: user}
-			},
-		},
-		// Validación 2: Cancha existe y está disponible
+		// Validación 1: Cancha existe y está disponible
 		{
 			Name: "cancha_validation",
 			Function: func() dto.ValidationResult {
@@ -86,7 +118,7 @@ func (s *reservaService) Create(req *dto.CreateReservaRequest, token string) (*d
 				return dto.ValidationResult{Valid: true, Data: cancha}
 			},
 		},
-		// Validación 3: Parsear fecha
+		// Validación 2: Parsear fecha
 		{
 			Name: "date_parsing",
 			Function: func() dto.ValidationResult {
@@ -117,7 +149,7 @@ func (s *reservaService) Create(req *dto.CreateReservaRequest, token string) (*d
 		return nil, errors.New(fmt.Sprintf("validation failed: %v", validationErrors))
 	}
 
-	startTime, endTime, slotDuration, err := utils.EnsureValidSlot(canchaData.Type, req.StartTime, req.EndTime)
+	startTime, endTime, slotDuration, err := utils.EnsureValidSlot(canchaData.EffectiveSchedule(), date, req.StartTime, req.EndTime)
 	if err != nil {
 		return nil, err
 	}
@@ -125,31 +157,36 @@ func (s *reservaService) Create(req *dto.CreateReservaRequest, token string) (*d
 	req.EndTime = endTime
 	duration = slotDuration
 
-	// Calcular precio (después de que todas las validaciones pasaron)
-	totalPrice = utils.CalculatePrice(canchaData.Price, duration)
+	if err := s.checkBlackouts(req.CanchaID, date, req.StartTime, duration); err != nil {
+		return nil, err
+	}
 
-	// Verificar disponibilidad (esto debe ser secuencial para evitar condiciones de carrera)
-	available, err := s.repo.CheckAvailability(req.CanchaID, date, req.StartTime, req.EndTime)
+	// Cotizar con la política de precios de canchas-api (después de que todas las validaciones pasaron)
+	quote, err := s.canchaClient.GetQuote(req.CanchaID, date, req.StartTime, claims.HasPermission(auth.PermReservasMember))
 	if err != nil {
-		return nil, fmt.Errorf("error checking availability: %w", err)
-	}
-	if !available {
-		return nil, errors.New("cancha not available for the selected time slot")
+		return nil, fmt.Errorf("error quoting price: %w", err)
 	}
 
-	// Crear la reserva
+	// Crear la reserva: el repositorio toma los bloqueos del turno de forma atómica,
+	// así de dos pedidos concurrentes por el mismo turno solo uno gana (repositories.ErrSlotUnavailable)
 	reserva := &domain.Reserva{
 		CanchaID:   req.CanchaID,
-		UserID:     req.UserID,
+		UserID:     claims.UserID,
 		Date:       date,
 		StartTime:  req.StartTime,
 		EndTime:    req.EndTime,
 		Duration:   duration,
 		Status:     "confirmed",
-		TotalPrice: totalPrice,
 		CanchaName: canchaData.Name,
-		UserName:   fmt.Sprintf("%s %s", userData.FirstName, userData.LastName),
+		UserName:   claims.Username,
 	}
+	if req.Hold {
+		// Turno retenido mientras el usuario paga o confirma; si no lo confirma a tiempo lo vence el reaper
+		expiresAt := time.Now().Add(s.holdDuration)
+		reserva.Status = "pending"
+		reserva.HoldExpiresAt = &expiresAt
+	}
+	applyQuote(reserva, quote)
 
 	if err := s.repo.Create(reserva); err != nil {
 		return nil, err
@@ -170,9 +207,9 @@ func (s *reservaService) Create(req *dto.CreateReservaRequest, token string) (*d
 	return s.domainToResponse(reserva), nil
 }
 
-// GetByID obtiene una reserva por su ID
-func (s *reservaService) GetByID(id string) (*dto.ReservaResponse, error) {
-	reserva, err := s.repo.GetByID(id)
+// GetByID obtiene una reserva por su ID (solo su dueño o quien puede ver todas)
+func (s *reservaService) GetByID(id string, claims *auth.Claims) (*dto.ReservaResponse, error) {
+	reserva, err := s.getOwned(id, claims, auth.PermReservasReadAll)
 	if err != nil {
 		return nil, err
 	}
@@ -234,18 +271,40 @@ func (s *reservaService) GetByCanchaID(canchaID string) (*dto.ReservasListRespon
 	}, nil
 }
 
-// Update actualiza una reserva existente
-func (s *reservaService) Update(id string, req *dto.UpdateReservaRequest) (*dto.ReservaResponse, error) {
+// Update actualiza una reserva existente (solo su dueño o quien puede modificar todas)
+func (s *reservaService) Update(id string, req *dto.UpdateReservaRequest, claims *auth.Claims) (*dto.ReservaResponse, error) {
 	// Obtener la reserva existente
-	existing, err := s.repo.GetByID(id)
+	existing, err := s.getOwned(id, claims, auth.PermReservasUpdateAny)
 	if err != nil {
 		return nil, err
 	}
 
-	// No permitir actualizar reservas canceladas
+	// Los cambios de estado pasan por Confirm y Cancel, que validan permisos, retenciones y lista de espera
+	if req.Status != "" && req.Status != existing.Status {
+		if req.Date != "" || req.StartTime != "" || req.EndTime != "" {
+			return nil, ErrStatusChange
+		}
+		switch req.Status {
+		case "confirmed":
+			return s.Confirm(id, claims)
+		case "cancelled":
+			if err := s.Cancel(id, claims); err != nil {
+				return nil, err
+			}
+			existing.Status = "cancelled"
+			return s.domainToResponse(existing), nil
+		default:
+			return nil, ErrStatusChange
+		}
+	}
+
+	// No permitir actualizar reservas canceladas ni turnos retenidos vencidos
 	if existing.Status == "cancelled" {
 		return nil, errors.New("cannot update a cancelled reservation")
 	}
+	if existing.Status == "expired" || existing.HoldExpired(time.Now()) {
+		return nil, ErrHoldExpired
+	}
 
 	// Actualizar campos si se proporcionan
 	if req.Date != "" {
@@ -264,39 +323,39 @@ func (s *reservaService) Update(id string, req *dto.UpdateReservaRequest) (*dto.
 		existing.EndTime = req.EndTime
 	}
 
-	if req.Status != "" {
-		existing.Status = req.Status
-	}
-
-	// Recalcular duración y precio si cambiaron las horas
-	if req.StartTime != "" || req.EndTime != "" {
+	// Revalidar contra el horario y recalcular duración y precio si cambiaron la fecha o las horas
+	// (cada día de la semana puede tener otro horario)
+	if req.Date != "" || req.StartTime != "" || req.EndTime != "" {
 		_, cancha, err := s.canchaClient.ValidateCancha(existing.CanchaID)
 		if err != nil {
 			return nil, err
 		}
 
-		startTime, endTime, duration, err := utils.EnsureValidSlot(cancha.Type, existing.StartTime, existing.EndTime)
+		startTime, endTime, duration, err := utils.EnsureValidSlot(cancha.EffectiveSchedule(), existing.Date, existing.StartTime, existing.EndTime)
 		if err != nil {
 			return nil, err
 		}
+		if err := s.checkBlackouts(existing.CanchaID, existing.Date, startTime, duration); err != nil {
+			return nil, err
+		}
+
+		// Se mantiene la tarifa con la que se reservó salvo que la cambie su dueño
+		member := existing.MemberPrice
+		if claims != nil && claims.UserID == existing.UserID {
+			member = claims.HasPermission(auth.PermReservasMember)
+		}
+		quote, err := s.canchaClient.GetQuote(existing.CanchaID, existing.Date, startTime, member)
+		if err != nil {
+			return nil, fmt.Errorf("error quoting price: %w", err)
+		}
 
 		existing.StartTime = startTime
 		existing.EndTime = endTime
 		existing.Duration = duration
-		existing.TotalPrice = utils.CalculatePrice(cancha.Price, duration)
-	}
-
-	// Verificar disponibilidad si cambió la fecha o las horas
-	if req.Date != "" || req.StartTime != "" || req.EndTime != "" {
-		available, err := s.repo.CheckAvailability(existing.CanchaID, existing.Date, existing.StartTime, existing.EndTime)
-		if err != nil {
-			return nil, err
-		}
-		if !available {
-			return nil, errors.New("cancha not available for the selected time slot")
-		}
+		applyQuote(existing, quote)
 	}
 
+	// El repositorio mueve los bloqueos al nuevo turno y falla con ErrSlotUnavailable si otra reserva lo ocupa
 	if err := s.repo.Update(id, existing); err != nil {
 		return nil, err
 	}
@@ -316,10 +375,10 @@ func (s *reservaService) Update(id string, req *dto.UpdateReservaRequest) (*dto.
 	return s.domainToResponse(existing), nil
 }
 
-// Cancel cancela una reserva
-func (s *reservaService) Cancel(id string) error {
-	// Verificar que la reserva existe
-	reserva, err := s.repo.GetByID(id)
+// Cancel cancela una reserva (solo su dueño o quien puede cancelar todas)
+func (s *reservaService) Cancel(id string, claims *auth.Claims) error {
+	// Verificar que la reserva existe y pertenece al usuario
+	reserva, err := s.getOwned(id, claims, auth.PermReservasCancelAny)
 	if err != nil {
 		return err
 	}
@@ -327,6 +386,9 @@ func (s *reservaService) Cancel(id string) error {
 	if reserva.Status == "cancelled" {
 		return errors.New("reservation already cancelled")
 	}
+	if reserva.Status == "expired" {
+		return ErrHoldExpired
+	}
 
 	if err := s.repo.Delete(id); err != nil {
 		return err
@@ -344,9 +406,107 @@ func (s *reservaService) Cancel(id string) error {
 		println("Warning: failed to publish event:", err.Error())
 	}
 
+	// Si era un turno ofrecido desde la lista de espera se rechaza; el turno pasa al siguiente de la fila
+	s.closeOffer(reserva, domain.WaitlistLeft)
+	s.offerFreedSlot(reserva)
+
 	return nil
 }
 
+// Confirm confirma una reserva pendiente. Recepción confirma cualquiera;
+// el dueño solo puede confirmar su propio turno retenido antes de que venza.
+func (s *reservaService) Confirm(id string, claims *auth.Claims) (*dto.ReservaResponse, error) {
+	reserva, err := s.getOwned(id, claims, auth.PermReservasConfirm)
+	if err != nil {
+		return nil, err
+	}
+	if reserva.HoldExpiresAt == nil && !claims.HasPermission(auth.PermReservasConfirm) {
+		return nil, errors.New("forbidden")
+	}
+
+	switch reserva.Status {
+	case "cancelled":
+		return nil, errors.New("cannot confirm a cancelled reservation")
+	case "confirmed":
+		return nil, errors.New("reservation already confirmed")
+	case "expired":
+		return nil, ErrHoldExpired
+	}
+	if reserva.HoldExpired(time.Now()) {
+		return nil, ErrHoldExpired
+	}
+
+	// Si el turno es una oferta de la lista de espera se acepta primero: no se confirma una oferta ya rechazada
+	entry, err := s.acceptOffer(reserva)
+	if err != nil {
+		return nil, err
+	}
+
+	reserva.Status = "confirmed"
+	reserva.HoldExpiresAt = nil
+	if err := s.repo.Update(id, reserva); err != nil {
+		if entry != nil {
+			if _, reopenErr := s.waitlist.SetStatus(entry.ID, []string{domain.WaitlistAccepted}, domain.WaitlistOffered); reopenErr != nil {
+				println("Warning: failed to reopen waitlist offer:", reopenErr.Error())
+			}
+		}
+		return nil, err
+	}
+
+	s.publishReservaEvent("confirm", reserva)
+	return s.domainToResponse(reserva), nil
+}
+
+// CheckIn registra la llegada del jugador a una reserva confirmada (recepción)
+func (s *reservaService) CheckIn(id string) (*dto.ReservaResponse, error) {
+	reserva, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if reserva.Status != "confirmed" {
+		return nil, errors.New("only confirmed reservations can be checked in")
+	}
+	if reserva.CheckedInAt != nil {
+		return nil, errors.New("reservation already checked in")
+	}
+
+	now := time.Now()
+	reserva.CheckedInAt = &now
+	if err := s.repo.Update(id, reserva); err != nil {
+		return nil, err
+	}
+
+	s.publishReservaEvent("check_in", reserva)
+	return s.domainToResponse(reserva), nil
+}
+
+// ExpireHolds vence los turnos retenidos cuyo plazo terminó y publica reserva.expired por cada uno.
+// La llama periódicamente el reaper (ver StartHoldReaper).
+func (s *reservaService) ExpireHolds() (int, error) {
+	expired, err := s.repo.ExpireHolds(time.Now())
+	for i := range expired {
+		s.publishReservaEvent("expired", &expired[i])
+		s.closeOffer(&expired[i], domain.WaitlistExpired)
+		s.offerFreedSlot(&expired[i])
+	}
+	return len(expired), err
+}
+
+// publishReservaEvent publica un evento reserva.<tipo>; un fallo solo se registra
+func (s *reservaService) publishReservaEvent(eventType string, reserva *domain.Reserva) {
+	event := messaging.Event{
+		Type:      eventType,
+		Entity:    "reserva",
+		EntityID:  reserva.ID.Hex(),
+		Data:      reserva,
+		Timestamp: time.Now().Unix(),
+	}
+	if err := s.publisher.PublishEvent(event); err != nil {
+		println("Warning: failed to publish event:", err.Error())
+	}
+}
+
 // DeleteByCanchaID elimina todas las reservas asociadas a una cancha
 func (s *reservaService) DeleteByCanchaID(canchaID string) (int64, error) {
 	deleted, err := s.repo.DeleteByCanchaID(canchaID)
@@ -356,21 +516,191 @@ func (s *reservaService) DeleteByCanchaID(canchaID string) (int64, error) {
 	return deleted, nil
 }
 
+// HandleUserEvent aplica un evento de users-api a las reservas del usuario.
+// Desactivar o borrar cancela sus reservas futuras; el borrado de datos además anonimiza su nombre.
+// Es idempotente para que un evento reenviado no falle.
+func (s *reservaService) HandleUserEvent(eventType string, userID uint) error {
+	switch eventType {
+	case userEventDeactivated, userEventDeleted:
+		if _, err := s.waitlist.LeaveAllByUser(userID, ""); err != nil {
+			return err
+		}
+		_, err := s.cancelFutureByUser(userID)
+		return err
+	case userEventErased:
+		if _, err := s.waitlist.LeaveAllByUser(userID, erasedUserName); err != nil {
+			return err
+		}
+		if _, err := s.cancelFutureByUser(userID); err != nil {
+			return err
+		}
+		_, err := s.repo.AnonymizeUser(userID, erasedUserName)
+		return err
+	default:
+		return nil
+	}
+}
+
+// cancelFutureByUser cancela las reservas del usuario que todavía no empezaron
+func (s *reservaService) cancelFutureByUser(userID uint) (int, error) {
+	now := time.Now()
+	// Desde ayer: un turno de ayer a la 01:00 todavía puede no haber empezado
+	from := now.Truncate(24*time.Hour).AddDate(0, 0, -1)
+	reservas, err := s.repo.GetActiveByUserIDFrom(userID, from)
+	if err != nil {
+		return 0, err
+	}
+
+	cancelled := 0
+	for i := range reservas {
+		reserva := &reservas[i]
+		if hasStarted(reserva, now) {
+			continue
+		}
+
+		id := reserva.ID.Hex()
+		if err := s.repo.Delete(id); err != nil {
+			return cancelled, err
+		}
+		reserva.Status = "cancelled"
+		cancelled++
+
+		event := messaging.Event{
+			Type:      "cancel",
+			Entity:    "reserva",
+			EntityID:  id,
+			Data:      reserva,
+			Timestamp: time.Now().Unix(),
+		}
+		if err := s.publisher.PublishEvent(event); err != nil {
+			println("Warning: failed to publish event:", err.Error())
+		}
+
+		s.closeOffer(reserva, domain.WaitlistLeft)
+		s.offerFreedSlot(reserva)
+	}
+
+	return cancelled, nil
+}
+
+// applyQuote guarda en la reserva el total y el desglose de la cotización de canchas-api
+func applyQuote(reserva *domain.Reserva, quote *clients.QuoteResponse) {
+	items := make([]domain.PriceItem, len(quote.Items))
+	for i, item := range quote.Items {
+		items[i] = domain.PriceItem{Kind: item.Kind, Name: item.Name, Amount: item.Amount}
+	}
+	reserva.PriceItems = items
+	reserva.TotalPrice = quote.Total
+	reserva.MemberPrice = quote.Member
+}
+
+// checkBlackouts rechaza el turno si se superpone con un cierre de la cancha o de todo el predio
+func (s *reservaService) checkBlackouts(canchaID string, date time.Time, startTime string, duration int) error {
+	start, end, err := utils.SlotInterval(date, startTime, duration)
+	if err != nil {
+		return err
+	}
+
+	blackouts, err := s.canchaClient.GetBlackouts(canchaID, start, end)
+	if err != nil {
+		return fmt.Errorf("error checking blackouts: %w", err)
+	}
+	for _, blackout := range blackouts {
+		if start.Before(blackout.End) && blackout.Start.Before(end) {
+			return fmt.Errorf("%w: %s", ErrCanchaClosed, blackout.Reason)
+		}
+	}
+	return nil
+}
+
+// FindConflicts lista las reservas activas que se superponen con un cierre creado en canchas-api.
+// Con cancel cancela las que todavía no empezaron; las ya empezadas solo se informan.
+func (s *reservaService) FindConflicts(req *dto.ConflictsRequest, cancel bool) (*dto.ConflictsResponse, error) {
+	if !req.End.After(req.Start) {
+		return nil, errors.New("end must be after start")
+	}
+
+	// Desde el día anterior: un turno de ese día a la 01:00 cae en la madrugada del inicio
+	from := req.Start.Truncate(24*time.Hour).AddDate(0, 0, -1)
+	reservas, err := s.repo.GetActiveByCanchasBetween(req.CanchaIDs, from, req.End)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	resp := &dto.ConflictsResponse{Reservas: []dto.ReservaResponse{}}
+	for i := range reservas {
+		reserva := &reservas[i]
+		start, end, err := utils.SlotInterval(reserva.Date, reserva.StartTime, reserva.Duration)
+		if err != nil || !start.Before(req.End) || !req.Start.Before(end) {
+			continue
+		}
+
+		if cancel && !hasStarted(reserva, now) {
+			if err := s.repo.Delete(reserva.ID.Hex()); err != nil {
+				return nil, err
+			}
+			reserva.Status = "cancelled"
+			resp.Cancelled++
+			s.publishReservaEvent("cancel", reserva)
+			// Si era un turno ofrecido a la lista de espera, la oferta se cierra; el turno no se vuelve
+			// a ofrecer porque queda cerrado
+			s.closeOffer(reserva, domain.WaitlistExpired)
+		}
+		resp.Reservas = append(resp.Reservas, *s.domainToResponse(reserva))
+	}
+
+	return resp, nil
+}
+
+// hasStarted indica si el turno de la reserva ya empezó (los turnos después de medianoche cuentan al día siguiente)
+func hasStarted(reserva *domain.Reserva, now time.Time) bool {
+	start, _, err := utils.SlotInterval(reserva.Date, reserva.StartTime, reserva.Duration)
+	if err != nil {
+		return false
+	}
+	return !start.After(now)
+}
+
+// getOwned obtiene una reserva verificando que el usuario sea su dueño o tenga el permiso indicado
+func (s *reservaService) getOwned(id string, claims *auth.Claims, permission string) (*domain.Reserva, error) {
+	reserva, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !auth.IsSelfOrHasPermission(claims, reserva.UserID, permission) {
+		return nil, errors.New("forbidden")
+	}
+
+	return reserva, nil
+}
+
 // domainToResponse convierte una Reserva del dominio a ReservaResponse DTO
 func (s *reservaService) domainToResponse(reserva *domain.Reserva) *dto.ReservaResponse {
+	priceItems := make([]dto.PriceItem, len(reserva.PriceItems))
+	for i, item := range reserva.PriceItems {
+		priceItems[i] = dto.PriceItem{Kind: item.Kind, Name: item.Name, Amount: item.Amount}
+	}
+
 	return &dto.ReservaResponse{
-		ID:         reserva.ID.Hex(),
-		CanchaID:   reserva.CanchaID,
-		CanchaName: reserva.CanchaName,
-		UserID:     reserva.UserID,
-		UserName:   reserva.UserName,
-		Date:       utils.FormatDate(reserva.Date),
-		StartTime:  reserva.StartTime,
-		EndTime:    reserva.EndTime,
-		Duration:   reserva.Duration,
-		Status:     reserva.Status,
-		TotalPrice: reserva.TotalPrice,
-		CreatedAt:  reserva.CreatedAt,
-		UpdatedAt:  reserva.UpdatedAt,
+		ID:            reserva.ID.Hex(),
+		CanchaID:      reserva.CanchaID,
+		CanchaName:    reserva.CanchaName,
+		UserID:        reserva.UserID,
+		UserName:      reserva.UserName,
+		Date:          utils.FormatDate(reserva.Date),
+		StartTime:     reserva.StartTime,
+		EndTime:       reserva.EndTime,
+		Duration:      reserva.Duration,
+		Status:        reserva.Status,
+		TotalPrice:    reserva.TotalPrice,
+		PriceItems:    priceItems,
+		MemberPrice:   reserva.MemberPrice,
+		SeriesID:      reserva.SeriesID,
+		CheckedInAt:   reserva.CheckedInAt,
+		HoldExpiresAt: reserva.HoldExpiresAt,
+		CreatedAt:     reserva.CreatedAt,
+		UpdatedAt:     reserva.UpdatedAt,
 	}
 }
@@ -2,6 +2,8 @@ package services
 
 import (
 	"errors"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
@@ -10,66 +12,290 @@ import (
 	"reservas-api/internal/domain"
 	"reservas-api/internal/dto"
 	"reservas-api/internal/messaging"
+	"reservas-api/internal/repositories"
+	"reservas-api/internal/utils"
+	"shared/auth"
+	"shared/schedule"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // Mocks para aislar el servicio
 type mockReservaRepository struct {
+	mu             sync.Mutex
 	created        *domain.Reserva
 	availabilityOk bool
+	stored         map[string]*domain.Reserva
+	// createErr, si está, decide que Create falle para una reserva (otro pedido ganó el turno tras la validación)
+	createErr func(reserva *domain.Reserva) error
 }
 
+// occupies indica si la reserva ocupa su turno, como activeFilter del repositorio real
+func occupies(r *domain.Reserva) bool {
+	return r.Status != "cancelled" && r.Status != "expired" && !r.HoldExpired(time.Now())
+}
+
+// Create emula los bloqueos del repositorio real: rechaza de forma atómica un turno superpuesto
 func (m *mockReservaRepository) Create(reserva *domain.Reserva) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.createErr != nil {
+		if err := m.createErr(reserva); err != nil {
+			return err
+		}
+	}
+	if start, end, err := utils.SlotInterval(reserva.Date, reserva.StartTime, reserva.Duration); err == nil && occupies(reserva) {
+		for _, r := range m.stored {
+			otherStart, otherEnd, err := utils.SlotInterval(r.Date, r.StartTime, r.Duration)
+			if err == nil && r.CanchaID == reserva.CanchaID && occupies(r) && start.Before(otherEnd) && otherStart.Before(end) {
+				return repositories.ErrSlotUnavailable
+			}
+		}
+	}
 	m.created = reserva
 	// simula persistencia asignando ID y fechas
 	reserva.ID = primitive.NewObjectID()
 	reserva.CreatedAt = time.Now()
 	reserva.UpdatedAt = time.Now()
+	if m.stored == nil {
+		m.stored = map[string]*domain.Reserva{}
+	}
+	m.stored[reserva.ID.Hex()] = reserva
 	return nil
 }
 func (m *mockReservaRepository) GetByID(id string) (*domain.Reserva, error) {
-	return nil, errors.New("not implemented")
+	if r, ok := m.stored[id]; ok {
+		return r, nil
+	}
+	return nil, errors.New("reserva not found")
 }
 func (m *mockReservaRepository) GetAll() ([]domain.Reserva, error) { return nil, nil }
 func (m *mockReservaRepository) GetByUserID(userID uint) ([]domain.Reserva, error) {
 	return nil, nil
 }
+func (m *mockReservaRepository) GetActiveByUserIDFrom(userID uint, from time.Time) ([]domain.Reserva, error) {
+	var reservas []domain.Reserva
+	for _, r := range m.stored {
+		if r.UserID == userID && !r.Date.Before(from) && r.Status != "cancelled" {
+			reservas = append(reservas, *r)
+		}
+	}
+	return reservas, nil
+}
+func (m *mockReservaRepository) AnonymizeUser(userID uint, userName string) (int64, error) {
+	var n int64
+	for _, r := range m.stored {
+		if r.UserID == userID {
+			r.UserName = userName
+			n++
+		}
+	}
+	return n, nil
+}
 func (m *mockReservaRepository) GetByCanchaID(canchaID string) ([]domain.Reserva, error) {
 	return nil, nil
 }
+func (m *mockReservaRepository) GetActiveByCanchasBetween(canchaIDs []string, from, to time.Time) ([]domain.Reserva, error) {
+	var reservas []domain.Reserva
+	for _, r := range m.stored {
+		if r.Date.Before(from) || r.Date.After(to) || r.Status == "cancelled" {
+			continue
+		}
+		for _, id := range canchaIDs {
+			if id == r.CanchaID {
+				reservas = append(reservas, *r)
+				break
+			}
+		}
+		if len(canchaIDs) == 0 {
+			reservas = append(reservas, *r)
+		}
+	}
+	return reservas, nil
+}
 func (m *mockReservaRepository) DeleteByCanchaID(canchaID string) (int64, error) { return 0, nil }
 func (m *mockReservaRepository) Update(id string, reserva *domain.Reserva) error { return nil }
-func (m *mockReservaRepository) Delete(id string) error                          { return nil }
+func (m *mockReservaRepository) Delete(id string) error {
+	if r, ok := m.stored[id]; ok {
+		r.Status = "cancelled"
+	}
+	return nil
+}
+func (m *mockReservaRepository) Purge(id string) error {
+	if _, ok := m.stored[id]; !ok {
+		return errors.New("reserva not found")
+	}
+	delete(m.stored, id)
+	return nil
+}
+func (m *mockReservaRepository) BackfillSlotLocks(from time.Time) error { return nil }
+func (m *mockReservaRepository) ExpireHolds(now time.Time) ([]domain.Reserva, error) {
+	var expired []domain.Reserva
+	for _, r := range m.stored {
+		if r.HoldExpired(now) {
+			r.Status = "expired"
+			expired = append(expired, *r)
+		}
+	}
+	return expired, nil
+}
+func (m *mockReservaRepository) GetBySeriesID(seriesID string) ([]domain.Reserva, error) {
+	var reservas []domain.Reserva
+	for _, r := range m.stored {
+		if r.SeriesID == seriesID {
+			reservas = append(reservas, *r)
+		}
+	}
+	sort.Slice(reservas, func(i, j int) bool { return reservas[i].Date.Before(reservas[j].Date) })
+	return reservas, nil
+}
 func (m *mockReservaRepository) CheckAvailability(canchaID string, date time.Time, startTime, endTime string) (bool, error) {
-	return m.availabilityOk, nil
+	if !m.availabilityOk {
+		return false, nil
+	}
+	for _, r := range m.stored {
+		if r.CanchaID == canchaID && r.Date.Equal(date) && r.StartTime == startTime && occupies(r) {
+			return false, nil
+		}
+	}
+	return true, nil
 }
 
-type mockUserClient struct {
-	valid bool
-	data  *clients.UserResponse
-	err   error
+// mockWaitlistRepository guarda la lista de espera en memoria
+type mockWaitlistRepository struct {
+	entries []*domain.WaitlistEntry
 }
 
-func (m *mockUserClient) ValidateUser(userID uint, token string) (bool, *clients.UserResponse, error) {
-	return m.valid, m.data, m.err
+func newMockWaitlistRepo() *mockWaitlistRepository {
+	return &mockWaitlistRepository{}
+}
+
+func (m *mockWaitlistRepository) Create(entry *domain.WaitlistEntry) error {
+	// Igual que el índice único parcial de Mongo sobre las entradas activas
+	for _, e := range m.entries {
+		if e.UserID == entry.UserID && e.CanchaID == entry.CanchaID && e.Date.Equal(entry.Date) && e.StartTime == entry.StartTime &&
+			(e.Status == domain.WaitlistWaiting || e.Status == domain.WaitlistOffered) {
+			return repositories.ErrAlreadyWaiting
+		}
+	}
+	entry.ID = primitive.NewObjectID()
+	entry.CreatedAt = time.Now()
+	m.entries = append(m.entries, entry)
+	return nil
+}
+func (m *mockWaitlistRepository) GetByID(id string) (*domain.WaitlistEntry, error) {
+	for _, e := range m.entries {
+		if e.ID.Hex() == id {
+			copied := *e
+			return &copied, nil
+		}
+	}
+	return nil, errors.New("waitlist entry not found")
+}
+func (m *mockWaitlistRepository) GetByUserID(userID uint) ([]domain.WaitlistEntry, error) {
+	var entries []domain.WaitlistEntry
+	for _, e := range m.entries {
+		if e.UserID == userID {
+			entries = append(entries, *e)
+		}
+	}
+	return entries, nil
+}
+func (m *mockWaitlistRepository) GetByReservaID(reservaID string) (*domain.WaitlistEntry, error) {
+	for _, e := range m.entries {
+		if e.ReservaID == reservaID {
+			copied := *e
+			return &copied, nil
+		}
+	}
+	return nil, errors.New("waitlist entry not found")
+}
+func (m *mockWaitlistRepository) FindWaiting(canchaID string, date time.Time) ([]domain.WaitlistEntry, error) {
+	var entries []domain.WaitlistEntry
+	for _, e := range m.entries {
+		if e.CanchaID == canchaID && e.Date.Equal(date) && e.Status == domain.WaitlistWaiting {
+			entries = append(entries, *e)
+		}
+	}
+	return entries, nil
+}
+func (m *mockWaitlistRepository) MarkOffered(id primitive.ObjectID, reservaID string, at time.Time) (bool, error) {
+	for _, e := range m.entries {
+		if e.ID == id && e.Status == domain.WaitlistWaiting {
+			e.Status = domain.WaitlistOffered
+			e.ReservaID = reservaID
+			e.OfferedAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+func (m *mockWaitlistRepository) SetStatus(id primitive.ObjectID, from []string, status string) (bool, error) {
+	for _, e := range m.entries {
+		if e.ID != id {
+			continue
+		}
+		for _, f := range from {
+			if e.Status == f {
+				e.Status = status
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+func (m *mockWaitlistRepository) LeaveAllByUser(userID uint, userName string) (int64, error) {
+	var n int64
+	for _, e := range m.entries {
+		if e.UserID == userID && e.Status == domain.WaitlistWaiting {
+			e.Status = domain.WaitlistLeft
+			n++
+		}
+		if e.UserID == userID && userName != "" {
+			e.UserName = userName
+		}
+	}
+	return n, nil
 }
 
 type mockCanchaClient struct {
-	valid bool
-	data  *clients.CanchaResponse
-	err   error
+	valid     bool
+	data      *clients.CanchaResponse
+	err       error
+	blackouts []clients.BlackoutResponse
+	// memberDiscount se resta del precio de la cancha cuando se cotiza con tarifa de socio
+	memberDiscount float64
 }
 
 func (m *mockCanchaClient) ValidateCancha(canchaID string) (bool, *clients.CanchaResponse, error) {
 	return m.valid, m.data, m.err
 }
 
+func (m *mockCanchaClient) GetQuote(canchaID string, date time.Time, startTime string, member bool) (*clients.QuoteResponse, error) {
+	quote := &clients.QuoteResponse{
+		Items:  []clients.QuoteItem{{Kind: "base", Name: "Turno", Amount: m.data.Price}},
+		Total:  m.data.Price,
+		Member: member,
+	}
+	if member && m.memberDiscount > 0 {
+		quote.Items = append(quote.Items, clients.QuoteItem{Kind: "discount", Name: "Descuento socio", Amount: -m.memberDiscount})
+		quote.Total -= m.memberDiscount
+	}
+	return quote, nil
+}
+
+func (m *mockCanchaClient) GetBlackouts(canchaID string, from, to time.Time) ([]clients.BlackoutResponse, error) {
+	return m.blackouts, nil
+}
+
 type mockPublisher struct {
+	mu     sync.Mutex
 	events []messaging.Event
 }
 
 func (m *mockPublisher) PublishEvent(e messaging.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.events = append(m.events, e)
 	return nil
 }
@@ -78,70 +304,841 @@ func (m *mockPublisher) Close() error {
 	return nil
 }
 
-// Tests
-func TestCreateReservaSuccess(t *testing.T) {
-	repo := &mockReservaRepository{availabilityOk: true}
-	userCli := &mockUserClient{
-		valid: true,
-		data: &clients.UserResponse{
-			ID:        1,
-			FirstName: "Alice",
-			LastName:  "Doe",
-		},
-	}
-	canchaCli := &mockCanchaClient{
-		valid: true,
-		data: &clients.CanchaResponse{
-			ID:        "c1",
-			Name:      "Cancha Uno",
-			Type:      "futbol",
-			Price:     100,
-			Available: true,
+// reservaFixture es el servicio bajo prueba con sus dobles, sobre la cancha c1 "Cancha Uno"
+type reservaFixture struct {
+	svc       ReservaService
+	repo      *mockReservaRepository
+	waitlist  *mockWaitlistRepository
+	canchaCli *mockCanchaClient
+	pub       *mockPublisher
+}
+
+// newReservaFixture instala cfg como configuración y arma el servicio. Los tests ajustan los dobles
+// después (horario, cierres, descuentos), pero la configuración se lee al crear el servicio.
+func newReservaFixture(cfg config.Config) *reservaFixture {
+	config.AppConfig = &cfg
+	f := &reservaFixture{
+		repo:     &mockReservaRepository{availabilityOk: true},
+		waitlist: newMockWaitlistRepo(),
+		canchaCli: &mockCanchaClient{
+			valid: true,
+			data:  &clients.CanchaResponse{ID: "c1", Name: "Cancha Uno", Type: "futbol", Price: 100, Available: true},
 		},
+		pub: &mockPublisher{},
 	}
-	pub := &mockPublisher{}
-	config.AppConfig = &config.Config{}
+	f.svc = NewReservaService(f.repo, f.waitlist, f.canchaCli, f.pub)
+	return f
+}
+
+// player arma los claims de un usuario común
+func player(id uint, name string) *auth.Claims {
+	return &auth.Claims{UserID: id, Username: name, Role: auth.RoleNormal}
+}
 
-	svc := NewReservaService(repo, userCli, canchaCli, pub)
+// Tests
+func TestCreateReservaSuccess(t *testing.T) {
+	f := newReservaFixture(config.Config{})
+	claims := player(1, "alice")
 
 	req := &dto.CreateReservaRequest{
 		CanchaID:  "c1",
-		UserID:    1,
 		Date:      time.Now().Add(24 * time.Hour).Format("2006-01-02"),
 		StartTime: "10:00",
 		EndTime:   "11:00",
 	}
 
-	resp, err := svc.Create(req, "token")
+	resp, err := f.svc.Create(req, claims)
 	if err != nil {
 		t.Fatalf("se esperaba reserva creada sin error, llego: %v", err)
 	}
-	if resp.CanchaID != "c1" || resp.UserID != 1 {
+	if resp.CanchaID != "c1" || resp.UserID != 1 || resp.UserName != "alice" {
 		t.Fatalf("respuesta inesperada: %+v", resp)
 	}
-	if len(pub.events) != 1 || pub.events[0].Type != "create" {
-		t.Fatalf("debe publicarse un evento create, eventos: %+v", pub.events)
+	if len(f.pub.events) != 1 || f.pub.events[0].Type != "create" {
+		t.Fatalf("debe publicarse un evento create, eventos: %+v", f.pub.events)
 	}
 }
 
 func TestCreateReservaUnavailable(t *testing.T) {
-	repo := &mockReservaRepository{availabilityOk: false}
-	userCli := &mockUserClient{valid: true, data: &clients.UserResponse{ID: 1, FirstName: "Test", LastName: "User"}}
-	canchaCli := &mockCanchaClient{valid: true, data: &clients.CanchaResponse{ID: "c1", Name: "Cancha", Type: "futbol", Price: 100, Available: true}}
-	pub := &mockPublisher{}
-	config.AppConfig = &config.Config{}
-
-	svc := NewReservaService(repo, userCli, canchaCli, pub)
+	f := newReservaFixture(config.Config{})
+	tomorrow, _ := time.Parse("2006-01-02", time.Now().Add(24*time.Hour).Format("2006-01-02"))
+	_ = f.repo.Create(&domain.Reserva{CanchaID: "c1", UserID: 2, Date: tomorrow, StartTime: "10:00", EndTime: "11:00", Duration: 60, Status: "confirmed"})
+	claims := player(1, "test")
 
 	req := &dto.CreateReservaRequest{
 		CanchaID:  "c1",
-		UserID:    1,
 		Date:      time.Now().Add(24 * time.Hour).Format("2006-01-02"),
 		StartTime: "10:00",
 		EndTime:   "11:00",
 	}
 
-	if _, err := svc.Create(req, "token"); err == nil {
-		t.Fatalf("se esperaba error por disponibilidad, llegó nil")
+	if _, err := f.svc.Create(req, claims); !errors.Is(err, repositories.ErrSlotUnavailable) {
+		t.Fatalf("se esperaba error por disponibilidad, llegó %v", err)
+	}
+}
+
+func TestCancelReservaOwnership(t *testing.T) {
+	// Solo el dueño o quien tenga reservas:cancel_any pueden cancelar una reserva
+	f := newReservaFixture(config.Config{})
+	_ = f.repo.Create(&domain.Reserva{CanchaID: "c1", UserID: 1, Status: "confirmed"})
+	id := f.repo.created.ID.Hex()
+
+	other := &auth.Claims{UserID: 2, Role: auth.RoleNormal}
+	if err := f.svc.Cancel(id, other); err == nil || err.Error() != "forbidden" {
+		t.Fatalf("se esperaba forbidden para otro usuario, llegó: %v", err)
+	}
+
+	// Un admin sin 2FA no ejerce sus permisos
+	admin := &auth.Claims{UserID: 99, Role: auth.RoleAdmin, Permissions: []string{auth.PermReservasCancelAny}}
+	if err := f.svc.Cancel(id, admin); err == nil || err.Error() != "forbidden" {
+		t.Fatalf("se esperaba forbidden para un admin sin 2FA, llegó: %v", err)
+	}
+
+	staff := &auth.Claims{UserID: 50, Role: auth.RoleStaff, Permissions: []string{auth.PermReservasCancelAny}}
+	if err := f.svc.Cancel(id, staff); err != nil {
+		t.Fatalf("recepción debería poder cancelar, llegó: %v", err)
+	}
+	if f.repo.stored[id].Status != "cancelled" {
+		t.Fatalf("la reserva debería quedar cancelada")
+	}
+}
+
+func TestUserErasedCancelsFutureAndAnonymizes(t *testing.T) {
+	f := newReservaFixture(config.Config{})
+	today := time.Now().Truncate(24 * time.Hour)
+	_ = f.repo.Create(&domain.Reserva{UserID: 1, UserName: "alice", Date: today.AddDate(0, 0, 2), StartTime: "18:00", Status: "confirmed"})
+	future := f.repo.created.ID.Hex()
+	_ = f.repo.Create(&domain.Reserva{UserID: 1, UserName: "alice", Date: today.AddDate(0, 0, -3), StartTime: "18:00", Status: "confirmed"})
+	past := f.repo.created.ID.Hex()
+	_ = f.repo.Create(&domain.Reserva{UserID: 2, UserName: "bob", Date: today.AddDate(0, 0, 2), StartTime: "18:00", Status: "confirmed"})
+	other := f.repo.created.ID.Hex()
+
+	if err := f.svc.HandleUserEvent("erased", 1); err != nil {
+		t.Fatalf("no se esperaba error, llegó: %v", err)
+	}
+
+	if f.repo.stored[future].Status != "cancelled" {
+		t.Fatalf("la reserva futura debería quedar cancelada")
+	}
+	if f.repo.stored[past].Status != "confirmed" || f.repo.stored[other].Status != "confirmed" {
+		t.Fatalf("solo deben cancelarse las reservas futuras del usuario")
+	}
+	if f.repo.stored[future].UserName != erasedUserName || f.repo.stored[past].UserName != erasedUserName {
+		t.Fatalf("el nombre del usuario debería quedar anonimizado")
+	}
+	if f.repo.stored[other].UserName != "bob" {
+		t.Fatalf("no deben tocarse reservas de otros usuarios")
+	}
+	if len(f.pub.events) != 1 || f.pub.events[0].Type != "cancel" || f.pub.events[0].EntityID != future {
+		t.Fatalf("debe publicarse un cancel por la reserva futura, eventos: %+v", f.pub.events)
+	}
+
+	// Reactivar no toca las reservas
+	if err := f.svc.HandleUserEvent("reactivated", 2); err != nil || f.repo.stored[other].Status != "confirmed" {
+		t.Fatalf("reactivated no debe cancelar reservas, error: %v", err)
+	}
+}
+
+func TestConfirmAndCheckIn(t *testing.T) {
+	f := newReservaFixture(config.Config{})
+	_ = f.repo.Create(&domain.Reserva{CanchaID: "c1", UserID: 1, Status: "pending"})
+	id := f.repo.created.ID.Hex()
+
+	if _, err := f.svc.CheckIn(id); err == nil || err.Error() != "only confirmed reservations can be checked in" {
+		t.Fatalf("no debe poder hacerse check-in de una reserva pendiente, llegó: %v", err)
+	}
+
+	staff := &auth.Claims{UserID: 9, Role: auth.RoleStaff, Permissions: []string{auth.PermReservasConfirm}}
+	if _, err := f.svc.Confirm(id, &auth.Claims{UserID: 1, Role: auth.RoleNormal}); err == nil || err.Error() != "forbidden" {
+		t.Fatalf("el dueño no debe poder confirmar una reserva pendiente que no es un turno retenido, llegó: %v", err)
+	}
+	resp, err := f.svc.Confirm(id, staff)
+	if err != nil || resp.Status != "confirmed" {
+		t.Fatalf("se esperaba la reserva confirmada, llegó: %+v, %v", resp, err)
+	}
+	if _, err := f.svc.Confirm(id, staff); err == nil || err.Error() != "reservation already confirmed" {
+		t.Fatalf("se esperaba error por confirmación repetida, llegó: %v", err)
+	}
+
+	resp, err = f.svc.CheckIn(id)
+	if err != nil || resp.CheckedInAt == nil {
+		t.Fatalf("se esperaba el check-in registrado, llegó: %+v, %v", resp, err)
+	}
+	if _, err := f.svc.CheckIn(id); err == nil || err.Error() != "reservation already checked in" {
+		t.Fatalf("se esperaba error por check-in repetido, llegó: %v", err)
+	}
+
+	if len(f.pub.events) != 2 || f.pub.events[0].Type != "confirm" || f.pub.events[1].Type != "check_in" {
+		t.Fatalf("se esperaban eventos confirm y check_in, llegaron: %+v", f.pub.events)
+	}
+}
+
+func TestCreateAndUpdateFollowCanchaSchedule(t *testing.T) {
+	f := newReservaFixture(config.Config{})
+	claims := player(1, "alice")
+	tomorrow := time.Now().Add(24 * time.Hour)
+	// Abre solo mañana, de 08:00 a 12:00, con turnos de 60 minutos cada 30
+	sched := &schedule.Schedule{
+		Days:         []schedule.Day{{Weekday: tomorrow.Weekday(), Open: "08:00", Close: "12:00"}},
+		SlotMinutes:  60,
+		AlignMinutes: 30,
+	}
+	f.canchaCli.data.Schedule = sched
+
+	resp, err := f.svc.Create(&dto.CreateReservaRequest{CanchaID: "c1", Date: tomorrow.Format("2006-01-02"), StartTime: "08:30"}, claims)
+	if err != nil || resp.EndTime != "09:30" || resp.Duration != 60 {
+		t.Fatalf("se esperaba el turno 08:30-09:30, llegó %+v, error: %v", resp, err)
+	}
+
+	// 20:00 era válido con el horario fijo de antes pero esta cancha ya cerró
+	_, err = f.svc.Create(&dto.CreateReservaRequest{CanchaID: "c1", Date: tomorrow.Format("2006-01-02"), StartTime: "20:00"}, claims)
+	var slotErr *schedule.SlotError
+	if !errors.As(err, &slotErr) {
+		t.Fatalf("se esperaba un SlotError fuera de horario, llegó %v", err)
+	}
+
+	// Mover la reserva a un día en que la cancha no abre
+	nextDay := tomorrow.Add(24 * time.Hour).Format("2006-01-02")
+	if _, err := f.svc.Update(resp.ID, &dto.UpdateReservaRequest{Date: nextDay}, claims); !errors.As(err, &slotErr) || slotErr.Message != "cancha is closed on "+tomorrow.Add(24*time.Hour).Weekday().String() {
+		t.Fatalf("se esperaba rechazo por día cerrado, llegó %v", err)
+	}
+}
+
+func TestBlackoutsRejectBookingsAndCancelConflicts(t *testing.T) {
+	f := newReservaFixture(config.Config{})
+	claims := player(1, "alice")
+	tomorrow := time.Now().Add(24 * time.Hour).Truncate(24 * time.Hour)
+
+	date := tomorrow.Format("2006-01-02")
+	early, err := f.svc.Create(&dto.CreateReservaRequest{CanchaID: "c1", Date: date, StartTime: "12:00"}, claims)
+	if err != nil {
+		t.Fatalf("se esperaba reserva creada, llegó %v", err)
+	}
+	late, err := f.svc.Create(&dto.CreateReservaRequest{CanchaID: "c1", Date: date, StartTime: "01:00"}, claims)
+	if err != nil {
+		t.Fatalf("se esperaba reserva de madrugada creada, llegó %v", err)
+	}
+
+	// Cierre desde las 18:00 hasta las 02:00 del día siguiente
+	f.canchaCli.blackouts = []clients.BlackoutResponse{{CanchaID: "c1", Start: tomorrow.Add(18 * time.Hour), End: tomorrow.Add(26 * time.Hour), Reason: "Torneo"}}
+	if _, err := f.svc.Create(&dto.CreateReservaRequest{CanchaID: "c1", Date: date, StartTime: "20:00"}, claims); !errors.Is(err, ErrCanchaClosed) {
+		t.Fatalf("se esperaba ErrCanchaClosed, llegó %v", err)
+	}
+
+	closure := &dto.ConflictsRequest{CanchaIDs: []string{"c1"}, Start: tomorrow.Add(18 * time.Hour), End: tomorrow.Add(26 * time.Hour)}
+	listed, err := f.svc.FindConflicts(closure, false)
+	if err != nil || len(listed.Reservas) != 1 || listed.Cancelled != 0 || f.repo.stored[late.ID].Status == "cancelled" {
+		t.Fatalf("la consulta de solo lectura no debe cancelar, llegó %+v, %v", listed, err)
+	}
+
+	conflicts, err := f.svc.FindConflicts(closure, true)
+	if err != nil {
+		t.Fatalf("se esperaba sin error, llegó %v", err)
+	}
+	if len(conflicts.Reservas) != 1 || conflicts.Reservas[0].ID != late.ID || conflicts.Cancelled != 1 {
+		t.Fatalf("solo la reserva de la 01:00 choca con el cierre, llegó %+v", conflicts)
+	}
+	if f.repo.stored[late.ID].Status != "cancelled" || f.repo.stored[early.ID].Status == "cancelled" {
+		t.Fatalf("se esperaba cancelada solo la reserva en conflicto")
+	}
+	if last := f.pub.events[len(f.pub.events)-1]; last.Type != "cancel" || last.EntityID != late.ID {
+		t.Fatalf("se esperaba un evento cancel de la reserva en conflicto, llegó %+v", last)
+	}
+
+	if _, err := f.svc.Update(early.ID, &dto.UpdateReservaRequest{StartTime: "18:00", EndTime: "19:00"}, claims); !errors.Is(err, ErrCanchaClosed) {
+		t.Fatalf("se esperaba ErrCanchaClosed al mover la reserva, llegó %v", err)
+	}
+}
+
+func TestReservaStoresItemizedQuote(t *testing.T) {
+	f := newReservaFixture(config.Config{})
+	f.canchaCli.memberDiscount = 20
+	member := &auth.Claims{UserID: 1, Username: "socio", Role: auth.RoleMember, Permissions: []string{auth.PermReservasMember}}
+	staff := &auth.Claims{UserID: 2, Username: "recepcion", Role: auth.RoleStaff, Permissions: []string{auth.PermReservasUpdateAny}}
+
+	date := time.Now().Add(24 * time.Hour).Format("2006-01-02")
+	resp, err := f.svc.Create(&dto.CreateReservaRequest{CanchaID: "c1", Date: date, StartTime: "18:00"}, member)
+	if err != nil {
+		t.Fatalf("se esperaba reserva creada, llegó %v", err)
+	}
+	if resp.TotalPrice != 80 || !resp.MemberPrice || len(resp.PriceItems) != 2 || resp.PriceItems[1].Kind != "discount" {
+		t.Fatalf("se esperaba el desglose con descuento de socio, llegó %+v", resp)
+	}
+
+	// Recepción mueve la reserva: se conserva la tarifa de socio del dueño
+	updated, err := f.svc.Update(resp.ID, &dto.UpdateReservaRequest{StartTime: "19:00", EndTime: "20:00"}, staff)
+	if err != nil {
+		t.Fatalf("se esperaba sin error, llegó %v", err)
+	}
+	if updated.TotalPrice != 80 || !updated.MemberPrice {
+		t.Fatalf("se esperaba conservar la tarifa de socio, llegó %+v", updated)
+	}
+}
+
+func TestConcurrentCreatesOnlyOneWins(t *testing.T) {
+	f := newReservaFixture(config.Config{})
+	date := time.Now().Add(24 * time.Hour).Format("2006-01-02")
+
+	const attempts = 300
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	won, lost := 0, 0
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(userID uint) {
+			defer wg.Done()
+			claims := &auth.Claims{UserID: userID, Username: "jugador", Role: auth.RoleNormal}
+			_, err := f.svc.Create(&dto.CreateReservaRequest{CanchaID: "c1", Date: date, StartTime: "18:00"}, claims)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				won++
+			case errors.Is(err, repositories.ErrSlotUnavailable):
+				lost++
+			default:
+				t.Errorf("error inesperado: %v", err)
+			}
+		}(uint(i + 1))
+	}
+	wg.Wait()
+
+	if won != 1 || lost != attempts-1 {
+		t.Fatalf("se esperaba exactamente una reserva ganadora, ganaron %d y perdieron %d", won, lost)
+	}
+	if len(f.repo.stored) != 1 {
+		t.Fatalf("se esperaba una sola reserva guardada, hay %d", len(f.repo.stored))
+	}
+}
+
+func TestPendingHoldsBlockTheSlotUntilTheyExpire(t *testing.T) {
+	f := newReservaFixture(config.Config{HoldMinutes: 15})
+
+	alice := player(1, "alice")
+	bob := player(2, "bob")
+	date := time.Now().Add(48 * time.Hour).Format("2006-01-02")
+	req := func(hold bool) *dto.CreateReservaRequest {
+		return &dto.CreateReservaRequest{CanchaID: "c1", Date: date, StartTime: "18:00", EndTime: "19:00", Hold: hold}
+	}
+
+	held, err := f.svc.Create(req(true), alice)
+	if err != nil || held.Status != "pending" || held.HoldExpiresAt == nil {
+		t.Fatalf("se esperaba un turno retenido, llegó: %+v, %v", held, err)
+	}
+	if remaining := time.Until(*held.HoldExpiresAt); remaining < 14*time.Minute || remaining > 15*time.Minute {
+		t.Fatalf("el turno debe retenerse HOLD_MINUTES, vence en %v", remaining)
+	}
+
+	// Mientras el turno está retenido cuenta como ocupado
+	if _, err := f.svc.Create(req(false), bob); !errors.Is(err, repositories.ErrSlotUnavailable) {
+		t.Fatalf("un turno retenido vigente debe bloquear el turno, llegó: %v", err)
+	}
+	if _, err := f.svc.Confirm(held.ID, bob); err == nil || err.Error() != "forbidden" {
+		t.Fatalf("otro usuario no debe poder confirmar el turno, llegó: %v", err)
+	}
+
+	// Vencido el plazo el turno queda libre aunque el reaper todavía no haya pasado
+	past := time.Now().Add(-time.Minute)
+	f.repo.stored[held.ID].HoldExpiresAt = &past
+	if _, err := f.svc.Confirm(held.ID, alice); !errors.Is(err, ErrHoldExpired) {
+		t.Fatalf("no debe poder confirmarse un turno vencido, llegó: %v", err)
+	}
+	taken, err := f.svc.Create(req(false), bob)
+	if err != nil || taken.Status != "confirmed" {
+		t.Fatalf("el turno vencido debe quedar libre, llegó: %+v, %v", taken, err)
+	}
+
+	f.pub.events = nil
+	expired, err := f.svc.ExpireHolds()
+	if err != nil || expired != 1 || f.repo.stored[held.ID].Status != "expired" {
+		t.Fatalf("el reaper debe vencer el turno retenido, vencidos: %d, %v", expired, err)
+	}
+	if len(f.pub.events) != 1 || f.pub.events[0].Type != "expired" || f.pub.events[0].EntityID != held.ID {
+		t.Fatalf("se esperaba un evento reserva.expired, llegaron: %+v", f.pub.events)
+	}
+	if expired, _ := f.svc.ExpireHolds(); expired != 0 {
+		t.Fatalf("un turno ya vencido no debe volver a vencerse, vencidos: %d", expired)
+	}
+}
+
+func TestOwnerConfirmsHoldBeforeItExpires(t *testing.T) {
+	f := newReservaFixture(config.Config{})
+
+	alice := player(1, "alice")
+	held, err := f.svc.Create(&dto.CreateReservaRequest{
+		CanchaID: "c1", Date: time.Now().Add(48 * time.Hour).Format("2006-01-02"), StartTime: "18:00", EndTime: "19:00", Hold: true,
+	}, alice)
+	if err != nil {
+		t.Fatalf("se esperaba un turno retenido, llegó: %v", err)
+	}
+	if remaining := time.Until(*held.HoldExpiresAt); remaining > defaultHoldDuration || remaining < defaultHoldDuration-time.Minute {
+		t.Fatalf("sin configuración el turno debe retenerse %v, vence en %v", defaultHoldDuration, remaining)
+	}
+
+	confirmed, err := f.svc.Confirm(held.ID, alice)
+	if err != nil || confirmed.Status != "confirmed" || confirmed.HoldExpiresAt != nil {
+		t.Fatalf("el dueño debe poder confirmar su turno retenido, llegó: %+v, %v", confirmed, err)
+	}
+	if expired, _ := f.svc.ExpireHolds(); expired != 0 {
+		t.Fatalf("una reserva confirmada no debe vencer, vencidos: %d", expired)
+	}
+}
+
+func TestUpdateStatusGoesThroughConfirmAndCancel(t *testing.T) {
+	f := newReservaFixture(config.Config{})
+	alice := player(1, "alice")
+	staff := &auth.Claims{UserID: 9, Role: auth.RoleStaff, Permissions: []string{auth.PermReservasUpdateAny}}
+	date := time.Now().Add(48 * time.Hour).Format("2006-01-02")
+	status := func(s string) *dto.UpdateReservaRequest { return &dto.UpdateReservaRequest{Status: s} }
+
+	// Una reserva pendiente que no es un turno retenido solo la confirma recepción
+	_ = f.repo.Create(&domain.Reserva{CanchaID: "c1", UserID: 1, Status: "pending"})
+	pending := f.repo.created.ID.Hex()
+	if _, err := f.svc.Update(pending, status("confirmed"), alice); err == nil || err.Error() != "forbidden" {
+		t.Fatalf("el dueño no debe confirmar por PUT lo que no puede confirmar, llegó: %v", err)
+	}
+	if f.repo.stored[pending].Status != "pending" {
+		t.Fatalf("la reserva no debe cambiar de estado, quedó %s", f.repo.stored[pending].Status)
+	}
+
+	// Una reserva confirmada no vuelve a pendiente: quedaría retenida sin vencimiento
+	booked, err := f.svc.Create(&dto.CreateReservaRequest{CanchaID: "c1", Date: date, StartTime: "18:00"}, alice)
+	if err != nil {
+		t.Fatalf("no se pudo crear la reserva: %v", err)
+	}
+	if _, err := f.svc.Update(booked.ID, status("pending"), alice); !errors.Is(err, ErrStatusChange) {
+		t.Fatalf("se esperaba ErrStatusChange al volver a pendiente, llegó: %v", err)
+	}
+	if r := f.repo.stored[booked.ID]; r.Status != "confirmed" || r.HoldExpiresAt != nil {
+		t.Fatalf("la reserva debe seguir confirmada, llegó: %+v", r)
+	}
+
+	// Un turno retenido se confirma por PUT igual que por Confirm, pero no junto con un cambio de horario
+	held, err := f.svc.Create(&dto.CreateReservaRequest{CanchaID: "c1", Date: date, StartTime: "20:00", Hold: true}, alice)
+	if err != nil {
+		t.Fatalf("no se pudo retener el turno: %v", err)
+	}
+	if _, err := f.svc.Update(held.ID, &dto.UpdateReservaRequest{Status: "confirmed", StartTime: "21:00"}, alice); !errors.Is(err, ErrStatusChange) {
+		t.Fatalf("se esperaba ErrStatusChange al confirmar y mover a la vez, llegó: %v", err)
+	}
+	f.pub.events = nil
+	confirmed, err := f.svc.Update(held.ID, status("confirmed"), alice)
+	if err != nil || confirmed.Status != "confirmed" || confirmed.HoldExpiresAt != nil || confirmed.StartTime != "20:00" {
+		t.Fatalf("se esperaba el turno confirmado, llegó: %+v, %v", confirmed, err)
+	}
+	if len(f.pub.events) != 1 || f.pub.events[0].Type != "confirm" {
+		t.Fatalf("se esperaba un evento confirm, llegaron: %+v", f.pub.events)
+	}
+
+	// Cancelar por PUT exige el mismo permiso que cancelar
+	if _, err := f.svc.Update(booked.ID, status("cancelled"), staff); err == nil || err.Error() != "forbidden" {
+		t.Fatalf("quien solo puede modificar no debe poder cancelar, llegó: %v", err)
+	}
+	f.pub.events = nil
+	cancelled, err := f.svc.Update(booked.ID, status("cancelled"), alice)
+	if err != nil || cancelled.Status != "cancelled" || f.repo.stored[booked.ID].Status != "cancelled" {
+		t.Fatalf("se esperaba la reserva cancelada, llegó: %+v, %v", cancelled, err)
+	}
+	if len(f.pub.events) != 1 || f.pub.events[0].Type != "cancel" {
+		t.Fatalf("se esperaba un evento cancel, llegaron: %+v", f.pub.events)
+	}
+	if _, err := f.svc.Update(booked.ID, status("confirmed"), alice); err == nil || f.repo.stored[booked.ID].Status != "cancelled" {
+		t.Fatalf("no debe poder reactivarse una reserva cancelada, llegó: %v", err)
+	}
+}
+
+// nextWeekday retorna la próxima fecha (UTC, sin hora) que cae en weekday, al menos a una semana de hoy
+func nextWeekday(weekday time.Weekday) time.Time {
+	date := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 7)
+	for date.Weekday() != weekday {
+		date = date.AddDate(0, 0, 1)
+	}
+	return date
+}
+
+func TestRecurringSeriesAllOrNothingOrSkipConflicts(t *testing.T) {
+	f := newReservaFixture(config.Config{})
+	team := player(1, "equipo")
+
+	// Otro usuario ya tiene el tercer martes
+	first := nextWeekday(time.Tuesday)
+	_ = f.repo.Create(&domain.Reserva{CanchaID: "c1", UserID: 2, Date: first.AddDate(0, 0, 14), StartTime: "20:00", EndTime: "21:00", Duration: 60, Status: "confirmed"})
+
+	req := &dto.CreateRecurringRequest{
+		CanchaID:   "c1",
+		Date:       utils.FormatDate(first),
+		StartTime:  "20:00",
+		EndTime:    "21:00",
+		Recurrence: dto.RecurrenceRule{Frequency: "weekly", Count: 4},
+	}
+	if _, err := f.svc.CreateRecurring(req, team); !errors.Is(err, ErrSeriesConflicts) {
+		t.Fatalf("sin skip_conflicts la serie debe fallar completa, llegó: %v", err)
+	}
+	if len(f.repo.stored) != 1 || len(f.pub.events) != 0 {
+		t.Fatalf("no debe crearse ninguna reserva de la serie, hay %d reservas y %d eventos", len(f.repo.stored), len(f.pub.events))
+	}
+
+	req.SkipConflicts = true
+	series, err := f.svc.CreateRecurring(req, team)
+	if err != nil {
+		t.Fatalf("con skip_conflicts se esperaba la serie creada, llegó: %v", err)
+	}
+	if len(series.Reservas) != 3 || len(series.Skipped) != 1 || series.Skipped[0].Date != utils.FormatDate(first.AddDate(0, 0, 14)) {
+		t.Fatalf("se esperaban 3 reservas y el tercer martes salteado, llegó: %+v", series)
+	}
+	for _, reserva := range series.Reservas {
+		if reserva.SeriesID != series.SeriesID || reserva.StartTime != "20:00" || reserva.Status != "confirmed" {
+			t.Fatalf("reserva de la serie inesperada: %+v", reserva)
+		}
+	}
+	if len(f.pub.events) != 3 {
+		t.Fatalf("se esperaba un evento create por reserva, llegaron: %d", len(f.pub.events))
+	}
+
+	// Un horario fuera del horario de la cancha en todas las fechas
+	req.StartTime, req.EndTime = "03:00", "04:00"
+	if _, err := f.svc.CreateRecurring(req, team); !errors.Is(err, ErrSeriesConflicts) {
+		t.Fatalf("si ninguna fecha se puede reservar la serie debe fallar, llegó: %v", err)
+	}
+
+	req.Recurrence = dto.RecurrenceRule{Frequency: "weekly"}
+	if _, err := f.svc.CreateRecurring(req, team); !errors.Is(err, utils.ErrInvalidRecurrence) {
+		t.Fatalf("una regla sin until ni count debe rechazarse, llegó: %v", err)
+	}
+	req.Recurrence = dto.RecurrenceRule{Frequency: "weekly", Until: utils.FormatDate(first.AddDate(2, 0, 0))}
+	if _, err := f.svc.CreateRecurring(req, team); !errors.Is(err, utils.ErrInvalidRecurrence) {
+		t.Fatalf("una serie de más de %d turnos debe rechazarse, llegó: %v", utils.MaxOccurrences, err)
+	}
+}
+
+func TestRecurringSeriesRollsBackWhenASlotIsTakenMidway(t *testing.T) {
+	f := newReservaFixture(config.Config{})
+	team := player(1, "equipo")
+	first := nextWeekday(time.Tuesday)
+	// Otro pedido toma el tercer martes entre la validación de la serie y su creación
+	f.repo.createErr = func(reserva *domain.Reserva) error {
+		if reserva.Date.Equal(first.AddDate(0, 0, 14)) {
+			return repositories.ErrSlotUnavailable
+		}
+		return nil
+	}
+
+	req := &dto.CreateRecurringRequest{
+		CanchaID:   "c1",
+		Date:       utils.FormatDate(first),
+		StartTime:  "20:00",
+		EndTime:    "21:00",
+		Recurrence: dto.RecurrenceRule{Frequency: "weekly", Count: 4},
+	}
+	if _, err := f.svc.CreateRecurring(req, team); !errors.Is(err, ErrSeriesConflicts) {
+		t.Fatalf("se esperaba ErrSeriesConflicts, llegó: %v", err)
+	}
+	// Las dos reservas ya creadas se borran: no quedan canceladas en el historial
+	if len(f.repo.stored) != 0 || len(f.pub.events) != 0 {
+		t.Fatalf("la serie fallida no debe dejar reservas ni eventos, hay %d reservas y %d eventos", len(f.repo.stored), len(f.pub.events))
+	}
+
+	req.SkipConflicts = true
+	series, err := f.svc.CreateRecurring(req, team)
+	if err != nil || len(series.Reservas) != 3 || len(series.Skipped) != 1 {
+		t.Fatalf("con skip_conflicts se esperaban 3 reservas y una salteada, llegó: %+v, %v", series, err)
+	}
+}
+
+func TestRecurringSeriesEditAndCancelRemaining(t *testing.T) {
+	f := newReservaFixture(config.Config{})
+	team := player(1, "equipo")
+	other := player(2, "otro")
+
+	first := nextWeekday(time.Tuesday)
+	series, err := f.svc.CreateRecurring(&dto.CreateRecurringRequest{
+		CanchaID:   "c1",
+		Date:       utils.FormatDate(first),
+		StartTime:  "20:00",
+		EndTime:    "21:00",
+		Recurrence: dto.RecurrenceRule{Frequency: "weekly", Interval: 2, Until: utils.FormatDate(first.AddDate(0, 0, 42))},
+	}, team)
+	if err != nil || len(series.Reservas) != 4 {
+		t.Fatalf("se esperaban 4 reservas cada dos semanas, llegó: %+v, %v", series, err)
+	}
+
+	if _, err := f.svc.CancelSeries(series.SeriesID, "", other); err == nil || err.Error() != "forbidden" {
+		t.Fatalf("otro usuario no debe poder cancelar la serie, llegó: %v", err)
+	}
+	if _, err := f.svc.GetSeries("missing", team); err == nil || err.Error() != "series not found" {
+		t.Fatalf("se esperaba serie no encontrada, llegó: %v", err)
+	}
+
+	// Mover las restantes desde la segunda fecha
+	second := utils.FormatDate(first.AddDate(0, 0, 14))
+	updated, err := f.svc.UpdateSeries(series.SeriesID, &dto.UpdateSeriesRequest{From: second, StartTime: "21:00", EndTime: "22:00"}, team)
+	if err != nil || len(updated.Reservas) != 3 || len(updated.Skipped) != 0 {
+		t.Fatalf("se esperaban 3 reservas movidas, llegó: %+v, %v", updated, err)
+	}
+
+	current, err := f.svc.GetSeries(series.SeriesID, team)
+	if err != nil {
+		t.Fatalf("se esperaba la serie, llegó: %v", err)
+	}
+	if current.Reservas[0].StartTime != "20:00" || current.Reservas[1].StartTime != "21:00" || current.Reservas[3].EndTime != "22:00" {
+		t.Fatalf("solo deben moverse las reservas desde la segunda fecha, llegó: %+v", current.Reservas)
+	}
+
+	cancelled, err := f.svc.CancelSeries(series.SeriesID, utils.FormatDate(first.AddDate(0, 0, 28)), team)
+	if err != nil || cancelled != 2 {
+		t.Fatalf("se esperaban 2 reservas canceladas, llegó: %d, %v", cancelled, err)
+	}
+	cancelled, err = f.svc.CancelSeries(series.SeriesID, "", team)
+	if err != nil || cancelled != 2 {
+		t.Fatalf("se esperaban canceladas las 2 reservas restantes de la serie, llegó: %d, %v", cancelled, err)
+	}
+}
+
+func TestWaitlistOffersFreedSlotsInOrder(t *testing.T) {
+	f := newReservaFixture(config.Config{})
+
+	alice, bob, carol, dan := player(1, "alice"), player(2, "bob"), player(3, "carol"), player(4, "dan")
+	date := time.Now().Add(48 * time.Hour).Format("2006-01-02")
+	slot := func() *dto.JoinWaitlistRequest {
+		return &dto.JoinWaitlistRequest{CanchaID: "c1", Date: date, StartTime: "18:00", EndTime: "19:00"}
+	}
+
+	booked, err := f.svc.Create(&dto.CreateReservaRequest{CanchaID: "c1", Date: date, StartTime: "18:00", EndTime: "19:00"}, alice)
+	if err != nil {
+		t.Fatalf("no se pudo crear la reserva: %v", err)
+	}
+
+	if _, err := f.svc.JoinWaitlist(&dto.JoinWaitlistRequest{CanchaID: "c1", Date: date, StartTime: "20:00", EndTime: "21:00"}, bob); !errors.Is(err, ErrSlotAvailable) {
+		t.Fatalf("no debe poder esperarse un turno libre, llegó: %v", err)
+	}
+	var entries []*dto.WaitlistEntryResponse
+	for _, user := range []*auth.Claims{bob, carol, dan} {
+		entry, err := f.svc.JoinWaitlist(slot(), user)
+		if err != nil {
+			t.Fatalf("%s debe poder anotarse en la lista de espera, llegó: %v", user.Username, err)
+		}
+		entries = append(entries, entry)
+	}
+	if entries[0].Position != 1 || entries[2].Position != 3 || entries[2].Status != domain.WaitlistWaiting {
+		t.Fatalf("la fila debe respetar el orden de llegada, llegó: %+v", entries)
+	}
+	if _, err := f.svc.JoinWaitlist(slot(), bob); !errors.Is(err, ErrAlreadyWaiting) {
+		t.Fatalf("no debe poder anotarse dos veces en el mismo turno, llegó: %v", err)
+	}
+
+	// Alice cancela: el turno queda retenido para Bob, el primero de la fila
+	f.pub.events = nil
+	if err := f.svc.Cancel(booked.ID, alice); err != nil {
+		t.Fatalf("no se pudo cancelar: %v", err)
+	}
+	bobs, _ := f.svc.GetWaitlists(bob)
+	if bobs.Total != 1 || bobs.Entries[0].Status != domain.WaitlistOffered || bobs.Entries[0].HoldExpiresAt == nil {
+		t.Fatalf("a Bob se le debe ofrecer el turno retenido, llegó: %+v", bobs)
+	}
+	offeredToBob := f.repo.stored[bobs.Entries[0].ReservaID]
+	if offeredToBob.UserID != bob.UserID || offeredToBob.Status != "pending" {
+		t.Fatalf("el turno ofrecido debe estar retenido a nombre de Bob, llegó: %+v", offeredToBob)
+	}
+	if len(f.pub.events) != 3 || f.pub.events[2].Type != "waitlist_offer" || f.pub.events[2].Data.(dto.WaitlistOfferEvent).UserID != bob.UserID {
+		t.Fatalf("se esperaban los eventos cancel, create y waitlist_offer, llegaron: %+v", f.pub.events)
+	}
+	carols, _ := f.svc.GetWaitlists(carol)
+	if carols.Entries[0].Position != 1 {
+		t.Fatalf("Carol pasa a ser la primera de la fila, llegó: %+v", carols.Entries[0])
+	}
+
+	// Bob no confirma a tiempo: la oferta vence y pasa a Carol
+	past := time.Now().Add(-time.Minute)
+	offeredToBob.HoldExpiresAt = &past
+	if expired, err := f.svc.ExpireHolds(); err != nil || expired != 1 {
+		t.Fatalf("se esperaba vencido el turno de Bob, llegó: %d, %v", expired, err)
+	}
+	bobs, _ = f.svc.GetWaitlists(bob)
+	carols, _ = f.svc.GetWaitlists(carol)
+	if bobs.Entries[0].Status != domain.WaitlistExpired || carols.Entries[0].Status != domain.WaitlistOffered {
+		t.Fatalf("la oferta vencida de Bob debe pasar a Carol, llegó: %+v / %+v", bobs.Entries[0], carols.Entries[0])
+	}
+
+	// Carol la rechaza saliendo de la lista: pasa a Dan, que la confirma
+	if err := f.svc.LeaveWaitlist(carols.Entries[0].ID, dan); err == nil || err.Error() != "forbidden" {
+		t.Fatalf("otro usuario no debe poder sacar a Carol de la lista, llegó: %v", err)
+	}
+	if err := f.svc.LeaveWaitlist(carols.Entries[0].ID, carol); err != nil {
+		t.Fatalf("Carol debe poder rechazar la oferta, llegó: %v", err)
+	}
+	if f.repo.stored[carols.Entries[0].ReservaID].Status != "cancelled" {
+		t.Fatalf("el turno retenido de Carol debe cancelarse al rechazarlo")
+	}
+	dans, _ := f.svc.GetWaitlists(dan)
+	if dans.Entries[0].Status != domain.WaitlistOffered {
+		t.Fatalf("a Dan se le debe ofrecer el turno, llegó: %+v", dans.Entries[0])
+	}
+	if _, err := f.svc.Confirm(dans.Entries[0].ReservaID, dan); err != nil {
+		t.Fatalf("Dan debe poder confirmar el turno ofrecido, llegó: %v", err)
+	}
+	dans, _ = f.svc.GetWaitlists(dan)
+	carols, _ = f.svc.GetWaitlists(carol)
+	if dans.Entries[0].Status != domain.WaitlistAccepted || carols.Entries[0].Status != domain.WaitlistLeft {
+		t.Fatalf("se esperaba aceptada la oferta de Dan y Carol fuera de la lista, llegó: %+v / %+v", dans.Entries[0], carols.Entries[0])
+	}
+	if err := f.svc.LeaveWaitlist(dans.Entries[0].ID, dan); err == nil || err.Error() != "waitlist entry is no longer active" {
+		t.Fatalf("no debe poder salirse de una entrada ya atendida, llegó: %v", err)
+	}
+
+	// Solo las entradas activas cuentan como duplicadas: Carol salió y puede volver a anotarse, una vez
+	if _, err := f.svc.JoinWaitlist(slot(), carol); err != nil {
+		t.Fatalf("Carol debe poder volver a la lista después de salir, llegó: %v", err)
+	}
+	if _, err := f.svc.JoinWaitlist(slot(), carol); !errors.Is(err, ErrAlreadyWaiting) {
+		t.Fatalf("no debe poder anotarse dos veces en el mismo turno, llegó: %v", err)
+	}
+}
+
+// waitlistFixture crea una reserva de alice para pasado mañana a las 18:00 y anota en la lista de espera
+// a los usuarios indicados, en ese orden
+func waitlistFixture(t *testing.T, waiting ...*auth.Claims) (*reservaFixture, *dto.ReservaResponse) {
+	t.Helper()
+	f := newReservaFixture(config.Config{})
+	date := time.Now().Add(48 * time.Hour).Format("2006-01-02")
+	booked, err := f.svc.Create(&dto.CreateReservaRequest{CanchaID: "c1", Date: date, StartTime: "18:00", EndTime: "19:00"}, player(1, "alice"))
+	if err != nil {
+		t.Fatalf("no se pudo crear la reserva: %v", err)
+	}
+	for _, user := range waiting {
+		if _, err := f.svc.JoinWaitlist(&dto.JoinWaitlistRequest{CanchaID: "c1", Date: date, StartTime: "18:00", EndTime: "19:00"}, user); err != nil {
+			t.Fatalf("%s no se pudo anotar en la lista de espera: %v", user.Username, err)
+		}
+	}
+	return f, booked
+}
+
+// entryOf retorna la única entrada de la lista de espera del usuario
+func entryOf(t *testing.T, f *reservaFixture, user *auth.Claims) dto.WaitlistEntryResponse {
+	t.Helper()
+	entries, err := f.svc.GetWaitlists(user)
+	if err != nil || entries.Total != 1 {
+		t.Fatalf("se esperaba una entrada de %s, llegó: %+v, %v", user.Username, entries, err)
+	}
+	return entries.Entries[0]
+}
+
+func TestConflictsCloseWaitlistOffers(t *testing.T) {
+	alice, bob, carol := player(1, "alice"), player(2, "bob"), player(3, "carol")
+	f, booked := waitlistFixture(t, bob, carol)
+	if err := f.svc.Cancel(booked.ID, alice); err != nil {
+		t.Fatalf("no se pudo cancelar: %v", err)
+	}
+	offer := entryOf(t, f, bob)
+	if offer.Status != domain.WaitlistOffered {
+		t.Fatalf("a Bob se le debe ofrecer el turno liberado, llegó: %+v", offer)
+	}
+
+	// Un cierre de la cancha cancela el turno retenido para Bob: su oferta se cierra y no pasa a Carol
+	date := f.repo.stored[offer.ReservaID].Date
+	conflicts, err := f.svc.FindConflicts(&dto.ConflictsRequest{CanchaIDs: []string{"c1"}, Start: date, End: date.AddDate(0, 0, 1)}, true)
+	if err != nil || conflicts.Cancelled != 1 {
+		t.Fatalf("se esperaba cancelado el turno retenido, llegó: %+v, %v", conflicts, err)
+	}
+	if entry := entryOf(t, f, bob); entry.Status != domain.WaitlistExpired {
+		t.Fatalf("la oferta de Bob no debe quedar abierta, llegó: %+v", entry)
+	}
+	if entry := entryOf(t, f, carol); entry.Status != domain.WaitlistWaiting {
+		t.Fatalf("el turno cerrado no debe ofrecerse a Carol, llegó: %+v", entry)
+	}
+	if err := f.svc.LeaveWaitlist(offer.ID, bob); !errors.Is(err, ErrWaitlistInactive) {
+		t.Fatalf("la oferta cerrada ya no puede rechazarse, llegó: %v", err)
+	}
+}
+
+func TestUpdateStatusDrivesTheWaitlist(t *testing.T) {
+	alice, bob, carol := player(1, "alice"), player(2, "bob"), player(3, "carol")
+	f, booked := waitlistFixture(t, bob, carol)
+
+	// Cancelar por PUT libera el turno igual que DELETE: se le ofrece a Bob
+	if _, err := f.svc.Update(booked.ID, &dto.UpdateReservaRequest{Status: "cancelled"}, alice); err != nil {
+		t.Fatalf("no se pudo cancelar por PUT: %v", err)
+	}
+	offer := entryOf(t, f, bob)
+	if offer.Status != domain.WaitlistOffered || f.repo.stored[offer.ReservaID].UserID != bob.UserID {
+		t.Fatalf("a Bob se le debe ofrecer el turno liberado por PUT, llegó: %+v", offer)
+	}
+
+	// Confirmar la oferta por PUT la acepta: la entrada no queda ofrecida para siempre
+	confirmed, err := f.svc.Update(offer.ReservaID, &dto.UpdateReservaRequest{Status: "confirmed"}, bob)
+	if err != nil || confirmed.Status != "confirmed" {
+		t.Fatalf("Bob debe poder confirmar la oferta por PUT, llegó: %+v, %v", confirmed, err)
+	}
+	if entry := entryOf(t, f, bob); entry.Status != domain.WaitlistAccepted {
+		t.Fatalf("la oferta confirmada por PUT debe quedar aceptada, llegó: %+v", entry)
+	}
+	if entry := entryOf(t, f, carol); entry.Status != domain.WaitlistWaiting || entry.Position != 1 {
+		t.Fatalf("Carol sigue esperando primera en la fila, llegó: %+v", entry)
+	}
+
+	// Bob cancela por PUT su turno confirmado: pasa a Carol
+	if _, err := f.svc.Update(offer.ReservaID, &dto.UpdateReservaRequest{Status: "cancelled"}, bob); err != nil {
+		t.Fatalf("no se pudo cancelar por PUT: %v", err)
+	}
+	if entry := entryOf(t, f, carol); entry.Status != domain.WaitlistOffered {
+		t.Fatalf("a Carol se le debe ofrecer el turno, llegó: %+v", entry)
+	}
+}
+
+func TestLeaveWaitlistRacesWithConfirm(t *testing.T) {
+	bob := player(2, "bob")
+	svcOf := func(f *reservaFixture) *reservaService { return f.svc.(*reservaService) }
+
+	// Bob confirma mientras su pedido de salir de la lista leyó la oferta todavía abierta:
+	// el rechazo pierde y no cancela el turno ya confirmado
+	f, booked := waitlistFixture(t, bob)
+	if err := f.svc.Cancel(booked.ID, player(1, "alice")); err != nil {
+		t.Fatalf("no se pudo cancelar: %v", err)
+	}
+	stale, _ := f.waitlist.GetByID(entryOf(t, f, bob).ID)
+	if _, err := f.svc.Confirm(stale.ReservaID, bob); err != nil {
+		t.Fatalf("Bob debe poder confirmar la oferta, llegó: %v", err)
+	}
+	if err := svcOf(f).declineOffer(stale, bob); !errors.Is(err, ErrWaitlistInactive) {
+		t.Fatalf("rechazar una oferta ya aceptada debe fallar, llegó: %v", err)
+	}
+	if f.repo.stored[stale.ReservaID].Status != "confirmed" {
+		t.Fatalf("el turno confirmado no debe cancelarse, quedó %s", f.repo.stored[stale.ReservaID].Status)
+	}
+	if err := f.svc.LeaveWaitlist(stale.ID.Hex(), bob); !errors.Is(err, ErrWaitlistInactive) {
+		t.Fatalf("no debe poder salirse de una oferta aceptada, llegó: %v", err)
+	}
+
+	// Bob rechaza mientras otro pedido suyo confirma: la confirmación pierde y el turno no queda confirmado
+	f, booked = waitlistFixture(t, bob)
+	if err := f.svc.Cancel(booked.ID, player(1, "alice")); err != nil {
+		t.Fatalf("no se pudo cancelar: %v", err)
+	}
+	entry := entryOf(t, f, bob)
+	offered, _ := f.waitlist.GetByID(entry.ID)
+	if _, err := f.waitlist.SetStatus(offered.ID, []string{domain.WaitlistOffered}, domain.WaitlistLeft); err != nil {
+		t.Fatalf("no se pudo cerrar la oferta: %v", err)
+	}
+	if _, err := f.svc.Confirm(entry.ReservaID, bob); !errors.Is(err, ErrWaitlistInactive) {
+		t.Fatalf("no debe confirmarse una oferta ya rechazada, llegó: %v", err)
+	}
+	if f.repo.stored[entry.ReservaID].Status != "pending" {
+		t.Fatalf("el turno rechazado no debe quedar confirmado, quedó %s", f.repo.stored[entry.ReservaID].Status)
+	}
+
+	// Rechazar dos veces: la segunda ya no encuentra la oferta abierta
+	f, booked = waitlistFixture(t, bob)
+	if err := f.svc.Cancel(booked.ID, player(1, "alice")); err != nil {
+		t.Fatalf("no se pudo cancelar: %v", err)
+	}
+	entry = entryOf(t, f, bob)
+	if err := f.svc.LeaveWaitlist(entry.ID, bob); err != nil {
+		t.Fatalf("Bob debe poder rechazar la oferta, llegó: %v", err)
+	}
+	if err := f.svc.LeaveWaitlist(entry.ID, bob); !errors.Is(err, ErrWaitlistInactive) {
+		t.Fatalf("el segundo rechazo debe fallar, llegó: %v", err)
+	}
+	if _, err := f.svc.Confirm(entry.ReservaID, bob); err == nil || err.Error() != "cannot confirm a cancelled reservation" {
+		t.Fatalf("no debe poder confirmarse un turno rechazado, llegó: %v", err)
 	}
 }
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reservas-api/internal/domain"
+	"reservas-api/internal/dto"
+	"reservas-api/internal/messaging"
+	"reservas-api/internal/repositories"
+	"reservas-api/internal/utils"
+	"shared/auth"
+	"time"
+)
+
+// ErrSlotAvailable indica que el turno está libre: no hace falta esperar, se puede reservar
+var ErrSlotAvailable = errors.New("slot is available, book it instead")
+
+// ErrAlreadyWaiting indica que el usuario ya está en la lista de espera de ese turno
+// (lo detecta el índice único de las entradas activas)
+var ErrAlreadyWaiting = repositories.ErrAlreadyWaiting
+
+// ErrWaitlistInactive indica que la entrada ya no espera ni tiene una oferta abierta
+// (salió de la lista, venció la oferta o ya la aceptó)
+var ErrWaitlistInactive = errors.New("waitlist entry is no longer active")
+
+// JoinWaitlist anota al usuario en la lista de espera de un turno ocupado.
+// Cuando el turno se libera se le retiene al primero de la fila (ver offerFreedSlot).
+func (s *reservaService) JoinWaitlist(req *dto.JoinWaitlistRequest, claims *auth.Claims) (*dto.WaitlistEntryResponse, error) {
+	if claims == nil {
+		return nil, errors.New("user validation failed")
+	}
+
+	date, err := utils.ParseDate(req.Date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date format: %w", err)
+	}
+	if date.Before(time.Now().Truncate(24 * time.Hour)) {
+		return nil, errors.New("cannot make reservations for past dates")
+	}
+
+	valid, cancha, err := s.canchaClient.ValidateCancha(req.CanchaID)
+	if err != nil || !valid {
+		return nil, fmt.Errorf("cancha validation failed: %v", err)
+	}
+
+	startTime, endTime, duration, err := utils.EnsureValidSlot(cancha.EffectiveSchedule(), date, req.StartTime, req.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkBlackouts(req.CanchaID, date, startTime, duration); err != nil {
+		return nil, err
+	}
+
+	available, err := s.repo.CheckAvailability(req.CanchaID, date, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	if available {
+		return nil, ErrSlotAvailable
+	}
+
+	entry := &domain.WaitlistEntry{
+		CanchaID:    req.CanchaID,
+		CanchaName:  cancha.Name,
+		UserID:      claims.UserID,
+		UserName:    claims.Username,
+		Date:        date,
+		StartTime:   startTime,
+		EndTime:     endTime,
+		Duration:    duration,
+		MemberPrice: claims.HasPermission(auth.PermReservasMember),
+		Status:      domain.WaitlistWaiting,
+	}
+	if err := s.waitlist.Create(entry); err != nil {
+		return nil, err
+	}
+
+	return s.waitlistToResponse(entry)
+}
+
+// GetWaitlists lista las entradas de la lista de espera del usuario
+func (s *reservaService) GetWaitlists(claims *auth.Claims) (*dto.WaitlistListResponse, error) {
+	if claims == nil {
+		return nil, errors.New("user validation failed")
+	}
+
+	entries, err := s.waitlist.GetByUserID(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dto.WaitlistListResponse{Entries: []dto.WaitlistEntryResponse{}}
+	for i := range entries {
+		entry, err := s.waitlistToResponse(&entries[i])
+		if err != nil {
+			return nil, err
+		}
+		resp.Entries = append(resp.Entries, *entry)
+	}
+	resp.Total = int64(len(resp.Entries))
+	return resp, nil
+}
+
+// LeaveWaitlist saca al usuario de la lista de espera.
+// Si ya tenía el turno ofrecido lo rechaza: se cancela el turno retenido y pasa al siguiente de la fila.
+func (s *reservaService) LeaveWaitlist(id string, claims *auth.Claims) error {
+	entry, err := s.waitlist.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if !auth.IsSelfOrHasPermission(claims, entry.UserID, auth.PermReservasCancelAny) {
+		return errors.New("forbidden")
+	}
+
+	switch entry.Status {
+	case domain.WaitlistWaiting:
+		left, err := s.waitlist.SetStatus(entry.ID, []string{domain.WaitlistWaiting}, domain.WaitlistLeft)
+		if err != nil {
+			return err
+		}
+		if left {
+			return nil
+		}
+		// Mientras tanto se le ofreció el turno: se rechaza la oferta
+		if entry, err = s.waitlist.GetByID(id); err != nil {
+			return err
+		}
+		if entry.Status != domain.WaitlistOffered {
+			return ErrWaitlistInactive
+		}
+		return s.declineOffer(entry, claims)
+	case domain.WaitlistOffered:
+		return s.declineOffer(entry, claims)
+	default:
+		return ErrWaitlistInactive
+	}
+}
+
+// declineOffer rechaza la oferta de la entrada: sale de la lista y se cancela su turno retenido, que pasa al siguiente.
+// La entrada se cierra antes de cancelar para no cancelar un turno que el usuario confirmó al mismo tiempo.
+func (s *reservaService) declineOffer(entry *domain.WaitlistEntry, claims *auth.Claims) error {
+	left, err := s.waitlist.SetStatus(entry.ID, []string{domain.WaitlistOffered}, domain.WaitlistLeft)
+	if err != nil {
+		return err
+	}
+	if !left {
+		return ErrWaitlistInactive
+	}
+	return s.Cancel(entry.ReservaID, claims)
+}
+
+// offerFreedSlot ofrece un turno recién liberado a la lista de espera: por orden de llegada,
+// al primero que espera un turno que se superpone y que ahora se puede retener.
+// Los errores solo se registran: la liberación del turno ya ocurrió.
+func (s *reservaService) offerFreedSlot(freed *domain.Reserva) {
+	freedStart, freedEnd, err := utils.SlotInterval(freed.Date, freed.StartTime, freed.Duration)
+	if err != nil {
+		return
+	}
+
+	entries, err := s.waitlist.FindWaiting(freed.CanchaID, freed.Date)
+	if err != nil {
+		log.Printf("Warning: failed to load waitlist of cancha %s: %v", freed.CanchaID, err)
+		return
+	}
+
+	now := time.Now()
+	for i := range entries {
+		entry := &entries[i]
+		start, end, err := utils.SlotInterval(entry.Date, entry.StartTime, entry.Duration)
+		if err != nil || !start.After(now) || !start.Before(freedEnd) || !freedStart.Before(end) {
+			continue
+		}
+		// Si el turno sigue ocupado en parte (por otra reserva) se prueba con el siguiente de la fila
+		if err := s.offer(entry); err == nil {
+			return
+		}
+	}
+}
+
+// offer retiene el turno de la entrada a nombre de su usuario y le avisa con reserva.waitlist_offer
+func (s *reservaService) offer(entry *domain.WaitlistEntry) error {
+	if err := s.checkBlackouts(entry.CanchaID, entry.Date, entry.StartTime, entry.Duration); err != nil {
+		return err
+	}
+
+	quote, err := s.canchaClient.GetQuote(entry.CanchaID, entry.Date, entry.StartTime, entry.MemberPrice)
+	if err != nil {
+		return fmt.Errorf("error quoting price: %w", err)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.holdDuration)
+	reserva := &domain.Reserva{
+		CanchaID:      entry.CanchaID,
+		UserID:        entry.UserID,
+		Date:          entry.Date,
+		StartTime:     entry.StartTime,
+		EndTime:       entry.EndTime,
+		Duration:      entry.Duration,
+		Status:        "pending",
+		HoldExpiresAt: &expiresAt,
+		CanchaName:    entry.CanchaName,
+		UserName:      entry.UserName,
+	}
+	applyQuote(reserva, quote)
+
+	if err := s.repo.Create(reserva); err != nil {
+		return err
+	}
+
+	offered, err := s.waitlist.MarkOffered(entry.ID, reserva.ID.Hex(), now)
+	if err != nil || !offered {
+		// Otra liberación ya le ofreció un turno o salió de la lista: se borra el que se acaba de retener
+		if deleteErr := s.repo.Purge(reserva.ID.Hex()); deleteErr != nil {
+			log.Printf("Warning: failed to release unused waitlist hold %s: %v", reserva.ID.Hex(), deleteErr)
+		}
+		if err == nil {
+			err = errors.New("waitlist entry is no longer waiting")
+		}
+		return err
+	}
+
+	s.publishReservaEvent("create", reserva)
+	event := messaging.Event{
+		Type:     "waitlist_offer",
+		Entity:   "waitlist",
+		EntityID: entry.ID.Hex(),
+		Data: dto.WaitlistOfferEvent{
+			EntryID:       entry.ID.Hex(),
+			UserID:        entry.UserID,
+			CanchaID:      entry.CanchaID,
+			CanchaName:    entry.CanchaName,
+			Date:          utils.FormatDate(entry.Date),
+			StartTime:     entry.StartTime,
+			EndTime:       entry.EndTime,
+			ReservaID:     reserva.ID.Hex(),
+			HoldExpiresAt: expiresAt,
+		},
+		Timestamp: now.Unix(),
+	}
+	if err := s.publisher.PublishEvent(event); err != nil {
+		println("Warning: failed to publish event:", err.Error())
+	}
+	return nil
+}
+
+// acceptOffer acepta la oferta de la lista de espera asociada a un turno retenido antes de confirmarlo.
+// Retorna la entrada aceptada, nil si el turno no salió de la lista, o ErrWaitlistInactive si la oferta
+// ya se cerró (el usuario la rechazó o venció mientras tanto).
+func (s *reservaService) acceptOffer(reserva *domain.Reserva) (*domain.WaitlistEntry, error) {
+	if reserva.HoldExpiresAt == nil {
+		return nil, nil
+	}
+	entry, err := s.waitlist.GetByReservaID(reserva.ID.Hex())
+	if err != nil {
+		return nil, nil
+	}
+	accepted, err := s.waitlist.SetStatus(entry.ID, []string{domain.WaitlistOffered}, domain.WaitlistAccepted)
+	if err != nil {
+		return nil, err
+	}
+	if !accepted {
+		return nil, ErrWaitlistInactive
+	}
+	return entry, nil
+}
+
+// closeOffer cierra la oferta de la lista de espera asociada a un turno retenido
+// (aceptada al confirmarlo, vencida o rechazada al cancelarlo). Si el turno no salió de la lista no hace nada.
+func (s *reservaService) closeOffer(reserva *domain.Reserva, status string) {
+	if reserva.HoldExpiresAt == nil {
+		return
+	}
+	entry, err := s.waitlist.GetByReservaID(reserva.ID.Hex())
+	if err != nil {
+		return
+	}
+	if _, err := s.waitlist.SetStatus(entry.ID, []string{domain.WaitlistOffered}, status); err != nil {
+		log.Printf("Warning: failed to close waitlist offer %s: %v", entry.ID.Hex(), err)
+	}
+}
+
+// waitlistToResponse convierte una entrada a su DTO con el lugar en la fila o el vencimiento de la oferta
+func (s *reservaService) waitlistToResponse(entry *domain.WaitlistEntry) (*dto.WaitlistEntryResponse, error) {
+	resp := &dto.WaitlistEntryResponse{
+		ID:         entry.ID.Hex(),
+		CanchaID:   entry.CanchaID,
+		CanchaName: entry.CanchaName,
+		UserID:     entry.UserID,
+		Date:       utils.FormatDate(entry.Date),
+		StartTime:  entry.StartTime,
+		EndTime:    entry.EndTime,
+		Status:     entry.Status,
+		ReservaID:  entry.ReservaID,
+		CreatedAt:  entry.CreatedAt,
+	}
+
+	switch entry.Status {
+	case domain.WaitlistWaiting:
+		queue, err := s.waitlist.FindWaiting(entry.CanchaID, entry.Date)
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range queue {
+			if other.StartTime != entry.StartTime {
+				continue
+			}
+			resp.Position++
+			if other.ID == entry.ID {
+				break
+			}
+		}
+	case domain.WaitlistOffered:
+		if reserva, err := s.repo.GetByID(entry.ReservaID); err == nil {
+			resp.HoldExpiresAt = reserva.HoldExpiresAt
+		}
+	}
+	return resp, nil
+}
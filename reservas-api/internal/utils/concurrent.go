@@ -80,12 +80,6 @@ func CalculateDuration(startTime, endTime string) (int, error) {
 	return int(duration.Minutes()), nil
 }
 
-// CalculatePrice devuelve el precio total del turno.
-// El valor almacenado en la cancha ya representa el costo completo.
-func CalculatePrice(pricePerTurn float64, _ int) float64 {
-	return pricePerTurn
-}
-
 // ParseDate convierte un string de fecha a time.Time
 func ParseDate(dateStr string) (time.Time, error) {
 	layout := "2006-01-02"
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxOccurrences limita la cantidad de turnos de una serie recurrente (un año de turnos semanales)
+const MaxOccurrences = 52
+
+// ErrInvalidRecurrence indica que la regla de repetición de una serie es inválida
+var ErrInvalidRecurrence = errors.New("invalid recurrence")
+
+// Recurrence es una regla de repetición al estilo RRULE (FREQ=WEEKLY;INTERVAL=n;UNTIL=fecha|COUNT=n).
+// Con Until y Count la serie termina en el primero que se cumpla.
+type Recurrence struct {
+	Frequency string    // solo "weekly"
+	Interval  int       // cada cuántas semanas (0 = 1)
+	Until     time.Time // última fecha posible, inclusive (cero = sin límite de fecha)
+	Count     int       // cantidad de turnos (0 = sin límite de cantidad)
+}
+
+// Occurrences retorna las fechas de la serie empezando por first
+func Occurrences(first time.Time, rule Recurrence) ([]time.Time, error) {
+	if rule.Frequency != "weekly" {
+		return nil, fmt.Errorf("%w: unsupported frequency %q", ErrInvalidRecurrence, rule.Frequency)
+	}
+	if rule.Interval < 0 || rule.Count < 0 {
+		return nil, fmt.Errorf("%w: interval and count must be positive", ErrInvalidRecurrence)
+	}
+	if rule.Until.IsZero() && rule.Count == 0 {
+		return nil, fmt.Errorf("%w: until or count is required", ErrInvalidRecurrence)
+	}
+	if !rule.Until.IsZero() && rule.Until.Before(first) {
+		return nil, fmt.Errorf("%w: until must not be before the first date", ErrInvalidRecurrence)
+	}
+
+	interval := rule.Interval
+	if interval == 0 {
+		interval = 1
+	}
+
+	var dates []time.Time
+	for date := first; rule.Count == 0 || len(dates) < rule.Count; date = date.AddDate(0, 0, 7*interval) {
+		if !rule.Until.IsZero() && date.After(rule.Until) {
+			break
+		}
+		if len(dates) == MaxOccurrences {
+			return nil, fmt.Errorf("%w: a series can have at most %d occurrences", ErrInvalidRecurrence, MaxOccurrences)
+		}
+		dates = append(dates, date)
+	}
+	return dates, nil
+}
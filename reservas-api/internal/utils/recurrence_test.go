@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOccurrences(t *testing.T) {
+	first := time.Date(2030, 3, 5, 0, 0, 0, 0, time.UTC)
+	week := func(n int) time.Time { return first.AddDate(0, 0, 7*n) }
+
+	tests := []struct {
+		name  string
+		rule  Recurrence
+		weeks []int // semanas desde first de cada fecha esperada
+	}{
+		{"count", Recurrence{Frequency: "weekly", Count: 3}, []int{0, 1, 2}},
+		{"until inclusivo", Recurrence{Frequency: "weekly", Until: week(2)}, []int{0, 1, 2}},
+		{"until entre dos fechas", Recurrence{Frequency: "weekly", Until: week(2).AddDate(0, 0, -1)}, []int{0, 1}},
+		{"until igual a la primera fecha", Recurrence{Frequency: "weekly", Until: first}, []int{0}},
+		{"count termina antes que until", Recurrence{Frequency: "weekly", Count: 2, Until: week(4)}, []int{0, 1}},
+		{"until termina antes que count", Recurrence{Frequency: "weekly", Count: 10, Until: week(2)}, []int{0, 1, 2}},
+		{"interval cada dos semanas", Recurrence{Frequency: "weekly", Interval: 2, Until: week(6)}, []int{0, 2, 4, 6}},
+		{"interval con count", Recurrence{Frequency: "weekly", Interval: 3, Count: 3}, []int{0, 3, 6}},
+		{"count en el máximo", Recurrence{Frequency: "weekly", Count: MaxOccurrences}, nil},
+		{"until en el máximo", Recurrence{Frequency: "weekly", Until: week(MaxOccurrences - 1)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dates, err := Occurrences(first, tt.rule)
+			if err != nil {
+				t.Fatalf("no se esperaba error, llegó: %v", err)
+			}
+			if tt.weeks == nil {
+				if len(dates) != MaxOccurrences || !dates[len(dates)-1].Equal(week(MaxOccurrences-1)) {
+					t.Fatalf("se esperaban %d fechas semanales, llegaron %d", MaxOccurrences, len(dates))
+				}
+				return
+			}
+			if len(dates) != len(tt.weeks) {
+				t.Fatalf("se esperaban %d fechas, llegaron %v", len(tt.weeks), dates)
+			}
+			for i, n := range tt.weeks {
+				if !dates[i].Equal(week(n)) {
+					t.Fatalf("la fecha %d debía ser %v, llegaron %v", i, week(n), dates)
+				}
+			}
+		})
+	}
+}
+
+func TestOccurrencesRejectsInvalidRules(t *testing.T) {
+	first := time.Date(2030, 3, 5, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		rule Recurrence
+	}{
+		{"frecuencia no soportada", Recurrence{Frequency: "daily", Count: 3}},
+		{"sin until ni count", Recurrence{Frequency: "weekly"}},
+		{"interval negativo", Recurrence{Frequency: "weekly", Interval: -1, Count: 3}},
+		{"count negativo", Recurrence{Frequency: "weekly", Count: -1}},
+		{"until antes de la primera fecha", Recurrence{Frequency: "weekly", Until: first.AddDate(0, 0, -1)}},
+		{"count sobre el máximo", Recurrence{Frequency: "weekly", Count: MaxOccurrences + 1}},
+		{"until sobre el máximo", Recurrence{Frequency: "weekly", Until: first.AddDate(0, 0, 7*MaxOccurrences)}},
+		{"interval largo sobre el máximo", Recurrence{Frequency: "weekly", Interval: 2, Until: first.AddDate(0, 0, 14*MaxOccurrences)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if dates, err := Occurrences(first, tt.rule); !errors.Is(err, ErrInvalidRecurrence) {
+				t.Fatalf("se esperaba ErrInvalidRecurrence, llegó: %v, %v", dates, err)
+			}
+		})
+	}
+}
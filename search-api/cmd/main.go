@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"os"
+	"shared/auth"
 	"time"
 
 	"search-api/config"
@@ -61,8 +62,11 @@ func main() {
 		c.Next()
 	})
 
-	router.GET("/search", searchController.Search)
-	router.GET("/search2", searchController.Search)
+	// La búsqueda es pública; si llega un token se valida localmente con las claves de users-api
+	validator := auth.NewJWKSValidator(config.AppConfig.JWKSURL, 10*time.Minute)
+	search := router.Group("/", auth.OptionalMiddleware(validator))
+	search.GET("/search", searchController.Search)
+	search.GET("/search2", searchController.Search)
 
 	port := os.Getenv("PORT")
 	if port == "" {
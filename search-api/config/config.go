@@ -20,6 +20,7 @@ type Config struct {
 	LocalCacheTTLMinutes int
 	MemcachedTTLMinutes  int
 	CanchasAPIURL        string
+	JWKSURL              string
 }
 
 var AppConfig *Config
@@ -47,6 +48,7 @@ func LoadConfig() {
 		LocalCacheTTLMinutes: localCacheTTL,
 		MemcachedTTLMinutes:  memcachedTTL,
 		CanchasAPIURL:        getEnv("CANCHAS_API_URL", "http://localhost:8081"),
+		JWKSURL:              getEnv("JWKS_URL", "http://localhost:8080/.well-known/jwks.json"),
 	}
 
 	log.Println("Configuration loaded successfully")
@@ -0,0 +1,343 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testKey genera una clave Ed25519 de prueba.
+func testKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("no se pudo generar la clave: %v", err)
+	}
+	return priv
+}
+
+// sign firma claims con EdDSA y el kid indicado.
+func sign(t *testing.T, claims *Claims, kid string, key ed25519.PrivateKey) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = kid
+	tokenString, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("no se pudo firmar el token: %v", err)
+	}
+	return tokenString
+}
+
+func staticValidator(kid string, key ed25519.PrivateKey) Validator {
+	return NewStaticKeyValidator(map[string]crypto.PublicKey{kid: key.Public()})
+}
+
+func validClaims(role string) *Claims {
+	return &Claims{
+		UserID:   7,
+		Username: "alice",
+		Role:     role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+}
+
+// withMFA marca los claims como autenticados con segundo factor.
+func withMFA(claims *Claims) *Claims {
+	claims.AMR = []string{AMRPassword, AMROTP}
+	return claims
+}
+
+func TestValidateSuccess(t *testing.T) {
+	key := testKey(t)
+	v := staticValidator("k1", key)
+	claims, err := v.Validate(sign(t, validClaims(RoleNormal), "k1", key))
+	if err != nil {
+		t.Fatalf("se esperaba token válido, llegó: %v", err)
+	}
+	if claims.UserID != 7 || claims.Username != "alice" || claims.Role != RoleNormal {
+		t.Fatalf("claims inesperados: %+v", claims)
+	}
+}
+
+func TestValidateRejectsInvalidTokens(t *testing.T) {
+	key := testKey(t)
+	v := staticValidator("k1", key)
+
+	if _, err := v.Validate(sign(t, validClaims(RoleNormal), "k1", testKey(t))); err == nil {
+		t.Fatalf("se esperaba error por firma inválida")
+	}
+
+	if _, err := v.Validate(sign(t, validClaims(RoleNormal), "otro", key)); err == nil {
+		t.Fatalf("se esperaba error por kid desconocido")
+	}
+
+	expired := validClaims(RoleNormal)
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	if _, err := v.Validate(sign(t, expired, "k1", key)); err == nil {
+		t.Fatalf("se esperaba error por token expirado")
+	}
+
+	noExp := validClaims(RoleNormal)
+	noExp.ExpiresAt = nil
+	if _, err := v.Validate(sign(t, noExp, "k1", key)); err == nil {
+		t.Fatalf("se esperaba error por token sin expiración")
+	}
+
+	// Un token HS256 no debe aceptarse aunque el atacante conozca la clave pública
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims(RoleAdmin))
+	hs.Header["kid"] = "k1"
+	hsString, _ := hs.SignedString([]byte(key.Public().(ed25519.PublicKey)))
+	if _, err := v.Validate(hsString); err == nil {
+		t.Fatalf("se esperaba error por algoritmo HS256")
+	}
+}
+
+func TestJWKSValidatorFetchesRotatedKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("no se pudo generar la clave RSA: %v", err)
+	}
+	edKey := testKey(t)
+
+	set := JWKS{}
+	jwk, _ := NewJWK("rsa-1", &rsaKey.PublicKey)
+	set.Keys = append(set.Keys, jwk)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(set)
+	}))
+	defer server.Close()
+
+	cache := NewJWKSCache(server.URL, time.Hour)
+	cache.minInterval = 0
+	v := NewKeyValidator(cache.Lookup)
+
+	rsToken := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims(RoleNormal))
+	rsToken.Header["kid"] = "rsa-1"
+	rsString, _ := rsToken.SignedString(rsaKey)
+	if _, err := v.Validate(rsString); err != nil {
+		t.Fatalf("se esperaba token RS256 válido, llegó: %v", err)
+	}
+
+	// Rotación: aparece una clave nueva y el cache debe descargarla
+	edJWK, _ := NewJWK("ed-2", edKey.Public())
+	set.Keys = append(set.Keys, edJWK)
+	if _, err := v.Validate(sign(t, validClaims(RoleNormal), "ed-2", edKey)); err != nil {
+		t.Fatalf("se esperaba token EdDSA válido tras la rotación, llegó: %v", err)
+	}
+}
+
+func TestMiddlewareAndRequireAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	key := testKey(t)
+	router.GET("/admin", Middleware(staticValidator("k1", key)), RequireAdmin(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	cases := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"sin header", "", http.StatusUnauthorized},
+		{"formato inválido", "Token abc", http.StatusUnauthorized},
+		{"usuario normal", "Bearer " + sign(t, validClaims(RoleNormal), "k1", key), http.StatusForbidden},
+		{"admin sin 2FA", "Bearer " + sign(t, validClaims(RoleAdmin), "k1", key), http.StatusForbidden},
+		{"admin con 2FA", "Bearer " + sign(t, withMFA(validClaims(RoleAdmin)), "k1", key), http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Fatalf("%s: status esperado %d, llegó %d", tc.name, tc.status, rec.Code)
+		}
+	}
+}
+
+func TestRequireSelfOrAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	key := testKey(t)
+	router := gin.New()
+	router.GET("/users/:id", Middleware(staticValidator("k1", key)), RequireSelfOrAdmin("id"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	normal := "Bearer " + sign(t, validClaims(RoleNormal), "k1", key) // UserID 7
+	admin := "Bearer " + sign(t, withMFA(validClaims(RoleAdmin)), "k1", key)
+	adminNoMFA := "Bearer " + sign(t, validClaims(RoleAdmin), "k1", key)
+
+	cases := []struct {
+		name   string
+		path   string
+		header string
+		status int
+	}{
+		{"propio recurso", "/users/7", normal, http.StatusOK},
+		{"recurso ajeno", "/users/8", normal, http.StatusForbidden},
+		{"admin recurso ajeno", "/users/8", admin, http.StatusOK},
+		{"admin sin 2FA recurso ajeno", "/users/8", adminNoMFA, http.StatusForbidden},
+		{"id inválido", "/users/abc", normal, http.StatusBadRequest},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Authorization", tc.header)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Fatalf("%s: status esperado %d, llegó %d", tc.name, tc.status, rec.Code)
+		}
+	}
+}
+
+// withPermissions agrega permisos a los claims.
+func withPermissions(claims *Claims, permissions ...string) *Claims {
+	claims.Permissions = permissions
+	return claims
+}
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	key := testKey(t)
+	router := gin.New()
+	router.POST("/reservas/:id/confirm", Middleware(staticValidator("k1", key)), RequirePermission(PermReservasConfirm), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.PUT("/canchas/:id", Middleware(staticValidator("k1", key)), RequireAnyPermission(PermCanchasManage, PermCanchasManageOwn), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	player := "Bearer " + sign(t, validClaims(RoleNormal), "k1", key)
+	staff := "Bearer " + sign(t, withPermissions(validClaims(RoleStaff), PermReservasConfirm, PermReservasCheckIn), "k1", key)
+	manager := "Bearer " + sign(t, withPermissions(validClaims(RoleVenueManager), PermCanchasManageOwn), "k1", key)
+	adminPerms := []string{PermReservasConfirm, PermCanchasManage}
+	admin := "Bearer " + sign(t, withMFA(withPermissions(validClaims(RoleAdmin), adminPerms...)), "k1", key)
+	adminNoMFA := "Bearer " + sign(t, withPermissions(validClaims(RoleAdmin), adminPerms...), "k1", key)
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		header string
+		status int
+	}{
+		{"jugador no confirma", http.MethodPost, "/reservas/r1/confirm", player, http.StatusForbidden},
+		{"staff confirma", http.MethodPost, "/reservas/r1/confirm", staff, http.StatusOK},
+		{"staff no edita canchas", http.MethodPut, "/canchas/c1", staff, http.StatusForbidden},
+		{"manager edita canchas", http.MethodPut, "/canchas/c1", manager, http.StatusOK},
+		{"admin con 2FA", http.MethodPut, "/canchas/c1", admin, http.StatusOK},
+		{"admin sin 2FA", http.MethodPost, "/reservas/r1/confirm", adminNoMFA, http.StatusForbidden},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set("Authorization", tc.header)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Fatalf("%s: status esperado %d, llegó %d", tc.name, tc.status, rec.Code)
+		}
+	}
+}
+
+func TestRequireSelfOrPermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	key := testKey(t)
+	router := gin.New()
+	router.GET("/users/:id", Middleware(staticValidator("k1", key)), RequireSelfOrPermission("id", PermUsersRead), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	player := "Bearer " + sign(t, validClaims(RoleNormal), "k1", key) // UserID 7
+	staff := "Bearer " + sign(t, withPermissions(validClaims(RoleStaff), PermUsersRead), "k1", key)
+
+	cases := []struct {
+		name   string
+		path   string
+		header string
+		status int
+	}{
+		{"propio recurso", "/users/7", player, http.StatusOK},
+		{"recurso ajeno sin permiso", "/users/8", player, http.StatusForbidden},
+		{"recurso ajeno con permiso", "/users/8", staff, http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Authorization", tc.header)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Fatalf("%s: status esperado %d, llegó %d", tc.name, tc.status, rec.Code)
+		}
+	}
+}
+
+func TestServiceOrPermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	key := testKey(t)
+	router := gin.New()
+	router.DELETE("/reservas/cancha/:id", ServiceOrPermission("s3cret", staticValidator("k1", key), PermCanchasManage), func(c *gin.Context) {
+		if IsServiceRequest(c) {
+			c.Status(http.StatusAccepted)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	router.DELETE("/disabled/:id", ServiceOrPermission("", staticValidator("k1", key), PermCanchasManage), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	player := "Bearer " + sign(t, validClaims(RoleNormal), "k1", key)
+	manager := "Bearer " + sign(t, withPermissions(validClaims(RoleVenueManager), PermCanchasManageOwn), "k1", key)
+	admin := "Bearer " + sign(t, withMFA(withPermissions(validClaims(RoleAdmin), PermCanchasManage)), "k1", key)
+
+	cases := []struct {
+		name    string
+		path    string
+		header  string
+		service string
+		status  int
+	}{
+		{"sin credenciales", "/reservas/cancha/c1", "", "", http.StatusUnauthorized},
+		{"servicio con el secreto", "/reservas/cancha/c1", "", "s3cret", http.StatusAccepted},
+		{"servicio con otro secreto", "/reservas/cancha/c1", admin, "otro", http.StatusUnauthorized},
+		{"secreto deshabilitado", "/disabled/c1", "", "s3cret", http.StatusUnauthorized},
+		{"jugador", "/reservas/cancha/c1", player, "", http.StatusForbidden},
+		{"encargado de canchas propias", "/reservas/cancha/c1", manager, "", http.StatusForbidden},
+		{"admin con canchas:manage", "/reservas/cancha/c1", admin, "", http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodDelete, tc.path, nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		if tc.service != "" {
+			req.Header.Set(ServiceTokenHeader, tc.service)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Fatalf("%s: status esperado %d, llegó %d", tc.name, tc.status, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Roles conocidos por todos los servicios. Los permisos de cada rol se guardan en users-api.
+const (
+	RoleNormal       = "normal"        // jugador
+	RoleMember       = "member"        // socio: jugador con tarifa de socio
+	RoleStaff        = "staff"         // recepción: confirma y registra la llegada a las reservas
+	RoleVenueManager = "venue_manager" // administra solo sus propias canchas
+	RoleAdmin        = "admin"
+)
+
+// Métodos de autenticación (claim "amr", RFC 8176)
+const (
+	AMRPassword  = "pwd"
+	AMROTP       = "otp"
+	AMRFederated = "fed" // login con un proveedor OIDC externo
+)
+
+// Claims son los claims que users-api firma en cada access token
+type Claims struct {
+	UserID   uint     `json:"user_id"`
+	Username string   `json:"username"`
+	Role     string   `json:"role"`
+	AMR      []string `json:"amr,omitempty"`
+	// Permissions son los permisos del rol al momento de emitir el token
+	Permissions []string `json:"perms,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// HasRole indica si el usuario tiene alguno de los roles indicados
+func (c *Claims) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if c.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin indica si el usuario es administrador
+func (c *Claims) IsAdmin() bool {
+	return c.HasRole(RoleAdmin)
+}
+
+// HasMFA indica si la sesión se autenticó con un segundo factor
+func (c *Claims) HasMFA() bool {
+	for _, method := range c.AMR {
+		if method == AMROTP {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAdminAccess indica si la sesión puede ejercer privilegios de admin: rol admin y 2FA
+func (c *Claims) HasAdminAccess() bool {
+	return c.IsAdmin() && c.HasMFA()
+}
+
+// HasPermission indica si la sesión tiene el permiso indicado.
+// Un admin solo ejerce sus permisos si inició sesión con 2FA.
+func (c *Claims) HasPermission(permission string) bool {
+	if c.IsAdmin() && !c.HasMFA() {
+		return false
+	}
+	for _, p := range c.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAnyPermission indica si la sesión tiene alguno de los permisos indicados
+func (c *Claims) HasAnyPermission(permissions ...string) bool {
+	for _, p := range permissions {
+		if c.HasPermission(p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// JWK es una clave pública en formato JSON Web Key (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 (OKP)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS es el documento publicado en /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK convierte una clave pública RSA o Ed25519 a JWK
+func NewJWK(kid string, key crypto.PublicKey) (JWK, error) {
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: kid,
+			Alg: "RS256",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Kid: kid,
+			Alg: "EdDSA",
+			Use: "sig",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}, nil
+	default:
+		return JWK{}, fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+// PublicKey convierte la JWK a una clave pública de Go
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA exponent: %w", err)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// JWKSCache mantiene en memoria las claves públicas publicadas por users-api.
+// Se refresca al vencer el TTL o cuando llega un kid desconocido (por ejemplo, tras una rotación).
+type JWKSCache struct {
+	url         string
+	ttl         time.Duration
+	minInterval time.Duration
+	httpClient  *http.Client
+
+	mu        sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// NewJWKSCache crea un cache de claves para la URL indicada
+func NewJWKSCache(url string, ttl time.Duration) *JWKSCache {
+	return &JWKSCache{
+		url:         url,
+		ttl:         ttl,
+		minInterval: 10 * time.Second,
+		httpClient:  &http.Client{Timeout: 5 * time.Second},
+		keys:        map[string]crypto.PublicKey{},
+	}
+}
+
+// NewJWKSValidator crea un validador que verifica tokens con las claves publicadas en jwksURL
+func NewJWKSValidator(jwksURL string, ttl time.Duration) Validator {
+	return NewKeyValidator(NewJWKSCache(jwksURL, ttl).Lookup)
+}
+
+// Lookup retorna la clave pública para un kid, descargando el JWKS si hace falta
+func (c *JWKSCache) Lookup(kid string) (crypto.PublicKey, error) {
+	c.mu.RLock()
+	key, ok := c.keys[kid]
+	fresh := time.Since(c.fetchedAt) < c.ttl
+	recent := time.Since(c.fetchedAt) < c.minInterval
+	c.mu.RUnlock()
+
+	if ok && fresh {
+		return key, nil
+	}
+
+	// Evitar que tokens con kid inventados fuercen descargas continuas
+	if !ok && recent {
+		return nil, ErrUnknownKID
+	}
+
+	if err := c.refresh(); err != nil {
+		// Si users-api no responde seguimos usando la clave cacheada
+		if ok {
+			return key, nil
+		}
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	key, ok = c.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKID
+	}
+	return key, nil
+}
+
+func (c *JWKSCache) refresh() error {
+	resp, err := c.httpClient.Get(c.url)
+	if err != nil {
+		return fmt.Errorf("error fetching JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("JWKS endpoint returned status: %d", resp.StatusCode)
+	}
+
+	var set JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("error decoding JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Claves usadas para guardar la identidad del usuario en el contexto de gin
+const (
+	ContextClaimsKey   = "claims"
+	ContextUserIDKey   = "user_id"
+	ContextUsernameKey = "username"
+	ContextRoleKey     = "role"
+)
+
+// errorResponse tiene la misma forma que dto.ErrorResponse de cada servicio
+type errorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message,omitempty"`
+}
+
+// Middleware valida el access token localmente y guarda los claims en el contexto
+func Middleware(v Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString, err := ParseBearer(c.GetHeader("Authorization"))
+		if err != nil {
+			abort(c, http.StatusUnauthorized, headerErrorMessage(err), "")
+			return
+		}
+
+		claims, err := v.Validate(tokenString)
+		if err != nil {
+			abort(c, http.StatusUnauthorized, "Invalid token", err.Error())
+			return
+		}
+
+		setClaims(c, claims)
+		c.Next()
+	}
+}
+
+// OptionalMiddleware guarda los claims si llega un token válido, pero no rechaza la request sin token
+func OptionalMiddleware(v Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString, err := ParseBearer(c.GetHeader("Authorization"))
+		if err == nil {
+			if claims, err := v.Validate(tokenString); err == nil {
+				setClaims(c, claims)
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireRole exige que el usuario autenticado tenga alguno de los roles indicados.
+// Debe usarse después de Middleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ClaimsFromContext(c)
+		if !ok {
+			abort(c, http.StatusUnauthorized, "Authentication required", "")
+			return
+		}
+
+		if !claims.HasRole(roles...) {
+			abort(c, http.StatusForbidden, "Insufficient role", "")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireAdmin exige que el usuario autenticado sea administrador y haya iniciado sesión con 2FA
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ClaimsFromContext(c)
+		if !ok || !claims.IsAdmin() {
+			abort(c, http.StatusForbidden, "Admin access required", "")
+			return
+		}
+
+		if !claims.HasMFA() {
+			abort(c, http.StatusForbidden, "Two-factor authentication required", "Admins must enable 2FA and log in with it")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePermission exige que la sesión tenga todos los permisos indicados.
+// Debe usarse después de Middleware.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ClaimsFromContext(c)
+		if !ok {
+			abort(c, http.StatusUnauthorized, "Authentication required", "")
+			return
+		}
+
+		for _, permission := range permissions {
+			if !claims.HasPermission(permission) {
+				abortForPermission(c, claims, permission)
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// RequireAnyPermission exige que la sesión tenga al menos uno de los permisos indicados.
+// Debe usarse después de Middleware.
+func RequireAnyPermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ClaimsFromContext(c)
+		if !ok {
+			abort(c, http.StatusUnauthorized, "Authentication required", "")
+			return
+		}
+
+		if !claims.HasAnyPermission(permissions...) {
+			abortForPermission(c, claims, strings.Join(permissions, " or "))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// abortForPermission responde 403 explicando si falta el permiso o el segundo factor del admin
+func abortForPermission(c *gin.Context, claims *Claims, permission string) {
+	if claims.IsAdmin() && !claims.HasMFA() {
+		abort(c, http.StatusForbidden, "Two-factor authentication required", "Admins must enable 2FA and log in with it")
+		return
+	}
+	abort(c, http.StatusForbidden, "Insufficient permissions", "missing permission: "+permission)
+}
+
+// ClaimsFromContext obtiene los claims guardados por Middleware
+func ClaimsFromContext(c *gin.Context) (*Claims, bool) {
+	value, exists := c.Get(ContextClaimsKey)
+	if !exists {
+		return nil, false
+	}
+	claims, ok := value.(*Claims)
+	return claims, ok
+}
+
+func setClaims(c *gin.Context, claims *Claims) {
+	c.Set(ContextClaimsKey, claims)
+	c.Set(ContextUserIDKey, claims.UserID)
+	c.Set(ContextUsernameKey, claims.Username)
+	c.Set(ContextRoleKey, claims.Role)
+}
+
+func headerErrorMessage(err error) string {
+	if err == ErrMissingHeader {
+		return "Authorization header required"
+	}
+	return "Invalid authorization header format"
+}
+
+func abort(c *gin.Context, status int, errMsg, message string) {
+	c.AbortWithStatusJSON(status, errorResponse{Error: errMsg, Message: message})
+}
@@ -0,0 +1,43 @@
+package auth
+
+// Permisos que users-api asigna a los roles y embebe en el claim "perms"
+const (
+	PermUsersRead   = "users:read"   // ver usuarios ajenos y listarlos
+	PermUsersManage = "users:manage" // invitar, desactivar, borrar y desbloquear usuarios; ver el log de auditoría
+	PermRolesManage = "roles:manage" // asignar roles y editar sus permisos
+
+	PermCanchasManage    = "canchas:manage"     // crear, editar y borrar cualquier cancha
+	PermCanchasManageOwn = "canchas:manage_own" // crear canchas y editar o borrar solo las propias
+
+	PermReservasReadAll   = "reservas:read_all"   // ver reservas de otros usuarios
+	PermReservasConfirm   = "reservas:confirm"    // confirmar reservas pendientes
+	PermReservasCheckIn   = "reservas:check_in"   // registrar la llegada del jugador
+	PermReservasCancelAny = "reservas:cancel_any" // cancelar reservas de otros usuarios
+	PermReservasUpdateAny = "reservas:update_any" // modificar reservas de otros usuarios
+	PermReservasMember    = "reservas:member"     // reservar con la tarifa de socio
+)
+
+// AllPermissions lista todos los permisos conocidos
+var AllPermissions = []string{
+	PermUsersRead,
+	PermUsersManage,
+	PermRolesManage,
+	PermCanchasManage,
+	PermCanchasManageOwn,
+	PermReservasReadAll,
+	PermReservasConfirm,
+	PermReservasCheckIn,
+	PermReservasCancelAny,
+	PermReservasUpdateAny,
+	PermReservasMember,
+}
+
+// IsKnownPermission indica si permission es uno de los permisos definidos
+func IsKnownPermission(permission string) bool {
+	for _, p := range AllPermissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IsSelfOrAdmin indica si el usuario autenticado puede acceder a un recurso cuyo dueño es ownerID.
+// Un admin solo accede a recursos ajenos si inició sesión con 2FA.
+func IsSelfOrAdmin(claims *Claims, ownerID uint) bool {
+	if claims == nil {
+		return false
+	}
+	return claims.HasAdminAccess() || claims.UserID == ownerID
+}
+
+// IsSelfOrHasPermission indica si el usuario autenticado es ownerID o tiene el permiso indicado
+func IsSelfOrHasPermission(claims *Claims, ownerID uint, permission string) bool {
+	if claims == nil {
+		return false
+	}
+	return claims.UserID == ownerID || claims.HasPermission(permission)
+}
+
+// RequireSelfOrAdmin exige que el parámetro de ruta param sea el ID del usuario autenticado,
+// salvo que sea admin. Debe usarse después de Middleware.
+func RequireSelfOrAdmin(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ClaimsFromContext(c)
+		if !ok {
+			abort(c, http.StatusUnauthorized, "Authentication required", "")
+			return
+		}
+
+		ownerID, err := strconv.ParseUint(c.Param(param), 10, 32)
+		if err != nil {
+			abort(c, http.StatusBadRequest, "Invalid ID", "ID must be a valid number")
+			return
+		}
+
+		if !IsSelfOrAdmin(claims, uint(ownerID)) {
+			abort(c, http.StatusForbidden, "Access denied", "you can only access your own resources")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireSelfOrPermission exige que el parámetro de ruta param sea el ID del usuario autenticado,
+// salvo que tenga el permiso indicado. Debe usarse después de Middleware.
+func RequireSelfOrPermission(param, permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ClaimsFromContext(c)
+		if !ok {
+			abort(c, http.StatusUnauthorized, "Authentication required", "")
+			return
+		}
+
+		ownerID, err := strconv.ParseUint(c.Param(param), 10, 32)
+		if err != nil {
+			abort(c, http.StatusBadRequest, "Invalid ID", "ID must be a valid number")
+			return
+		}
+
+		if !IsSelfOrHasPermission(claims, uint(ownerID), permission) {
+			abort(c, http.StatusForbidden, "Access denied", "you can only access your own resources")
+			return
+		}
+
+		c.Next()
+	}
+}
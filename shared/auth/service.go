@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServiceTokenHeader lleva el secreto compartido con el que un servicio llama a otro
+const ServiceTokenHeader = "X-Service-Token"
+
+// ContextServiceKey marca en el contexto de gin las requests hechas por otro servicio
+const ContextServiceKey = "service"
+
+// ServiceOrPermission admite llamadas de otros servicios que presentan el secreto compartido en
+// ServiceTokenHeader, o usuarios autenticados con alguno de los permisos indicados.
+// Con secret vacío las llamadas entre servicios quedan deshabilitadas.
+func ServiceOrPermission(secret string, v Validator, permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := c.GetHeader(ServiceTokenHeader); token != "" {
+			if secret == "" || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+				abort(c, http.StatusUnauthorized, "Invalid service token", "")
+				return
+			}
+			c.Set(ContextServiceKey, true)
+			c.Next()
+			return
+		}
+
+		tokenString, err := ParseBearer(c.GetHeader("Authorization"))
+		if err != nil {
+			abort(c, http.StatusUnauthorized, headerErrorMessage(err), "")
+			return
+		}
+
+		claims, err := v.Validate(tokenString)
+		if err != nil {
+			abort(c, http.StatusUnauthorized, "Invalid token", err.Error())
+			return
+		}
+
+		if !claims.HasAnyPermission(permissions...) {
+			abortForPermission(c, claims, strings.Join(permissions, " or "))
+			return
+		}
+
+		setClaims(c, claims)
+		c.Next()
+	}
+}
+
+// IsServiceRequest indica si la request la hizo otro servicio con el secreto compartido
+func IsServiceRequest(c *gin.Context) bool {
+	return c.GetBool(ContextServiceKey)
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"crypto"
+	"errors"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrMissingHeader = errors.New("authorization header required")
+	ErrInvalidHeader = errors.New("invalid authorization header format")
+	ErrInvalidToken  = errors.New("invalid token")
+	ErrNoExpiration  = errors.New("token has no expiration")
+	ErrMissingKID    = errors.New("token has no kid header")
+	ErrUnknownKID    = errors.New("unknown signing key")
+)
+
+// SigningMethods son los algoritmos asimétricos aceptados para access tokens
+var SigningMethods = []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+
+// Validator valida un access token y retorna sus claims
+type Validator interface {
+	Validate(tokenString string) (*Claims, error)
+}
+
+// KeyLookup resuelve la clave pública correspondiente a un kid
+type KeyLookup func(kid string) (crypto.PublicKey, error)
+
+type keyValidator struct {
+	lookup KeyLookup
+}
+
+// NewKeyValidator crea un validador que busca la clave pública por el header kid del token
+func NewKeyValidator(lookup KeyLookup) Validator {
+	return &keyValidator{lookup: lookup}
+}
+
+// NewStaticKeyValidator crea un validador con un conjunto fijo de claves públicas indexadas por kid
+func NewStaticKeyValidator(keys map[string]crypto.PublicKey) Validator {
+	return NewKeyValidator(func(kid string) (crypto.PublicKey, error) {
+		key, ok := keys[kid]
+		if !ok {
+			return nil, ErrUnknownKID
+		}
+		return key, nil
+	})
+}
+
+// Validate verifica firma (RS256 o EdDSA) y expiración del token
+func (v *keyValidator) Validate(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, ErrMissingKID
+		}
+		return v.lookup(kid)
+	}, jwt.WithValidMethods(SigningMethods))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	// Un token sin expiración no es aceptable
+	if claims.ExpiresAt == nil {
+		return nil, ErrNoExpiration
+	}
+
+	return claims, nil
+}
+
+// ParseBearer extrae el token de un header "Authorization: Bearer <token>"
+func ParseBearer(authHeader string) (string, error) {
+	if authHeader == "" {
+		return "", ErrMissingHeader
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" || parts[1] == "" {
+		return "", ErrInvalidHeader
+	}
+
+	return parts[1], nil
+}
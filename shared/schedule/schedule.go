@@ -0,0 +1,245 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DayStartMinutes es el corte del día de reservas (06:00): un turno a la 01:00 pertenece a la fecha anterior
+	DayStartMinutes = 6 * 60
+	MinutesPerDay   = 24 * 60
+
+	MinSlotMinutes = 15
+	MaxSlotMinutes = 4 * 60
+
+	// Granularity es la unidad de los horarios: aperturas, cierres, turnos y alineación son múltiplos de 5 minutos.
+	// reservas-api bloquea los turnos en celdas de este tamaño.
+	Granularity = 5
+
+	defaultOpen         = "10:00"
+	defaultClose        = "02:00"
+	defaultSlotMinutes  = 60
+	extendedSlotMinutes = 90
+)
+
+// Day es el horario de apertura de un día de la semana.
+// Si Close es menor o igual que Open la cancha cierra después de medianoche.
+type Day struct {
+	Weekday time.Weekday `bson:"weekday" json:"weekday"` // 0 = domingo ... 6 = sábado
+	Open    string       `bson:"open" json:"open"`       // HH:MM
+	Close   string       `bson:"close" json:"close"`     // HH:MM
+}
+
+// Schedule es el horario semanal de una cancha. Los días que no figuran la cancha está cerrada.
+type Schedule struct {
+	Days         []Day `bson:"days" json:"days"`
+	SlotMinutes  int   `bson:"slot_minutes" json:"slot_minutes"`   // duración de cada turno
+	AlignMinutes int   `bson:"align_minutes" json:"align_minutes"` // cada cuántos minutos desde la apertura puede empezar un turno (0 = SlotMinutes)
+}
+
+// Slot es un turno validado contra el horario
+type Slot struct {
+	Start    string // HH:MM
+	End      string // HH:MM
+	Duration int    // minutos
+}
+
+// SlotError indica que el turno pedido no respeta el horario de la cancha
+type SlotError struct {
+	Message string
+}
+
+func (e *SlotError) Error() string {
+	return e.Message
+}
+
+func slotErrorf(format string, args ...interface{}) error {
+	return &SlotError{Message: fmt.Sprintf(format, args...)}
+}
+
+// Default retorna el horario que tenían todas las canchas antes de poder configurarlo:
+// todos los días de 10:00 a 02:00, turnos de 90 minutos para tenis y paddle y de 60 para el resto
+func Default(canchaType string) Schedule {
+	slot := defaultSlotMinutes
+	switch strings.ToLower(strings.TrimSpace(canchaType)) {
+	case "padel", "paddle", "tenis":
+		slot = extendedSlotMinutes
+	}
+
+	days := make([]Day, 7)
+	for i := range days {
+		days[i] = Day{Weekday: time.Weekday(i), Open: defaultOpen, Close: defaultClose}
+	}
+	return Schedule{Days: days, SlotMinutes: slot, AlignMinutes: slot}
+}
+
+// Validate verifica que el horario sea coherente: días únicos, horas HH:MM dentro del día de reservas
+// y al menos un turno posible en cada día abierto
+func (s Schedule) Validate() error {
+	if s.SlotMinutes < MinSlotMinutes || s.SlotMinutes > MaxSlotMinutes {
+		return fmt.Errorf("slot_minutes must be between %d and %d", MinSlotMinutes, MaxSlotMinutes)
+	}
+	if s.AlignMinutes < 0 || s.AlignMinutes > s.SlotMinutes {
+		return fmt.Errorf("align_minutes must be between 0 and slot_minutes")
+	}
+	if s.SlotMinutes%Granularity != 0 || s.AlignMinutes%Granularity != 0 {
+		return fmt.Errorf("slot_minutes and align_minutes must be multiples of %d", Granularity)
+	}
+
+	seen := map[time.Weekday]bool{}
+	for _, day := range s.Days {
+		if day.Weekday < time.Sunday || day.Weekday > time.Saturday {
+			return fmt.Errorf("invalid weekday: %d", day.Weekday)
+		}
+		if seen[day.Weekday] {
+			return fmt.Errorf("weekday %d appears more than once", day.Weekday)
+		}
+		seen[day.Weekday] = true
+
+		open, closing, err := day.window()
+		if err != nil {
+			return err
+		}
+		if open%Granularity != 0 || closing%Granularity != 0 {
+			return fmt.Errorf("%s: open and close must be multiples of %d minutes", day.Weekday, Granularity)
+		}
+		if closing-open < s.SlotMinutes {
+			return fmt.Errorf("%s: opening hours are shorter than one slot", day.Weekday)
+		}
+	}
+	return nil
+}
+
+// DayFor retorna el horario del día de la semana, o false si la cancha cierra ese día
+func (s Schedule) DayFor(weekday time.Weekday) (Day, bool) {
+	for _, day := range s.Days {
+		if day.Weekday == weekday {
+			return day, true
+		}
+	}
+	return Day{}, false
+}
+
+// Slot valida un turno que empieza a startTime en la fecha date y calcula su fin.
+// Si endTime no está vacío debe coincidir con el fin calculado.
+func (s Schedule) Slot(date time.Time, startTime, endTime string) (Slot, error) {
+	day, ok := s.DayFor(date.Weekday())
+	if !ok {
+		return Slot{}, slotErrorf("cancha is closed on %s", date.Weekday())
+	}
+	open, closing, err := day.window()
+	if err != nil {
+		return Slot{}, err
+	}
+
+	start, err := Minutes(startTime)
+	if err != nil {
+		return Slot{}, &SlotError{Message: err.Error()}
+	}
+	if start < open || start >= closing {
+		return Slot{}, slotErrorf("start time must be between %s and %s", Clock(open), Clock(closing-1))
+	}
+
+	if (start-open)%s.align() != 0 {
+		return Slot{}, slotErrorf("start time must align with %d-minute slots for this cancha", s.align())
+	}
+
+	expectedEnd := start + s.SlotMinutes
+	if expectedEnd > closing {
+		return Slot{}, slotErrorf("selected slot exceeds closing time (%s)", Clock(closing))
+	}
+
+	if endTime != "" {
+		// Se compara la hora del reloj: un turno puede terminar justo en el corte del día
+		end, err := clockMinutes(endTime)
+		if err != nil {
+			return Slot{}, &SlotError{Message: err.Error()}
+		}
+		if end != expectedEnd%MinutesPerDay {
+			return Slot{}, slotErrorf("end time must be %s for this cancha", Clock(expectedEnd))
+		}
+	}
+
+	return Slot{Start: Clock(start), End: Clock(expectedEnd), Duration: s.SlotMinutes}, nil
+}
+
+// Slots lista los turnos que se pueden reservar en la fecha date, en orden
+func (s Schedule) Slots(date time.Time) []Slot {
+	day, ok := s.DayFor(date.Weekday())
+	if !ok {
+		return nil
+	}
+	open, closing, err := day.window()
+	if err != nil {
+		return nil
+	}
+
+	var slots []Slot
+	for start := open; start+s.SlotMinutes <= closing; start += s.align() {
+		slots = append(slots, Slot{Start: Clock(start), End: Clock(start + s.SlotMinutes), Duration: s.SlotMinutes})
+	}
+	return slots
+}
+
+func (s Schedule) align() int {
+	if s.AlignMinutes > 0 {
+		return s.AlignMinutes
+	}
+	return s.SlotMinutes
+}
+
+// window retorna apertura y cierre en minutos desde la medianoche de la fecha de reserva
+func (d Day) window() (int, int, error) {
+	open, err := clockMinutes(d.Open)
+	if err != nil {
+		return 0, 0, err
+	}
+	if open < DayStartMinutes {
+		return 0, 0, fmt.Errorf("%s: open must be between %s and 23:59", d.Weekday, Clock(DayStartMinutes))
+	}
+
+	closing, err := clockMinutes(d.Close)
+	if err != nil {
+		return 0, 0, err
+	}
+	if closing <= open {
+		closing += MinutesPerDay
+	}
+	if closing > MinutesPerDay+DayStartMinutes {
+		return 0, 0, fmt.Errorf("%s: close must be at most %s of the next day", d.Weekday, Clock(DayStartMinutes))
+	}
+
+	return open, closing, nil
+}
+
+// Minutes convierte HH:MM en minutos desde la medianoche de la fecha de reserva:
+// las horas anteriores al corte del día cuentan como madrugada del día siguiente
+func Minutes(timeStr string) (int, error) {
+	minutes, err := clockMinutes(timeStr)
+	if err != nil {
+		return 0, err
+	}
+	if minutes < DayStartMinutes {
+		minutes += MinutesPerDay
+	}
+	return minutes, nil
+}
+
+func clockMinutes(timeStr string) (int, error) {
+	parsed, err := time.Parse("15:04", timeStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time format: %w", err)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// Clock convierte minutos absolutos en HH:MM
+func Clock(totalMinutes int) string {
+	minutes := totalMinutes % MinutesPerDay
+	if minutes < 0 {
+		minutes += MinutesPerDay
+	}
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
@@ -0,0 +1,96 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// monday es un lunes cualquiera
+var monday = time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+func TestDefaultKeepsLegacyWindow(t *testing.T) {
+	futbol := Default("futbol")
+	if err := futbol.Validate(); err != nil {
+		t.Fatalf("el horario por defecto debería ser válido: %v", err)
+	}
+
+	slot, err := futbol.Slot(monday, "01:00", "")
+	if err != nil || slot.End != "02:00" || slot.Duration != 60 {
+		t.Fatalf("se esperaba el turno 01:00-02:00, llegó %+v, error: %v", slot, err)
+	}
+	if slots := futbol.Slots(monday); len(slots) != 16 || slots[0].Start != "10:00" || slots[15].End != "02:00" {
+		t.Fatalf("se esperaban 16 turnos de 10:00 a 02:00, llegaron %+v", slots)
+	}
+
+	paddle := Default("Paddle")
+	if _, err := paddle.Slot(monday, "11:00", ""); err == nil {
+		t.Fatalf("11:00 no está alineado a turnos de 90 minutos desde las 10:00")
+	}
+	if slot, err := paddle.Slot(monday, "11:30", "13:00"); err != nil || slot.Duration != 90 {
+		t.Fatalf("se esperaba el turno 11:30-13:00, llegó %+v, error: %v", slot, err)
+	}
+}
+
+func TestSlotFollowsWeekdayHoursAndAlignment(t *testing.T) {
+	s := Schedule{
+		Days: []Day{
+			{Weekday: time.Monday, Open: "08:00", Close: "22:00"},
+			{Weekday: time.Saturday, Open: "09:00", Close: "03:00"},
+		},
+		SlotMinutes:  60,
+		AlignMinutes: 30,
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("horario válido rechazado: %v", err)
+	}
+
+	if _, err := s.Slot(monday, "08:30", "09:30"); err != nil {
+		t.Fatalf("con alineación de 30 minutos 08:30 debería ser válido: %v", err)
+	}
+
+	cases := []struct {
+		date  time.Time
+		start string
+		end   string
+	}{
+		{monday, "08:15", ""},                  // no alineado
+		{monday, "21:30", ""},                  // termina después del cierre
+		{monday, "07:00", ""},                  // antes de abrir
+		{monday, "08:00", "09:30"},             // fin distinto del turno
+		{monday.AddDate(0, 0, 1), "10:00", ""}, // martes cerrado
+	}
+	for _, tc := range cases {
+		_, err := s.Slot(tc.date, tc.start, tc.end)
+		var slotErr *SlotError
+		if !errors.As(err, &slotErr) {
+			t.Fatalf("%s %s-%s: se esperaba SlotError, llegó %v", tc.date.Weekday(), tc.start, tc.end, err)
+		}
+	}
+
+	saturday := monday.AddDate(0, 0, 5)
+	if slot, err := s.Slot(saturday, "02:00", ""); err != nil || slot.End != "03:00" {
+		t.Fatalf("el sábado cierra a las 03:00 del domingo, llegó %+v, error: %v", slot, err)
+	}
+}
+
+func TestValidateRejectsInconsistentSchedules(t *testing.T) {
+	invalid := []Schedule{
+		{SlotMinutes: 10},
+		{SlotMinutes: 60, AlignMinutes: 90},
+		{SlotMinutes: 60, Days: []Day{{Weekday: 7, Open: "10:00", Close: "20:00"}}},
+		{SlotMinutes: 60, Days: []Day{{Weekday: time.Monday, Open: "10:00", Close: "20:00"}, {Weekday: time.Monday, Open: "12:00", Close: "20:00"}}},
+		{SlotMinutes: 60, Days: []Day{{Weekday: time.Monday, Open: "10:00", Close: "10:30"}}},
+		{SlotMinutes: 60, Days: []Day{{Weekday: time.Monday, Open: "05:00", Close: "12:00"}}},
+		{SlotMinutes: 60, Days: []Day{{Weekday: time.Monday, Open: "18:00", Close: "07:00"}}},
+		{SlotMinutes: 60, Days: []Day{{Weekday: time.Monday, Open: "10", Close: "20:00"}}},
+		{SlotMinutes: 62},
+		{SlotMinutes: 60, AlignMinutes: 3},
+		{SlotMinutes: 60, Days: []Day{{Weekday: time.Monday, Open: "10:03", Close: "20:00"}}},
+	}
+	for i, s := range invalid {
+		if err := s.Validate(); err == nil {
+			t.Fatalf("caso %d: se esperaba error para %+v", i, s)
+		}
+	}
+}
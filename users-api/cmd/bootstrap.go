@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"users-api/internal/dto"
+	"users-api/internal/services"
+)
+
+// runBootstrapAdmin implementa el subcomando "bootstrap-admin", que crea el primer
+// administrador sin exponer ningún endpoint. Falla si ya existe un admin.
+//
+//	./main bootstrap-admin -username admin -email admin@example.com -first-name Ada -last-name Admin
+//
+// La contraseña se lee de -password o de la variable BOOTSTRAP_ADMIN_PASSWORD.
+func runBootstrapAdmin(userService services.UserService, args []string) {
+	fs := flag.NewFlagSet("bootstrap-admin", flag.ExitOnError)
+	username := fs.String("username", "", "username del admin")
+	email := fs.String("email", "", "email del admin")
+	password := fs.String("password", os.Getenv("BOOTSTRAP_ADMIN_PASSWORD"), "contraseña del admin")
+	firstName := fs.String("first-name", "", "nombre del admin")
+	lastName := fs.String("last-name", "", "apellido del admin")
+	_ = fs.Parse(args)
+
+	if *username == "" || *email == "" || *password == "" {
+		log.Fatal("bootstrap-admin requires -username, -email and -password (or BOOTSTRAP_ADMIN_PASSWORD)")
+	}
+
+	user, err := userService.CreateFirstAdmin(&dto.RegisterRequest{
+		Username:  *username,
+		Email:     *email,
+		Password:  *password,
+		FirstName: *firstName,
+		LastName:  *lastName,
+	})
+	if err != nil {
+		log.Fatalf("Failed to bootstrap admin: %v", err)
+	}
+
+	log.Printf("Admin %s (id %d) created successfully", user.Username, user.ID)
+}
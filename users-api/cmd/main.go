@@ -2,14 +2,25 @@ package main
 
 import (
 	"log"
+	"os"
+	"shared/auth"
 	"time"
 	"users-api/config"
+	"users-api/internal/clients"
+	"users-api/internal/consumers"
 	"users-api/internal/controllers"
 	"users-api/internal/domain"
-	"users-api/internal/middleware"
+	"users-api/internal/dto"
+	"users-api/internal/limiter"
+	"users-api/internal/mailer"
+	"users-api/internal/messaging"
+	"users-api/internal/oidc"
+	"users-api/internal/password"
 	"users-api/internal/repositories"
 	"users-api/internal/services"
+	"users-api/utils"
 
+	"github.com/bradfitz/gomemcache/memcache"
 	"github.com/gin-gonic/gin"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -23,20 +34,53 @@ func main() {
 	// Conectar a la base de datos
 	db := connectDatabase()
 
-	// Migrar modelos
+	// Subcomando para administrar las migraciones de esquema
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(db, os.Args[2:])
+		return
+	}
+
+	// Verificar la versión del esquema (y aplicar las migraciones pendientes si MIGRATE_ON_START)
 	migrateDatabase(db)
 
+	// Cargar claves de firma de JWT
+	loadSigningKeys()
+
+	// Conectar a RabbitMQ para publicar eventos user.*
+	publisher, err := messaging.NewRabbitMQPublisher()
+	if err != nil {
+		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
+	}
+	defer publisher.Close()
+
 	// Inicializar repositorios
 	userRepo := repositories.NewUserRepository(db)
 
 	// Inicializar servicios
-	userService := services.NewUserService(userRepo)
+	userService := services.NewUserService(userRepo, newMailer(), newLoginGuard(), newPasswordPolicy(), publisher, clients.NewReservaClient(), newOIDCProviders())
+
+	// Subcomando para crear el primer admin desde la línea de comandos
+	if len(os.Args) > 1 && os.Args[1] == "bootstrap-admin" {
+		runBootstrapAdmin(userService, os.Args[2:])
+		return
+	}
+
+	// Escuchar las ofertas de la lista de espera de reservas-api para avisarlas por email
+	reservaConsumer, err := consumers.NewReservaEventsConsumer(config.AppConfig.RabbitMQURL, userService)
+	if err != nil {
+		log.Fatalf("Failed to connect reserva events consumer: %v", err)
+	}
+	defer reservaConsumer.Close()
+	if err := reservaConsumer.Listen(config.AppConfig.ReservasExchange, config.AppConfig.ReservasQueue); err != nil {
+		log.Fatalf("Failed to listen for reserva events: %v", err)
+	}
 
 	// Inicializar controladores
 	userController := controllers.NewUserController(userService)
 
 	// Configurar Gin
-	router := setupRouter(userController)
+	validator := utils.TokenValidator()
+	router := setupRouter(userController, validator)
 
 	// Iniciar servidor
 	port := config.AppConfig.Port
@@ -46,6 +90,116 @@ func main() {
 	}
 }
 
+// loadSigningKeys carga las claves privadas de JWT_KEYS_DIR o genera una efímera para desarrollo
+func loadSigningKeys() {
+	if config.AppConfig.JWTKeysDir == "" {
+		log.Println("Warning: JWT_KEYS_DIR not set, tokens will be signed with an ephemeral key")
+		return
+	}
+
+	keySet, err := utils.LoadKeySet(config.AppConfig.JWTKeysDir, config.AppConfig.JWTActiveKID)
+	if err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+	utils.SetKeySet(keySet)
+
+	log.Println("JWT signing keys loaded successfully")
+}
+
+// newMailer usa SMTP si SMTP_HOST está configurado; si no, escribe los emails en MAIL_DIR
+func newMailer() mailer.Mailer {
+	cfg := config.AppConfig
+	if cfg.SMTPHost == "" {
+		log.Printf("Warning: SMTP_HOST not set, emails will be written to %s", cfg.MailDir)
+		return mailer.NewFileMailer(cfg.MailDir, cfg.MailFrom)
+	}
+
+	return mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPassword, cfg.MailFrom)
+}
+
+// newPasswordPolicy arma la política de contraseñas nuevas con la lista embebida y la de PASSWORD_BLOCKLIST_FILE
+func newPasswordPolicy() *password.Policy {
+	cfg := config.AppConfig
+
+	policy := &password.Policy{
+		MinLength:     cfg.PasswordMinLength,
+		RequireLower:  cfg.PasswordRequireLower,
+		RequireUpper:  cfg.PasswordRequireUpper,
+		RequireDigit:  cfg.PasswordRequireDigit,
+		RequireSymbol: cfg.PasswordRequireSymbol,
+	}
+	if !cfg.PasswordCheckBlocklist {
+		log.Println("Warning: PASSWORD_CHECK_BLOCKLIST disabled, breached passwords will be accepted")
+		return policy
+	}
+
+	policy.Blocklist = password.DefaultBlocklist()
+	if cfg.PasswordBlocklistFile != "" {
+		if err := policy.Blocklist.LoadFile(cfg.PasswordBlocklistFile); err != nil {
+			log.Fatalf("Failed to load password blocklist: %v", err)
+		}
+	}
+	log.Printf("Password blocklist loaded with %d entries", policy.Blocklist.Len())
+
+	return policy
+}
+
+// newLoginGuard arma el limitador de intentos de login sobre Memcached o en memoria
+func newLoginGuard() *limiter.Guard {
+	cfg := config.AppConfig
+
+	var store limiter.Store = limiter.NewMemoryStore()
+	if cfg.LimiterStore == "memcached" {
+		client := memcache.New(cfg.MemcachedURL)
+		if err := client.Ping(); err != nil {
+			log.Printf("Warning: Memcached unreachable (%v), login limiter will use in-process memory", err)
+		} else {
+			store = limiter.NewMemcachedStore(client, "users-api:")
+		}
+	}
+
+	window := time.Duration(cfg.LoginFailureWindowMinutes) * time.Minute
+	lockout := time.Duration(cfg.LoginLockoutMinutes) * time.Minute
+	base := time.Duration(cfg.LoginBackoffBaseSeconds) * time.Second
+	maxDelay := time.Duration(cfg.LoginBackoffMaxSeconds) * time.Second
+
+	accountPolicy := limiter.Policy{
+		BackoffAfter:    cfg.LoginBackoffAfter,
+		BaseDelay:       base,
+		MaxDelay:        maxDelay,
+		LockoutAfter:    cfg.LoginLockoutAfter,
+		LockoutDuration: lockout,
+		Window:          window,
+	}
+	ipPolicy := limiter.Policy{
+		BackoffAfter:    cfg.LoginIPBackoffAfter,
+		BaseDelay:       base,
+		MaxDelay:        maxDelay,
+		LockoutAfter:    cfg.LoginIPLockoutAfter,
+		LockoutDuration: lockout,
+		Window:          window,
+	}
+
+	return limiter.NewGuard(store, accountPolicy, ipPolicy)
+}
+
+// newOIDCProviders arma los proveedores OIDC declarados en OIDC_PROVIDERS
+func newOIDCProviders() []oidc.Provider {
+	var providers []oidc.Provider
+	for _, cfg := range config.AppConfig.OIDCProviders {
+		providers = append(providers, oidc.NewProvider(oidc.Config{
+			Name:         cfg.Name,
+			Issuer:       cfg.Issuer,
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+		}))
+		log.Printf("OIDC provider %s enabled", cfg.Name)
+	}
+	return providers
+}
+
 // connectDatabase establece la conexión con MySQL
 func connectDatabase() *gorm.DB {
 	dsn := config.AppConfig.GetDSN()
@@ -83,24 +237,63 @@ func connectDatabase() *gorm.DB {
 	return nil
 }
 
-// migrateDatabase ejecuta las migraciones automáticas
+// migrateDatabase verifica la versión del esquema antes de arrancar.
+// Se niega a correr contra un esquema más nuevo que el binario; las migraciones pendientes
+// se aplican solo si MIGRATE_ON_START está activo, si no hay que correr "migrate up".
 func migrateDatabase(db *gorm.DB) {
-	log.Println("Running database migrations...")
+	migrator := newMigrator(db)
 
-	if err := db.AutoMigrate(&domain.User{}); err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+	pending, err := migrator.Check()
+	if err != nil {
+		log.Fatalf("Refusing to start: %v", err)
 	}
 
-	log.Println("Database migrations completed successfully")
+	if pending > 0 {
+		if !config.AppConfig.MigrateOnStart {
+			log.Fatalf("Refusing to start: %d pending migrations, run \"migrate up\" first", pending)
+		}
+
+		log.Printf("Applying %d pending migrations...", pending)
+		applied, err := migrator.Up()
+		for _, m := range applied {
+			log.Printf("Applied %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+	}
+
+	// Sembrar roles y permisos iniciales
+	if err := repositories.NewUserRepository(db).SeedRoles(domain.DefaultRoles); err != nil {
+		log.Fatalf("Failed to seed roles: %v", err)
+	}
+
+	log.Printf("Database schema at version %d", migrator.Latest())
 }
 
 // setupRouter configura las rutas de la API
-func setupRouter(userController *controllers.UserController) *gin.Engine {
+func setupRouter(userController *controllers.UserController, validator auth.Validator) *gin.Engine {
 	router := gin.Default()
 
+	// La IP del cliente limita los intentos de login: solo se confía en X-Forwarded-For de proxies conocidos
+	if err := router.SetTrustedProxies(config.AppConfig.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
 	// Middleware CORS (opcional pero útil para desarrollo frontend)
 	router.Use(corsMiddleware())
 
+	// Claves públicas para que los demás servicios validen tokens sin conocer ningún secreto
+	router.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		jwks, err := utils.PublicJWKS()
+		if err != nil {
+			c.JSON(500, dto.ErrorResponse{Error: "Failed to load signing keys", Message: err.Error()})
+			return
+		}
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(200, jwks)
+	})
+
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -114,23 +307,59 @@ func setupRouter(userController *controllers.UserController) *gin.Engine {
 	{
 		public.POST("/register", userController.Register)
 		public.POST("/login", userController.Login)
-		public.POST("/admin", userController.RegisterAdmin) // Para crear el primer admin
+		public.POST("/login/2fa", userController.LoginTwoFactor)
+		public.POST("/refresh", userController.Refresh)
+		public.POST("/logout", userController.Logout)
+		public.POST("/bootstrap", userController.Bootstrap) // Crea el primer admin con BOOTSTRAP_TOKEN
+		public.POST("/password/forgot", userController.ForgotPassword)
+		public.POST("/password/reset", userController.ResetPassword)
+		public.POST("/email/verify", userController.VerifyEmail)
+
+		// Login con proveedores OIDC (authorization code + PKCE)
+		public.GET("/oidc/providers", userController.OIDCProviders)
+		public.GET("/oidc/:provider/authorize", userController.OIDCAuthorize)
+		public.POST("/oidc/:provider/callback", userController.OIDCCallback)
 	}
 
 	// Rutas protegidas (requieren autenticación)
 	protected := router.Group("/users")
-	protected.Use(middleware.AuthMiddleware())
+	protected.Use(auth.Middleware(validator))
 	{
-		protected.GET("/:id", userController.GetByID)
-		protected.PUT("/:id", userController.Update)
+		// Perfil del usuario autenticado
+		protected.GET("/me", userController.GetProfile)
+		protected.PATCH("/me", userController.UpdateProfile)
+		protected.POST("/me/password", userController.ChangePassword)
+
+		// Solo el propio usuario o quien tenga el permiso
+		protected.GET("/:id", auth.RequireSelfOrPermission("id", auth.PermUsersRead), userController.GetByID)
+		protected.PUT("/:id", auth.RequireSelfOrPermission("id", auth.PermUsersManage), userController.Update)
+		protected.POST("/:id/erase", auth.RequireSelfOrPermission("id", auth.PermUsersManage), userController.Erase) // Borrado de datos personales
+		protected.POST("/email/verify/resend", userController.ResendVerification)
+
+		// 2FA del usuario autenticado
+		protected.POST("/2fa/enroll", userController.EnrollTwoFactor)
+		protected.POST("/2fa/confirm", userController.ConfirmTwoFactor)
+		protected.POST("/2fa/disable", userController.DisableTwoFactor)
+		protected.POST("/2fa/recovery-codes", userController.RegenerateRecoveryCodes)
 	}
 
-	// Rutas de administrador (requieren autenticación y rol admin)
-	admin := router.Group("/users")
-	admin.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	// Rutas de gestión (requieren autenticación y el permiso de cada ruta)
+	manage := router.Group("/users")
+	manage.Use(auth.Middleware(validator))
 	{
-		admin.GET("", userController.GetAll)
-		admin.DELETE("/:id", userController.Delete)
+		manage.GET("", auth.RequirePermission(auth.PermUsersRead), userController.GetAll)
+		manage.GET("/export", auth.RequirePermission(auth.PermUsersManage), userController.Export)
+		manage.GET("/audit", auth.RequirePermission(auth.PermUsersManage), userController.ListAudit)
+		manage.POST("/invitations", auth.RequirePermission(auth.PermUsersManage), userController.CreateInvitation)
+		manage.POST("/:id/unlock", auth.RequirePermission(auth.PermUsersManage), userController.UnlockUser)
+		manage.POST("/:id/deactivate", auth.RequirePermission(auth.PermUsersManage), userController.Deactivate)
+		manage.POST("/:id/reactivate", auth.RequirePermission(auth.PermUsersManage), userController.Reactivate)
+		manage.DELETE("/:id", auth.RequirePermission(auth.PermUsersManage), userController.Delete) // Borrado lógico
+
+		// Roles y permisos
+		manage.GET("/roles", auth.RequirePermission(auth.PermUsersRead), userController.ListRoles)
+		manage.PUT("/roles/:role/permissions", auth.RequirePermission(auth.PermRolesManage), userController.SetRolePermissions)
+		manage.PUT("/:id/role", auth.RequirePermission(auth.PermRolesManage), userController.AssignRole)
 	}
 
 	log.Println("Routes configured successfully")
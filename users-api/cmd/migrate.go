@@ -0,0 +1,87 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"users-api/internal/migrations"
+
+	"gorm.io/gorm"
+)
+
+// runMigrate implementa el subcomando "migrate", que administra las migraciones embebidas en el binario.
+//
+//	./main migrate up        aplica todas las pendientes
+//	./main migrate down [n]  revierte las últimas n (por defecto 1)
+//	./main migrate status    lista las migraciones y cuáles están aplicadas
+func runMigrate(db *gorm.DB, args []string) {
+	if len(args) == 0 {
+		log.Fatal("usage: migrate up | down [n] | status")
+	}
+
+	migrator := newMigrator(db)
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		for _, m := range applied {
+			log.Printf("Applied %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if len(applied) == 0 {
+			log.Println("No pending migrations")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				log.Fatalf("Invalid number of steps: %s", args[1])
+			}
+			steps = n
+		}
+
+		reverted, err := migrator.Down(steps)
+		for _, m := range reverted {
+			log.Printf("Reverted %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Rollback failed: %v", err)
+		}
+		if len(reverted) == 0 {
+			log.Println("No applied migrations to revert")
+		}
+
+	case "status":
+		statuses, err := migrator.Status()
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			log.Printf("%04d_%-30s %s", s.Version, s.Name, state)
+		}
+		if err != nil {
+			log.Fatalf("Schema check failed: %v", err)
+		}
+
+	default:
+		log.Fatalf("Unknown migrate command %q, expected up, down or status", args[0])
+	}
+}
+
+// newMigrator crea el migrador sobre la conexión de GORM
+func newMigrator(db *gorm.DB) *migrations.Migrator {
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("Failed to get database instance: %v", err)
+	}
+
+	migrator, err := migrations.NewMigrator(migrations.NewMySQLStore(sqlDB))
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	return migrator
+}
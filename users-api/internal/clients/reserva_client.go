@@ -0,0 +1,66 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"users-api/config"
+)
+
+type ReservaClient interface {
+	GetByUserID(userID uint, accessToken string) ([]ReservaResponse, error)
+}
+
+// ReservaResponse son los campos de una reserva que usa users-api
+type ReservaResponse struct {
+	ID         string  `json:"id"`
+	Date       string  `json:"date"` // YYYY-MM-DD
+	StartTime  string  `json:"start_time"`
+	Status     string  `json:"status"`
+	TotalPrice float64 `json:"total_price"`
+}
+
+type reservaClient struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewReservaClient crea una nueva instancia del cliente HTTP para reservas-api
+func NewReservaClient() ReservaClient {
+	return &reservaClient{
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		baseURL:    config.AppConfig.ReservasAPIURL,
+	}
+}
+
+// GetByUserID obtiene las reservas de un usuario con el token de quien consulta,
+// así reservas-api aplica su propio control de acceso.
+func (c *reservaClient) GetByUserID(userID uint, accessToken string) ([]ReservaResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/reservas/user/%d", c.baseURL, userID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request to reservas-api: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call reservas-api: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("reservas-api returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var list struct {
+		Reservas []ReservaResponse `json:"reservas"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return list.Reservas, nil
+}
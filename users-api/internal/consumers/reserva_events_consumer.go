@@ -0,0 +1,229 @@
+package consumers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+	"users-api/internal/dto"
+	"users-api/internal/services"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	// maxNotifyAttempts es la cantidad de intentos de enviar un aviso antes de dejarlo en la cola de descarte
+	maxNotifyAttempts = 5
+	// retryBaseDelay es la espera antes del primer reintento; se duplica en cada uno
+	retryBaseDelay = 5 * time.Second
+	// retriesHeader cuenta los reintentos que ya tuvo un mensaje
+	retriesHeader = "x-retries"
+)
+
+type ReservaEventsConsumer struct {
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	service services.UserService
+}
+
+// NewReservaEventsConsumer inicializa la conexión y el canal
+func NewReservaEventsConsumer(rabbitURL string, userService services.UserService) (*ReservaEventsConsumer, error) {
+	conn, err := amqp.Dial(rabbitURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open RabbitMQ channel: %w", err)
+	}
+
+	return &ReservaEventsConsumer{
+		conn:    conn,
+		channel: ch,
+		service: userService,
+	}, nil
+}
+
+// Listen suscribe el consumidor a las ofertas de la lista de espera de reservas-api
+func (c *ReservaEventsConsumer) Listen(exchangeName, queueName string) error {
+	err := c.channel.ExchangeDeclare(
+		exchangeName, // nombre del exchange
+		"topic",      // tipo de exchange
+		true,         // durable
+		false,        // auto-deleted
+		false,        // internal
+		false,        // no-wait
+		nil,          // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare exchange: %w", err)
+	}
+
+	q, err := c.channel.QueueDeclare(
+		queueName, // nombre de la cola
+		true,      // durable
+		false,     // delete when unused
+		false,     // exclusive
+		false,     // no-wait
+		nil,       // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare queue: %w", err)
+	}
+
+	err = c.channel.QueueBind(
+		q.Name,
+		"reserva.waitlist_offer", // routing key: solo interesan las ofertas de turnos liberados
+		exchangeName,             // exchange
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to bind queue: %w", err)
+	}
+
+	// Cola de reintentos: nadie la consume, cada mensaje vence a su tiempo y vuelve a la cola principal
+	_, err = c.channel.QueueDeclare(retryQueueName(q.Name), true, false, false, false, amqp.Table{
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": q.Name,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to declare retry queue: %w", err)
+	}
+
+	// Cola de descarte: avisos que agotaron los reintentos, para revisarlos a mano
+	if _, err := c.channel.QueueDeclare(deadQueueName(q.Name), true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead letter queue: %w", err)
+	}
+
+	msgs, err := c.channel.Consume(
+		q.Name, // cola
+		"",     // consumer tag
+		false,  // auto-ack desactivado para no perder avisos si falla el envío del email
+		false,  // exclusive
+		false,  // no-local
+		false,  // no-wait
+		nil,    // args
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register consumer: %w", err)
+	}
+
+	log.Printf("[RabbitMQ] Listening on queue: %s (exchange: %s)", q.Name, exchangeName)
+
+	go func() {
+		for d := range msgs {
+			log.Printf("[RabbitMQ] Received message: %s", d.RoutingKey)
+
+			var event struct {
+				Type   string                        `json:"type"`
+				Entity string                        `json:"entity"`
+				Data   dto.WaitlistOfferNotification `json:"data"`
+			}
+
+			if err := json.Unmarshal(d.Body, &event); err != nil {
+				log.Printf("[RabbitMQ] Error decoding message: %v", err)
+				_ = d.Nack(false, false) // mensaje inválido: no tiene sentido reencolarlo
+				continue
+			}
+
+			if event.Type != "waitlist_offer" || event.Data.UserID == 0 {
+				_ = d.Ack(false)
+				continue
+			}
+
+			if err := c.service.NotifyWaitlistOffer(&event.Data); err != nil {
+				c.retry(d, q.Name, event.Data.EntryID, err)
+				continue
+			}
+
+			if err := d.Ack(false); err != nil {
+				log.Printf("[Users] Failed to ack message: %v", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// retry reprograma un aviso que no se pudo enviar, con espera exponencial para no insistir
+// contra un mailer caído; agotados los intentos lo deja en la cola de descarte.
+// El original se confirma recién cuando la copia quedó publicada.
+func (c *ReservaEventsConsumer) retry(d amqp.Delivery, queue, entryID string, cause error) {
+	attempt := retries(d.Headers) + 1
+	target, expiration := retryQueueName(queue), ""
+	if attempt < maxNotifyAttempts {
+		delay := retryDelay(attempt)
+		expiration = strconv.FormatInt(delay.Milliseconds(), 10)
+		log.Printf("[Users] Failed to notify waitlist offer (%s), attempt %d: %v. Retrying in %v...", entryID, attempt, cause, delay)
+	} else {
+		target = deadQueueName(queue)
+		log.Printf("[Users] Failed to notify waitlist offer (%s) after %d attempts: %v. Moving to %s", entryID, attempt, cause, target)
+	}
+
+	headers := amqp.Table{}
+	for key, value := range d.Headers {
+		headers[key] = value
+	}
+	headers[retriesHeader] = int32(attempt)
+
+	err := c.channel.Publish("", target, false, false, amqp.Publishing{
+		ContentType:  d.ContentType,
+		Body:         d.Body,
+		Headers:      headers,
+		DeliveryMode: amqp.Persistent,
+		Expiration:   expiration,
+	})
+	if err != nil {
+		// Sin poder reprogramarlo se devuelve a la cola: mejor reintentar antes que perder el aviso
+		log.Printf("[Users] Failed to schedule retry: %v. Requeueing...", err)
+		if err := d.Nack(false, true); err != nil {
+			log.Printf("[Users] Failed to nack message: %v", err)
+		}
+		return
+	}
+
+	if err := d.Ack(false); err != nil {
+		log.Printf("[Users] Failed to ack message: %v", err)
+	}
+}
+
+// retries retorna cuántas veces se reintentó ya el mensaje
+func retries(headers amqp.Table) int {
+	switch n := headers[retriesHeader].(type) {
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	case int:
+		return n
+	default:
+		return 0
+	}
+}
+
+// retryDelay es la espera antes del reintento número attempt (1, 2, ...): retryBaseDelay duplicado en cada uno
+func retryDelay(attempt int) time.Duration {
+	return retryBaseDelay << (attempt - 1)
+}
+
+func retryQueueName(queue string) string {
+	return queue + ".retry"
+}
+
+func deadQueueName(queue string) string {
+	return queue + ".dead"
+}
+
+// Close cierra la conexión y canal
+func (c *ReservaEventsConsumer) Close() {
+	if c.channel != nil {
+		_ = c.channel.Close()
+	}
+	if c.conn != nil {
+		_ = c.conn.Close()
+	}
+}
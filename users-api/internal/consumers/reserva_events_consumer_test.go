@@ -0,0 +1,35 @@
+package consumers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+func TestRetriesReadsTheHeaderAfterARoundTrip(t *testing.T) {
+	// Según el camino, RabbitMQ devuelve el contador como int32 o int64
+	for _, headers := range []amqp.Table{{retriesHeader: int32(3)}, {retriesHeader: int64(3)}, {retriesHeader: 3}} {
+		if got := retries(headers); got != 3 {
+			t.Fatalf("se esperaban 3 reintentos en %v, llegó %d", headers, got)
+		}
+	}
+	if got := retries(nil); got != 0 {
+		t.Fatalf("un mensaje sin el header no tiene reintentos, llegó %d", got)
+	}
+	if got := retries(amqp.Table{retriesHeader: "3"}); got != 0 {
+		t.Fatalf("un header inválido cuenta como sin reintentos, llegó %d", got)
+	}
+}
+
+func TestRetryDelayDoublesUntilTheLastAttempt(t *testing.T) {
+	want := []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second}
+	if len(want) != maxNotifyAttempts-1 {
+		t.Fatalf("se esperaban %d reintentos antes del descarte", len(want))
+	}
+	for i, delay := range want {
+		if got := retryDelay(i + 1); got != delay {
+			t.Fatalf("el reintento %d debe esperar %v, llegó %v", i+1, delay, got)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"net/http"
+	"shared/auth"
+	"users-api/internal/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListAudit lista el log de auditoría con filtros (actor_id, target_id, action, ip, from, to)
+// GET /users/audit
+func (ctrl *UserController) ListAudit(c *gin.Context) {
+	var query dto.AuditListQuery
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid query",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	entries, err := ctrl.service.ListAudit(&query)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "invalid action", "invalid from", "invalid to":
+			statusCode = http.StatusBadRequest
+		}
+
+		c.JSON(statusCode, dto.ErrorResponse{
+			Error:   "Failed to get audit log",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// requestMeta arma los datos del request que se guardan en el log de auditoría
+func requestMeta(c *gin.Context) dto.RequestMeta {
+	meta := dto.RequestMeta{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if claims, ok := auth.ClaimsFromContext(c); ok {
+		meta.ActorID = claims.UserID
+	}
+	return meta
+}
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"net/http"
+	"users-api/internal/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OIDCProviders lista los proveedores OIDC con los que se puede iniciar sesión
+// GET /users/oidc/providers
+func (ctrl *UserController) OIDCProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, ctrl.service.OIDCProviders())
+}
+
+// OIDCAuthorize inicia el login con un proveedor OIDC y retorna la URL a la que redirigir al usuario
+// GET /users/oidc/:provider/authorize
+func (ctrl *UserController) OIDCAuthorize(c *gin.Context) {
+	response, err := ctrl.service.OIDCAuthorize(c.Param("provider"))
+	if err != nil {
+		c.JSON(oidcErrorStatus(err), dto.ErrorResponse{
+			Error:   "Failed to start login",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// OIDCCallback completa el login con el código y el state que el proveedor devolvió al frontend
+// POST /users/oidc/:provider/callback
+func (ctrl *UserController) OIDCCallback(c *gin.Context) {
+	var req dto.OIDCCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response, err := ctrl.service.OIDCCallback(c.Param("provider"), &req, requestMeta(c))
+	if err != nil {
+		c.JSON(oidcErrorStatus(err), dto.ErrorResponse{
+			Error:   "Login failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// oidcErrorStatus mapea los errores del login OIDC a un código HTTP
+func oidcErrorStatus(err error) int {
+	switch err.Error() {
+	case "unknown provider":
+		return http.StatusNotFound
+	case "invalid or expired state", "provider did not return an email":
+		return http.StatusBadRequest
+	case "oidc login failed", "user not found":
+		return http.StatusUnauthorized
+	case "email not verified", "account deactivated":
+		return http.StatusForbidden
+	case "email already registered":
+		return http.StatusConflict
+	case "provider unavailable":
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
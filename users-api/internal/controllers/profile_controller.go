@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"net/http"
+	"shared/auth"
+	"users-api/internal/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetProfile obtiene el perfil del usuario autenticado con el resumen de sus reservas
+// GET /users/me
+func (ctrl *UserController) GetProfile(c *gin.Context) {
+	claims, _ := auth.ClaimsFromContext(c)
+	// El token se reenvía a reservas-api, que aplica su propio control de acceso
+	token, _ := auth.ParseBearer(c.GetHeader("Authorization"))
+
+	profile, err := ctrl.service.GetProfile(claims.UserID, token)
+	if err != nil {
+		c.JSON(profileErrorStatus(err), dto.ErrorResponse{
+			Error:   "Failed to get profile",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// UpdateProfile actualiza los campos enviados del perfil del usuario autenticado
+// PATCH /users/me
+func (ctrl *UserController) UpdateProfile(c *gin.Context) {
+	claims, _ := auth.ClaimsFromContext(c)
+
+	var req dto.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	user, err := ctrl.service.Update(claims.UserID, &req)
+	if err != nil {
+		c.JSON(profileErrorStatus(err), dto.ErrorResponse{
+			Error:   "Failed to update profile",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ChangePassword cambia la contraseña del usuario autenticado y cierra sus sesiones
+// POST /users/me/password
+func (ctrl *UserController) ChangePassword(c *gin.Context) {
+	claims, _ := auth.ClaimsFromContext(c)
+
+	var req dto.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := ctrl.service.ChangePassword(claims.UserID, &req, requestMeta(c)); err != nil {
+		if passwordRejected(c, "Failed to change password", err) {
+			return
+		}
+
+		statusCode := profileErrorStatus(err)
+		if throttled(c, err) {
+			statusCode = http.StatusTooManyRequests
+		}
+
+		c.JSON(statusCode, dto.ErrorResponse{
+			Error:   "Failed to change password",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password changed successfully, please log in again",
+	})
+}
+
+// profileErrorStatus mapea los errores de perfil y contraseña a un código HTTP
+func profileErrorStatus(err error) int {
+	switch err.Error() {
+	case "user not found":
+		return http.StatusNotFound
+	case "username already exists", "email already exists":
+		return http.StatusConflict
+	case "invalid current password":
+		return http.StatusForbidden
+	case "new password must be different":
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"users-api/internal/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListRoles lista los roles con sus permisos
+// GET /users/roles
+func (ctrl *UserController) ListRoles(c *gin.Context) {
+	roles, err := ctrl.service.ListRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error:   "Failed to get roles",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// SetRolePermissions reemplaza los permisos de un rol
+// PUT /users/roles/:role/permissions
+func (ctrl *UserController) SetRolePermissions(c *gin.Context) {
+	var req dto.SetRolePermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	role, err := ctrl.service.SetRolePermissions(c.Param("role"), &req, requestMeta(c))
+	if err != nil {
+		c.JSON(roleErrorStatus(err), dto.ErrorResponse{
+			Error:   "Failed to update role",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// AssignRole cambia el rol de un usuario
+// PUT /users/:id/role
+func (ctrl *UserController) AssignRole(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req dto.AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	user, err := ctrl.service.AssignRole(id, &req, requestMeta(c))
+	if err != nil {
+		c.JSON(roleErrorStatus(err), dto.ErrorResponse{
+			Error:   "Failed to assign role",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// roleErrorStatus mapea los errores de roles y permisos a un código HTTP
+func roleErrorStatus(err error) int {
+	switch {
+	case err.Error() == "user not found" || err.Error() == "role not found":
+		return http.StatusNotFound
+	case err.Error() == "invalid role" || strings.HasPrefix(err.Error(), "unknown permission"):
+		return http.StatusBadRequest
+	case err.Error() == "cannot remove the last admin" || err.Error() == "admin role must keep roles:manage":
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"net/http"
+	"shared/auth"
+	"users-api/internal/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoginTwoFactor completa el login con el challenge y un código TOTP o de recuperación
+// POST /users/login/2fa
+func (ctrl *UserController) LoginTwoFactor(c *gin.Context) {
+	var req dto.TwoFactorLoginRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response, err := ctrl.service.LoginTwoFactor(&req, requestMeta(c))
+	if err != nil {
+		statusCode := twoFactorStatus(err)
+		if throttled(c, err) {
+			statusCode = http.StatusTooManyRequests
+		}
+
+		c.JSON(statusCode, dto.ErrorResponse{
+			Error:   "Login failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// EnrollTwoFactor inicia el enrolamiento de 2FA del usuario autenticado
+// POST /users/2fa/enroll
+func (ctrl *UserController) EnrollTwoFactor(c *gin.Context) {
+	claims, _ := auth.ClaimsFromContext(c)
+
+	response, err := ctrl.service.EnrollTwoFactor(claims.UserID)
+	if err != nil {
+		c.JSON(twoFactorStatus(err), dto.ErrorResponse{
+			Error:   "Two-factor enrollment failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ConfirmTwoFactor activa 2FA con un código de la app y devuelve los códigos de recuperación
+// POST /users/2fa/confirm
+func (ctrl *UserController) ConfirmTwoFactor(c *gin.Context) {
+	var req dto.TwoFactorCodeRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	claims, _ := auth.ClaimsFromContext(c)
+	response, err := ctrl.service.ConfirmTwoFactor(claims.UserID, &req, requestMeta(c))
+	if err != nil {
+		c.JSON(twoFactorStatus(err), dto.ErrorResponse{
+			Error:   "Two-factor confirmation failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DisableTwoFactor desactiva 2FA del usuario autenticado
+// POST /users/2fa/disable
+func (ctrl *UserController) DisableTwoFactor(c *gin.Context) {
+	var req dto.DisableTwoFactorRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	claims, _ := auth.ClaimsFromContext(c)
+	if err := ctrl.service.DisableTwoFactor(claims.UserID, &req, requestMeta(c)); err != nil {
+		c.JSON(twoFactorStatus(err), dto.ErrorResponse{
+			Error:   "Failed to disable two-factor",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes emite nuevos códigos de recuperación
+// POST /users/2fa/recovery-codes
+func (ctrl *UserController) RegenerateRecoveryCodes(c *gin.Context) {
+	var req dto.TwoFactorCodeRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	claims, _ := auth.ClaimsFromContext(c)
+	response, err := ctrl.service.RegenerateRecoveryCodes(claims.UserID, &req)
+	if err != nil {
+		c.JSON(twoFactorStatus(err), dto.ErrorResponse{
+			Error:   "Failed to regenerate recovery codes",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// twoFactorStatus mapea los errores de 2FA a códigos HTTP
+func twoFactorStatus(err error) int {
+	switch err.Error() {
+	case "invalid two-factor code", "invalid or expired challenge", "invalid credentials":
+		return http.StatusUnauthorized
+	case "two-factor already enabled":
+		return http.StatusConflict
+	case "two-factor not enabled", "two-factor enrollment not started":
+		return http.StatusBadRequest
+	case "user not found":
+		return http.StatusNotFound
+	case "two-factor encryption key not configured":
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
@@ -1,9 +1,14 @@
 package controllers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"shared/auth"
 	"strconv"
+	"strings"
 	"users-api/internal/dto"
+	"users-api/internal/password"
 	"users-api/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -18,7 +23,8 @@ func NewUserController(service services.UserService) *UserController {
 	return &UserController{service: service}
 }
 
-// Register maneja el registro de usuarios normales
+// Register maneja el registro de usuarios.
+// Sin invitación el rol es "normal"; con invitation_code se usa el rol de la invitación.
 // POST /users/register
 func (ctrl *UserController) Register(c *gin.Context) {
 	var req dto.RegisterRequest
@@ -31,11 +37,18 @@ func (ctrl *UserController) Register(c *gin.Context) {
 		return
 	}
 
-	user, err := ctrl.service.Register(&req, "normal")
+	user, err := ctrl.service.Register(&req)
 	if err != nil {
+		if passwordRejected(c, "Registration failed", err) {
+			return
+		}
+
 		statusCode := http.StatusInternalServerError
-		if err.Error() == "username already exists" || err.Error() == "email already exists" {
+		switch err.Error() {
+		case "username already exists", "email already exists":
 			statusCode = http.StatusConflict
+		case "invalid invitation code", "invitation already used", "invitation expired", "invitation email mismatch":
+			statusCode = http.StatusBadRequest
 		}
 
 		c.JSON(statusCode, dto.ErrorResponse{
@@ -48,10 +61,10 @@ func (ctrl *UserController) Register(c *gin.Context) {
 	c.JSON(http.StatusCreated, user)
 }
 
-// RegisterAdmin maneja el registro de usuarios administradores
-// POST /users/admin
-func (ctrl *UserController) RegisterAdmin(c *gin.Context) {
-	var req dto.RegisterRequest
+// Bootstrap crea el primer administrador usando el token de instalación
+// POST /users/bootstrap
+func (ctrl *UserController) Bootstrap(c *gin.Context) {
+	var req dto.BootstrapRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
@@ -61,15 +74,24 @@ func (ctrl *UserController) RegisterAdmin(c *gin.Context) {
 		return
 	}
 
-	user, err := ctrl.service.Register(&req, "admin")
+	user, err := ctrl.service.BootstrapAdmin(&req)
 	if err != nil {
+		if passwordRejected(c, "Bootstrap failed", err) {
+			return
+		}
+
 		statusCode := http.StatusInternalServerError
-		if err.Error() == "username already exists" || err.Error() == "email already exists" {
+		switch err.Error() {
+		case "bootstrap disabled":
+			statusCode = http.StatusNotFound
+		case "invalid setup token":
+			statusCode = http.StatusUnauthorized
+		case "admin already exists", "username already exists", "email already exists":
 			statusCode = http.StatusConflict
 		}
 
 		c.JSON(statusCode, dto.ErrorResponse{
-			Error:   "Registration failed",
+			Error:   "Bootstrap failed",
 			Message: err.Error(),
 		})
 		return
@@ -78,6 +100,32 @@ func (ctrl *UserController) RegisterAdmin(c *gin.Context) {
 	c.JSON(http.StatusCreated, user)
 }
 
+// CreateInvitation emite un código de invitación (requiere users:manage)
+// POST /users/invitations
+func (ctrl *UserController) CreateInvitation(c *gin.Context) {
+	var req dto.CreateInvitationRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	claims, _ := auth.ClaimsFromContext(c)
+	invitation, err := ctrl.service.CreateInvitation(&req, claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error:   "Failed to create invitation",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, invitation)
+}
+
 // Login maneja el login de usuarios
 // POST /users/login
 func (ctrl *UserController) Login(c *gin.Context) {
@@ -91,9 +139,17 @@ func (ctrl *UserController) Login(c *gin.Context) {
 		return
 	}
 
-	response, err := ctrl.service.Login(&req)
+	response, err := ctrl.service.Login(&req, requestMeta(c))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+		statusCode := http.StatusUnauthorized
+		if err.Error() == "email not verified" || err.Error() == "account deactivated" {
+			statusCode = http.StatusForbidden
+		}
+		if throttled(c, err) {
+			statusCode = http.StatusTooManyRequests
+		}
+
+		c.JSON(statusCode, dto.ErrorResponse{
 			Error:   "Login failed",
 			Message: err.Error(),
 		})
@@ -103,6 +159,252 @@ func (ctrl *UserController) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// Refresh rota el refresh token y emite un nuevo access token
+// POST /users/refresh
+func (ctrl *UserController) Refresh(c *gin.Context) {
+	var req dto.RefreshRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response, err := ctrl.service.Refresh(&req)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "invalid refresh token" ||
+			err.Error() == "refresh token expired" ||
+			err.Error() == "refresh token reuse detected" {
+			statusCode = http.StatusUnauthorized
+		}
+
+		c.JSON(statusCode, dto.ErrorResponse{
+			Error:   "Refresh failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Logout revoca el refresh token de la sesión
+// POST /users/logout
+func (ctrl *UserController) Logout(c *gin.Context) {
+	var req dto.RefreshRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := ctrl.service.Logout(&req); err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "invalid refresh token" {
+			statusCode = http.StatusUnauthorized
+		}
+
+		c.JSON(statusCode, dto.ErrorResponse{
+			Error:   "Logout failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Logged out successfully",
+	})
+}
+
+// ForgotPassword envía un link de recuperación de contraseña.
+// Responde lo mismo exista o no el email.
+// POST /users/password/forgot
+func (ctrl *UserController) ForgotPassword(c *gin.Context) {
+	var req dto.ForgotPasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := ctrl.service.ForgotPassword(&req); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error:   "Failed to send reset email",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "If the email is registered, a reset link has been sent",
+	})
+}
+
+// ResetPassword fija una nueva contraseña con el token recibido por email
+// POST /users/password/reset
+func (ctrl *UserController) ResetPassword(c *gin.Context) {
+	var req dto.ResetPasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := ctrl.service.ResetPassword(&req, requestMeta(c)); err != nil {
+		if passwordRejected(c, "Password reset failed", err) {
+			return
+		}
+
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "invalid or expired token" {
+			statusCode = http.StatusBadRequest
+		}
+
+		c.JSON(statusCode, dto.ErrorResponse{
+			Error:   "Password reset failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password updated successfully",
+	})
+}
+
+// VerifyEmail confirma el email con el token recibido
+// POST /users/email/verify
+func (ctrl *UserController) VerifyEmail(c *gin.Context) {
+	var req dto.VerifyEmailRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := ctrl.service.VerifyEmail(&req); err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "invalid or expired token" {
+			statusCode = http.StatusBadRequest
+		}
+
+		c.JSON(statusCode, dto.ErrorResponse{
+			Error:   "Email verification failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email verified successfully",
+	})
+}
+
+// ResendVerification reenvía el email de verificación al usuario autenticado
+// POST /users/email/verify/resend
+func (ctrl *UserController) ResendVerification(c *gin.Context) {
+	claims, _ := auth.ClaimsFromContext(c)
+	if err := ctrl.service.SendVerificationEmail(claims.UserID); err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "email already verified":
+			statusCode = http.StatusConflict
+		case "user not found":
+			statusCode = http.StatusNotFound
+		}
+
+		c.JSON(statusCode, dto.ErrorResponse{
+			Error:   "Failed to send verification email",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Verification email sent",
+	})
+}
+
+// UnlockUser desbloquea el login de un usuario (requiere users:manage)
+// POST /users/:id/unlock
+func (ctrl *UserController) UnlockUser(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.ParseUint(idParam, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid ID",
+			Message: "ID must be a valid number",
+		})
+		return
+	}
+
+	if err := ctrl.service.UnlockUser(uint(id), requestMeta(c)); err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "user not found" {
+			statusCode = http.StatusNotFound
+		}
+
+		c.JSON(statusCode, dto.ErrorResponse{
+			Error:   "Failed to unlock user",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User unlocked successfully",
+	})
+}
+
+// throttled indica si el error es un login frenado y, en ese caso, setea el header Retry-After
+func throttled(c *gin.Context, err error) bool {
+	var throttledErr *services.ThrottledError
+	if !errors.As(err, &throttledErr) {
+		return false
+	}
+
+	seconds := int(math.Ceil(throttledErr.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	return true
+}
+
+// passwordRejected responde 400 con los motivos si el error es una contraseña que no cumple la política
+func passwordRejected(c *gin.Context, title string, err error) bool {
+	var policyErr *password.PolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	reasons := make([]dto.PasswordReason, len(policyErr.Reasons))
+	messages := make([]string, len(policyErr.Reasons))
+	for i, reason := range policyErr.Reasons {
+		reasons[i] = dto.PasswordReason{Code: reason.Code, Message: reason.Message}
+		messages[i] = reason.Message
+	}
+
+	c.JSON(http.StatusBadRequest, dto.PasswordPolicyErrorResponse{
+		Error:   title,
+		Message: strings.Join(messages, "; "),
+		Reasons: reasons,
+	})
+	return true
+}
+
 // GetByID obtiene un usuario por su ID
 // GET /users/:id
 func (ctrl *UserController) GetByID(c *gin.Context) {
@@ -131,15 +433,32 @@ func (ctrl *UserController) GetByID(c *gin.Context) {
 		return
 	}
 
+	// El estado de bloqueo solo se muestra a quien puede desbloquear usuarios
+	if claims, ok := auth.ClaimsFromContext(c); ok && claims.HasPermission(auth.PermUsersManage) {
+		if lockout, err := ctrl.service.LockoutStatus(user.ID); err == nil {
+			user.Lockout = lockout
+		}
+	}
+
 	c.JSON(http.StatusOK, user)
 }
 
-// GetAll obtiene todos los usuarios
+// GetAll lista usuarios paginados con filtros (role, q, created_from, created_to) y orden (sort)
 // GET /users
 func (ctrl *UserController) GetAll(c *gin.Context) {
-	users, err := ctrl.service.GetAll()
+	var query dto.UserListQuery
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid query",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	users, err := ctrl.service.GetAll(&query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+		c.JSON(listErrorStatus(err), dto.ErrorResponse{
 			Error:   "Failed to get users",
 			Message: err.Error(),
 		})
@@ -149,6 +468,40 @@ func (ctrl *UserController) GetAll(c *gin.Context) {
 	c.JSON(http.StatusOK, users)
 }
 
+// Export recorre todos los usuarios con paginación por cursor (requiere users:manage)
+// GET /users/export
+func (ctrl *UserController) Export(c *gin.Context) {
+	var query dto.UserExportQuery
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid query",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	users, err := ctrl.service.Export(&query)
+	if err != nil {
+		c.JSON(listErrorStatus(err), dto.ErrorResponse{
+			Error:   "Failed to export users",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// listErrorStatus mapea los errores de validación del listado a 400
+func listErrorStatus(err error) int {
+	switch err.Error() {
+	case "invalid sort field", "invalid role", "invalid created_from", "invalid created_to", "invalid cursor":
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
 // Update actualiza un usuario
 // PUT /users/:id
 func (ctrl *UserController) Update(c *gin.Context) {
@@ -163,7 +516,7 @@ func (ctrl *UserController) Update(c *gin.Context) {
 		return
 	}
 
-	var req dto.RegisterRequest
+	var req dto.UpdateProfileRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
 			Error:   "Invalid request",
@@ -174,12 +527,7 @@ func (ctrl *UserController) Update(c *gin.Context) {
 
 	user, err := ctrl.service.Update(uint(id), &req)
 	if err != nil {
-		statusCode := http.StatusInternalServerError
-		if err.Error() == "user not found" {
-			statusCode = http.StatusNotFound
-		}
-
-		c.JSON(statusCode, dto.ErrorResponse{
+		c.JSON(profileErrorStatus(err), dto.ErrorResponse{
 			Error:   "Failed to update user",
 			Message: err.Error(),
 		})
@@ -189,7 +537,7 @@ func (ctrl *UserController) Update(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
-// Delete elimina un usuario
+// Delete hace un borrado lógico de un usuario (requiere users:manage)
 // DELETE /users/:id
 func (ctrl *UserController) Delete(c *gin.Context) {
 	idParam := c.Param("id")
@@ -203,7 +551,7 @@ func (ctrl *UserController) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := ctrl.service.Delete(uint(id)); err != nil {
+	if err := ctrl.service.Delete(uint(id), requestMeta(c)); err != nil {
 		statusCode := http.StatusInternalServerError
 		if err.Error() == "user not found" {
 			statusCode = http.StatusNotFound
@@ -220,3 +568,91 @@ func (ctrl *UserController) Delete(c *gin.Context) {
 		"message": "User deleted successfully",
 	})
 }
+
+// Deactivate bloquea el login de un usuario sin borrar sus datos (requiere users:manage)
+// POST /users/:id/deactivate
+func (ctrl *UserController) Deactivate(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.service.Deactivate(id, requestMeta(c)); err != nil {
+		c.JSON(accountStateStatus(err), dto.ErrorResponse{
+			Error:   "Failed to deactivate user",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User deactivated successfully",
+	})
+}
+
+// Reactivate vuelve a habilitar el login de un usuario desactivado (requiere users:manage)
+// POST /users/:id/reactivate
+func (ctrl *UserController) Reactivate(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.service.Reactivate(id, requestMeta(c)); err != nil {
+		c.JSON(accountStateStatus(err), dto.ErrorResponse{
+			Error:   "Failed to reactivate user",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User reactivated successfully",
+	})
+}
+
+// Erase anonimiza los datos personales de un usuario de forma irreversible
+// POST /users/:id/erase
+func (ctrl *UserController) Erase(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.service.Erase(id, requestMeta(c)); err != nil {
+		c.JSON(accountStateStatus(err), dto.ErrorResponse{
+			Error:   "Failed to erase user",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User erased successfully",
+	})
+}
+
+// userIDParam parsea el :id de la ruta y responde 400 si no es válido
+func userIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "Invalid ID",
+			Message: "ID must be a valid number",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// accountStateStatus mapea los errores de desactivar/reactivar/borrar a un código HTTP
+func accountStateStatus(err error) int {
+	switch err.Error() {
+	case "user not found":
+		return http.StatusNotFound
+	case "user already deactivated", "user is not deactivated", "user already erased":
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
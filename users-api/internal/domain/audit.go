@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"time"
+)
+
+// Acciones registradas en el log de auditoría
+const (
+	AuditLoginSucceeded         = "login.succeeded"
+	AuditLoginFailed            = "login.failed"
+	AuditPasswordChanged        = "password.changed"
+	AuditPasswordReset          = "password.reset"
+	AuditTwoFactorEnabled       = "two_factor.enabled"
+	AuditTwoFactorDisabled      = "two_factor.disabled"
+	AuditRoleAssigned           = "role.assigned"
+	AuditRolePermissionsChanged = "role.permissions_changed"
+	AuditUserUnlocked           = "user.unlocked"
+	AuditUserDeactivated        = "user.deactivated"
+	AuditUserReactivated        = "user.reactivated"
+	AuditUserDeleted            = "user.deleted"
+	AuditUserErased             = "user.erased"
+)
+
+// AuditActions lista las acciones conocidas, para validar el filtro del listado
+var AuditActions = []string{
+	AuditLoginSucceeded,
+	AuditLoginFailed,
+	AuditPasswordChanged,
+	AuditPasswordReset,
+	AuditTwoFactorEnabled,
+	AuditTwoFactorDisabled,
+	AuditRoleAssigned,
+	AuditRolePermissionsChanged,
+	AuditUserUnlocked,
+	AuditUserDeactivated,
+	AuditUserReactivated,
+	AuditUserDeleted,
+	AuditUserErased,
+}
+
+// AuditEntry es un registro del log de auditoría. La tabla es de solo inserción:
+// la base rechaza UPDATE y DELETE. Solo guarda IDs, nunca usernames ni emails,
+// así el borrado GDPR de un usuario no necesita tocar el log.
+type AuditEntry struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ActorID   *uint     `gorm:"index" json:"actor_id"`                // quién hizo la acción; nil si fue anónima (login fallido)
+	TargetID  *uint     `gorm:"index" json:"target_id"`               // usuario afectado; nil si la acción no es sobre un usuario
+	Action    string    `gorm:"not null;size:50;index" json:"action"` // una de las constantes Audit*
+	IP        string    `gorm:"size:45" json:"ip"`                    // IPv4 o IPv6
+	UserAgent string    `gorm:"size:255" json:"user_agent"`           // recortado a 255 caracteres
+	Details   string    `gorm:"size:255" json:"details,omitempty"`    // motivo del fallo, rol anterior y nuevo, etc.
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// TableName especifica el nombre de la tabla
+func (AuditEntry) TableName() string {
+	return "audit_log"
+}
+
+// IsAuditAction indica si action es una de las acciones registradas
+func IsAuditAction(action string) bool {
+	for _, a := range AuditActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"time"
+)
+
+// Identity vincula un usuario con una cuenta de un proveedor OIDC externo.
+// Un mismo usuario puede tener varias identidades, pero cada (proveedor, subject) pertenece a un solo usuario.
+type Identity struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      uint       `gorm:"index;not null" json:"user_id"`
+	Provider    string     `gorm:"not null;size:50;uniqueIndex:idx_identity_provider_subject" json:"provider"`
+	Subject     string     `gorm:"not null;size:255;uniqueIndex:idx_identity_provider_subject" json:"subject"` // claim "sub" del proveedor
+	Email       string     `gorm:"size:100" json:"email"`                                                      // email informado por el proveedor al vincular
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName especifica el nombre de la tabla
+func (Identity) TableName() string {
+	return "identities"
+}
+
+// OIDCState guarda el state, el nonce y el code_verifier de PKCE de un login OIDC en curso.
+// Solo se guarda el hash del state; el verifier nunca sale del servidor.
+type OIDCState struct {
+	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	StateHash    string     `gorm:"uniqueIndex;not null;size:64" json:"-"`
+	Provider     string     `gorm:"not null;size:50" json:"provider"`
+	Nonce        string     `gorm:"not null;size:64" json:"-"`
+	CodeVerifier string     `gorm:"not null;size:128" json:"-"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt       *time.Time `json:"used_at"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName especifica el nombre de la tabla
+func (OIDCState) TableName() string {
+	return "oidc_states"
+}
+
+// IsUsable indica si el state todavía puede canjearse
+func (s *OIDCState) IsUsable(now time.Time) bool {
+	return s.UsedAt == nil && now.Before(s.ExpiresAt)
+}
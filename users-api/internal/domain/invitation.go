@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"time"
+)
+
+// Invitation es un código emitido por un admin que permite registrarse con un rol dado.
+// Solo se guarda el hash SHA-256 del código.
+type Invitation struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	CodeHash   string     `gorm:"uniqueIndex;not null;size:64" json:"-"`
+	Role       string     `gorm:"not null;size:20" json:"role"`
+	Email      string     `gorm:"size:100" json:"email"` // Opcional: restringe la invitación a un email
+	CreatedBy  uint       `gorm:"not null" json:"created_by"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	RedeemedAt *time.Time `json:"redeemed_at"`
+	RedeemedBy *uint      `json:"redeemed_by"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName especifica el nombre de la tabla
+func (Invitation) TableName() string {
+	return "invitations"
+}
+
+// IsExpired indica si la invitación ya venció
+func (i *Invitation) IsExpired(now time.Time) bool {
+	return !now.Before(i.ExpiresAt)
+}
+
+// IsRedeemed indica si la invitación ya fue usada
+func (i *Invitation) IsRedeemed() bool {
+	return i.RedeemedAt != nil
+}
@@ -0,0 +1,20 @@
+package domain
+
+import (
+	"time"
+)
+
+// RecoveryCode es un código de recuperación de 2FA de un solo uso.
+// Solo se guarda el hash SHA-256 del código.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	CodeHash  string     `gorm:"uniqueIndex;not null;size:64" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName especifica el nombre de la tabla
+func (RecoveryCode) TableName() string {
+	return "recovery_codes"
+}
@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"time"
+)
+
+// RefreshToken representa un refresh token emitido a un usuario.
+// Solo se guarda el hash SHA-256 del token, nunca el valor en claro.
+type RefreshToken struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	TokenHash  string     `gorm:"uniqueIndex;not null;size:64" json:"-"`
+	FamilyID   string     `gorm:"index;not null;size:64" json:"family_id"` // Cadena de rotaciones que nace en un login
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	ReplacedBy *uint      `json:"replaced_by"`                                  // ID del token que lo reemplazó al rotar
+	MFA        bool       `gorm:"not null;default:false" json:"mfa"`            // la sesión se abrió con 2FA; se conserva al rotar
+	Method     string     `gorm:"not null;size:10;default:'pwd'" json:"method"` // primer factor del login ("pwd" o "fed"); se conserva al rotar
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName especifica el nombre de la tabla
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// IsExpired indica si el token ya venció
+func (t *RefreshToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// IsRevoked indica si el token fue revocado (por logout o rotación)
+func (t *RefreshToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"shared/auth"
+	"time"
+)
+
+// Role es un rol asignable a usuarios. Los roles los define el código; sus permisos se editan en la base.
+type Role struct {
+	Name        string    `gorm:"primaryKey;size:20" json:"name"`
+	Description string    `gorm:"size:100" json:"description"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName especifica el nombre de la tabla
+func (Role) TableName() string {
+	return "roles"
+}
+
+// Permission es un permiso que los servicios verifican desde el claim "perms"
+type Permission struct {
+	Name string `gorm:"primaryKey;size:50" json:"name"`
+}
+
+// TableName especifica el nombre de la tabla
+func (Permission) TableName() string {
+	return "permissions"
+}
+
+// RolePermission asigna un permiso a un rol
+type RolePermission struct {
+	RoleName       string `gorm:"primaryKey;size:20"`
+	PermissionName string `gorm:"primaryKey;size:50"`
+}
+
+// TableName especifica el nombre de la tabla
+func (RolePermission) TableName() string {
+	return "role_permissions"
+}
+
+// DefaultRole es la definición inicial de un rol, usada para sembrar la base
+type DefaultRole struct {
+	Name        string
+	Description string
+	Permissions []string
+}
+
+// DefaultRoles son los roles conocidos con sus permisos iniciales.
+// Al sembrar solo se crean los roles que faltan, así no se pisan los cambios hechos por un admin.
+var DefaultRoles = []DefaultRole{
+	{
+		Name:        auth.RoleNormal,
+		Description: "Jugador",
+	},
+	{
+		Name:        auth.RoleMember,
+		Description: "Socio: reserva con tarifa de socio",
+		Permissions: []string{auth.PermReservasMember},
+	},
+	{
+		Name:        auth.RoleStaff,
+		Description: "Recepción: confirma reservas y registra llegadas",
+		Permissions: []string{
+			auth.PermUsersRead,
+			auth.PermReservasReadAll,
+			auth.PermReservasConfirm,
+			auth.PermReservasCheckIn,
+			auth.PermReservasCancelAny,
+		},
+	},
+	{
+		Name:        auth.RoleVenueManager,
+		Description: "Administra sus propias canchas",
+		Permissions: []string{auth.PermCanchasManageOwn},
+	},
+	{
+		Name:        auth.RoleAdmin,
+		Description: "Administrador",
+		Permissions: auth.AllPermissions,
+	},
+}
+
+// IsKnownRole indica si name es uno de los roles definidos
+func IsKnownRole(name string) bool {
+	for _, role := range DefaultRoles {
+		if role.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,8 @@ package domain
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type User struct {
@@ -11,12 +13,32 @@ type User struct {
 	Password  string    `gorm:"not null;size:255" json:"-"` // El "-" evita que se serialice en JSON
 	FirstName string    `gorm:"size:50" json:"first_name"`
 	LastName  string    `gorm:"size:50" json:"last_name"`
-	Role      string    `gorm:"not null;size:20;default:'normal'" json:"role"` // "normal" o "admin"
+	Role      string    `gorm:"not null;size:20;default:'normal'" json:"role"` // nombre de un Role: "normal", "member", "staff", "venue_manager" o "admin"
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	// VerifiedAt es nil hasta que el usuario confirma su email
+	VerifiedAt *time.Time `json:"verified_at"`
+	// 2FA (TOTP): el secreto se guarda al iniciar el enrolamiento y TOTPEnabledAt se fija al confirmarlo
+	TOTPSecret    string     `gorm:"size:64" json:"-"`
+	TOTPEnabledAt *time.Time `json:"totp_enabled_at"`
+	TOTPLastStep  int64      `gorm:"not null;default:0" json:"-"` // último paso TOTP aceptado, evita reutilizar códigos
+	// Ciclo de vida: desactivado (no puede iniciar sesión), borrado lógico y borrado de datos personales
+	DeactivatedAt *time.Time     `json:"deactivated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+	ErasedAt      *time.Time     `json:"erased_at"`
 }
 
 // TableName especifica el nombre de la tabla
 func (User) TableName() string {
 	return "users"
 }
+
+// IsDeactivated indica si la cuenta fue desactivada por un admin
+func (u *User) IsDeactivated() bool {
+	return u.DeactivatedAt != nil
+}
+
+// TwoFactorEnabled indica si el usuario confirmó el enrolamiento de 2FA
+func (u *User) TwoFactorEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
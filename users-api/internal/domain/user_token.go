@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"time"
+)
+
+// Propósitos de los tokens de un solo uso
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeLoginChallenge    = "login_challenge" // segundo paso del login con 2FA
+)
+
+// UserToken es un token de un solo uso enviado por email (recuperación de contraseña, verificación).
+// Solo se guarda el hash SHA-256 del token.
+type UserToken struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	Purpose   string     `gorm:"not null;size:30" json:"purpose"`
+	TokenHash string     `gorm:"uniqueIndex;not null;size:64" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	Attempts  int        `gorm:"not null;default:0" json:"attempts"` // intentos fallidos de canje
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName especifica el nombre de la tabla
+func (UserToken) TableName() string {
+	return "user_tokens"
+}
+
+// IsUsable indica si el token todavía puede canjearse
+func (t *UserToken) IsUsable(now time.Time) bool {
+	return t.UsedAt == nil && now.Before(t.ExpiresAt)
+}
@@ -0,0 +1,43 @@
+package dto
+
+import "time"
+
+// RequestMeta - quién hace la acción y desde dónde; el controlador lo arma para el log de auditoría
+type RequestMeta struct {
+	ActorID   uint // 0 si el request no está autenticado
+	IP        string
+	UserAgent string
+}
+
+// AuditListQuery - DTO para el listado paginado del log de auditoría
+type AuditListQuery struct {
+	ActorID  uint   `form:"actor_id" json:"actor_id"`
+	TargetID uint   `form:"target_id" json:"target_id"`
+	Action   string `form:"action" json:"action"`
+	IP       string `form:"ip" json:"ip"`
+	From     string `form:"from" json:"from"` // RFC 3339 o YYYY-MM-DD
+	To       string `form:"to" json:"to"`     // RFC 3339 o YYYY-MM-DD (día incluido)
+	Page     int    `form:"page" json:"page"`
+	PageSize int    `form:"page_size" json:"page_size"`
+}
+
+// AuditEntryResponse - DTO de un registro del log de auditoría
+type AuditEntryResponse struct {
+	ID        uint      `json:"id"`
+	ActorID   *uint     `json:"actor_id"`
+	TargetID  *uint     `json:"target_id"`
+	Action    string    `json:"action"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Details   string    `json:"details,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditListResponse - DTO con una página del log, del registro más nuevo al más viejo
+type AuditListResponse struct {
+	Entries    []AuditEntryResponse `json:"entries"`
+	Total      int64                `json:"total"`
+	Page       int                  `json:"page"`
+	PageSize   int                  `json:"page_size"`
+	TotalPages int                  `json:"total_pages"`
+}
@@ -0,0 +1,25 @@
+package dto
+
+import "time"
+
+// CreateInvitationRequest - DTO para que un admin emita una invitación
+type CreateInvitationRequest struct {
+	Role           string `json:"role" binding:"required,oneof=normal member staff venue_manager admin"`
+	Email          string `json:"email" binding:"omitempty,email"`
+	ExpiresInHours int    `json:"expires_in_hours" binding:"omitempty,gt=0,lte=720"`
+}
+
+// InvitationResponse - DTO con el código de invitación (solo se muestra al crearla)
+type InvitationResponse struct {
+	ID        uint      `json:"id"`
+	Code      string    `json:"code"`
+	Role      string    `json:"role"`
+	Email     string    `json:"email,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// BootstrapRequest - DTO para crear el primer admin con el token de instalación
+type BootstrapRequest struct {
+	SetupToken string `json:"setup_token" binding:"required"`
+	RegisterRequest
+}
@@ -6,8 +6,20 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
-// LoginResponse - DTO para respuesta de login
+// LoginResponse - DTO para respuesta de login.
+// Si el usuario tiene 2FA, solo viene ChallengeToken y el login se completa en POST /users/login/2fa.
 type LoginResponse struct {
-	Token string       `json:"token"`
-	User  UserResponse `json:"user"`
+	Token        string        `json:"token,omitempty"`
+	RefreshToken string        `json:"refresh_token,omitempty"`
+	User         *UserResponse `json:"user,omitempty"`
+
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
+	// Admin sin 2FA: puede iniciar sesión pero no usar rutas de admin hasta enrolarse
+	TwoFactorSetupRequired bool `json:"two_factor_setup_required,omitempty"`
+}
+
+// RefreshRequest - DTO para rotar o revocar un refresh token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
@@ -0,0 +1,17 @@
+package dto
+
+// OIDCProvidersResponse - DTO con los proveedores OIDC habilitados
+type OIDCProvidersResponse struct {
+	Providers []string `json:"providers"`
+}
+
+// OIDCAuthorizeResponse - DTO con la URL del proveedor a la que el frontend redirige al usuario
+type OIDCAuthorizeResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// OIDCCallbackRequest - DTO con lo que el proveedor devuelve al frontend en la redirect URI
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
@@ -0,0 +1,30 @@
+package dto
+
+// ForgotPasswordRequest - DTO para solicitar recuperación de contraseña
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest - DTO para fijar una nueva contraseña con el token recibido por email
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8"`
+}
+
+// VerifyEmailRequest - DTO para confirmar el email con el token recibido
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// PasswordReason - DTO con una regla de la política de contraseñas que no se cumple
+type PasswordReason struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyErrorResponse - DTO de error para una contraseña rechazada por la política
+type PasswordPolicyErrorResponse struct {
+	Error   string           `json:"error"`
+	Message string           `json:"message"`
+	Reasons []PasswordReason `json:"reasons"`
+}
@@ -0,0 +1,31 @@
+package dto
+
+// UpdateProfileRequest - DTO para actualizar el perfil; solo se modifican los campos enviados
+type UpdateProfileRequest struct {
+	Username  *string `json:"username" binding:"omitempty,min=3,max=50"`
+	Email     *string `json:"email" binding:"omitempty,email"`
+	FirstName *string `json:"first_name" binding:"omitempty,min=1"`
+	LastName  *string `json:"last_name" binding:"omitempty,min=1"`
+}
+
+// ChangePasswordRequest - DTO para cambiar la contraseña conociendo la actual
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+// ProfileResponse - DTO con el perfil del usuario autenticado
+type ProfileResponse struct {
+	User UserResponse `json:"user"`
+	// Reservations es nil si reservas-api no respondió
+	Reservations *ReservationStats `json:"reservations"`
+}
+
+// ReservationStats - DTO con el resumen de reservas del usuario
+type ReservationStats struct {
+	Total      int     `json:"total"`
+	Upcoming   int     `json:"upcoming"`
+	Past       int     `json:"past"`
+	Cancelled  int     `json:"cancelled"`
+	TotalSpent float64 `json:"total_spent"` // suma de las reservas no canceladas
+}
@@ -0,0 +1,23 @@
+package dto
+
+// RoleResponse - DTO con un rol y sus permisos
+type RoleResponse struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// RolesListResponse - DTO para lista de roles
+type RolesListResponse struct {
+	Roles []RoleResponse `json:"roles"`
+}
+
+// SetRolePermissionsRequest - DTO para reemplazar los permisos de un rol
+type SetRolePermissionsRequest struct {
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+// AssignRoleRequest - DTO para cambiar el rol de un usuario
+type AssignRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
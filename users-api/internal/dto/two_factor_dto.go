@@ -0,0 +1,29 @@
+package dto
+
+// TwoFactorEnrollResponse - DTO con el secreto TOTP a cargar en la app de autenticación
+type TwoFactorEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// TwoFactorCodeRequest - DTO con un código TOTP (o de recuperación, donde se acepte)
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableTwoFactorRequest - DTO para desactivar 2FA
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// RecoveryCodesResponse - DTO con los códigos de recuperación; solo se muestran una vez
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TwoFactorLoginRequest - DTO para completar el segundo paso del login
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
@@ -9,6 +9,8 @@ type RegisterRequest struct {
 	Password  string `json:"password" binding:"required,min=8"`
 	FirstName string `json:"first_name" binding:"required"`
 	LastName  string `json:"last_name" binding:"required"`
+	// Código de invitación opcional; es la única forma de registrarse con un rol distinto de "normal"
+	InvitationCode string `json:"invitation_code"`
 }
 
 // UserResponse - DTO para respuesta de usuario (sin password)
@@ -20,10 +22,55 @@ type UserResponse struct {
 	LastName  string    `json:"last_name"`
 	Role      string    `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
+	// VerifiedAt es nil mientras el email no esté confirmado
+	VerifiedAt       *time.Time `json:"verified_at"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled"`
+	DeactivatedAt    *time.Time `json:"deactivated_at,omitempty"`
+	// Lockout solo se completa para quien tiene el permiso users:manage
+	Lockout *LockoutStatus `json:"lockout,omitempty"`
+}
+
+// LockoutStatus - DTO con el estado de bloqueo por intentos fallidos de login
+type LockoutStatus struct {
+	FailedAttempts int64      `json:"failed_attempts"`
+	Locked         bool       `json:"locked"`
+	BlockedUntil   *time.Time `json:"blocked_until,omitempty"`
+}
+
+// UserFilterQuery - filtros comunes al listado y al export de usuarios
+type UserFilterQuery struct {
+	Role        string `form:"role" json:"role"`
+	Q           string `form:"q" json:"q"`                       // prefijo de username, email, nombre o apellido
+	CreatedFrom string `form:"created_from" json:"created_from"` // RFC 3339 o YYYY-MM-DD
+	CreatedTo   string `form:"created_to" json:"created_to"`     // RFC 3339 o YYYY-MM-DD (día incluido)
+}
+
+// UserListQuery - DTO para el listado paginado de usuarios
+type UserListQuery struct {
+	UserFilterQuery
+	Page     int    `form:"page" json:"page"`
+	PageSize int    `form:"page_size" json:"page_size"`
+	Sort     string `form:"sort" json:"sort"` // campo, con "-" adelante para orden descendente
 }
 
 // UsersListResponse - DTO para lista de usuarios
 type UsersListResponse struct {
-	Users []UserResponse `json:"users"`
-	Total int64          `json:"total"`
+	Users      []UserResponse `json:"users"`
+	Total      int64          `json:"total"`
+	Page       int            `json:"page"`
+	PageSize   int            `json:"page_size"`
+	TotalPages int            `json:"total_pages"`
+}
+
+// UserExportQuery - DTO para recorrer todos los usuarios con paginación por cursor
+type UserExportQuery struct {
+	UserFilterQuery
+	Cursor string `form:"cursor" json:"cursor"`
+	Limit  int    `form:"limit" json:"limit"`
+}
+
+// UsersExportResponse - DTO con una página del export; NextCursor vacío indica el final
+type UsersExportResponse struct {
+	Users      []UserResponse `json:"users"`
+	NextCursor string         `json:"next_cursor,omitempty"`
 }
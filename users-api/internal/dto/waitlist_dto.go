@@ -0,0 +1,17 @@
+package dto
+
+import "time"
+
+// WaitlistOfferNotification - datos del evento reserva.waitlist_offer de reservas-api:
+// se liberó el turno que esperaba el usuario y quedó retenido a su nombre hasta HoldExpiresAt
+type WaitlistOfferNotification struct {
+	EntryID       string    `json:"entry_id"`
+	UserID        uint      `json:"user_id"`
+	CanchaID      string    `json:"cancha_id"`
+	CanchaName    string    `json:"cancha_name"`
+	Date          string    `json:"date"`
+	StartTime     string    `json:"start_time"`
+	EndTime       string    `json:"end_time"`
+	ReservaID     string    `json:"reserva_id"`
+	HoldExpiresAt time.Time `json:"hold_expires_at"`
+}
@@ -0,0 +1,68 @@
+package limiter
+
+import (
+	"time"
+)
+
+// Guard protege el login contando fallos por cuenta y por IP de cliente
+type Guard struct {
+	account *Limiter
+	ip      *Limiter
+}
+
+// NewGuard crea un Guard con políticas separadas para cuentas e IPs sobre el mismo Store
+func NewGuard(store Store, accountPolicy, ipPolicy Policy) *Guard {
+	return &Guard{
+		account: New(store, "login:acct:", accountPolicy),
+		ip:      New(store, "login:ip:", ipPolicy),
+	}
+}
+
+// RetryAfter retorna la espera pendiente de la cuenta o la IP, la mayor de ambas
+func (g *Guard) RetryAfter(account, ip string) (time.Duration, error) {
+	wait, err := g.account.RetryAfter(account)
+	if err != nil {
+		return 0, err
+	}
+
+	if ip != "" {
+		ipWait, err := g.ip.RetryAfter(ip)
+		if err != nil {
+			return 0, err
+		}
+		if ipWait > wait {
+			wait = ipWait
+		}
+	}
+
+	return wait, nil
+}
+
+// Fail registra un intento fallido para la cuenta y la IP
+func (g *Guard) Fail(account, ip string) error {
+	if _, err := g.account.Fail(account); err != nil {
+		return err
+	}
+	if ip != "" {
+		if _, err := g.ip.Fail(ip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Succeed limpia los fallos de la cuenta tras un login correcto.
+// Los de la IP se conservan para que una cuenta propia no sirva para resetearlos.
+func (g *Guard) Succeed(account string) error {
+	return g.account.Reset(account)
+}
+
+// Unlock desbloquea una cuenta (acción de admin)
+func (g *Guard) Unlock(account string) error {
+	return g.account.Reset(account)
+}
+
+// AccountStatus retorna el estado de bloqueo de una cuenta
+func (g *Guard) AccountStatus(account string) (Status, error) {
+	return g.account.Status(account)
+}
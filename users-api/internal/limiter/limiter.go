@@ -0,0 +1,155 @@
+package limiter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy define cuándo un contador de fallos empieza a frenar intentos
+type Policy struct {
+	// BackoffAfter es la cantidad de fallos a partir de la cual se aplica espera exponencial
+	BackoffAfter int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	// LockoutAfter es la cantidad de fallos que bloquea temporalmente la clave
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	// Window es cuánto dura el contador de fallos desde el primero
+	Window time.Duration
+}
+
+// Status es el estado de una clave (cuenta o IP)
+type Status struct {
+	Failures     int64
+	BlockedUntil time.Time
+	Locked       bool // true si es un bloqueo por LockoutAfter y no una espera de backoff
+}
+
+// Blocked indica si la clave sigue bloqueada en el instante now
+func (s Status) Blocked(now time.Time) bool {
+	return now.Before(s.BlockedUntil)
+}
+
+// Limiter cuenta fallos por clave y bloquea según una Policy
+type Limiter struct {
+	store  Store
+	policy Policy
+	prefix string
+	now    func() time.Time
+}
+
+// New crea un Limiter; prefix separa los espacios de claves (por ejemplo "acct:" o "ip:")
+func New(store Store, prefix string, policy Policy) *Limiter {
+	return &Limiter{store: store, policy: policy, prefix: prefix, now: time.Now}
+}
+
+// RetryAfter retorna cuánto falta para que la clave pueda volver a intentar; 0 si no está bloqueada
+func (l *Limiter) RetryAfter(key string) (time.Duration, error) {
+	status, err := l.Status(key)
+	if err != nil {
+		return 0, err
+	}
+	if !status.Blocked(l.now()) {
+		return 0, nil
+	}
+	return status.BlockedUntil.Sub(l.now()), nil
+}
+
+// Fail registra un fallo y, según la cantidad acumulada, bloquea la clave con backoff o lockout
+func (l *Limiter) Fail(key string) (Status, error) {
+	failures, err := l.store.Incr(l.failuresKey(key), l.policy.Window)
+	if err != nil {
+		return Status{}, err
+	}
+
+	status := Status{Failures: failures}
+	var delay time.Duration
+	switch {
+	case l.policy.LockoutAfter > 0 && failures >= int64(l.policy.LockoutAfter):
+		delay = l.policy.LockoutDuration
+		status.Locked = true
+	case l.policy.BackoffAfter > 0 && failures >= int64(l.policy.BackoffAfter):
+		delay = l.backoff(failures)
+	}
+
+	if delay <= 0 {
+		return status, nil
+	}
+
+	status.BlockedUntil = l.now().Add(delay)
+	if err := l.store.Set(l.blockKey(key), encodeBlock(status), delay); err != nil {
+		return Status{}, err
+	}
+	return status, nil
+}
+
+// Reset borra fallos y bloqueos de la clave
+func (l *Limiter) Reset(key string) error {
+	if err := l.store.Delete(l.failuresKey(key)); err != nil {
+		return err
+	}
+	return l.store.Delete(l.blockKey(key))
+}
+
+// Status retorna los fallos acumulados y el bloqueo vigente de la clave
+func (l *Limiter) Status(key string) (Status, error) {
+	var status Status
+
+	value, ok, err := l.store.Get(l.failuresKey(key))
+	if err != nil {
+		return Status{}, err
+	}
+	if ok {
+		status.Failures, _ = strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	}
+
+	value, ok, err = l.store.Get(l.blockKey(key))
+	if err != nil {
+		return Status{}, err
+	}
+	if ok {
+		status.BlockedUntil, status.Locked = decodeBlock(value)
+	}
+
+	return status, nil
+}
+
+// backoff duplica la espera por cada fallo desde BackoffAfter, con tope MaxDelay
+func (l *Limiter) backoff(failures int64) time.Duration {
+	delay := l.policy.BaseDelay
+	for i := int64(l.policy.BackoffAfter); i < failures; i++ {
+		delay *= 2
+		if l.policy.MaxDelay > 0 && delay >= l.policy.MaxDelay {
+			return l.policy.MaxDelay
+		}
+	}
+	return delay
+}
+
+func (l *Limiter) failuresKey(key string) string {
+	return l.prefix + "fails:" + key
+}
+
+func (l *Limiter) blockKey(key string) string {
+	return l.prefix + "block:" + key
+}
+
+// encodeBlock serializa un bloqueo como "<unix nano>:<locked>"
+func encodeBlock(status Status) string {
+	locked := 0
+	if status.Locked {
+		locked = 1
+	}
+	return fmt.Sprintf("%d:%d", status.BlockedUntil.UnixNano(), locked)
+}
+
+func decodeBlock(value string) (time.Time, bool) {
+	until, locked, _ := strings.Cut(value, ":")
+	nanos, err := strconv.ParseInt(until, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nanos), locked == "1"
+}
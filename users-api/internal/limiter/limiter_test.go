@@ -0,0 +1,329 @@
+package limiter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// fakeClock es un reloj que los tests avanzan a mano
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// newTestLimiter crea un Limiter sobre un MemoryStore, ambos con el reloj indicado
+func newTestLimiter(clock *fakeClock, policy Policy) *Limiter {
+	store := NewMemoryStore()
+	store.now = clock.Now
+	limiter := New(store, "test:", policy)
+	limiter.now = clock.Now
+	return limiter
+}
+
+func TestLimiterBackoffThenLockout(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)}
+	limiter := newTestLimiter(clock, Policy{
+		BackoffAfter:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        4 * time.Second,
+		LockoutAfter:    6,
+		LockoutDuration: 15 * time.Minute,
+		Window:          time.Hour,
+	})
+
+	// Espera esperada después de cada fallo: sin espera, luego duplicando hasta el tope y por último el bloqueo
+	expected := []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second, 15 * time.Minute}
+	for i, want := range expected {
+		status, err := limiter.Fail("ana")
+		if err != nil {
+			t.Fatalf("fallo %d: %v", i+1, err)
+		}
+		if status.Failures != int64(i+1) {
+			t.Fatalf("fallo %d: se esperaban %d fallos acumulados, llegó %d", i+1, i+1, status.Failures)
+		}
+		wait, _ := limiter.RetryAfter("ana")
+		if wait != want {
+			t.Fatalf("fallo %d: se esperaba esperar %v, llegó %v", i+1, want, wait)
+		}
+		if status.Locked != (i == len(expected)-1) {
+			t.Fatalf("fallo %d: solo el último fallo bloquea la cuenta, llegó %+v", i+1, status)
+		}
+	}
+
+	// Otra clave no se ve afectada
+	if wait, _ := limiter.RetryAfter("beto"); wait != 0 {
+		t.Fatalf("una clave sin fallos no debe esperar, llegó %v", wait)
+	}
+
+	clock.Advance(15 * time.Minute)
+	if wait, _ := limiter.RetryAfter("ana"); wait != 0 {
+		t.Fatalf("el bloqueo debe vencer solo, falta %v", wait)
+	}
+	if status, _ := limiter.Status("ana"); status.Failures != 6 {
+		t.Fatalf("los fallos siguen contando mientras dure la ventana, llegó %+v", status)
+	}
+
+	// Al vencer la ventana el contador vuelve a empezar
+	clock.Advance(time.Hour)
+	if status, _ := limiter.Fail("ana"); status.Failures != 1 || status.Locked {
+		t.Fatalf("el contador debe reiniciarse al vencer la ventana, llegó %+v", status)
+	}
+}
+
+func TestGuardUnlockKeepsIPFailures(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	store.now = clock.Now
+	guard := NewGuard(store,
+		Policy{LockoutAfter: 2, LockoutDuration: 15 * time.Minute, Window: time.Hour},
+		Policy{LockoutAfter: 3, LockoutDuration: time.Hour, Window: time.Hour},
+	)
+	guard.account.now = clock.Now
+	guard.ip.now = clock.Now
+
+	for i := 0; i < 2; i++ {
+		if err := guard.Fail("id:1", "10.0.0.1"); err != nil {
+			t.Fatalf("fail: %v", err)
+		}
+	}
+	if status, _ := guard.AccountStatus("id:1"); !status.Locked || !status.Blocked(clock.Now()) {
+		t.Fatalf("la cuenta debe quedar bloqueada, llegó %+v", status)
+	}
+	if wait, _ := guard.RetryAfter("id:1", ""); wait != 15*time.Minute {
+		t.Fatalf("se esperaba esperar el bloqueo de la cuenta, llegó %v", wait)
+	}
+
+	// El admin desbloquea la cuenta: vuelve a poder intentar y sus fallos se borran
+	if err := guard.Unlock("id:1"); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if status, _ := guard.AccountStatus("id:1"); status.Failures != 0 || status.Blocked(clock.Now()) {
+		t.Fatalf("el desbloqueo debe borrar fallos y bloqueo, llegó %+v", status)
+	}
+
+	// Los fallos de la IP se conservan: un login correcto en otra cuenta no los resetea
+	if err := guard.Succeed("id:2"); err != nil {
+		t.Fatalf("succeed: %v", err)
+	}
+	if err := guard.Fail("id:2", "10.0.0.1"); err != nil {
+		t.Fatalf("fail: %v", err)
+	}
+	if wait, _ := guard.RetryAfter("id:3", "10.0.0.1"); wait != time.Hour {
+		t.Fatalf("la IP debe quedar bloqueada para cualquier cuenta, llegó %v", wait)
+	}
+	if wait, _ := guard.RetryAfter("id:3", "10.0.0.2"); wait != 0 {
+		t.Fatalf("otra IP no debe esperar, llegó %v", wait)
+	}
+}
+
+func TestMemoryStoreConcurrentIncr(t *testing.T) {
+	store := NewMemoryStore()
+	assertConcurrentIncr(t, store)
+}
+
+func TestMemcachedStoreIncrRetriesWhenAddLosesTheRace(t *testing.T) {
+	server := startFakeMemcached(t)
+	store := NewMemcachedStore(memcache.New(server.addr()), "users:")
+
+	// Otra réplica crea el contador entre el incr fallido y el add: el add no se guarda y se reintenta el incr
+	server.beforeAdd = func(items map[string]string, key string) {
+		items[key] = "1"
+	}
+	count, err := store.Incr("login:acct:fails:id:1", time.Minute)
+	if err != nil || count != 2 {
+		t.Fatalf("se esperaba 2 tras perder el add, llegó %d, %v", count, err)
+	}
+	if server.notStored == 0 {
+		t.Fatalf("el add debería haber perdido contra el contador creado por otra réplica")
+	}
+
+	server.beforeAdd = nil
+	assertConcurrentIncr(t, store)
+}
+
+func TestMemcachedStoreGetSetDelete(t *testing.T) {
+	server := startFakeMemcached(t)
+	store := NewMemcachedStore(memcache.New(server.addr()), "users:")
+
+	if _, ok, err := store.Get("missing"); ok || err != nil {
+		t.Fatalf("una clave inexistente no es un error, llegó %v, %v", ok, err)
+	}
+	if err := store.Set("block:id:1", "123:1", time.Minute); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if value, ok, err := store.Get("block:id:1"); !ok || err != nil || value != "123:1" {
+		t.Fatalf("se esperaba el valor guardado, llegó %q, %v, %v", value, ok, err)
+	}
+	if _, ok := server.items["users:block:id:1"]; !ok {
+		t.Fatalf("las claves deben guardarse con el prefijo del servicio")
+	}
+	if err := store.Delete("block:id:1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := store.Delete("block:id:1"); err != nil {
+		t.Fatalf("borrar una clave inexistente no es un error, llegó %v", err)
+	}
+}
+
+// assertConcurrentIncr incrementa en paralelo una clave nueva: cada llamada debe ver un valor distinto
+// y el total no debe perder incrementos
+func assertConcurrentIncr(t *testing.T, store Store) {
+	t.Helper()
+	const workers = 50
+
+	var wg sync.WaitGroup
+	results := make([]int64, workers)
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			count, err := store.Incr("concurrent", time.Minute)
+			if err != nil {
+				errs <- err
+				return
+			}
+			results[i] = count
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("incr concurrente falló: %v", err)
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i] < results[j] })
+	for i, count := range results {
+		if count != int64(i+1) {
+			t.Fatalf("se esperaban los valores 1..%d sin repetir, llegó %v", workers, results)
+		}
+	}
+	if value, _, _ := store.Get("concurrent"); value != strconv.Itoa(workers) {
+		t.Fatalf("se esperaba el contador en %d, llegó %q", workers, value)
+	}
+}
+
+// fakeMemcached es un servidor mínimo del protocolo de texto de Memcached (get/gets, set, add, incr y delete).
+// Cada comando es atómico como en Memcached, así las carreras entre comandos son las mismas.
+type fakeMemcached struct {
+	listener  net.Listener
+	mu        sync.Mutex
+	items     map[string]string
+	notStored int
+	// beforeAdd, si está, corre antes de cada add con el lock tomado
+	beforeAdd func(items map[string]string, key string)
+}
+
+func startFakeMemcached(t *testing.T) *fakeMemcached {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("no se pudo abrir el puerto: %v", err)
+	}
+	server := &fakeMemcached{listener: listener, items: make(map[string]string)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (f *fakeMemcached) addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeMemcached) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			fmt.Fprint(conn, "ERROR\r\n")
+			continue
+		}
+
+		var data []byte
+		if fields[0] == "set" || fields[0] == "add" {
+			size, _ := strconv.Atoi(fields[4])
+			data = make([]byte, size+2)
+			if _, err := io.ReadFull(reader, data); err != nil {
+				return
+			}
+			data = data[:size]
+		}
+
+		fmt.Fprint(conn, f.handle(fields, data))
+	}
+}
+
+func (f *fakeMemcached) handle(fields []string, data []byte) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := fields[1]
+	switch fields[0] {
+	case "get", "gets":
+		var reply strings.Builder
+		for _, k := range fields[1:] {
+			if value, ok := f.items[k]; ok {
+				fmt.Fprintf(&reply, "VALUE %s 0 %d 1\r\n%s\r\n", k, len(value), value)
+			}
+		}
+		return reply.String() + "END\r\n"
+	case "set":
+		f.items[key] = string(data)
+		return "STORED\r\n"
+	case "add":
+		if f.beforeAdd != nil {
+			f.beforeAdd(f.items, key)
+		}
+		if _, exists := f.items[key]; exists {
+			f.notStored++
+			return "NOT_STORED\r\n"
+		}
+		f.items[key] = string(data)
+		return "STORED\r\n"
+	case "incr":
+		value, ok := f.items[key]
+		if !ok {
+			return "NOT_FOUND\r\n"
+		}
+		count, _ := strconv.ParseUint(value, 10, 64)
+		delta, _ := strconv.ParseUint(fields[2], 10, 64)
+		count += delta
+		f.items[key] = strconv.FormatUint(count, 10)
+		return strconv.FormatUint(count, 10) + "\r\n"
+	case "delete":
+		if _, ok := f.items[key]; !ok {
+			return "NOT_FOUND\r\n"
+		}
+		delete(f.items, key)
+		return "DELETED\r\n"
+	}
+	return "ERROR\r\n"
+}
@@ -0,0 +1,172 @@
+package limiter
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// Store guarda contadores y bloqueos con vencimiento.
+// Incr debe ser atómico para que intentos concurrentes no se pierdan.
+type Store interface {
+	// Incr suma uno al contador; si no existe lo crea con el ttl indicado
+	Incr(key string, ttl time.Duration) (int64, error)
+	// Get retorna el valor guardado y false si no existe o venció
+	Get(key string) (string, bool, error)
+	Set(key, value string, ttl time.Duration) error
+	Delete(key string) error
+}
+
+type memoryEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+// MemoryStore es un Store en memoria del proceso. No se comparte entre réplicas.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+// NewMemoryStore crea un MemoryStore vacío
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]memoryEntry),
+		now:     time.Now,
+	}
+}
+
+// Incr suma uno al contador
+func (s *MemoryStore) Incr(key string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.get(key)
+	if !ok {
+		entry = memoryEntry{value: "0", expiresAt: s.now().Add(ttl)}
+	}
+
+	count, err := strconv.ParseInt(entry.value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	count++
+	entry.value = strconv.FormatInt(count, 10)
+	s.entries[key] = entry
+
+	return count, nil
+}
+
+// Get retorna el valor de una clave vigente
+func (s *MemoryStore) Get(key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.get(key)
+	return entry.value, ok, nil
+}
+
+// Set guarda un valor con vencimiento
+func (s *MemoryStore) Set(key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = memoryEntry{value: value, expiresAt: s.now().Add(ttl)}
+	return nil
+}
+
+// Delete elimina una clave
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// get retorna la entrada si no venció; las vencidas se eliminan. Requiere el lock tomado.
+func (s *MemoryStore) get(key string) (memoryEntry, bool) {
+	entry, ok := s.entries[key]
+	if !ok {
+		return memoryEntry{}, false
+	}
+	if !s.now().Before(entry.expiresAt) {
+		delete(s.entries, key)
+		return memoryEntry{}, false
+	}
+	return entry, true
+}
+
+// MemcachedStore es un Store compartido entre réplicas sobre Memcached
+type MemcachedStore struct {
+	client *memcache.Client
+	prefix string
+}
+
+// NewMemcachedStore crea un Store sobre Memcached. Las claves se prefijan para no chocar con otros servicios.
+func NewMemcachedStore(client *memcache.Client, prefix string) *MemcachedStore {
+	return &MemcachedStore{client: client, prefix: prefix}
+}
+
+// Incr suma uno al contador usando incr atómico de Memcached
+func (s *MemcachedStore) Incr(key string, ttl time.Duration) (int64, error) {
+	key = s.prefix + key
+
+	for {
+		count, err := s.client.Increment(key, 1)
+		if err == nil {
+			return int64(count), nil
+		}
+		if !errors.Is(err, memcache.ErrCacheMiss) {
+			return 0, err
+		}
+
+		// Primer fallo de la ventana: Add falla si otro proceso lo creó en el medio y se reintenta el incr
+		err = s.client.Add(&memcache.Item{Key: key, Value: []byte("1"), Expiration: expiration(ttl)})
+		if err == nil {
+			return 1, nil
+		}
+		if !errors.Is(err, memcache.ErrNotStored) {
+			return 0, err
+		}
+	}
+}
+
+// Get retorna el valor de una clave
+func (s *MemcachedStore) Get(key string) (string, bool, error) {
+	item, err := s.client.Get(s.prefix + key)
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return string(item.Value), true, nil
+}
+
+// Set guarda un valor con vencimiento
+func (s *MemcachedStore) Set(key, value string, ttl time.Duration) error {
+	return s.client.Set(&memcache.Item{Key: s.prefix + key, Value: []byte(value), Expiration: expiration(ttl)})
+}
+
+// Delete elimina una clave
+func (s *MemcachedStore) Delete(key string) error {
+	err := s.client.Delete(s.prefix + key)
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return nil
+	}
+	return err
+}
+
+// expiration convierte un ttl a segundos de Memcached, como mínimo 1
+func expiration(ttl time.Duration) int32 {
+	seconds := int32((ttl + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
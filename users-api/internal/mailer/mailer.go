@@ -0,0 +1,134 @@
+package mailer
+
+import (
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message es un email de texto plano
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer envía emails transaccionales (verificación, recuperación de contraseña)
+type Mailer interface {
+	Send(msg Message) error
+}
+
+type smtpMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer crea un Mailer que envía por SMTP. Si username está vacío no se autentica.
+func NewSMTPMailer(host, port, username, password, from string) Mailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &smtpMailer{
+		addr: host + ":" + port,
+		auth: auth,
+		from: from,
+	}
+}
+
+// Send envía el mensaje por SMTP
+func (m *smtpMailer) Send(msg Message) error {
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, format(m.from, msg)); err != nil {
+		return fmt.Errorf("error sending email: %w", err)
+	}
+	return nil
+}
+
+// MemoryMailer guarda los mensajes en memoria. Pensado para tests.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewMemoryMailer crea un MemoryMailer vacío
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+// Send guarda el mensaje
+func (m *MemoryMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages retorna una copia de los mensajes enviados
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// Last retorna el último mensaje enviado a un destinatario
+func (m *MemoryMailer) Last(to string) (Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == to {
+			return m.messages[i], true
+		}
+	}
+	return Message{}, false
+}
+
+type fileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer crea un Mailer que escribe cada mensaje como archivo .eml en dir.
+// Útil en desarrollo cuando no hay un servidor SMTP.
+func NewFileMailer(dir, from string) Mailer {
+	return &fileMailer{dir: dir, from: from}
+}
+
+// Send escribe el mensaje en disco
+func (m *fileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return fmt.Errorf("error creating mail dir: %w", err)
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), sanitize(msg.To))
+	if err := os.WriteFile(filepath.Join(m.dir, name), format(m.from, msg), 0o644); err != nil {
+		return fmt.Errorf("error writing email: %w", err)
+	}
+	return nil
+}
+
+// format arma el mensaje en formato RFC 5322
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + msg.Subject + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+	return []byte(b.String())
+}
+
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '@' || r == '.' || r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,120 @@
+package messaging
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"users-api/config"
+
+	"github.com/streadway/amqp"
+)
+
+// Tipos de eventos de usuario; se publican con routing key "user.<tipo>"
+const (
+	EventUserDeactivated = "deactivated"
+	EventUserReactivated = "reactivated"
+	EventUserDeleted     = "deleted"
+	EventUserErased      = "erased"
+)
+
+type RabbitMQPublisher interface {
+	PublishEvent(event Event) error
+	Close() error
+}
+
+type Event struct {
+	Type      string      `json:"type"`      // "deactivated", "reactivated", "deleted", "erased"
+	Entity    string      `json:"entity"`    // "user"
+	EntityID  string      `json:"entity_id"` // ID del usuario
+	Data      interface{} `json:"data"`      // Datos del evento (nunca PII)
+	Timestamp int64       `json:"timestamp"` // Unix timestamp
+}
+
+// UserEventData es el payload de los eventos de usuario
+type UserEventData struct {
+	UserID uint `json:"user_id"`
+}
+
+type rabbitmqPublisher struct {
+	conn    *amqp.Connection
+	channel *amqp.Channel
+}
+
+// NewRabbitMQPublisher crea una nueva instancia del publicador de RabbitMQ.
+// Solo declara el exchange: cada servicio consumidor declara y enlaza su propia cola.
+func NewRabbitMQPublisher() (RabbitMQPublisher, error) {
+	conn, err := amqp.Dial(config.AppConfig.RabbitMQURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	// Declarar el exchange
+	err = channel.ExchangeDeclare(
+		config.AppConfig.RabbitMQExchange, // name
+		"topic",                           // type
+		true,                              // durable
+		false,                             // auto-deleted
+		false,                             // internal
+		false,                             // no-wait
+		nil,                               // arguments
+	)
+	if err != nil {
+		channel.Close()
+		conn.Close()
+		return nil, fmt.Errorf("failed to declare exchange: %w", err)
+	}
+
+	log.Println("RabbitMQ connection established successfully")
+
+	return &rabbitmqPublisher{
+		conn:    conn,
+		channel: channel,
+	}, nil
+}
+
+// PublishEvent publica un evento a RabbitMQ
+func (p *rabbitmqPublisher) PublishEvent(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error marshaling event: %w", err)
+	}
+
+	routingKey := fmt.Sprintf("user.%s", event.Type)
+
+	err = p.channel.Publish(
+		config.AppConfig.RabbitMQExchange, // exchange
+		routingKey,                        // routing key
+		false,                             // mandatory
+		false,                             // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			Body:         body,
+			DeliveryMode: amqp.Persistent,
+		},
+	)
+
+	if err != nil {
+		return fmt.Errorf("error publishing event: %w", err)
+	}
+
+	log.Printf("Event published: %s - %s", routingKey, event.EntityID)
+	return nil
+}
+
+// Close cierra la conexión con RabbitMQ
+func (p *rabbitmqPublisher) Close() error {
+	if err := p.channel.Close(); err != nil {
+		return err
+	}
+	if err := p.conn.Close(); err != nil {
+		return err
+	}
+	log.Println("RabbitMQ connection closed")
+	return nil
+}
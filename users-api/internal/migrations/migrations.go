@@ -0,0 +1,276 @@
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// files son las migraciones embebidas en el binario: NNNN_nombre.up.sql y NNNN_nombre.down.sql
+//
+//go:embed sql/*.sql
+var files embed.FS
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// ErrSchemaTooNew indica que la base fue migrada por una versión más nueva del servicio
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+
+// Migration es un cambio de esquema versionado con su reversión
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status es el estado de una migración conocida por el binario
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// Store persiste qué migraciones se aplicaron y ejecuta su SQL
+type Store interface {
+	// Lock toma un lock exclusivo para que dos procesos no migren a la vez
+	Lock() error
+	Unlock() error
+	// Applied retorna las versiones aplicadas y cuándo se aplicaron
+	Applied() (map[int64]time.Time, error)
+	// Apply ejecuta el SQL de subida y registra la versión
+	Apply(m Migration) error
+	// Revert ejecuta el SQL de bajada y borra el registro de la versión
+	Revert(m Migration) error
+}
+
+// Migrator aplica y revierte migraciones en orden de versión
+type Migrator struct {
+	store      Store
+	migrations []Migration
+}
+
+// NewMigrator crea un migrador con las migraciones embebidas en el binario
+func NewMigrator(store Store) (*Migrator, error) {
+	migrations, err := Load(files)
+	if err != nil {
+		return nil, err
+	}
+	return NewMigratorWith(store, migrations), nil
+}
+
+// NewMigratorWith crea un migrador con un conjunto explícito de migraciones
+func NewMigratorWith(store Store, migrations []Migration) *Migrator {
+	return &Migrator{store: store, migrations: migrations}
+}
+
+// Latest retorna la versión más alta conocida por el binario
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Check compara la base con las migraciones del binario y retorna cuántas faltan aplicar.
+// Falla con ErrSchemaTooNew si la base tiene una versión que este binario no conoce.
+func (m *Migrator) Check() (int, error) {
+	applied, err := m.store.Applied()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := m.checkKnown(applied); err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// Up aplica en orden todas las migraciones pendientes y retorna las aplicadas
+func (m *Migrator) Up() ([]Migration, error) {
+	if err := m.store.Lock(); err != nil {
+		return nil, err
+	}
+	defer m.store.Unlock()
+
+	applied, err := m.store.Applied()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkKnown(applied); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err := m.store.Apply(migration); err != nil {
+			return done, fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down revierte las últimas steps migraciones aplicadas, de la más nueva a la más vieja
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, errors.New("steps must be positive")
+	}
+
+	if err := m.store.Lock(); err != nil {
+		return nil, err
+	}
+	defer m.store.Unlock()
+
+	applied, err := m.store.Applied()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkKnown(applied); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if err := m.store.Revert(migration); err != nil {
+			return done, fmt.Errorf("rollback of %04d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Status lista las migraciones del binario indicando cuáles están aplicadas
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.store.Applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = Status{Version: migration.Version, Name: migration.Name}
+		if at, ok := applied[migration.Version]; ok {
+			at := at
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, m.checkKnown(applied)
+}
+
+// checkKnown falla si la base tiene aplicada alguna versión que el binario no conoce
+func (m *Migrator) checkKnown(applied map[int64]time.Time) error {
+	known := make(map[int64]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+	}
+
+	var unknown []int64
+	for version := range applied {
+		if !known[version] {
+			unknown = append(unknown, version)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i] < unknown[j] })
+	return fmt.Errorf("%w: applied version %d, binary knows up to %d", ErrSchemaTooNew, unknown[len(unknown)-1], m.Latest())
+}
+
+// Load lee las migraciones de un directorio "sql" y las ordena por versión.
+// Cada versión debe tener su archivo up y su archivo down.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		content, err := fs.ReadFile(fsys, path.Join("sql", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if strings.TrimSpace(migration.Up) == "" || strings.TrimSpace(migration.Down) == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Statements separa un script en sentencias individuales (terminadas en ";" al final de la línea)
+// y descarta los comentarios de línea completa.
+func Statements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
@@ -0,0 +1,141 @@
+package migrations
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryStore simula schema_migrations y registra el orden en que se ejecutan los scripts
+type memoryStore struct {
+	applied map[int64]time.Time
+	log     []string
+	locked  bool
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{applied: map[int64]time.Time{}}
+}
+
+func (s *memoryStore) Lock() error {
+	if s.locked {
+		return errors.New("already locked")
+	}
+	s.locked = true
+	return nil
+}
+
+func (s *memoryStore) Unlock() error {
+	s.locked = false
+	return nil
+}
+
+func (s *memoryStore) Applied() (map[int64]time.Time, error) {
+	applied := make(map[int64]time.Time, len(s.applied))
+	for v, at := range s.applied {
+		applied[v] = at
+	}
+	return applied, nil
+}
+
+func (s *memoryStore) Apply(m Migration) error {
+	s.applied[m.Version] = time.Now()
+	s.log = append(s.log, m.Up)
+	return nil
+}
+
+func (s *memoryStore) Revert(m Migration) error {
+	delete(s.applied, m.Version)
+	s.log = append(s.log, m.Down)
+	return nil
+}
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "create", Up: "up1", Down: "down1"},
+	{Version: 2, Name: "rename", Up: "up2", Down: "down2"},
+	{Version: 3, Name: "backfill", Up: "up3", Down: "down3"},
+}
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	migrations, err := Load(files)
+	if err != nil {
+		t.Fatalf("las migraciones embebidas no cargan: %v", err)
+	}
+	if len(migrations) == 0 || migrations[0].Version != 1 || migrations[0].Name != "baseline" {
+		t.Fatalf("se esperaba 0001_baseline primero, llegó %+v", migrations)
+	}
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version <= migrations[i-1].Version {
+			t.Fatalf("las migraciones deben quedar ordenadas por versión")
+		}
+	}
+
+	statements := Statements(migrations[0].Up)
+	for _, statement := range statements {
+		if !strings.HasPrefix(statement, "CREATE TABLE IF NOT EXISTS") || strings.HasSuffix(statement, ";") {
+			t.Fatalf("sentencia mal separada: %q", statement)
+		}
+	}
+	if len(statements) != len(Statements(migrations[0].Down)) {
+		t.Fatalf("el down de la baseline debería borrar cada tabla que crea el up")
+	}
+
+	// Los triggers de una sola sentencia ocupan dos líneas y deben quedar enteros
+	audit := Statements(migrations[1].Up)
+	if migrations[1].Name != "audit_log" || len(audit) != 3 || !strings.HasSuffix(audit[1], "'audit_log is append-only'") {
+		t.Fatalf("migración de auditoría mal separada: %q", audit)
+	}
+}
+
+func TestMigratorUpDownStatus(t *testing.T) {
+	store := newMemoryStore()
+	migrator := NewMigratorWith(store, testMigrations)
+
+	if pending, err := migrator.Check(); err != nil || pending != 3 {
+		t.Fatalf("se esperaban 3 pendientes, llegó %d, error: %v", pending, err)
+	}
+
+	applied, err := migrator.Up()
+	if err != nil || len(applied) != 3 || strings.Join(store.log, ",") != "up1,up2,up3" {
+		t.Fatalf("up debería aplicar todo en orden, log: %v, error: %v", store.log, err)
+	}
+	if store.locked {
+		t.Fatalf("el lock debería liberarse al terminar")
+	}
+
+	// Volver a correr up no hace nada
+	if applied, _ := migrator.Up(); len(applied) != 0 {
+		t.Fatalf("no debería haber nada para aplicar, llegó %+v", applied)
+	}
+
+	reverted, err := migrator.Down(2)
+	if err != nil || len(reverted) != 2 || strings.Join(store.log[3:], ",") != "down3,down2" {
+		t.Fatalf("down 2 debería revertir 3 y luego 2, log: %v, error: %v", store.log, err)
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil || statuses[0].AppliedAt == nil || statuses[1].AppliedAt != nil || statuses[2].AppliedAt != nil {
+		t.Fatalf("estado inesperado: %+v, error: %v", statuses, err)
+	}
+}
+
+func TestMigratorRefusesNewerSchema(t *testing.T) {
+	store := newMemoryStore()
+	store.applied[1] = time.Now()
+	store.applied[4] = time.Now() // aplicada por una versión más nueva del servicio
+	migrator := NewMigratorWith(store, testMigrations)
+
+	if _, err := migrator.Check(); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("se esperaba ErrSchemaTooNew, llegó %v", err)
+	}
+	if _, err := migrator.Up(); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("up no debería tocar un esquema más nuevo, llegó %v", err)
+	}
+	if _, err := migrator.Down(1); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("down no debería tocar un esquema más nuevo, llegó %v", err)
+	}
+	if len(store.log) != 0 {
+		t.Fatalf("no debería haberse ejecutado ningún script: %v", store.log)
+	}
+}
@@ -0,0 +1,136 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// lockName es el lock de MySQL (GET_LOCK) que serializa las migraciones entre procesos
+	lockName    = "users-api:schema_migrations"
+	lockTimeout = 30 // segundos
+)
+
+// mysqlStore registra las migraciones aplicadas en la tabla schema_migrations.
+// Usa una sola conexión porque GET_LOCK pertenece a la sesión que lo tomó.
+type mysqlStore struct {
+	db   *sql.DB
+	conn *sql.Conn
+}
+
+// NewMySQLStore crea el store sobre una conexión a MySQL
+func NewMySQLStore(db *sql.DB) Store {
+	return &mysqlStore{db: db}
+}
+
+func (s *mysqlStore) Lock() error {
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, lockTimeout).Scan(&acquired); err != nil {
+		conn.Close()
+		return err
+	}
+	if acquired.Int64 != 1 {
+		conn.Close()
+		return errors.New("could not acquire migrations lock, is another migration running?")
+	}
+
+	s.conn = conn
+	return nil
+}
+
+func (s *mysqlStore) Unlock() error {
+	if s.conn == nil {
+		return nil
+	}
+	defer func() {
+		s.conn.Close()
+		s.conn = nil
+	}()
+
+	_, err := s.conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+	return err
+}
+
+func (s *mysqlStore) Applied() (map[int64]time.Time, error) {
+	if err := s.ensureTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.queryer().QueryContext(context.Background(), "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// Apply ejecuta la migración sentencia por sentencia. MySQL confirma cada DDL implícitamente,
+// así que una falla a mitad de camino deja la versión sin registrar y hay que corregirla a mano.
+func (s *mysqlStore) Apply(m Migration) error {
+	if err := s.exec(m.Up); err != nil {
+		return err
+	}
+
+	_, err := s.queryer().ExecContext(context.Background(),
+		"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		m.Version, m.Name, time.Now())
+	return err
+}
+
+func (s *mysqlStore) Revert(m Migration) error {
+	if err := s.exec(m.Down); err != nil {
+		return err
+	}
+
+	_, err := s.queryer().ExecContext(context.Background(), "DELETE FROM schema_migrations WHERE version = ?", m.Version)
+	return err
+}
+
+func (s *mysqlStore) exec(script string) error {
+	for _, statement := range Statements(script) {
+		if _, err := s.queryer().ExecContext(context.Background(), statement); err != nil {
+			return fmt.Errorf("%w\n%s", err, statement)
+		}
+	}
+	return nil
+}
+
+func (s *mysqlStore) ensureTable() error {
+	_, err := s.queryer().ExecContext(context.Background(), `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT NOT NULL,
+		name VARCHAR(100) NOT NULL,
+		applied_at DATETIME(3) NOT NULL,
+		PRIMARY KEY (version)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`)
+	return err
+}
+
+// queryer usa la conexión con el lock si está tomado, o el pool si solo se consulta el estado
+func (s *mysqlStore) queryer() interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+} {
+	if s.conn != nil {
+		return s.conn
+	}
+	return s.db
+}
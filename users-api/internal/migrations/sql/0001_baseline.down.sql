@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS oidc_states;
+DROP TABLE IF EXISTS identities;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS user_tokens;
+DROP TABLE IF EXISTS invitations;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,125 @@
+-- Esquema que generaba AutoMigrate hasta la introducción de las migraciones versionadas.
+-- Usa IF NOT EXISTS para poder adoptar bases creadas por AutoMigrate sin recrearlas.
+
+CREATE TABLE IF NOT EXISTS users (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    username VARCHAR(50) NOT NULL,
+    email VARCHAR(100) NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    first_name VARCHAR(50),
+    last_name VARCHAR(50),
+    role VARCHAR(20) NOT NULL DEFAULT 'normal',
+    created_at DATETIME(3) NULL,
+    updated_at DATETIME(3) NULL,
+    verified_at DATETIME(3) NULL,
+    totp_secret VARCHAR(64),
+    totp_enabled_at DATETIME(3) NULL,
+    totp_last_step BIGINT NOT NULL DEFAULT 0,
+    deactivated_at DATETIME(3) NULL,
+    deleted_at DATETIME(3) NULL,
+    erased_at DATETIME(3) NULL,
+    PRIMARY KEY (id),
+    UNIQUE INDEX idx_users_username (username),
+    UNIQUE INDEX idx_users_email (email),
+    INDEX idx_users_deleted_at (deleted_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    user_id BIGINT UNSIGNED NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    family_id VARCHAR(64) NOT NULL,
+    expires_at DATETIME(3) NOT NULL,
+    revoked_at DATETIME(3) NULL,
+    replaced_by BIGINT UNSIGNED NULL,
+    mfa BOOLEAN NOT NULL DEFAULT false,
+    method VARCHAR(10) NOT NULL DEFAULT 'pwd',
+    created_at DATETIME(3) NULL,
+    PRIMARY KEY (id),
+    UNIQUE INDEX idx_refresh_tokens_token_hash (token_hash),
+    INDEX idx_refresh_tokens_user_id (user_id),
+    INDEX idx_refresh_tokens_family_id (family_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS invitations (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    code_hash VARCHAR(64) NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    email VARCHAR(100),
+    created_by BIGINT UNSIGNED NOT NULL,
+    expires_at DATETIME(3) NOT NULL,
+    redeemed_at DATETIME(3) NULL,
+    redeemed_by BIGINT UNSIGNED NULL,
+    created_at DATETIME(3) NULL,
+    PRIMARY KEY (id),
+    UNIQUE INDEX idx_invitations_code_hash (code_hash)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    user_id BIGINT UNSIGNED NOT NULL,
+    purpose VARCHAR(30) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at DATETIME(3) NOT NULL,
+    used_at DATETIME(3) NULL,
+    attempts BIGINT NOT NULL DEFAULT 0,
+    created_at DATETIME(3) NULL,
+    PRIMARY KEY (id),
+    UNIQUE INDEX idx_user_tokens_token_hash (token_hash),
+    INDEX idx_user_tokens_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    user_id BIGINT UNSIGNED NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at DATETIME(3) NULL,
+    created_at DATETIME(3) NULL,
+    PRIMARY KEY (id),
+    UNIQUE INDEX idx_recovery_codes_code_hash (code_hash),
+    INDEX idx_recovery_codes_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(20) NOT NULL,
+    description VARCHAR(100),
+    created_at DATETIME(3) NULL,
+    PRIMARY KEY (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(50) NOT NULL,
+    PRIMARY KEY (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_name VARCHAR(20) NOT NULL,
+    permission_name VARCHAR(50) NOT NULL,
+    PRIMARY KEY (role_name, permission_name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS identities (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    user_id BIGINT UNSIGNED NOT NULL,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(100),
+    last_login_at DATETIME(3) NULL,
+    created_at DATETIME(3) NULL,
+    PRIMARY KEY (id),
+    UNIQUE INDEX idx_identity_provider_subject (provider, subject),
+    INDEX idx_identities_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS oidc_states (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    state_hash VARCHAR(64) NOT NULL,
+    provider VARCHAR(50) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    expires_at DATETIME(3) NOT NULL,
+    used_at DATETIME(3) NULL,
+    created_at DATETIME(3) NULL,
+    PRIMARY KEY (id),
+    UNIQUE INDEX idx_oidc_states_state_hash (state_hash)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,23 @@
+-- Log de auditoría de acciones sensibles. Es de solo inserción: los triggers rechazan UPDATE y DELETE.
+
+CREATE TABLE audit_log (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    actor_id BIGINT UNSIGNED NULL,
+    target_id BIGINT UNSIGNED NULL,
+    action VARCHAR(50) NOT NULL,
+    ip VARCHAR(45),
+    user_agent VARCHAR(255),
+    details VARCHAR(255),
+    created_at DATETIME(3) NULL,
+    PRIMARY KEY (id),
+    INDEX idx_audit_log_actor_id (actor_id),
+    INDEX idx_audit_log_target_id (target_id),
+    INDEX idx_audit_log_action (action),
+    INDEX idx_audit_log_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log FOR EACH ROW
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
+
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log FOR EACH ROW
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
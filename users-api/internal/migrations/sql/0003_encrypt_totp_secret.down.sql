@@ -0,0 +1,5 @@
+-- Los secretos cifrados no entran en 64 caracteres: se descartan y esos usuarios deben volver a enrolar 2FA.
+
+DELETE FROM recovery_codes WHERE user_id IN (SELECT id FROM users WHERE totp_secret LIKE 'enc:%');
+UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0 WHERE totp_secret LIKE 'enc:%';
+ALTER TABLE users MODIFY totp_secret VARCHAR(64);
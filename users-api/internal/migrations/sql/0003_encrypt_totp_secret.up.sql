@@ -0,0 +1,4 @@
+-- Los secretos TOTP se guardan cifrados (AES-256-GCM con TOTP_ENCRYPTION_KEY), que ocupan más que el secreto en claro.
+-- Los secretos ya guardados en claro se cifran la próxima vez que el usuario usa su segundo factor.
+
+ALTER TABLE users MODIFY totp_secret VARCHAR(255);
@@ -0,0 +1,252 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"shared/auth"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config es la configuración de un proveedor OIDC (flujo authorization code con PKCE)
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string // vacío para clientes públicos
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Identity son los datos del usuario que el proveedor certifica en el id_token
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	FirstName     string
+	LastName      string
+}
+
+// Provider es un proveedor de identidad externo
+type Provider interface {
+	Name() string
+	// AuthCodeURL arma la URL de autorización a la que se redirige al usuario
+	AuthCodeURL(state, nonce, codeVerifier string) (string, error)
+	// Exchange canjea el código de autorización y retorna la identidad verificada del id_token
+	Exchange(code, codeVerifier, nonce string) (*Identity, error)
+}
+
+// discoveryDocument es el subconjunto de /.well-known/openid-configuration que usamos
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// idTokenClaims son los claims del id_token que nos interesan
+type idTokenClaims struct {
+	Nonce             string `json:"nonce"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	GivenName         string `json:"given_name"`
+	FamilyName        string `json:"family_name"`
+	jwt.RegisteredClaims
+}
+
+type provider struct {
+	cfg        Config
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keys      *auth.JWKSCache
+}
+
+// NewProvider crea un proveedor. El documento de discovery se descarga en el primer uso.
+func NewProvider(cfg Config) Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &provider{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *provider) Name() string {
+	return p.cfg.Name
+}
+
+// AuthCodeURL arma la URL de autorización con state, nonce y el challenge S256 del verifier
+func (p *provider) AuthCodeURL(state, nonce, codeVerifier string) (string, error) {
+	doc, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {CodeChallenge(codeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return doc.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange canjea el código en el token endpoint y verifica firma, emisor, audiencia, vencimiento y nonce del id_token
+func (p *provider) Exchange(code, codeVerifier, nonce string) (*Identity, error) {
+	doc, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequest(http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned status: %d", resp.StatusCode)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("error decoding token response: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	return p.verifyIDToken(tokens.IDToken, nonce)
+}
+
+func (p *provider) verifyIDToken(raw, nonce string) (*Identity, error) {
+	claims := &idTokenClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, auth.ErrMissingKID
+		}
+		return p.keys.Lookup(kid)
+	},
+		jwt.WithValidMethods(auth.SigningMethods),
+		jwt.WithIssuer(p.cfg.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	if claims.ExpiresAt == nil {
+		return nil, errors.New("invalid id_token: missing expiration")
+	}
+	if claims.Nonce == "" || claims.Nonce != nonce {
+		return nil, errors.New("invalid id_token: nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("invalid id_token: missing subject")
+	}
+
+	return &Identity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Username:      claims.PreferredUsername,
+		FirstName:     claims.GivenName,
+		LastName:      claims.FamilyName,
+	}, nil
+}
+
+// discover descarga el documento de discovery y lo cachea mientras el proceso viva
+func (p *provider) discover() (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	resp, err := p.httpClient.Get(strings.TrimRight(p.cfg.Issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching OIDC discovery: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OIDC discovery returned status: %d", resp.StatusCode)
+	}
+
+	var doc discoveryDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("error decoding OIDC discovery: %w", err)
+	}
+
+	// Un documento que declara otro emisor podría suplantar al proveedor
+	if doc.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("OIDC discovery issuer mismatch: %q", doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document is incomplete")
+	}
+
+	p.discovery = &doc
+	p.keys = auth.NewJWKSCache(doc.JWKSURI, time.Hour)
+	return p.discovery, nil
+}
+
+// NewCodeVerifier genera un code_verifier de PKCE (RFC 7636)
+func NewCodeVerifier() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// CodeChallenge calcula el code_challenge S256 de un verifier
+func CodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
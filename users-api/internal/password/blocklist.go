@@ -0,0 +1,104 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// commonPasswords es la lista embebida de contraseñas comunes que se usa por defecto
+//
+//go:embed common.txt
+var commonPasswords string
+
+// Blocklist es una lista offline de contraseñas filtradas o demasiado comunes.
+// Las contraseñas en claro se comparan sin distinguir mayúsculas; los hashes SHA-1
+// (como los que publica Have I Been Pwned) se comparan contra la contraseña exacta.
+type Blocklist struct {
+	words  map[string]struct{}
+	hashes map[string]struct{}
+}
+
+// NewBlocklist crea una lista vacía
+func NewBlocklist() *Blocklist {
+	return &Blocklist{words: map[string]struct{}{}, hashes: map[string]struct{}{}}
+}
+
+// DefaultBlocklist retorna la lista embebida en el binario
+func DefaultBlocklist() *Blocklist {
+	list := NewBlocklist()
+	// La lista embebida es fija: un error de lectura sería un bug de compilación
+	if err := list.Read(strings.NewReader(commonPasswords)); err != nil {
+		panic(err)
+	}
+	return list
+}
+
+// LoadFile agrega a la lista las entradas de un archivo, una por línea
+func (b *Blocklist) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := b.Read(file); err != nil {
+		return fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return nil
+}
+
+// Read agrega las entradas de r. Ignora líneas vacías y comentarios (#).
+// Una línea de 40 caracteres hexadecimales, opcionalmente seguida de ":cantidad", es un hash SHA-1.
+func (b *Blocklist) Read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if hash, ok := sha1Entry(line); ok {
+			b.hashes[hash] = struct{}{}
+			continue
+		}
+		b.words[strings.ToLower(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Contains indica si la contraseña está en la lista
+func (b *Blocklist) Contains(password string) bool {
+	if _, ok := b.words[strings.ToLower(password)]; ok {
+		return true
+	}
+	if len(b.hashes) == 0 {
+		return false
+	}
+
+	sum := sha1.Sum([]byte(password))
+	_, ok := b.hashes[strings.ToUpper(hex.EncodeToString(sum[:]))]
+	return ok
+}
+
+// Len retorna la cantidad de entradas cargadas
+func (b *Blocklist) Len() int {
+	return len(b.words) + len(b.hashes)
+}
+
+// sha1Entry reconoce una línea "HASH" o "HASH:cantidad" y retorna el hash en mayúsculas
+func sha1Entry(line string) (string, bool) {
+	hash, _, _ := strings.Cut(line, ":")
+	if len(hash) != 2*sha1.Size {
+		return "", false
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", false
+	}
+	return strings.ToUpper(hash), true
+}
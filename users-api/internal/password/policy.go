@@ -0,0 +1,110 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxBytes es el largo máximo que procesa bcrypt; una contraseña más larga no se puede hashear
+const MaxBytes = 72
+
+// Códigos de los motivos de rechazo
+const (
+	ReasonTooShort      = "too_short"
+	ReasonTooLong       = "too_long"
+	ReasonMissingLower  = "missing_lowercase"
+	ReasonMissingUpper  = "missing_uppercase"
+	ReasonMissingDigit  = "missing_digit"
+	ReasonMissingSymbol = "missing_symbol"
+	ReasonBreached      = "breached"
+)
+
+// Reason es una regla de la política que la contraseña no cumple
+type Reason struct {
+	Code    string
+	Message string
+}
+
+// PolicyError indica que la contraseña fue rechazada, con un motivo por cada regla incumplida
+type PolicyError struct {
+	Reasons []Reason
+}
+
+func (e *PolicyError) Error() string {
+	codes := make([]string, len(e.Reasons))
+	for i, reason := range e.Reasons {
+		codes[i] = reason.Code
+	}
+	return fmt.Sprintf("password does not meet the policy (%s)", strings.Join(codes, ", "))
+}
+
+// Policy son las reglas que debe cumplir una contraseña nueva
+type Policy struct {
+	MinLength     int // en caracteres
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	Blocklist     *Blocklist // nil deshabilita el chequeo contra contraseñas filtradas
+}
+
+// DefaultPolicy es la política por defecto: 10 caracteres, minúsculas, mayúsculas y dígitos,
+// y la lista embebida de contraseñas comunes
+func DefaultPolicy() *Policy {
+	return &Policy{
+		MinLength:    10,
+		RequireLower: true,
+		RequireUpper: true,
+		RequireDigit: true,
+		Blocklist:    DefaultBlocklist(),
+	}
+}
+
+// Validate retorna un *PolicyError con todos los motivos de rechazo, o nil si la contraseña es aceptable
+func (p *Policy) Validate(password string) error {
+	var reasons []Reason
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		reasons = append(reasons, Reason{ReasonTooShort, fmt.Sprintf("password must be at least %d characters long", p.MinLength)})
+	}
+	if len(password) > MaxBytes {
+		reasons = append(reasons, Reason{ReasonTooLong, fmt.Sprintf("password must be at most %d bytes long", MaxBytes)})
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireLower && !lower {
+		reasons = append(reasons, Reason{ReasonMissingLower, "password must contain a lowercase letter"})
+	}
+	if p.RequireUpper && !upper {
+		reasons = append(reasons, Reason{ReasonMissingUpper, "password must contain an uppercase letter"})
+	}
+	if p.RequireDigit && !digit {
+		reasons = append(reasons, Reason{ReasonMissingDigit, "password must contain a digit"})
+	}
+	if p.RequireSymbol && !symbol {
+		reasons = append(reasons, Reason{ReasonMissingSymbol, "password must contain a symbol"})
+	}
+
+	if p.Blocklist != nil && p.Blocklist.Contains(password) {
+		reasons = append(reasons, Reason{ReasonBreached, "password appears in a list of breached or common passwords"})
+	}
+
+	if len(reasons) > 0 {
+		return &PolicyError{Reasons: reasons}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func reasonCodes(t *testing.T, err error) []string {
+	t.Helper()
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("se esperaba un *PolicyError, llegó %v", err)
+	}
+	codes := make([]string, len(policyErr.Reasons))
+	for i, reason := range policyErr.Reasons {
+		if reason.Message == "" {
+			t.Fatalf("el motivo %s no tiene mensaje", reason.Code)
+		}
+		codes[i] = reason.Code
+	}
+	return codes
+}
+
+func TestPolicyReportsEveryFailedRule(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.RequireSymbol = true
+
+	codes := reasonCodes(t, policy.Validate("abc"))
+	expected := []string{ReasonTooShort, ReasonMissingUpper, ReasonMissingDigit, ReasonMissingSymbol}
+	if strings.Join(codes, ",") != strings.Join(expected, ",") {
+		t.Fatalf("se esperaba %v, llegó %v", expected, codes)
+	}
+
+	if err := policy.Validate("Pase-Largo-1234"); err != nil {
+		t.Fatalf("una contraseña que cumple todo no debería rechazarse: %v", err)
+	}
+
+	// Los caracteres multibyte cuentan como uno para el mínimo, pero bcrypt limita en bytes
+	if codes := reasonCodes(t, policy.Validate("Ñandú-1"+strings.Repeat("é", 40))); len(codes) != 1 || codes[0] != ReasonTooLong {
+		t.Fatalf("se esperaba solo too_long, llegó %v", codes)
+	}
+}
+
+func TestBlocklistMatchesWordsAndSHA1(t *testing.T) {
+	policy := &Policy{MinLength: 8, RequireUpper: true, RequireDigit: true, Blocklist: DefaultBlocklist()}
+
+	// La lista ignora mayúsculas: cumplir las clases de caracteres no alcanza
+	if codes := reasonCodes(t, policy.Validate("Password123")); len(codes) != 1 || codes[0] != ReasonBreached {
+		t.Fatalf("se esperaba solo breached, llegó %v", codes)
+	}
+
+	// Formato de Have I Been Pwned: SHA-1 de "Cancha-Boca-1905" con la cantidad de apariciones
+	list := NewBlocklist()
+	if err := list.Read(strings.NewReader("# comentario\n\n1E44F6DA83D1CA1C4469C9CA4FE74A1AF34A5C44:12\nfutbol\n")); err != nil {
+		t.Fatalf("read falló: %v", err)
+	}
+	if list.Len() != 2 {
+		t.Fatalf("se esperaban 2 entradas, llegó %d", list.Len())
+	}
+	if !list.Contains("Cancha-Boca-1905") || !list.Contains("FUTBOL") {
+		t.Fatalf("deberían coincidir el hash y la palabra")
+	}
+	// El hash corresponde a la contraseña exacta
+	if list.Contains("cancha-boca-1905") {
+		t.Fatalf("el hash no debería coincidir con otra capitalización")
+	}
+}
@@ -2,22 +2,117 @@ package repositories
 
 import (
 	"errors"
+	"shared/auth"
+	"strings"
+	"time"
 	"users-api/internal/domain"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrUserNotFound indica que no existe un usuario (activo) con ese dato
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrLastAdmin indica que el cambio de rol dejaría el sistema sin ningún admin
+var ErrLastAdmin = errors.New("cannot remove the last admin")
+
 type UserRepository interface {
 	Create(user *domain.User) error
 	GetByID(id uint) (*domain.User, error)
 	GetByUsername(username string) (*domain.User, error)
 	GetByEmail(email string) (*domain.User, error)
 	GetByUsernameOrEmail(login string) (*domain.User, error)
-	GetAll() ([]domain.User, error)
+	List(filter UserFilter, sort string, offset, limit int) ([]domain.User, int64, error)
+	ListAfter(filter UserFilter, afterID uint, limit int) ([]domain.User, error)
 	Update(user *domain.User) error
 	Delete(id uint) error
+	GetByIDUnscoped(id uint) (*domain.User, error)
+	Erase(user *domain.User) error
 	ExistsByUsername(username string) (bool, error)
 	ExistsByEmail(email string) (bool, error)
+	UpdateRole(userID uint, role string) error
+	UpdatePasswordHash(userID uint, oldHash, newHash string) (bool, error)
+
+	// Refresh tokens
+	CreateRefreshToken(token *domain.RefreshToken) error
+	GetRefreshTokenByHash(hash string) (*domain.RefreshToken, error)
+	RevokeRefreshToken(id uint, replacedBy *uint) (bool, error)
+	RevokeRefreshTokenFamily(familyID string) error
+	RevokeUserRefreshTokens(userID uint) error
+
+	// Invitaciones
+	CreateInvitation(invitation *domain.Invitation) error
+	GetInvitationByCodeHash(hash string) (*domain.Invitation, error)
+	CreateWithInvitation(user *domain.User, invitationID uint) error
+	CreateFirstAdmin(user *domain.User) error
+
+	// Tokens de un solo uso (recuperación de contraseña, verificación de email)
+	CreateUserToken(token *domain.UserToken) error
+	GetUserTokenByHash(hash, purpose string) (*domain.UserToken, error)
+	ConsumeUserToken(id uint) (bool, error)
+	InvalidateUserTokens(userID uint, purpose string) error
+	RecordUserTokenFailure(id uint, maxAttempts int) error
+
+	// 2FA
+	UpdateTOTPLastStep(userID uint, step int64) (bool, error)
+	UpdateTOTPSecret(userID uint, oldValue, newValue string) (bool, error)
+	ReplaceRecoveryCodes(userID uint, codes []domain.RecoveryCode) error
+	UseRecoveryCode(userID uint, hash string) (bool, error)
+
+	// Roles y permisos
+	SeedRoles(defaults []domain.DefaultRole) error
+	ListRoles() ([]domain.Role, error)
+	GetRolePermissions(role string) ([]string, error)
+	SetRolePermissions(role string, permissions []string) error
+
+	// Login con proveedores OIDC
+	CreateOIDCState(state *domain.OIDCState) error
+	GetOIDCStateByHash(hash string) (*domain.OIDCState, error)
+	ConsumeOIDCState(id uint) (bool, error)
+	GetIdentity(provider, subject string) (*domain.Identity, error)
+	CreateIdentity(identity *domain.Identity) error
+	CreateWithIdentity(user *domain.User, identity *domain.Identity) error
+	TouchIdentity(id uint) error
+
+	// Log de auditoría (solo inserción)
+	CreateAuditEntry(entry *domain.AuditEntry) error
+	ListAuditEntries(filter AuditFilter, offset, limit int) ([]domain.AuditEntry, int64, error)
+}
+
+// UserFilter son los criterios de búsqueda del listado de usuarios
+type UserFilter struct {
+	Role        string
+	Prefix      string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+}
+
+// AuditFilter son los criterios de búsqueda del log de auditoría
+type AuditFilter struct {
+	ActorID  uint
+	TargetID uint
+	Action   string
+	IP       string
+	From     *time.Time
+	To       *time.Time
+}
+
+// userSortColumns son los órdenes permitidos, para no interpolar entrada del usuario en el SQL
+var userSortColumns = map[string]string{
+	"id":         "id",
+	"username":   "username",
+	"email":      "email",
+	"first_name": "first_name",
+	"last_name":  "last_name",
+	"role":       "role",
+	"created_at": "created_at",
+}
+
+// ValidUserSort indica si sort es un orden aceptado por List ("campo" o "-campo")
+func ValidUserSort(sort string) bool {
+	_, ok := userSortColumns[strings.TrimPrefix(sort, "-")]
+	return ok
 }
 
 type userRepository struct {
@@ -42,7 +137,7 @@ func (r *userRepository) GetByID(id uint) (*domain.User, error) {
 
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, errors.New("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, result.Error
 	}
@@ -57,7 +152,7 @@ func (r *userRepository) GetByUsername(username string) (*domain.User, error) {
 
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, errors.New("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, result.Error
 	}
@@ -72,7 +167,7 @@ func (r *userRepository) GetByEmail(email string) (*domain.User, error) {
 
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, errors.New("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, result.Error
 	}
@@ -87,7 +182,7 @@ func (r *userRepository) GetByUsernameOrEmail(login string) (*domain.User, error
 
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, errors.New("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, result.Error
 	}
@@ -95,10 +190,46 @@ func (r *userRepository) GetByUsernameOrEmail(login string) (*domain.User, error
 	return &user, nil
 }
 
-// GetAll obtiene todos los usuarios
-func (r *userRepository) GetAll() ([]domain.User, error) {
+// List obtiene una página de usuarios filtrados y el total que cumple el filtro
+func (r *userRepository) List(filter UserFilter, sort string, offset, limit int) ([]domain.User, int64, error) {
+	var total int64
+	if err := r.filtered(filter).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	column, ok := userSortColumns[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		return nil, 0, errors.New("invalid sort field")
+	}
+	direction := "ASC"
+	if strings.HasPrefix(sort, "-") {
+		direction = "DESC"
+	}
+
+	var users []domain.User
+	result := r.filtered(filter).
+		Order(column + " " + direction).
+		Order("id " + direction). // desempate estable entre páginas
+		Offset(offset).
+		Limit(limit).
+		Find(&users)
+
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return users, total, nil
+}
+
+// ListAfter obtiene hasta limit usuarios con ID mayor a afterID, en orden de ID.
+// Paginación por cursor: no se saltea ni repite filas aunque se inserten usuarios durante el recorrido.
+func (r *userRepository) ListAfter(filter UserFilter, afterID uint, limit int) ([]domain.User, error) {
 	var users []domain.User
-	result := r.db.Find(&users)
+	result := r.filtered(filter).
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&users)
 
 	if result.Error != nil {
 		return nil, result.Error
@@ -107,13 +238,42 @@ func (r *userRepository) GetAll() ([]domain.User, error) {
 	return users, nil
 }
 
+// filtered aplica los criterios de UserFilter a una consulta nueva
+func (r *userRepository) filtered(filter UserFilter) *gorm.DB {
+	query := r.db.Model(&domain.User{})
+
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.Prefix != "" {
+		like := escapeLike(filter.Prefix) + "%"
+		query = query.Where(
+			"username LIKE ? OR email LIKE ? OR first_name LIKE ? OR last_name LIKE ? OR CONCAT(first_name, ' ', last_name) LIKE ?",
+			like, like, like, like, like,
+		)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at <= ?", *filter.CreatedTo)
+	}
+
+	return query
+}
+
+// escapeLike escapa los comodines de LIKE para que el prefijo se busque literalmente
+func escapeLike(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(s)
+}
+
 // Update actualiza un usuario
 func (r *userRepository) Update(user *domain.User) error {
 	result := r.db.Save(user)
 	return result.Error
 }
 
-// Delete elimina un usuario por su ID
+// Delete hace un borrado lógico (deleted_at) del usuario
 func (r *userRepository) Delete(id uint) error {
 	result := r.db.Delete(&domain.User{}, id)
 
@@ -122,16 +282,51 @@ func (r *userRepository) Delete(id uint) error {
 	}
 
 	if result.RowsAffected == 0 {
-		return errors.New("user not found")
+		return ErrUserNotFound
 	}
 
 	return nil
 }
 
-// ExistsByUsername verifica si existe un usuario con ese username
+// GetByIDUnscoped obtiene un usuario por su ID aunque tenga borrado lógico
+func (r *userRepository) GetByIDUnscoped(id uint) (*domain.User, error) {
+	var user domain.User
+	result := r.db.Unscoped().First(&user, id)
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, result.Error
+	}
+
+	return &user, nil
+}
+
+// Erase guarda el usuario ya anonimizado y borra sus credenciales, tokens e identidades externas en una sola transacción
+func (r *userRepository) Erase(user *domain.User) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Save(user).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&domain.RefreshToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&domain.UserToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&domain.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&domain.Identity{}).Error
+	})
+}
+
+// ExistsByUsername verifica si existe un usuario con ese username.
+// Incluye usuarios con borrado lógico porque siguen ocupando el índice único.
 func (r *userRepository) ExistsByUsername(username string) (bool, error) {
 	var count int64
-	result := r.db.Model(&domain.User{}).Where("username = ?", username).Count(&count)
+	result := r.db.Unscoped().Model(&domain.User{}).Where("username = ?", username).Count(&count)
 
 	if result.Error != nil {
 		return false, result.Error
@@ -140,10 +335,10 @@ func (r *userRepository) ExistsByUsername(username string) (bool, error) {
 	return count > 0, nil
 }
 
-// ExistsByEmail verifica si existe un usuario con ese email
+// ExistsByEmail verifica si existe un usuario con ese email (incluye borrados lógicos)
 func (r *userRepository) ExistsByEmail(email string) (bool, error) {
 	var count int64
-	result := r.db.Model(&domain.User{}).Where("email = ?", email).Count(&count)
+	result := r.db.Unscoped().Model(&domain.User{}).Where("email = ?", email).Count(&count)
 
 	if result.Error != nil {
 		return false, result.Error
@@ -151,3 +346,498 @@ func (r *userRepository) ExistsByEmail(email string) (bool, error) {
 
 	return count > 0, nil
 }
+
+// UpdateRole cambia el rol del usuario sin dejar el sistema sin admins.
+// Bloquea la fila del rol admin y las de los usuarios admin (SELECT ... FOR UPDATE) antes de contar,
+// así dos admins que se quitan el rol entre sí se serializan y el segundo ve que ya queda uno solo.
+func (r *userRepository) UpdateRole(userID uint, role string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var adminRole domain.Role
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("name = ?", auth.RoleAdmin).First(&adminRole).Error; err != nil {
+			return err
+		}
+
+		var admins []uint
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&domain.User{}).Where("role = ?", auth.RoleAdmin).Pluck("id", &admins).Error; err != nil {
+			return err
+		}
+		if role != auth.RoleAdmin && len(admins) <= 1 {
+			for _, id := range admins {
+				if id == userID {
+					return ErrLastAdmin
+				}
+			}
+		}
+
+		result := tx.Model(&domain.User{}).Where("id = ?", userID).Update("role", role)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrUserNotFound
+		}
+		return nil
+	})
+}
+
+// UpdatePasswordHash reemplaza el hash de la contraseña solo si sigue siendo oldHash.
+// Retorna false si la contraseña cambió en el medio.
+func (r *userRepository) UpdatePasswordHash(userID uint, oldHash, newHash string) (bool, error) {
+	result := r.db.Model(&domain.User{}).
+		Where("id = ? AND password = ?", userID, oldHash).
+		Update("password", newHash)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// CreateRefreshToken guarda un nuevo refresh token
+func (r *userRepository) CreateRefreshToken(token *domain.RefreshToken) error {
+	result := r.db.Create(token)
+	return result.Error
+}
+
+// GetRefreshTokenByHash obtiene un refresh token por el hash de su valor
+func (r *userRepository) GetRefreshTokenByHash(hash string) (*domain.RefreshToken, error) {
+	var token domain.RefreshToken
+	result := r.db.Where("token_hash = ?", hash).First(&token)
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errors.New("refresh token not found")
+		}
+		return nil, result.Error
+	}
+
+	return &token, nil
+}
+
+// RevokeRefreshToken revoca un token solo si seguía activo.
+// Retorna false si ya estaba revocado, lo que permite detectar reutilización concurrente.
+func (r *userRepository) RevokeRefreshToken(id uint, replacedBy *uint) (bool, error) {
+	result := r.db.Model(&domain.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"revoked_at":  time.Now(),
+			"replaced_by": replacedBy,
+		})
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// RevokeRefreshTokenFamily revoca todos los tokens activos de una familia de rotación
+func (r *userRepository) RevokeRefreshTokenFamily(familyID string) error {
+	result := r.db.Model(&domain.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now())
+	return result.Error
+}
+
+// RevokeUserRefreshTokens revoca todas las sesiones activas de un usuario
+func (r *userRepository) RevokeUserRefreshTokens(userID uint) error {
+	result := r.db.Model(&domain.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now())
+	return result.Error
+}
+
+// CreateInvitation guarda una nueva invitación
+func (r *userRepository) CreateInvitation(invitation *domain.Invitation) error {
+	result := r.db.Create(invitation)
+	return result.Error
+}
+
+// GetInvitationByCodeHash obtiene una invitación por el hash de su código
+func (r *userRepository) GetInvitationByCodeHash(hash string) (*domain.Invitation, error) {
+	var invitation domain.Invitation
+	result := r.db.Where("code_hash = ?", hash).First(&invitation)
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invitation not found")
+		}
+		return nil, result.Error
+	}
+
+	return &invitation, nil
+}
+
+// CreateWithInvitation crea el usuario y marca la invitación como usada en una sola transacción.
+// Falla si la invitación ya fue usada o venció, incluso ante registros concurrentes.
+func (r *userRepository) CreateWithInvitation(user *domain.User, invitationID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&domain.Invitation{}).
+			Where("id = ? AND redeemed_at IS NULL AND expires_at > ?", invitationID, time.Now()).
+			Updates(map[string]interface{}{
+				"redeemed_at": time.Now(),
+				"redeemed_by": user.ID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("invitation already used")
+		}
+
+		return nil
+	})
+}
+
+// CreateFirstAdmin crea el usuario solo si todavía no existe ningún admin.
+// Bloquea la fila del rol admin (SELECT ... FOR UPDATE) antes de contar, así dos bootstraps
+// concurrentes se serializan y el segundo ve el admin creado por el primero.
+func (r *userRepository) CreateFirstAdmin(user *domain.User) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var role domain.Role
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("name = ?", auth.RoleAdmin).First(&role).Error; err != nil {
+			return err
+		}
+
+		var admins int64
+		if err := tx.Model(&domain.User{}).Where("role = ?", auth.RoleAdmin).Count(&admins).Error; err != nil {
+			return err
+		}
+		if admins > 0 {
+			return errors.New("admin already exists")
+		}
+
+		return tx.Create(user).Error
+	})
+}
+
+// CreateUserToken guarda un nuevo token de un solo uso
+func (r *userRepository) CreateUserToken(token *domain.UserToken) error {
+	result := r.db.Create(token)
+	return result.Error
+}
+
+// GetUserTokenByHash obtiene un token de un solo uso por su hash y propósito
+func (r *userRepository) GetUserTokenByHash(hash, purpose string) (*domain.UserToken, error) {
+	var token domain.UserToken
+	result := r.db.Where("token_hash = ? AND purpose = ?", hash, purpose).First(&token)
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errors.New("token not found")
+		}
+		return nil, result.Error
+	}
+
+	return &token, nil
+}
+
+// ConsumeUserToken marca el token como usado solo si seguía disponible.
+// Retorna false si ya fue usado o venció, así dos canjes concurrentes no pueden ganar ambos.
+func (r *userRepository) ConsumeUserToken(id uint) (bool, error) {
+	now := time.Now()
+	result := r.db.Model(&domain.UserToken{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", id, now).
+		Update("used_at", now)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// InvalidateUserTokens invalida los tokens pendientes de un usuario para un propósito dado
+func (r *userRepository) InvalidateUserTokens(userID uint, purpose string) error {
+	result := r.db.Model(&domain.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now())
+	return result.Error
+}
+
+// RecordUserTokenFailure suma un intento fallido e invalida el token al llegar a maxAttempts
+func (r *userRepository) RecordUserTokenFailure(id uint, maxAttempts int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.UserToken{}).
+			Where("id = ?", id).
+			Update("attempts", gorm.Expr("attempts + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Model(&domain.UserToken{}).
+			Where("id = ? AND used_at IS NULL AND attempts >= ?", id, maxAttempts).
+			Update("used_at", time.Now()).Error
+	})
+}
+
+// UpdateTOTPLastStep registra el paso TOTP usado solo si es posterior al último aceptado.
+// Retorna false si el código ya se había usado, incluso ante logins concurrentes.
+func (r *userRepository) UpdateTOTPLastStep(userID uint, step int64) (bool, error) {
+	result := r.db.Model(&domain.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// UpdateTOTPSecret reemplaza el secreto TOTP guardado solo si sigue siendo oldValue.
+// Retorna false si el secreto cambió en el medio (por ejemplo, un nuevo enrolamiento).
+func (r *userRepository) UpdateTOTPSecret(userID uint, oldValue, newValue string) (bool, error) {
+	result := r.db.Model(&domain.User{}).
+		Where("id = ? AND totp_secret = ?", userID, oldValue).
+		Update("totp_secret", newValue)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// ReplaceRecoveryCodes reemplaza todos los códigos de recuperación de un usuario
+func (r *userRepository) ReplaceRecoveryCodes(userID uint, codes []domain.RecoveryCode) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&domain.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// UseRecoveryCode marca como usado un código de recuperación disponible.
+// Retorna false si no existe o ya fue usado.
+func (r *userRepository) UseRecoveryCode(userID uint, hash string) (bool, error) {
+	result := r.db.Model(&domain.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// SeedRoles crea los permisos y roles que falten con sus permisos iniciales.
+// Los roles existentes conservan sus permisos, salvo admin, que recibe cualquier permiso nuevo.
+func (r *userRepository) SeedRoles(defaults []domain.DefaultRole) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, name := range auth.AllPermissions {
+			if err := tx.FirstOrCreate(&domain.Permission{Name: name}).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, def := range defaults {
+			role := domain.Role{Name: def.Name}
+			result := tx.Where(domain.Role{Name: def.Name}).
+				Attrs(domain.Role{Description: def.Description}).
+				FirstOrCreate(&role)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 && def.Name != auth.RoleAdmin {
+				continue
+			}
+
+			for _, permission := range def.Permissions {
+				link := domain.RolePermission{RoleName: def.Name, PermissionName: permission}
+				if err := tx.FirstOrCreate(&link).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// ListRoles obtiene todos los roles
+func (r *userRepository) ListRoles() ([]domain.Role, error) {
+	var roles []domain.Role
+	result := r.db.Order("name ASC").Find(&roles)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return roles, nil
+}
+
+// GetRolePermissions obtiene los permisos de un rol
+func (r *userRepository) GetRolePermissions(role string) ([]string, error) {
+	var permissions []string
+	result := r.db.Model(&domain.RolePermission{}).
+		Where("role_name = ?", role).
+		Order("permission_name ASC").
+		Pluck("permission_name", &permissions)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return permissions, nil
+}
+
+// SetRolePermissions reemplaza los permisos de un rol en una sola transacción
+func (r *userRepository) SetRolePermissions(role string, permissions []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&domain.Role{}).Where("name = ?", role).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return errors.New("role not found")
+		}
+
+		if err := tx.Where("role_name = ?", role).Delete(&domain.RolePermission{}).Error; err != nil {
+			return err
+		}
+		if len(permissions) == 0 {
+			return nil
+		}
+
+		links := make([]domain.RolePermission, len(permissions))
+		for i, permission := range permissions {
+			links[i] = domain.RolePermission{RoleName: role, PermissionName: permission}
+		}
+		return tx.Create(&links).Error
+	})
+}
+
+// CreateOIDCState guarda el state de un login OIDC en curso
+func (r *userRepository) CreateOIDCState(state *domain.OIDCState) error {
+	result := r.db.Create(state)
+	return result.Error
+}
+
+// GetOIDCStateByHash obtiene un state OIDC por el hash de su valor
+func (r *userRepository) GetOIDCStateByHash(hash string) (*domain.OIDCState, error) {
+	var state domain.OIDCState
+	result := r.db.Where("state_hash = ?", hash).First(&state)
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errors.New("oidc state not found")
+		}
+		return nil, result.Error
+	}
+
+	return &state, nil
+}
+
+// ConsumeOIDCState marca el state como usado solo si seguía disponible.
+// Retorna false si ya fue usado o venció, así un mismo callback no puede canjearse dos veces.
+func (r *userRepository) ConsumeOIDCState(id uint) (bool, error) {
+	now := time.Now()
+	result := r.db.Model(&domain.OIDCState{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", id, now).
+		Update("used_at", now)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// GetIdentity obtiene la identidad externa de un proveedor por su subject
+func (r *userRepository) GetIdentity(provider, subject string) (*domain.Identity, error) {
+	var identity domain.Identity
+	result := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity)
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errors.New("identity not found")
+		}
+		return nil, result.Error
+	}
+
+	return &identity, nil
+}
+
+// CreateIdentity vincula una identidad externa a un usuario existente
+func (r *userRepository) CreateIdentity(identity *domain.Identity) error {
+	result := r.db.Create(identity)
+	return result.Error
+}
+
+// CreateWithIdentity crea el usuario y su identidad externa en una sola transacción
+func (r *userRepository) CreateWithIdentity(user *domain.User, identity *domain.Identity) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
+
+// TouchIdentity registra el último login con la identidad
+func (r *userRepository) TouchIdentity(id uint) error {
+	result := r.db.Model(&domain.Identity{}).
+		Where("id = ?", id).
+		Update("last_login_at", time.Now())
+	return result.Error
+}
+
+// CreateAuditEntry agrega un registro al log de auditoría
+func (r *userRepository) CreateAuditEntry(entry *domain.AuditEntry) error {
+	result := r.db.Create(entry)
+	return result.Error
+}
+
+// ListAuditEntries obtiene una página del log filtrado, del más nuevo al más viejo, y el total que cumple el filtro
+func (r *userRepository) ListAuditEntries(filter AuditFilter, offset, limit int) ([]domain.AuditEntry, int64, error) {
+	query := r.db.Model(&domain.AuditEntry{})
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.TargetID != 0 {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at <= ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var entries []domain.AuditEntry
+	result := query.
+		Order("id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&entries)
+
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return entries, total, nil
+}
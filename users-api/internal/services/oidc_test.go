@@ -0,0 +1,262 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"shared/auth"
+	"users-api/config"
+	"users-api/internal/dto"
+	"users-api/internal/mailer"
+	"users-api/internal/oidc"
+	"users-api/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const fakeClientID = "canchas-test"
+
+// fakeUser es la cuenta con la que el usuario "inicia sesión" en el proveedor falso
+type fakeUser struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+}
+
+type fakeAuthorization struct {
+	challenge   string
+	nonce       string
+	redirectURI string
+	user        fakeUser
+}
+
+// fakeOIDCProvider es un proveedor OIDC local: discovery, JWKS y token endpoint con PKCE.
+// El paso de consentimiento se simula con approve, sin navegador.
+type fakeOIDCProvider struct {
+	server *httptest.Server
+	key    ed25519.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]fakeAuthorization
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("no se pudo generar la clave: %v", err)
+	}
+
+	f := &fakeOIDCProvider{key: key, codes: map[string]fakeAuthorization{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 f.server.URL,
+			"authorization_endpoint": f.server.URL + "/authorize",
+			"token_endpoint":         f.server.URL + "/token",
+			"jwks_uri":               f.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		jwk, _ := auth.NewJWK("fake-1", key.Public())
+		_ = json.NewEncoder(w).Encode(auth.JWKS{Keys: []auth.JWK{jwk}})
+	})
+	mux.HandleFunc("/token", f.token)
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// approve simula que el usuario aceptó el login en el proveedor y retorna el code y el state de la redirección
+func (f *fakeOIDCProvider) approve(t *testing.T, authURL string, user fakeUser) (string, string) {
+	t.Helper()
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("URL de autorización inválida: %v", err)
+	}
+	q := parsed.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != fakeClientID || q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("parámetros de autorización inesperados: %v", q)
+	}
+	if q.Get("code_challenge") == "" || q.Get("nonce") == "" || q.Get("state") == "" {
+		t.Fatalf("faltan challenge, nonce o state: %v", q)
+	}
+
+	code, _ := utils.GenerateOpaqueToken()
+	f.mu.Lock()
+	f.codes[code] = fakeAuthorization{
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		redirectURI: q.Get("redirect_uri"),
+		user:        user,
+	}
+	f.mu.Unlock()
+	return code, q.Get("state")
+}
+
+func (f *fakeOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "authorization_code" || r.Form.Get("client_id") != fakeClientID {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	authz, ok := f.codes[r.Form.Get("code")]
+	delete(f.codes, r.Form.Get("code")) // los códigos son de un solo uso
+	f.mu.Unlock()
+
+	if !ok || authz.redirectURI != r.Form.Get("redirect_uri") || oidc.CodeChallenge(r.Form.Get("code_verifier")) != authz.challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{
+		"iss":                f.server.URL,
+		"aud":                fakeClientID,
+		"sub":                authz.user.Subject,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              authz.nonce,
+		"email":              authz.user.Email,
+		"email_verified":     authz.user.EmailVerified,
+		"preferred_username": authz.user.Username,
+	})
+	token.Header["kid"] = "fake-1"
+	signed, err := token.SignedString(f.key)
+	if err != nil {
+		http.Error(w, `{"error":"server_error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "unused", "token_type": "Bearer", "id_token": signed})
+}
+
+func (f *fakeOIDCProvider) provider() oidc.Provider {
+	return oidc.NewProvider(oidc.Config{
+		Name:        "fake",
+		Issuer:      f.server.URL,
+		ClientID:    fakeClientID,
+		RedirectURL: "http://front/auth/fake/callback",
+	})
+}
+
+// oidcLogin recorre el flujo completo: authorize, consentimiento en el proveedor y callback
+func oidcLogin(t *testing.T, svc UserService, fake *fakeOIDCProvider, user fakeUser) (*dto.LoginResponse, error) {
+	t.Helper()
+	start, err := svc.OIDCAuthorize("fake")
+	if err != nil {
+		t.Fatalf("authorize falló: %v", err)
+	}
+	code, state := fake.approve(t, start.AuthorizationURL, user)
+	return svc.OIDCCallback("fake", &dto.OIDCCallbackRequest{Code: code, State: state}, testMeta)
+}
+
+func TestOIDCLoginCreatesUserAndReusesIdentity(t *testing.T) {
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{JWTExpirationHours: 1}
+	fake := newFakeOIDCProvider(t)
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, []oidc.Provider{fake.provider()})
+	player := fakeUser{Subject: "sub-123", Email: "Ana@example.com", EmailVerified: true, Username: "Ana Pérez"}
+
+	first, err := oidcLogin(t, svc, fake, player)
+	if err != nil {
+		t.Fatalf("login OIDC falló: %v", err)
+	}
+	if first.User.Username != "anaprez" || first.User.Role != auth.RoleNormal {
+		t.Fatalf("usuario creado inesperado: %+v", first.User)
+	}
+	if u, _ := repo.GetByID(first.User.ID); u.VerifiedAt == nil {
+		t.Fatalf("el email verificado por el proveedor debería marcar la cuenta como verificada")
+	}
+
+	claims, err := utils.ValidateToken(first.Token)
+	if err != nil || len(claims.AMR) != 1 || claims.AMR[0] != auth.AMRFederated {
+		t.Fatalf("se esperaba amr [fed], claims: %+v, error: %v", claims, err)
+	}
+
+	// El mismo subject vuelve a la misma cuenta
+	second, err := oidcLogin(t, svc, fake, player)
+	if err != nil || second.User.ID != first.User.ID {
+		t.Fatalf("se esperaba el mismo usuario, llegó %+v, error: %v", second, err)
+	}
+	if len(repo.users) != 1 || len(repo.identities) != 1 {
+		t.Fatalf("no deberían crearse usuarios ni identidades nuevas: %d usuarios, %d identidades", len(repo.users), len(repo.identities))
+	}
+
+	// La renovación conserva el método de login
+	refreshed, err := svc.Refresh(&dto.RefreshRequest{RefreshToken: second.RefreshToken})
+	if err != nil {
+		t.Fatalf("refresh falló: %v", err)
+	}
+	claims, _ = utils.ValidateToken(refreshed.Token)
+	if len(claims.AMR) != 1 || claims.AMR[0] != auth.AMRFederated {
+		t.Fatalf("el refresh debería conservar amr [fed], llegó %v", claims.AMR)
+	}
+}
+
+func TestOIDCCallbackRejectsReplayAndWrongVerifier(t *testing.T) {
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{JWTExpirationHours: 1}
+	fake := newFakeOIDCProvider(t)
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, []oidc.Provider{fake.provider()})
+	player := fakeUser{Subject: "sub-1", Email: "p@example.com", EmailVerified: true}
+
+	if _, err := svc.OIDCAuthorize("otro"); err == nil || err.Error() != "unknown provider" {
+		t.Fatalf("se esperaba unknown provider, llegó %v", err)
+	}
+
+	start, _ := svc.OIDCAuthorize("fake")
+	code, state := fake.approve(t, start.AuthorizationURL, player)
+	if _, err := svc.OIDCCallback("fake", &dto.OIDCCallbackRequest{Code: code, State: state}, testMeta); err != nil {
+		t.Fatalf("login OIDC falló: %v", err)
+	}
+
+	// El state es de un solo uso
+	if _, err := svc.OIDCCallback("fake", &dto.OIDCCallbackRequest{Code: code, State: state}, testMeta); err == nil || err.Error() != "invalid or expired state" {
+		t.Fatalf("se esperaba invalid or expired state al reutilizar, llegó %v", err)
+	}
+
+	// Sin el code_verifier correcto el proveedor no entrega el id_token
+	start, _ = svc.OIDCAuthorize("fake")
+	code, state = fake.approve(t, start.AuthorizationURL, player)
+	stored, _ := repo.GetOIDCStateByHash(utils.HashToken(state))
+	stored.CodeVerifier = "otro-verifier-que-no-corresponde-al-challenge"
+	if _, err := svc.OIDCCallback("fake", &dto.OIDCCallbackRequest{Code: code, State: state}, testMeta); err == nil || err.Error() != "oidc login failed" {
+		t.Fatalf("se esperaba oidc login failed con un verifier ajeno, llegó %v", err)
+	}
+}
+
+func TestOIDCLinksExistingAccountOnlyWithVerifiedEmail(t *testing.T) {
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{JWTExpirationHours: 1}
+	fake := newFakeOIDCProvider(t)
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, []oidc.Provider{fake.provider()})
+
+	existing, err := svc.Register(&dto.RegisterRequest{Username: "bruno", Email: "bruno@example.com", Password: "Pase-Largo-1234", FirstName: "Bruno", LastName: "Díaz"})
+	if err != nil {
+		t.Fatalf("register falló: %v", err)
+	}
+
+	// Un email sin verificar en el proveedor no alcanza para tomar la cuenta
+	_, err = oidcLogin(t, svc, fake, fakeUser{Subject: "sub-b", Email: "bruno@example.com", EmailVerified: false})
+	if err == nil || err.Error() != "email already registered" {
+		t.Fatalf("se esperaba email already registered, llegó %v", err)
+	}
+
+	linked, err := oidcLogin(t, svc, fake, fakeUser{Subject: "sub-b", Email: "bruno@example.com", EmailVerified: true})
+	if err != nil || linked.User.ID != existing.ID {
+		t.Fatalf("se esperaba vincular la cuenta existente, llegó %+v, error: %v", linked, err)
+	}
+	if identity, err := repo.GetIdentity("fake", "sub-b"); err != nil || identity.UserID != existing.ID {
+		t.Fatalf("la identidad debería quedar vinculada al usuario %d: %+v, %v", existing.ID, identity, err)
+	}
+}
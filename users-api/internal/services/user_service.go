@@ -1,33 +1,233 @@
 package services
 
 import (
+	"crypto/subtle"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"shared/auth"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"users-api/config"
+	"users-api/internal/clients"
 	"users-api/internal/domain"
 	"users-api/internal/dto"
+	"users-api/internal/limiter"
+	"users-api/internal/mailer"
+	"users-api/internal/messaging"
+	"users-api/internal/oidc"
+	"users-api/internal/password"
 	"users-api/internal/repositories"
 	"users-api/utils"
+
+	"gorm.io/gorm"
 )
 
 type UserService interface {
-	Register(req *dto.RegisterRequest, role string) (*dto.UserResponse, error)
-	Login(req *dto.LoginRequest) (*dto.LoginResponse, error)
+	Register(req *dto.RegisterRequest) (*dto.UserResponse, error)
+	BootstrapAdmin(req *dto.BootstrapRequest) (*dto.UserResponse, error)
+	CreateFirstAdmin(req *dto.RegisterRequest) (*dto.UserResponse, error)
+	CreateInvitation(req *dto.CreateInvitationRequest, createdBy uint) (*dto.InvitationResponse, error)
+	Login(req *dto.LoginRequest, meta dto.RequestMeta) (*dto.LoginResponse, error)
+	Refresh(req *dto.RefreshRequest) (*dto.LoginResponse, error)
+	Logout(req *dto.RefreshRequest) error
+	ForgotPassword(req *dto.ForgotPasswordRequest) error
+	ResetPassword(req *dto.ResetPasswordRequest, meta dto.RequestMeta) error
+	VerifyEmail(req *dto.VerifyEmailRequest) error
+	SendVerificationEmail(userID uint) error
+	EnrollTwoFactor(userID uint) (*dto.TwoFactorEnrollResponse, error)
+	ConfirmTwoFactor(userID uint, req *dto.TwoFactorCodeRequest, meta dto.RequestMeta) (*dto.RecoveryCodesResponse, error)
+	DisableTwoFactor(userID uint, req *dto.DisableTwoFactorRequest, meta dto.RequestMeta) error
+	RegenerateRecoveryCodes(userID uint, req *dto.TwoFactorCodeRequest) (*dto.RecoveryCodesResponse, error)
+	LoginTwoFactor(req *dto.TwoFactorLoginRequest, meta dto.RequestMeta) (*dto.LoginResponse, error)
+	UnlockUser(id uint, meta dto.RequestMeta) error
+	LockoutStatus(id uint) (*dto.LockoutStatus, error)
 	GetByID(id uint) (*dto.UserResponse, error)
-	GetAll() (*dto.UsersListResponse, error)
-	Update(id uint, req *dto.RegisterRequest) (*dto.UserResponse, error)
-	Delete(id uint) error
+	GetAll(query *dto.UserListQuery) (*dto.UsersListResponse, error)
+	Export(query *dto.UserExportQuery) (*dto.UsersExportResponse, error)
+	Update(id uint, req *dto.UpdateProfileRequest) (*dto.UserResponse, error)
+	ChangePassword(userID uint, req *dto.ChangePasswordRequest, meta dto.RequestMeta) error
+	GetProfile(userID uint, accessToken string) (*dto.ProfileResponse, error)
+	Delete(id uint, meta dto.RequestMeta) error
+	Deactivate(id uint, meta dto.RequestMeta) error
+	Reactivate(id uint, meta dto.RequestMeta) error
+	Erase(id uint, meta dto.RequestMeta) error
+	NotifyWaitlistOffer(offer *dto.WaitlistOfferNotification) error
+	ListRoles() (*dto.RolesListResponse, error)
+	SetRolePermissions(role string, req *dto.SetRolePermissionsRequest, meta dto.RequestMeta) (*dto.RoleResponse, error)
+	AssignRole(userID uint, req *dto.AssignRoleRequest, meta dto.RequestMeta) (*dto.UserResponse, error)
+	OIDCProviders() *dto.OIDCProvidersResponse
+	OIDCAuthorize(provider string) (*dto.OIDCAuthorizeResponse, error)
+	OIDCCallback(provider string, req *dto.OIDCCallbackRequest, meta dto.RequestMeta) (*dto.LoginResponse, error)
+	ListAudit(query *dto.AuditListQuery) (*dto.AuditListResponse, error)
+}
+
+const (
+	defaultPageSize   = 20
+	maxPageSize       = 100
+	defaultExportSize = 500
+	maxExportSize     = 1000
+	defaultUserSort   = "-created_at"
+
+	// recoveryCodeCount es la cantidad de códigos de recuperación emitidos al activar 2FA
+	recoveryCodeCount = 10
+	// maxChallengeAttempts es la cantidad de códigos erróneos que invalida un challenge de login
+	maxChallengeAttempts = 5
+	// maxUsernameAttempts es la cantidad de sufijos numéricos que se prueban al derivar un username
+	maxUsernameAttempts = 20
+)
+
+// ThrottledError indica que el login está frenado por demasiados intentos fallidos
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return "too many login attempts"
 }
 
 type userService struct {
-	repo repositories.UserRepository
+	repo          repositories.UserRepository
+	mailer        mailer.Mailer
+	guard         *limiter.Guard
+	policy        *password.Policy
+	publisher     messaging.RabbitMQPublisher
+	reservaClient clients.ReservaClient
+	oidcProviders map[string]oidc.Provider
 }
 
 // NewUserService crea una nueva instancia del servicio
-func NewUserService(repo repositories.UserRepository) UserService {
-	return &userService{repo: repo}
+func NewUserService(
+	repo repositories.UserRepository,
+	m mailer.Mailer,
+	guard *limiter.Guard,
+	policy *password.Policy,
+	publisher messaging.RabbitMQPublisher,
+	reservaClient clients.ReservaClient,
+	oidcProviders []oidc.Provider,
+) UserService {
+	if policy == nil {
+		policy = password.DefaultPolicy()
+	}
+
+	providers := make(map[string]oidc.Provider, len(oidcProviders))
+	for _, provider := range oidcProviders {
+		providers[provider.Name()] = provider
+	}
+
+	return &userService{
+		repo:          repo,
+		mailer:        m,
+		guard:         guard,
+		policy:        policy,
+		publisher:     publisher,
+		reservaClient: reservaClient,
+		oidcProviders: providers,
+	}
+}
+
+// Register registra un nuevo usuario y le envía el email de verificación.
+// El rol es "normal" salvo que se presente una invitación válida, en cuyo caso se usa el rol de la invitación.
+func (s *userService) Register(req *dto.RegisterRequest) (*dto.UserResponse, error) {
+	response, err := s.register(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// El usuario ya quedó creado: si el envío falla puede pedir el reenvío más tarde
+	if err := s.SendVerificationEmail(response.ID); err != nil {
+		log.Printf("Error sending verification email to user %d: %v", response.ID, err)
+	}
+
+	return response, nil
+}
+
+func (s *userService) register(req *dto.RegisterRequest) (*dto.UserResponse, error) {
+	if req.InvitationCode == "" {
+		return s.createUser(req, auth.RoleNormal, nil)
+	}
+
+	invitation, err := s.repo.GetInvitationByCodeHash(utils.HashToken(req.InvitationCode))
+	if err != nil {
+		return nil, errors.New("invalid invitation code")
+	}
+	if invitation.IsRedeemed() {
+		return nil, errors.New("invitation already used")
+	}
+	if invitation.IsExpired(time.Now()) {
+		return nil, errors.New("invitation expired")
+	}
+	if invitation.Email != "" && !strings.EqualFold(invitation.Email, req.Email) {
+		return nil, errors.New("invitation email mismatch")
+	}
+
+	return s.createUser(req, invitation.Role, invitation)
+}
+
+// BootstrapAdmin crea el primer admin validando el token de instalación (BOOTSTRAP_TOKEN)
+func (s *userService) BootstrapAdmin(req *dto.BootstrapRequest) (*dto.UserResponse, error) {
+	expected := config.AppConfig.BootstrapToken
+	if expected == "" {
+		return nil, errors.New("bootstrap disabled")
+	}
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(req.SetupToken)) != 1 {
+		return nil, errors.New("invalid setup token")
+	}
+
+	return s.CreateFirstAdmin(&req.RegisterRequest)
+}
+
+// CreateFirstAdmin crea un admin solo si todavía no existe ninguno.
+// Lo usan el endpoint de bootstrap y el comando "bootstrap-admin".
+// El chequeo y la creación van en la misma transacción (ver createUser).
+func (s *userService) CreateFirstAdmin(req *dto.RegisterRequest) (*dto.UserResponse, error) {
+	return s.createUser(req, auth.RoleAdmin, nil)
+}
+
+// CreateInvitation emite un código de invitación para registrarse con un rol dado
+func (s *userService) CreateInvitation(req *dto.CreateInvitationRequest, createdBy uint) (*dto.InvitationResponse, error) {
+	code, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, errors.New("error generating invitation code")
+	}
+
+	hours := req.ExpiresInHours
+	if hours <= 0 {
+		hours = config.AppConfig.InvitationTTLHours
+	}
+	if hours <= 0 {
+		hours = 72
+	}
+
+	invitation := &domain.Invitation{
+		CodeHash:  utils.HashToken(code),
+		Role:      req.Role,
+		Email:     req.Email,
+		CreatedBy: createdBy,
+		ExpiresAt: time.Now().Add(time.Duration(hours) * time.Hour),
+	}
+
+	if err := s.repo.CreateInvitation(invitation); err != nil {
+		return nil, err
+	}
+
+	return &dto.InvitationResponse{
+		ID:        invitation.ID,
+		Code:      code,
+		Role:      invitation.Role,
+		Email:     invitation.Email,
+		ExpiresAt: invitation.ExpiresAt,
+	}, nil
 }
 
-// Register registra un nuevo usuario
-func (s *userService) Register(req *dto.RegisterRequest, role string) (*dto.UserResponse, error) {
+// createUser valida unicidad, hashea la contraseña y persiste el usuario.
+// Si viene una invitación se marca como usada en la misma transacción.
+// Un admin sin invitación es el primer admin: se crea solo si todavía no existe ninguno.
+func (s *userService) createUser(req *dto.RegisterRequest, role string, invitation *domain.Invitation) (*dto.UserResponse, error) {
 	// Validar si el username ya existe
 	exists, err := s.repo.ExistsByUsername(req.Username)
 	if err != nil {
@@ -46,6 +246,10 @@ func (s *userService) Register(req *dto.RegisterRequest, role string) (*dto.User
 		return nil, errors.New("email already exists")
 	}
 
+	if err := s.policy.Validate(req.Password); err != nil {
+		return nil, err
+	}
+
 	// Hashear la contraseña
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
@@ -62,108 +266,1630 @@ func (s *userService) Register(req *dto.RegisterRequest, role string) (*dto.User
 		Role:      role,
 	}
 
-	if err := s.repo.Create(user); err != nil {
+	switch {
+	case invitation != nil:
+		err = s.repo.CreateWithInvitation(user, invitation.ID)
+	case role == auth.RoleAdmin:
+		err = s.repo.CreateFirstAdmin(user)
+	default:
+		err = s.repo.Create(user)
+	}
+	if err != nil {
 		return nil, err
 	}
 
 	return s.domainToResponse(user), nil
 }
 
-// Login valida las credenciales y retorna un token
-func (s *userService) Login(req *dto.LoginRequest) (*dto.LoginResponse, error) {
+// Login valida las credenciales y retorna un token.
+// Los fallos se cuentan por cuenta y por IP; al superar los umbrales se frena con backoff y luego con bloqueo.
+func (s *userService) Login(req *dto.LoginRequest, meta dto.RequestMeta) (*dto.LoginResponse, error) {
 	// Buscar usuario por username o email
 	user, err := s.repo.GetByUsernameOrEmail(req.Login)
-	if err != nil {
-		return nil, errors.New("invalid credentials")
+
+	// Los logins inexistentes también se cuentan, así la respuesta no revela si la cuenta existe
+	account := unknownLoginKey(req.Login)
+	if err == nil {
+		account = lockoutKey(user.ID)
+	}
+
+	if err := s.checkThrottle(account, meta.IP); err != nil {
+		return nil, err
 	}
 
 	// Verificar la contraseña
-	if !utils.CheckPasswordHash(req.Password, user.Password) {
+	if err != nil || !utils.CheckPasswordHash(req.Password, user.Password) {
+		s.recordFailure(account, meta.IP)
+		var targetID uint
+		if user != nil {
+			targetID = user.ID
+		}
+		s.audit(meta, domain.AuditLoginFailed, targetID, "invalid credentials")
 		return nil, errors.New("invalid credentials")
 	}
+	s.upgradePasswordHash(user, req.Password)
 
-	// Generar token JWT
-	token, err := utils.GenerateToken(user)
+	if user.IsDeactivated() {
+		s.audit(meta, domain.AuditLoginFailed, user.ID, "account deactivated")
+		return nil, errors.New("account deactivated")
+	}
+
+	if config.AppConfig.RequireEmailVerification && user.VerifiedAt == nil {
+		return nil, errors.New("email not verified")
+	}
+
+	// Con 2FA activo la contraseña sola no alcanza: se emite un challenge de corta duración
+	if user.TwoFactorEnabled() {
+		return s.loginChallenge(user)
+	}
+
+	response, err := s.startSession(user, []string{auth.AMRPassword})
 	if err != nil {
-		return nil, errors.New("error generating token")
+		return nil, err
+	}
+	s.recordSuccess(account)
+	meta.ActorID = user.ID
+	s.audit(meta, domain.AuditLoginSucceeded, user.ID, "amr=pwd")
+
+	// Los admins deben activar 2FA: las rutas de admin rechazan sesiones sin segundo factor
+	response.TwoFactorSetupRequired = user.Role == auth.RoleAdmin
+	return response, nil
+}
+
+// upgradePasswordHash vuelve a hashear la contraseña si BCRYPT_COST cambió desde que se guardó el hash.
+// Solo se puede hacer en el login, que es cuando se tiene la contraseña en claro. Un fallo no impide el login.
+func (s *userService) upgradePasswordHash(user *domain.User, plain string) {
+	if !utils.NeedsRehash(user.Password) {
+		return
+	}
+
+	hashed, err := utils.HashPassword(plain)
+	if err != nil {
+		log.Printf("Error rehashing password of user %d: %v", user.ID, err)
+		return
+	}
+	// Condicional al hash viejo: no pisa un cambio de contraseña hecho en paralelo
+	if _, err := s.repo.UpdatePasswordHash(user.ID, user.Password, hashed); err != nil {
+		log.Printf("Error saving rehashed password of user %d: %v", user.ID, err)
+		return
+	}
+	user.Password = hashed
+}
+
+// loginChallenge emite el challenge de corta duración que se canjea en POST /users/login/2fa
+func (s *userService) loginChallenge(user *domain.User) (*dto.LoginResponse, error) {
+	minutes := config.AppConfig.TwoFactorChallengeMinutes
+	if minutes <= 0 {
+		minutes = 5
+	}
+
+	challenge, err := s.issueUserToken(user.ID, domain.TokenPurposeLoginChallenge, time.Duration(minutes)*time.Minute)
+	if err != nil {
+		return nil, err
 	}
 
 	return &dto.LoginResponse{
-		Token: token,
-		User:  *s.domainToResponse(user),
+		TwoFactorRequired: true,
+		ChallengeToken:    challenge,
 	}, nil
 }
 
-// GetByID obtiene un usuario por su ID
-func (s *userService) GetByID(id uint) (*dto.UserResponse, error) {
-	user, err := s.repo.GetByID(id)
+// LoginTwoFactor completa el login canjeando el challenge con un código TOTP o de recuperación
+// Los códigos erróneos cuentan como fallos de login de la cuenta.
+func (s *userService) LoginTwoFactor(req *dto.TwoFactorLoginRequest, meta dto.RequestMeta) (*dto.LoginResponse, error) {
+	challenge, err := s.repo.GetUserTokenByHash(utils.HashToken(req.ChallengeToken), domain.TokenPurposeLoginChallenge)
+	if err != nil || !challenge.IsUsable(time.Now()) {
+		return nil, errors.New("invalid or expired challenge")
+	}
+
+	user, err := s.repo.GetByID(challenge.UserID)
+	if err != nil || !user.TwoFactorEnabled() || user.IsDeactivated() {
+		return nil, errors.New("invalid or expired challenge")
+	}
+
+	account := lockoutKey(user.ID)
+	if err := s.checkThrottle(account, meta.IP); err != nil {
+		return nil, err
+	}
+
+	if err := s.verifySecondFactor(user, req.Code, true); err != nil {
+		s.recordFailure(account, meta.IP)
+		s.audit(meta, domain.AuditLoginFailed, user.ID, "invalid two-factor code")
+		if err := s.repo.RecordUserTokenFailure(challenge.ID, maxChallengeAttempts); err != nil {
+			return nil, err
+		}
+		return nil, err
+	}
+
+	consumed, err := s.repo.ConsumeUserToken(challenge.ID)
 	if err != nil {
 		return nil, err
 	}
+	if !consumed {
+		return nil, errors.New("invalid or expired challenge")
+	}
 
-	return s.domainToResponse(user), nil
+	response, err := s.startSession(user, []string{auth.AMRPassword, auth.AMROTP})
+	if err != nil {
+		return nil, err
+	}
+	s.recordSuccess(account)
+	meta.ActorID = user.ID
+	s.audit(meta, domain.AuditLoginSucceeded, user.ID, "amr=pwd,otp")
+
+	return response, nil
+}
+
+// UnlockUser borra los fallos y el bloqueo de login de un usuario (SOLO ADMIN)
+func (s *userService) UnlockUser(id uint, meta dto.RequestMeta) error {
+	if _, err := s.repo.GetByID(id); err != nil {
+		return err
+	}
+	if err := s.guard.Unlock(lockoutKey(id)); err != nil {
+		return err
+	}
+
+	s.audit(meta, domain.AuditUserUnlocked, id, "")
+	return nil
 }
 
-// GetAll obtiene todos los usuarios
-func (s *userService) GetAll() (*dto.UsersListResponse, error) {
-	users, err := s.repo.GetAll()
+// LockoutStatus retorna el estado de bloqueo de login de un usuario
+func (s *userService) LockoutStatus(id uint) (*dto.LockoutStatus, error) {
+	status, err := s.guard.AccountStatus(lockoutKey(id))
 	if err != nil {
 		return nil, err
 	}
 
-	userResponses := make([]dto.UserResponse, len(users))
-	for i, user := range users {
-		userResponses[i] = *s.domainToResponse(&user)
+	response := &dto.LockoutStatus{
+		FailedAttempts: status.Failures,
+		Locked:         status.Locked && status.Blocked(time.Now()),
+	}
+	if status.Blocked(time.Now()) {
+		response.BlockedUntil = &status.BlockedUntil
 	}
+	return response, nil
+}
 
-	return &dto.UsersListResponse{
-		Users: userResponses,
-		Total: int64(len(users)),
+// checkThrottle rechaza el intento si la cuenta o la IP están en espera o bloqueadas.
+// Si el store no responde se deja pasar el intento para no bloquear a todos los usuarios.
+func (s *userService) checkThrottle(account, clientIP string) error {
+	wait, err := s.guard.RetryAfter(account, clientIP)
+	if err != nil {
+		log.Printf("Error checking login limiter: %v", err)
+		return nil
+	}
+	if wait > 0 {
+		return &ThrottledError{RetryAfter: wait}
+	}
+	return nil
+}
+
+func (s *userService) recordFailure(account, clientIP string) {
+	if err := s.guard.Fail(account, clientIP); err != nil {
+		log.Printf("Error recording failed login: %v", err)
+	}
+}
+
+func (s *userService) recordSuccess(account string) {
+	if err := s.guard.Succeed(account); err != nil {
+		log.Printf("Error resetting login limiter: %v", err)
+	}
+}
+
+// lockoutKey es la clave del limitador para la cuenta de un usuario
+func lockoutKey(userID uint) string {
+	return fmt.Sprintf("id:%d", userID)
+}
+
+// unknownLoginKey es la clave del limitador para un login que no corresponde a ninguna cuenta.
+// Se hashea porque el login es texto libre y Memcached rechaza claves con espacios o de más de 250 bytes.
+func unknownLoginKey(login string) string {
+	return "login:" + utils.HashToken(strings.ToLower(strings.TrimSpace(login)))
+}
+
+// EnrollTwoFactor genera un secreto TOTP nuevo. 2FA no se activa hasta confirmarlo con un código.
+func (s *userService) EnrollTwoFactor(userID uint) (*dto.TwoFactorEnrollResponse, error) {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled() {
+		return nil, errors.New("two-factor already enabled")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, errors.New("error generating secret")
+	}
+	sealed, err := sealTOTPSecret(user.ID, secret)
+	if err != nil {
+		if errors.Is(err, utils.ErrNoEncryptionKey) {
+			return nil, errors.New("two-factor encryption key not configured")
+		}
+		return nil, errors.New("error encrypting secret")
+	}
+
+	user.TOTPSecret = sealed
+	user.TOTPLastStep = 0
+	if err := s.repo.Update(user); err != nil {
+		return nil, err
+	}
+
+	return &dto.TwoFactorEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPURI(config.AppConfig.TOTPIssuer, user.Email, secret),
 	}, nil
 }
 
-// Update actualiza un usuario
-func (s *userService) Update(id uint, req *dto.RegisterRequest) (*dto.UserResponse, error) {
-	user, err := s.repo.GetByID(id)
+// ConfirmTwoFactor activa 2FA verificando un código del secreto enrolado y emite los códigos de recuperación
+func (s *userService) ConfirmTwoFactor(userID uint, req *dto.TwoFactorCodeRequest, meta dto.RequestMeta) (*dto.RecoveryCodesResponse, error) {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled() {
+		return nil, errors.New("two-factor already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("two-factor enrollment not started")
+	}
+
+	secret, err := s.openTOTPSecret(user)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := utils.ValidateTOTP(secret, req.Code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return nil, errors.New("invalid two-factor code")
+	}
+
+	now := time.Now()
+	user.TOTPEnabledAt = &now
+	user.TOTPLastStep = step
+	if err := s.repo.Update(user); err != nil {
+		return nil, err
+	}
+	s.audit(meta, domain.AuditTwoFactorEnabled, user.ID, "")
+
+	return s.newRecoveryCodes(user.ID)
+}
+
+// DisableTwoFactor desactiva 2FA pidiendo la contraseña y un código vigente
+func (s *userService) DisableTwoFactor(userID uint, req *dto.DisableTwoFactorRequest, meta dto.RequestMeta) error {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.TwoFactorEnabled() {
+		return errors.New("two-factor not enabled")
+	}
+	if !utils.CheckPasswordHash(req.Password, user.Password) {
+		return errors.New("invalid credentials")
+	}
+	if err := s.verifySecondFactor(user, req.Code, true); err != nil {
+		return err
+	}
+
+	user.TOTPSecret = ""
+	user.TOTPEnabledAt = nil
+	user.TOTPLastStep = 0
+	if err := s.repo.Update(user); err != nil {
+		return err
+	}
+	if err := s.repo.ReplaceRecoveryCodes(user.ID, nil); err != nil {
+		return err
+	}
+
+	s.audit(meta, domain.AuditTwoFactorDisabled, user.ID, "")
+	return nil
+}
+
+// RegenerateRecoveryCodes invalida los códigos de recuperación anteriores y emite nuevos
+func (s *userService) RegenerateRecoveryCodes(userID uint, req *dto.TwoFactorCodeRequest) (*dto.RecoveryCodesResponse, error) {
+	user, err := s.repo.GetByID(userID)
 	if err != nil {
 		return nil, err
 	}
+	if !user.TwoFactorEnabled() {
+		return nil, errors.New("two-factor not enabled")
+	}
+	if err := s.verifySecondFactor(user, req.Code, false); err != nil {
+		return nil, err
+	}
+
+	return s.newRecoveryCodes(user.ID)
+}
 
-	// Actualizar campos
-	user.FirstName = req.FirstName
-	user.LastName = req.LastName
-	user.Email = req.Email
+// verifySecondFactor valida un código TOTP (una sola vez por paso) o, si allowRecovery, un código de recuperación
+func (s *userService) verifySecondFactor(user *domain.User, code string, allowRecovery bool) error {
+	// Si el secreto no se puede descifrar (clave cambiada o perdida) todavía sirven los códigos de recuperación
+	secret, err := s.openTOTPSecret(user)
+	if err != nil {
+		log.Printf("Error decrypting TOTP secret of user %d: %v", user.ID, err)
+	} else if step, ok := utils.ValidateTOTP(secret, code, time.Now(), user.TOTPLastStep); ok {
+		updated, err := s.repo.UpdateTOTPLastStep(user.ID, step)
+		if err != nil {
+			return err
+		}
+		if updated {
+			return nil
+		}
+		return errors.New("invalid two-factor code")
+	}
 
-	// Si se proporciona una nueva contraseña, hashearla
-	if req.Password != "" {
-		hashedPassword, err := utils.HashPassword(req.Password)
+	if allowRecovery {
+		used, err := s.repo.UseRecoveryCode(user.ID, utils.HashToken(utils.NormalizeRecoveryCode(code)))
 		if err != nil {
-			return nil, errors.New("error hashing password")
+			return err
+		}
+		if used {
+			return nil
 		}
-		user.Password = hashedPassword
 	}
 
-	if err := s.repo.Update(user); err != nil {
+	return errors.New("invalid two-factor code")
+}
+
+// sealTOTPSecret cifra un secreto TOTP para guardarlo, ligado al usuario
+func sealTOTPSecret(userID uint, secret string) (string, error) {
+	return utils.EncryptString(config.AppConfig.TOTPEncryptionKey, secret, totpAAD(userID))
+}
+
+// openTOTPSecret descifra el secreto TOTP guardado del usuario.
+// Un secreto guardado en claro antes del cifrado se cifra en el momento; si eso falla igual se usa.
+func (s *userService) openTOTPSecret(user *domain.User) (string, error) {
+	secret, legacy, err := utils.DecryptString(config.AppConfig.TOTPEncryptionKey, user.TOTPSecret, totpAAD(user.ID))
+	if err != nil || !legacy {
+		return secret, err
+	}
+
+	sealed, err := sealTOTPSecret(user.ID, secret)
+	if err != nil {
+		log.Printf("Error encrypting legacy TOTP secret of user %d: %v", user.ID, err)
+		return secret, nil
+	}
+	// Condicional al valor viejo: no pisa un enrolamiento hecho en paralelo
+	if _, err := s.repo.UpdateTOTPSecret(user.ID, user.TOTPSecret, sealed); err != nil {
+		log.Printf("Error saving encrypted TOTP secret of user %d: %v", user.ID, err)
+		return secret, nil
+	}
+	user.TOTPSecret = sealed
+	return secret, nil
+}
+
+// totpAAD son los datos asociados del secreto cifrado: un secreto copiado a otro usuario no se descifra
+func totpAAD(userID uint) []byte {
+	return []byte(fmt.Sprintf("totp:%d", userID))
+}
+
+// newRecoveryCodes reemplaza los códigos de recuperación y retorna los valores en claro
+func (s *userService) newRecoveryCodes(userID uint) (*dto.RecoveryCodesResponse, error) {
+	codes := make([]string, recoveryCodeCount)
+	records := make([]domain.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return nil, errors.New("error generating recovery codes")
+		}
+		codes[i] = code
+		records[i] = domain.RecoveryCode{
+			UserID:   userID,
+			CodeHash: utils.HashToken(utils.NormalizeRecoveryCode(code)),
+		}
+	}
+
+	if err := s.repo.ReplaceRecoveryCodes(userID, records); err != nil {
 		return nil, err
 	}
 
-	return s.domainToResponse(user), nil
+	return &dto.RecoveryCodesResponse{RecoveryCodes: codes}, nil
 }
 
-// Delete elimina un usuario
-func (s *userService) Delete(id uint) error {
-	return s.repo.Delete(id)
+// startSession abre una nueva familia de refresh tokens y emite el primer par de tokens
+func (s *userService) startSession(user *domain.User, amr []string) (*dto.LoginResponse, error) {
+	familyID, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, errors.New("error generating token")
+	}
+
+	response, _, err := s.issueTokens(user, familyID, amr)
+	return response, err
 }
 
-// domainToResponse convierte un User del dominio a UserResponse DTO
-func (s *userService) domainToResponse(user *domain.User) *dto.UserResponse {
-	return &dto.UserResponse{
-		ID:        user.ID,
-		Username:  user.Username,
-		Email:     user.Email,
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
-		Role:      user.Role,
-		CreatedAt: user.CreatedAt,
+// Refresh rota un refresh token: revoca el actual y emite un nuevo par de tokens.
+// Si se presenta un token ya revocado se asume robo y se revoca toda su familia.
+func (s *userService) Refresh(req *dto.RefreshRequest) (*dto.LoginResponse, error) {
+	stored, err := s.repo.GetRefreshTokenByHash(utils.HashToken(req.RefreshToken))
+	if err != nil {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	if stored.IsRevoked() {
+		if err := s.repo.RevokeRefreshTokenFamily(stored.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("refresh token reuse detected")
+	}
+
+	if stored.IsExpired(time.Now()) {
+		return nil, errors.New("refresh token expired")
+	}
+
+	user, err := s.repo.GetByID(stored.UserID)
+	if err != nil || user.IsDeactivated() {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	amr := []string{auth.AMRPassword}
+	if stored.Method != "" {
+		amr = []string{stored.Method}
+	}
+	if stored.MFA {
+		amr = append(amr, auth.AMROTP)
+	}
+
+	response, newToken, err := s.issueTokens(user, stored.FamilyID, amr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Revocación condicional: si otro request rotó el mismo token en paralelo, es reutilización
+	revoked, err := s.repo.RevokeRefreshToken(stored.ID, &newToken.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !revoked {
+		if err := s.repo.RevokeRefreshTokenFamily(stored.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("refresh token reuse detected")
+	}
+
+	return response, nil
+}
+
+// Logout revoca el refresh token indicado. Es idempotente.
+func (s *userService) Logout(req *dto.RefreshRequest) error {
+	stored, err := s.repo.GetRefreshTokenByHash(utils.HashToken(req.RefreshToken))
+	if err != nil {
+		return errors.New("invalid refresh token")
+	}
+
+	if stored.IsRevoked() {
+		return nil
+	}
+
+	_, err = s.repo.RevokeRefreshToken(stored.ID, nil)
+	return err
+}
+
+// ForgotPassword envía un link de recuperación si el email existe.
+// No informa si el email está registrado para no permitir enumerar cuentas.
+func (s *userService) ForgotPassword(req *dto.ForgotPasswordRequest) error {
+	user, err := s.repo.GetByEmail(req.Email)
+	if err != nil {
+		return nil
+	}
+
+	minutes := config.AppConfig.PasswordResetTTLMinutes
+	if minutes <= 0 {
+		minutes = 30
+	}
+
+	token, err := s.issueUserToken(user.ID, domain.TokenPurposePasswordReset, time.Duration(minutes)*time.Minute)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Recuperá tu contraseña",
+		Body: fmt.Sprintf("Hola %s,\n\nRecibimos un pedido para cambiar tu contraseña. "+
+			"Usá este link dentro de los próximos %d minutos:\n\n%s\n\n"+
+			"Si no fuiste vos, ignorá este mensaje.\n",
+			user.FirstName, minutes, frontendLink("/reset-password", token)),
+	})
+}
+
+// ResetPassword fija una nueva contraseña canjeando un token de recuperación.
+// El token es de un solo uso y se cierran todas las sesiones abiertas del usuario.
+func (s *userService) ResetPassword(req *dto.ResetPasswordRequest, meta dto.RequestMeta) error {
+	// Se valida antes de canjear el token para que el usuario pueda reintentar con otra contraseña
+	if err := s.policy.Validate(req.NewPassword); err != nil {
+		return err
+	}
+
+	token, err := s.consumeUserToken(req.Token, domain.TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetByID(token.UserID)
+	if err != nil {
+		return errors.New("invalid or expired token")
+	}
+
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		return errors.New("error hashing password")
+	}
+	user.Password = hashedPassword
+
+	// Quien recibió el link controla el email
+	if user.VerifiedAt == nil {
+		now := time.Now()
+		user.VerifiedAt = &now
+	}
+
+	if err := s.repo.Update(user); err != nil {
+		return err
+	}
+	if err := s.repo.RevokeUserRefreshTokens(user.ID); err != nil {
+		return err
+	}
+
+	s.audit(meta, domain.AuditPasswordReset, user.ID, "")
+	return nil
+}
+
+// VerifyEmail confirma el email del usuario canjeando un token de verificación
+func (s *userService) VerifyEmail(req *dto.VerifyEmailRequest) error {
+	token, err := s.consumeUserToken(req.Token, domain.TokenPurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetByID(token.UserID)
+	if err != nil {
+		return errors.New("invalid or expired token")
+	}
+
+	if user.VerifiedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	user.VerifiedAt = &now
+	return s.repo.Update(user)
+}
+
+// SendVerificationEmail emite un nuevo token de verificación y lo envía por email.
+// Los tokens de verificación anteriores dejan de ser válidos.
+func (s *userService) SendVerificationEmail(userID uint) error {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if user.VerifiedAt != nil {
+		return errors.New("email already verified")
+	}
+
+	hours := config.AppConfig.EmailVerificationTTLHours
+	if hours <= 0 {
+		hours = 48
+	}
+
+	token, err := s.issueUserToken(user.ID, domain.TokenPurposeEmailVerification, time.Duration(hours)*time.Hour)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirmá tu email",
+		Body: fmt.Sprintf("Hola %s,\n\nConfirmá tu email ingresando a este link:\n\n%s\n\n"+
+			"El link vence en %d horas.\n",
+			user.FirstName, frontendLink("/verify-email", token), hours),
+	})
+}
+
+// NotifyWaitlistOffer avisa por email que se liberó el turno que el usuario esperaba.
+// Si la cuenta ya no existe o está desactivada no se envía nada: la retención vence sola.
+// Tampoco se avisa si la retención ya venció (el aviso se demoró en la cola): el turno pasó al siguiente.
+func (s *userService) NotifyWaitlistOffer(offer *dto.WaitlistOfferNotification) error {
+	if !offer.HoldExpiresAt.IsZero() && !time.Now().Before(offer.HoldExpiresAt) {
+		return nil
+	}
+
+	user, err := s.repo.GetByID(offer.UserID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if user.IsDeactivated() || user.ErasedAt != nil {
+		return nil
+	}
+
+	link := strings.TrimRight(config.AppConfig.FrontendURL, "/") + "/mis-reservas"
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Se liberó el turno que esperabas",
+		Body: fmt.Sprintf("Hola %s,\n\nSe liberó el turno de %s del %s de %s a %s y lo retuvimos a tu nombre. "+
+			"Confirmalo antes de las %s (UTC) desde:\n\n%s\n\n"+
+			"Si no lo confirmás a tiempo, se le ofrece a la siguiente persona de la lista de espera.\n",
+			user.FirstName, offer.CanchaName, offer.Date, offer.StartTime, offer.EndTime,
+			offer.HoldExpiresAt.UTC().Format("15:04"), link),
+	})
+}
+
+// issueUserToken invalida los tokens pendientes del mismo propósito y emite uno nuevo.
+// Retorna el valor en claro, que solo viaja por email.
+func (s *userService) issueUserToken(userID uint, purpose string, ttl time.Duration) (string, error) {
+	if err := s.repo.InvalidateUserTokens(userID, purpose); err != nil {
+		return "", err
+	}
+
+	value, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", errors.New("error generating token")
+	}
+
+	token := &domain.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(value),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.repo.CreateUserToken(token); err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
+// consumeUserToken valida y marca como usado un token de un solo uso
+func (s *userService) consumeUserToken(value, purpose string) (*domain.UserToken, error) {
+	token, err := s.repo.GetUserTokenByHash(utils.HashToken(value), purpose)
+	if err != nil || !token.IsUsable(time.Now()) {
+		return nil, errors.New("invalid or expired token")
+	}
+
+	consumed, err := s.repo.ConsumeUserToken(token.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, errors.New("invalid or expired token")
+	}
+
+	return token, nil
+}
+
+// frontendLink arma el link al frontend que recibe el token
+func frontendLink(path, token string) string {
+	return strings.TrimRight(config.AppConfig.FrontendURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// issueTokens genera un access token y un refresh token dentro de la familia indicada.
+// Retorna también el registro persistido del refresh token.
+func (s *userService) issueTokens(user *domain.User, familyID string, amr []string) (*dto.LoginResponse, *domain.RefreshToken, error) {
+	// Los permisos se leen en cada emisión: un cambio de rol o de permisos aplica desde el próximo refresh
+	permissions, err := s.repo.GetRolePermissions(user.Role)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Generar token JWT
+	token, err := utils.GenerateToken(user, amr, permissions)
+	if err != nil {
+		return nil, nil, errors.New("error generating token")
+	}
+
+	refreshToken, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, nil, errors.New("error generating token")
+	}
+
+	days := config.AppConfig.RefreshTokenDays
+	if days <= 0 {
+		days = 30
+	}
+
+	record := &domain.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(refreshToken),
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(time.Duration(days) * 24 * time.Hour),
+		MFA:       hasMFA(amr),
+		Method:    amr[0],
+	}
+	if err := s.repo.CreateRefreshToken(record); err != nil {
+		return nil, nil, err
+	}
+
+	return &dto.LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		User:         s.domainToResponse(user),
+	}, record, nil
+}
+
+// GetByID obtiene un usuario por su ID
+func (s *userService) GetByID(id uint) (*dto.UserResponse, error) {
+	user, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.domainToResponse(user), nil
+}
+
+// GetAll obtiene una página de usuarios según los filtros y el orden pedidos
+func (s *userService) GetAll(query *dto.UserListQuery) (*dto.UsersListResponse, error) {
+	filter, err := parseUserFilter(&query.UserFilterQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	page := query.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := query.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	sort := query.Sort
+	if sort == "" {
+		sort = defaultUserSort
+	}
+	if !repositories.ValidUserSort(sort) {
+		return nil, errors.New("invalid sort field")
+	}
+
+	users, total, err := s.repo.List(filter, sort, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.UsersListResponse{
+		Users:      s.adminResponses(users),
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+	}, nil
+}
+
+// Export recorre los usuarios en orden de ID con un cursor opaco, pensado para exportar todo el listado
+func (s *userService) Export(query *dto.UserExportQuery) (*dto.UsersExportResponse, error) {
+	filter, err := parseUserFilter(&query.UserFilterQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	var afterID uint
+	if query.Cursor != "" {
+		afterID, err = decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultExportSize
+	}
+	if limit > maxExportSize {
+		limit = maxExportSize
+	}
+
+	// Se pide una fila de más para saber si hay otra página sin una consulta extra
+	users, err := s.repo.ListAfter(filter, afterID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.UsersExportResponse{}
+	if len(users) > limit {
+		users = users[:limit]
+		response.NextCursor = encodeCursor(users[limit-1].ID)
+	}
+	response.Users = s.adminResponses(users)
+
+	return response, nil
+}
+
+// adminResponses convierte usuarios a DTO incluyendo su estado de bloqueo (solo para admins)
+func (s *userService) adminResponses(users []domain.User) []dto.UserResponse {
+	responses := make([]dto.UserResponse, len(users))
+	for i := range users {
+		responses[i] = *s.domainToResponse(&users[i])
+		if lockout, err := s.LockoutStatus(users[i].ID); err == nil {
+			responses[i].Lockout = lockout
+		}
+	}
+	return responses
+}
+
+// parseUserFilter valida los filtros del listado y los convierte al filtro del repositorio
+func parseUserFilter(query *dto.UserFilterQuery) (repositories.UserFilter, error) {
+	filter := repositories.UserFilter{
+		Role:   query.Role,
+		Prefix: strings.TrimSpace(query.Q),
+	}
+
+	if filter.Role != "" && !domain.IsKnownRole(filter.Role) {
+		return filter, errors.New("invalid role")
+	}
+
+	if query.CreatedFrom != "" {
+		from, _, err := parseDateBound(query.CreatedFrom)
+		if err != nil {
+			return filter, errors.New("invalid created_from")
+		}
+		filter.CreatedFrom = &from
+	}
+
+	if query.CreatedTo != "" {
+		to, dateOnly, err := parseDateBound(query.CreatedTo)
+		if err != nil {
+			return filter, errors.New("invalid created_to")
+		}
+		// Una fecha sin hora incluye el día completo
+		if dateOnly {
+			to = to.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		filter.CreatedTo = &to
+	}
+
+	return filter, nil
+}
+
+// parseDateBound acepta RFC 3339 o YYYY-MM-DD; indica si el valor era solo una fecha
+func parseDateBound(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	return t, true, err
+}
+
+// encodeCursor arma el cursor opaco del export a partir del último ID devuelto
+func encodeCursor(id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(id), 10)))
+}
+
+func decodeCursor(cursor string) (uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+	id, err := strconv.ParseUint(string(raw), 10, 32)
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+	return uint(id), nil
+}
+
+// Update actualiza solo los campos enviados del perfil.
+// Un email nuevo queda sin verificar y se envía el email de verificación; la contraseña se cambia con ChangePassword.
+func (s *userService) Update(id uint, req *dto.UpdateProfileRequest) (*dto.UserResponse, error) {
+	user, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Username != nil && *req.Username != user.Username {
+		exists, err := s.repo.ExistsByUsername(*req.Username)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, errors.New("username already exists")
+		}
+		user.Username = *req.Username
+	}
+
+	emailChanged := false
+	if req.Email != nil && *req.Email != user.Email {
+		// Un cambio solo de mayúsculas no es un email nuevo (y MySQL lo encontraría como existente)
+		if !strings.EqualFold(*req.Email, user.Email) {
+			exists, err := s.repo.ExistsByEmail(*req.Email)
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				return nil, errors.New("email already exists")
+			}
+			user.VerifiedAt = nil
+			emailChanged = true
+		}
+		user.Email = *req.Email
+	}
+
+	if req.FirstName != nil {
+		user.FirstName = *req.FirstName
+	}
+	if req.LastName != nil {
+		user.LastName = *req.LastName
+	}
+
+	if err := s.repo.Update(user); err != nil {
+		return nil, err
+	}
+
+	if emailChanged {
+		if err := s.SendVerificationEmail(user.ID); err != nil {
+			log.Printf("Error sending verification email to user %d: %v", user.ID, err)
+		}
+	}
+
+	return s.domainToResponse(user), nil
+}
+
+// ChangePassword cambia la contraseña verificando la actual y cierra todas las sesiones del usuario.
+// Los intentos con la contraseña actual equivocada cuentan como fallos de login.
+func (s *userService) ChangePassword(userID uint, req *dto.ChangePasswordRequest, meta dto.RequestMeta) error {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+
+	account := lockoutKey(user.ID)
+	if err := s.checkThrottle(account, meta.IP); err != nil {
+		return err
+	}
+
+	if !utils.CheckPasswordHash(req.CurrentPassword, user.Password) {
+		s.recordFailure(account, meta.IP)
+		return errors.New("invalid current password")
+	}
+
+	if req.NewPassword == req.CurrentPassword {
+		return errors.New("new password must be different")
+	}
+	if err := s.policy.Validate(req.NewPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		return errors.New("error hashing password")
+	}
+	user.Password = hashedPassword
+
+	if err := s.repo.Update(user); err != nil {
+		return err
+	}
+	if err := s.repo.RevokeUserRefreshTokens(user.ID); err != nil {
+		return err
+	}
+
+	s.audit(meta, domain.AuditPasswordChanged, user.ID, "")
+	return nil
+}
+
+// GetProfile obtiene el perfil del usuario y un resumen de sus reservas.
+// Si reservas-api no responde se devuelve el perfil sin el resumen.
+func (s *userService) GetProfile(userID uint, accessToken string) (*dto.ProfileResponse, error) {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &dto.ProfileResponse{User: *s.domainToResponse(user)}
+
+	reservas, err := s.reservaClient.GetByUserID(userID, accessToken)
+	if err != nil {
+		log.Printf("Warning: failed to load reservations for user %d: %v", userID, err)
+		return profile, nil
+	}
+	profile.Reservations = reservationStats(reservas, time.Now())
+
+	return profile, nil
+}
+
+// reservationStats resume las reservas; las de hoy cuentan como próximas
+func reservationStats(reservas []clients.ReservaResponse, now time.Time) *dto.ReservationStats {
+	today := now.UTC().Format("2006-01-02")
+	stats := &dto.ReservationStats{Total: len(reservas)}
+
+	for _, r := range reservas {
+		if r.Status == "cancelled" {
+			stats.Cancelled++
+			continue
+		}
+
+		stats.TotalSpent += r.TotalPrice
+		// Las fechas YYYY-MM-DD se ordenan igual como texto
+		if r.Date >= today {
+			stats.Upcoming++
+		} else {
+			stats.Past++
+		}
+	}
+
+	return stats
+}
+
+// Delete hace un borrado lógico del usuario y cierra sus sesiones
+func (s *userService) Delete(id uint, meta dto.RequestMeta) error {
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	if err := s.repo.RevokeUserRefreshTokens(id); err != nil {
+		return err
+	}
+
+	s.audit(meta, domain.AuditUserDeleted, id, "")
+	s.publishUserEvent(messaging.EventUserDeleted, id)
+	return nil
+}
+
+// Deactivate bloquea el login del usuario conservando sus datos y cierra sus sesiones
+func (s *userService) Deactivate(id uint, meta dto.RequestMeta) error {
+	user, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if user.IsDeactivated() {
+		return errors.New("user already deactivated")
+	}
+
+	now := time.Now()
+	user.DeactivatedAt = &now
+	if err := s.repo.Update(user); err != nil {
+		return err
+	}
+	if err := s.repo.RevokeUserRefreshTokens(id); err != nil {
+		return err
+	}
+
+	s.audit(meta, domain.AuditUserDeactivated, id, "")
+	s.publishUserEvent(messaging.EventUserDeactivated, id)
+	return nil
+}
+
+// Reactivate vuelve a habilitar el login de un usuario desactivado
+func (s *userService) Reactivate(id uint, meta dto.RequestMeta) error {
+	user, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if !user.IsDeactivated() {
+		return errors.New("user is not deactivated")
+	}
+
+	user.DeactivatedAt = nil
+	if err := s.repo.Update(user); err != nil {
+		return err
+	}
+
+	s.audit(meta, domain.AuditUserReactivated, id, "")
+	s.publishUserEvent(messaging.EventUserReactivated, id)
+	return nil
+}
+
+// Erase anonimiza los datos personales del usuario (derecho al olvido) y lo marca como borrado.
+// Funciona también sobre usuarios con borrado lógico. No se puede deshacer.
+func (s *userService) Erase(id uint, meta dto.RequestMeta) error {
+	user, err := s.repo.GetByIDUnscoped(id)
+	if err != nil {
+		return err
+	}
+	if user.ErasedAt != nil {
+		return errors.New("user already erased")
+	}
+
+	now := time.Now()
+	placeholder := fmt.Sprintf("erased-%d", user.ID)
+	user.Username = placeholder
+	user.Email = placeholder + "@erased.invalid"
+	user.Password = ""
+	user.FirstName = ""
+	user.LastName = ""
+	user.VerifiedAt = nil
+	user.TOTPSecret = ""
+	user.TOTPEnabledAt = nil
+	user.ErasedAt = &now
+	if !user.DeletedAt.Valid {
+		user.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+	}
+
+	if err := s.repo.Erase(user); err != nil {
+		return err
+	}
+	if err := s.guard.Unlock(lockoutKey(id)); err != nil {
+		log.Printf("Error clearing login limiter for erased user %d: %v", id, err)
+	}
+
+	s.audit(meta, domain.AuditUserErased, id, "")
+	s.publishUserEvent(messaging.EventUserErased, id)
+	return nil
+}
+
+// ListRoles obtiene los roles con sus permisos
+func (s *userService) ListRoles() (*dto.RolesListResponse, error) {
+	roles, err := s.repo.ListRoles()
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.RoleResponse, len(roles))
+	for i, role := range roles {
+		permissions, err := s.repo.GetRolePermissions(role.Name)
+		if err != nil {
+			return nil, err
+		}
+		responses[i] = dto.RoleResponse{
+			Name:        role.Name,
+			Description: role.Description,
+			Permissions: permissions,
+		}
+	}
+
+	return &dto.RolesListResponse{Roles: responses}, nil
+}
+
+// SetRolePermissions reemplaza los permisos de un rol.
+// Los usuarios con ese rol reciben los permisos nuevos al renovar su access token.
+func (s *userService) SetRolePermissions(role string, req *dto.SetRolePermissionsRequest, meta dto.RequestMeta) (*dto.RoleResponse, error) {
+	if !domain.IsKnownRole(role) {
+		return nil, errors.New("role not found")
+	}
+
+	seen := make(map[string]bool, len(req.Permissions))
+	permissions := make([]string, 0, len(req.Permissions))
+	for _, permission := range req.Permissions {
+		if !auth.IsKnownPermission(permission) {
+			return nil, fmt.Errorf("unknown permission: %s", permission)
+		}
+		if !seen[permission] {
+			seen[permission] = true
+			permissions = append(permissions, permission)
+		}
+	}
+	sort.Strings(permissions)
+
+	// Sin roles:manage nadie podría volver a editar los permisos
+	if role == auth.RoleAdmin && !seen[auth.PermRolesManage] {
+		return nil, errors.New("admin role must keep roles:manage")
+	}
+
+	if err := s.repo.SetRolePermissions(role, permissions); err != nil {
+		return nil, err
+	}
+	s.audit(meta, domain.AuditRolePermissionsChanged, 0, fmt.Sprintf("role=%s permissions=%s", role, strings.Join(permissions, ",")))
+
+	response := &dto.RoleResponse{Name: role, Permissions: permissions}
+	for _, def := range domain.DefaultRoles {
+		if def.Name == role {
+			response.Description = def.Description
+		}
+	}
+	return response, nil
+}
+
+// AssignRole cambia el rol de un usuario; aplica desde su próximo refresh
+func (s *userService) AssignRole(userID uint, req *dto.AssignRoleRequest, meta dto.RequestMeta) (*dto.UserResponse, error) {
+	if !domain.IsKnownRole(req.Role) {
+		return nil, errors.New("invalid role")
+	}
+
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == req.Role {
+		return s.domainToResponse(user), nil
+	}
+
+	// El repositorio verifica en la misma transacción que no se quite el último admin
+	previous := user.Role
+	if err := s.repo.UpdateRole(user.ID, req.Role); err != nil {
+		return nil, err
+	}
+	user.Role = req.Role
+	s.audit(meta, domain.AuditRoleAssigned, user.ID, fmt.Sprintf("from=%s to=%s", previous, req.Role))
+
+	return s.domainToResponse(user), nil
+}
+
+// OIDCProviders lista los proveedores OIDC habilitados
+func (s *userService) OIDCProviders() *dto.OIDCProvidersResponse {
+	names := make([]string, 0, len(s.oidcProviders))
+	for name := range s.oidcProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return &dto.OIDCProvidersResponse{Providers: names}
+}
+
+// OIDCAuthorize inicia un login OIDC: guarda state, nonce y code_verifier y retorna la URL del proveedor
+func (s *userService) OIDCAuthorize(providerName string) (*dto.OIDCAuthorizeResponse, error) {
+	provider, ok := s.oidcProviders[providerName]
+	if !ok {
+		return nil, errors.New("unknown provider")
+	}
+
+	state, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, errors.New("error generating token")
+	}
+	nonce, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, errors.New("error generating token")
+	}
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return nil, errors.New("error generating token")
+	}
+
+	authURL, err := provider.AuthCodeURL(state, nonce, verifier)
+	if err != nil {
+		log.Printf("Error building %s authorization URL: %v", providerName, err)
+		return nil, errors.New("provider unavailable")
+	}
+
+	minutes := config.AppConfig.OIDCStateTTLMinutes
+	if minutes <= 0 {
+		minutes = 10
+	}
+
+	record := &domain.OIDCState{
+		StateHash:    utils.HashToken(state),
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(time.Duration(minutes) * time.Minute),
+	}
+	if err := s.repo.CreateOIDCState(record); err != nil {
+		return nil, err
+	}
+
+	return &dto.OIDCAuthorizeResponse{AuthorizationURL: authURL}, nil
+}
+
+// OIDCCallback completa el login OIDC: canjea el código con el verifier guardado, busca o crea
+// el usuario vinculado a la identidad y emite nuestros tokens. Con 2FA activo se pide el código igual que en Login.
+func (s *userService) OIDCCallback(providerName string, req *dto.OIDCCallbackRequest, meta dto.RequestMeta) (*dto.LoginResponse, error) {
+	provider, ok := s.oidcProviders[providerName]
+	if !ok {
+		return nil, errors.New("unknown provider")
+	}
+
+	stored, err := s.repo.GetOIDCStateByHash(utils.HashToken(req.State))
+	if err != nil || !stored.IsUsable(time.Now()) || stored.Provider != providerName {
+		return nil, errors.New("invalid or expired state")
+	}
+
+	consumed, err := s.repo.ConsumeOIDCState(stored.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, errors.New("invalid or expired state")
+	}
+
+	identity, err := provider.Exchange(req.Code, stored.CodeVerifier, stored.Nonce)
+	if err != nil {
+		log.Printf("OIDC login with %s failed: %v", providerName, err)
+		s.audit(meta, domain.AuditLoginFailed, 0, "oidc login failed provider="+providerName)
+		return nil, errors.New("oidc login failed")
+	}
+
+	user, err := s.userForIdentity(providerName, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.IsDeactivated() {
+		s.audit(meta, domain.AuditLoginFailed, user.ID, "account deactivated provider="+providerName)
+		return nil, errors.New("account deactivated")
+	}
+
+	if config.AppConfig.RequireEmailVerification && user.VerifiedAt == nil {
+		return nil, errors.New("email not verified")
+	}
+
+	if user.TwoFactorEnabled() {
+		return s.loginChallenge(user)
+	}
+
+	response, err := s.startSession(user, []string{auth.AMRFederated})
+	if err != nil {
+		return nil, err
+	}
+	meta.ActorID = user.ID
+	s.audit(meta, domain.AuditLoginSucceeded, user.ID, "amr=fed provider="+providerName)
+
+	response.TwoFactorSetupRequired = user.Role == auth.RoleAdmin
+	return response, nil
+}
+
+// userForIdentity resuelve el usuario de una identidad externa.
+// Si no está vinculada, se vincula a la cuenta con el mismo email solo si el proveedor lo verificó;
+// si no existe ninguna cuenta, se crea un jugador nuevo.
+func (s *userService) userForIdentity(providerName string, identity *oidc.Identity) (*domain.User, error) {
+	linked, err := s.repo.GetIdentity(providerName, identity.Subject)
+	if err == nil {
+		if err := s.repo.TouchIdentity(linked.ID); err != nil {
+			log.Printf("Error updating identity %d last login: %v", linked.ID, err)
+		}
+		return s.repo.GetByID(linked.UserID)
+	}
+	if err.Error() != "identity not found" {
+		return nil, err
+	}
+
+	email := strings.TrimSpace(identity.Email)
+	if email == "" {
+		return nil, errors.New("provider did not return an email")
+	}
+
+	now := time.Now()
+	record := &domain.Identity{
+		Provider:    providerName,
+		Subject:     identity.Subject,
+		Email:       email,
+		LastLoginAt: &now,
+	}
+
+	existing, err := s.repo.GetByEmail(email)
+	if err == nil {
+		// Sin email verificado, vincular permitiría tomar la cuenta de otro registrando su email en el proveedor
+		if !identity.EmailVerified {
+			return nil, errors.New("email already registered")
+		}
+
+		record.UserID = existing.ID
+		if err := s.repo.CreateIdentity(record); err != nil {
+			return nil, err
+		}
+
+		if existing.VerifiedAt == nil {
+			existing.VerifiedAt = &now
+			if err := s.repo.Update(existing); err != nil {
+				return nil, err
+			}
+		}
+		return existing, nil
+	}
+	if !errors.Is(err, repositories.ErrUserNotFound) {
+		return nil, err
+	}
+
+	// Un usuario borrado lógicamente sigue ocupando el email
+	exists, err := s.repo.ExistsByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errors.New("email already registered")
+	}
+
+	username, err := s.availableUsername(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	// La cuenta no tiene contraseña conocida: puede definir una con la recuperación de contraseña
+	secret, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, errors.New("error generating token")
+	}
+	hashedPassword, err := utils.HashPassword(secret)
+	if err != nil {
+		return nil, errors.New("error hashing password")
+	}
+
+	user := &domain.User{
+		Username:  username,
+		Email:     email,
+		Password:  hashedPassword,
+		FirstName: identity.FirstName,
+		LastName:  identity.LastName,
+		Role:      auth.RoleNormal,
+	}
+	if identity.EmailVerified {
+		user.VerifiedAt = &now
+	}
+
+	if err := s.repo.CreateWithIdentity(user, record); err != nil {
+		return nil, err
+	}
+
+	if user.VerifiedAt == nil {
+		if err := s.SendVerificationEmail(user.ID); err != nil {
+			log.Printf("Error sending verification email to user %d: %v", user.ID, err)
+		}
+	}
+
+	return user, nil
+}
+
+// availableUsername deriva un username libre del preferred_username o del email de la identidad
+func (s *userService) availableUsername(identity *oidc.Identity) (string, error) {
+	base := identity.Username
+	if base == "" {
+		base, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	base = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return -1
+		}
+	}, base)
+	if len(base) < 3 {
+		base = "user" + base
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+
+	candidate := base
+	for i := 1; i <= maxUsernameAttempts; i++ {
+		exists, err := s.repo.ExistsByUsername(candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+		candidate = base + strconv.Itoa(i)
+	}
+
+	return "", errors.New("could not derive a free username")
+}
+
+// ListAudit obtiene una página del log de auditoría según los filtros pedidos
+func (s *userService) ListAudit(query *dto.AuditListQuery) (*dto.AuditListResponse, error) {
+	filter := repositories.AuditFilter{
+		ActorID:  query.ActorID,
+		TargetID: query.TargetID,
+		Action:   query.Action,
+		IP:       strings.TrimSpace(query.IP),
+	}
+
+	if filter.Action != "" && !domain.IsAuditAction(filter.Action) {
+		return nil, errors.New("invalid action")
+	}
+
+	if query.From != "" {
+		from, _, err := parseDateBound(query.From)
+		if err != nil {
+			return nil, errors.New("invalid from")
+		}
+		filter.From = &from
+	}
+
+	if query.To != "" {
+		to, dateOnly, err := parseDateBound(query.To)
+		if err != nil {
+			return nil, errors.New("invalid to")
+		}
+		// Una fecha sin hora incluye el día completo
+		if dateOnly {
+			to = to.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		filter.To = &to
+	}
+
+	page := query.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := query.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	entries, total, err := s.repo.ListAuditEntries(filter, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.AuditEntryResponse, len(entries))
+	for i, entry := range entries {
+		responses[i] = dto.AuditEntryResponse{
+			ID:        entry.ID,
+			ActorID:   entry.ActorID,
+			TargetID:  entry.TargetID,
+			Action:    entry.Action,
+			IP:        entry.IP,
+			UserAgent: entry.UserAgent,
+			Details:   entry.Details,
+			CreatedAt: entry.CreatedAt,
+		}
+	}
+
+	return &dto.AuditListResponse{
+		Entries:    responses,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+	}, nil
+}
+
+// audit agrega un registro al log de auditoría. Un fallo no revierte la operación ya hecha.
+// targetID 0 indica que la acción no es sobre un usuario.
+func (s *userService) audit(meta dto.RequestMeta, action string, targetID uint, details string) {
+	entry := &domain.AuditEntry{
+		ActorID:   optionalID(meta.ActorID),
+		TargetID:  optionalID(targetID),
+		Action:    action,
+		IP:        truncate(meta.IP, 45),
+		UserAgent: truncate(meta.UserAgent, 255),
+		Details:   truncate(details, 255),
+	}
+	if err := s.repo.CreateAuditEntry(entry); err != nil {
+		log.Printf("Warning: failed to write audit entry %s: %v", action, err)
+	}
+}
+
+// optionalID convierte un ID 0 en nil
+func optionalID(id uint) *uint {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+// truncate recorta value a max caracteres sin cortar un carácter multibyte
+func truncate(value string, max int) string {
+	runes := []rune(value)
+	if len(runes) <= max {
+		return value
+	}
+	return string(runes[:max])
+}
+
+// publishUserEvent publica un evento user.* con el ID del usuario. Un fallo no revierte la operación.
+func (s *userService) publishUserEvent(eventType string, userID uint) {
+	event := messaging.Event{
+		Type:      eventType,
+		Entity:    "user",
+		EntityID:  strconv.FormatUint(uint64(userID), 10),
+		Data:      messaging.UserEventData{UserID: userID},
+		Timestamp: time.Now().Unix(),
+	}
+	if err := s.publisher.PublishEvent(event); err != nil {
+		log.Printf("Warning: failed to publish event: %v", err)
+	}
+}
+
+// domainToResponse convierte un User del dominio a UserResponse DTO
+func (s *userService) domainToResponse(user *domain.User) *dto.UserResponse {
+	return &dto.UserResponse{
+		ID:               user.ID,
+		Username:         user.Username,
+		Email:            user.Email,
+		FirstName:        user.FirstName,
+		LastName:         user.LastName,
+		Role:             user.Role,
+		CreatedAt:        user.CreatedAt,
+		VerifiedAt:       user.VerifiedAt,
+		TwoFactorEnabled: user.TwoFactorEnabled(),
+		DeactivatedAt:    user.DeactivatedAt,
+	}
+}
+
+// hasMFA indica si la lista de métodos de autenticación incluye un segundo factor
+func hasMFA(amr []string) bool {
+	for _, method := range amr {
+		if method == auth.AMROTP {
+			return true
+		}
 	}
+	return false
 }
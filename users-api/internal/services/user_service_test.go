@@ -2,27 +2,97 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"shared/auth"
 	"users-api/config"
+	"users-api/internal/clients"
 	"users-api/internal/domain"
 	"users-api/internal/dto"
+	"users-api/internal/limiter"
+	"users-api/internal/mailer"
+	"users-api/internal/messaging"
+	"users-api/internal/password"
+	"users-api/internal/repositories"
 	"users-api/utils"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
+const testIP = "10.0.0.1"
+
+// testMeta son los datos de request con los que los tests llaman al servicio
+var testMeta = dto.RequestMeta{IP: testIP, UserAgent: "go-test"}
+
+// newTestGuard crea un limitador en memoria con umbrales altos para no interferir con otros tests.
+func newTestGuard() *limiter.Guard {
+	policy := limiter.Policy{LockoutAfter: 1000, LockoutDuration: time.Minute, Window: time.Minute}
+	return limiter.NewGuard(limiter.NewMemoryStore(), policy, policy)
+}
+
+// mockPublisher guarda los eventos publicados en lugar de enviarlos a RabbitMQ.
+type mockPublisher struct {
+	events []messaging.Event
+}
+
+func (p *mockPublisher) PublishEvent(event messaging.Event) error {
+	p.events = append(p.events, event)
+	return nil
+}
+
+func (p *mockPublisher) Close() error { return nil }
+
+// mockReservaClient devuelve reservas fijas en lugar de llamar a reservas-api.
+type mockReservaClient struct {
+	reservas []clients.ReservaResponse
+	err      error
+	token    string
+}
+
+func (c *mockReservaClient) GetByUserID(userID uint, accessToken string) ([]clients.ReservaResponse, error) {
+	c.token = accessToken
+	return c.reservas, c.err
+}
+
 // mockUserRepository implementa UserRepository en memoria para probar el servicio.
+// Los usuarios con borrado lógico pasan a deleted y solo se ven con GetByIDUnscoped.
 type mockUserRepository struct {
-	users map[uint]*domain.User
+	users         map[uint]*domain.User
+	deleted       map[uint]*domain.User
+	refreshTokens map[uint]*domain.RefreshToken
+	invitations   map[uint]*domain.Invitation
+	userTokens    map[uint]*domain.UserToken
+	recoveryCodes map[uint][]domain.RecoveryCode
+	roles         map[string][]string
+	oidcStates    map[uint]*domain.OIDCState
+	identities    map[uint]*domain.Identity
+	auditLog      []domain.AuditEntry
 }
 
 // newMockUserRepo inicializa el repositorio en memoria.
 func newMockUserRepo() *mockUserRepository {
-	return &mockUserRepository{users: make(map[uint]*domain.User)}
+	m := &mockUserRepository{
+		users:         make(map[uint]*domain.User),
+		deleted:       make(map[uint]*domain.User),
+		refreshTokens: make(map[uint]*domain.RefreshToken),
+		invitations:   make(map[uint]*domain.Invitation),
+		userTokens:    make(map[uint]*domain.UserToken),
+		recoveryCodes: make(map[uint][]domain.RecoveryCode),
+		roles:         make(map[string][]string),
+		oidcStates:    make(map[uint]*domain.OIDCState),
+		identities:    make(map[uint]*domain.Identity),
+	}
+	_ = m.SeedRoles(domain.DefaultRoles)
+	return m
 }
 
 func (m *mockUserRepository) nextID() uint {
-	return uint(len(m.users) + 1)
+	return uint(len(m.users) + len(m.deleted) + 1)
 }
 
 // Create simula un insert y asigna ID/fechas.
@@ -39,7 +109,7 @@ func (m *mockUserRepository) GetByID(id uint) (*domain.User, error) {
 	if u, ok := m.users[id]; ok {
 		return u, nil
 	}
-	return nil, errors.New("user not found")
+	return nil, repositories.ErrUserNotFound
 }
 
 func (m *mockUserRepository) GetByUsername(username string) (*domain.User, error) {
@@ -48,7 +118,7 @@ func (m *mockUserRepository) GetByUsername(username string) (*domain.User, error
 			return u, nil
 		}
 	}
-	return nil, errors.New("user not found")
+	return nil, repositories.ErrUserNotFound
 }
 
 func (m *mockUserRepository) GetByEmail(email string) (*domain.User, error) {
@@ -57,7 +127,7 @@ func (m *mockUserRepository) GetByEmail(email string) (*domain.User, error) {
 			return u, nil
 		}
 	}
-	return nil, errors.New("user not found")
+	return nil, repositories.ErrUserNotFound
 }
 
 func (m *mockUserRepository) GetByUsernameOrEmail(login string) (*domain.User, error) {
@@ -66,14 +136,82 @@ func (m *mockUserRepository) GetByUsernameOrEmail(login string) (*domain.User, e
 			return u, nil
 		}
 	}
-	return nil, errors.New("user not found")
+	return nil, repositories.ErrUserNotFound
 }
 
-func (m *mockUserRepository) GetAll() ([]domain.User, error) { return nil, nil }
+// List filtra, ordena y pagina en memoria imitando al repositorio real.
+func (m *mockUserRepository) List(filter repositories.UserFilter, sortBy string, offset, limit int) ([]domain.User, int64, error) {
+	users := m.filter(filter)
+	desc := strings.HasPrefix(sortBy, "-")
+	field := strings.TrimPrefix(sortBy, "-")
+	sort.SliceStable(users, func(i, j int) bool {
+		var less bool
+		switch field {
+		case "username":
+			less = users[i].Username < users[j].Username
+		case "created_at":
+			less = users[i].CreatedAt.Before(users[j].CreatedAt)
+		default:
+			less = users[i].ID < users[j].ID
+		}
+		if desc {
+			return !less
+		}
+		return less
+	})
+
+	total := int64(len(users))
+	if offset >= len(users) {
+		return nil, total, nil
+	}
+	users = users[offset:]
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, total, nil
+}
+
+func (m *mockUserRepository) ListAfter(filter repositories.UserFilter, afterID uint, limit int) ([]domain.User, error) {
+	var users []domain.User
+	for _, u := range m.filter(filter) {
+		if u.ID > afterID {
+			users = append(users, u)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (m *mockUserRepository) filter(filter repositories.UserFilter) []domain.User {
+	var users []domain.User
+	for _, u := range m.users {
+		if filter.Role != "" && u.Role != filter.Role {
+			continue
+		}
+		if filter.Prefix != "" &&
+			!strings.HasPrefix(u.Username, filter.Prefix) &&
+			!strings.HasPrefix(u.Email, filter.Prefix) &&
+			!strings.HasPrefix(u.FirstName, filter.Prefix) &&
+			!strings.HasPrefix(u.LastName, filter.Prefix) {
+			continue
+		}
+		if filter.CreatedFrom != nil && u.CreatedAt.Before(*filter.CreatedFrom) {
+			continue
+		}
+		if filter.CreatedTo != nil && u.CreatedAt.After(*filter.CreatedTo) {
+			continue
+		}
+		users = append(users, *u)
+	}
+	return users
+}
 
 func (m *mockUserRepository) Update(user *domain.User) error {
 	if _, ok := m.users[user.ID]; !ok {
-		return errors.New("user not found")
+		return repositories.ErrUserNotFound
 	}
 	user.UpdatedAt = time.Now()
 	m.users[user.ID] = user
@@ -81,12 +219,46 @@ func (m *mockUserRepository) Update(user *domain.User) error {
 }
 
 func (m *mockUserRepository) Delete(id uint) error {
+	u, ok := m.users[id]
+	if !ok {
+		return repositories.ErrUserNotFound
+	}
+	u.DeletedAt.Time = time.Now()
+	u.DeletedAt.Valid = true
+	m.deleted[id] = u
 	delete(m.users, id)
 	return nil
 }
 
+func (m *mockUserRepository) GetByIDUnscoped(id uint) (*domain.User, error) {
+	if u, ok := m.users[id]; ok {
+		return u, nil
+	}
+	if u, ok := m.deleted[id]; ok {
+		return u, nil
+	}
+	return nil, repositories.ErrUserNotFound
+}
+
+func (m *mockUserRepository) Erase(user *domain.User) error {
+	delete(m.users, user.ID)
+	m.deleted[user.ID] = user
+	for id, rt := range m.refreshTokens {
+		if rt.UserID == user.ID {
+			delete(m.refreshTokens, id)
+		}
+	}
+	for id, ut := range m.userTokens {
+		if ut.UserID == user.ID {
+			delete(m.userTokens, id)
+		}
+	}
+	delete(m.recoveryCodes, user.ID)
+	return nil
+}
+
 func (m *mockUserRepository) ExistsByUsername(username string) (bool, error) {
-	for _, u := range m.users {
+	for _, u := range m.all() {
 		if u.Username == username {
 			return true, nil
 		}
@@ -95,7 +267,7 @@ func (m *mockUserRepository) ExistsByUsername(username string) (bool, error) {
 }
 
 func (m *mockUserRepository) ExistsByEmail(email string) (bool, error) {
-	for _, u := range m.users {
+	for _, u := range m.all() {
 		if u.Email == email {
 			return true, nil
 		}
@@ -103,25 +275,384 @@ func (m *mockUserRepository) ExistsByEmail(email string) (bool, error) {
 	return false, nil
 }
 
+// all devuelve también los usuarios con borrado lógico, como las consultas Unscoped.
+func (m *mockUserRepository) all() []*domain.User {
+	users := make([]*domain.User, 0, len(m.users)+len(m.deleted))
+	for _, u := range m.users {
+		users = append(users, u)
+	}
+	for _, u := range m.deleted {
+		users = append(users, u)
+	}
+	return users
+}
+
+func (m *mockUserRepository) CountByRole(role string) (int64, error) {
+	var count int64
+	for _, u := range m.users {
+		if u.Role == role {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *mockUserRepository) UpdateRole(userID uint, role string) error {
+	u, ok := m.users[userID]
+	if !ok {
+		return repositories.ErrUserNotFound
+	}
+	if admins, _ := m.CountByRole(auth.RoleAdmin); u.Role == auth.RoleAdmin && role != auth.RoleAdmin && admins <= 1 {
+		return repositories.ErrLastAdmin
+	}
+	u.Role = role
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+func (m *mockUserRepository) UpdatePasswordHash(userID uint, oldHash, newHash string) (bool, error) {
+	u, ok := m.users[userID]
+	if !ok || u.Password != oldHash {
+		return false, nil
+	}
+	u.Password = newHash
+	return true, nil
+}
+
+func (m *mockUserRepository) CreateInvitation(invitation *domain.Invitation) error {
+	invitation.ID = uint(len(m.invitations) + 1)
+	invitation.CreatedAt = time.Now()
+	m.invitations[invitation.ID] = invitation
+	return nil
+}
+
+func (m *mockUserRepository) GetInvitationByCodeHash(hash string) (*domain.Invitation, error) {
+	for _, i := range m.invitations {
+		if i.CodeHash == hash {
+			return i, nil
+		}
+	}
+	return nil, errors.New("invitation not found")
+}
+
+func (m *mockUserRepository) CreateFirstAdmin(user *domain.User) error {
+	if admins, _ := m.CountByRole(auth.RoleAdmin); admins > 0 {
+		return errors.New("admin already exists")
+	}
+	return m.Create(user)
+}
+
+func (m *mockUserRepository) CreateWithInvitation(user *domain.User, invitationID uint) error {
+	inv, ok := m.invitations[invitationID]
+	if !ok || inv.RedeemedAt != nil {
+		return errors.New("invitation already used")
+	}
+	if err := m.Create(user); err != nil {
+		return err
+	}
+	now := time.Now()
+	inv.RedeemedAt = &now
+	inv.RedeemedBy = &user.ID
+	return nil
+}
+
+func (m *mockUserRepository) CreateRefreshToken(token *domain.RefreshToken) error {
+	token.ID = uint(len(m.refreshTokens) + 1)
+	token.CreatedAt = time.Now()
+	m.refreshTokens[token.ID] = token
+	return nil
+}
+
+func (m *mockUserRepository) GetRefreshTokenByHash(hash string) (*domain.RefreshToken, error) {
+	for _, t := range m.refreshTokens {
+		if t.TokenHash == hash {
+			return t, nil
+		}
+	}
+	return nil, errors.New("refresh token not found")
+}
+
+func (m *mockUserRepository) RevokeRefreshToken(id uint, replacedBy *uint) (bool, error) {
+	t, ok := m.refreshTokens[id]
+	if !ok || t.RevokedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	t.RevokedAt = &now
+	t.ReplacedBy = replacedBy
+	return true, nil
+}
+
+func (m *mockUserRepository) RevokeRefreshTokenFamily(familyID string) error {
+	now := time.Now()
+	for _, t := range m.refreshTokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (m *mockUserRepository) RevokeUserRefreshTokens(userID uint) error {
+	now := time.Now()
+	for _, t := range m.refreshTokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (m *mockUserRepository) CreateUserToken(token *domain.UserToken) error {
+	token.ID = uint(len(m.userTokens) + 1)
+	token.CreatedAt = time.Now()
+	m.userTokens[token.ID] = token
+	return nil
+}
+
+func (m *mockUserRepository) GetUserTokenByHash(hash, purpose string) (*domain.UserToken, error) {
+	for _, t := range m.userTokens {
+		if t.TokenHash == hash && t.Purpose == purpose {
+			return t, nil
+		}
+	}
+	return nil, errors.New("token not found")
+}
+
+func (m *mockUserRepository) ConsumeUserToken(id uint) (bool, error) {
+	t, ok := m.userTokens[id]
+	if !ok || !t.IsUsable(time.Now()) {
+		return false, nil
+	}
+	now := time.Now()
+	t.UsedAt = &now
+	return true, nil
+}
+
+func (m *mockUserRepository) InvalidateUserTokens(userID uint, purpose string) error {
+	now := time.Now()
+	for _, t := range m.userTokens {
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			t.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+func (m *mockUserRepository) RecordUserTokenFailure(id uint, maxAttempts int) error {
+	t, ok := m.userTokens[id]
+	if !ok {
+		return nil
+	}
+	t.Attempts++
+	if t.Attempts >= maxAttempts && t.UsedAt == nil {
+		now := time.Now()
+		t.UsedAt = &now
+	}
+	return nil
+}
+
+func (m *mockUserRepository) UpdateTOTPLastStep(userID uint, step int64) (bool, error) {
+	u, ok := m.users[userID]
+	if !ok || u.TOTPLastStep >= step {
+		return false, nil
+	}
+	u.TOTPLastStep = step
+	return true, nil
+}
+
+func (m *mockUserRepository) UpdateTOTPSecret(userID uint, oldValue, newValue string) (bool, error) {
+	u, ok := m.users[userID]
+	if !ok || u.TOTPSecret != oldValue {
+		return false, nil
+	}
+	u.TOTPSecret = newValue
+	return true, nil
+}
+
+func (m *mockUserRepository) ReplaceRecoveryCodes(userID uint, codes []domain.RecoveryCode) error {
+	m.recoveryCodes[userID] = codes
+	return nil
+}
+
+func (m *mockUserRepository) UseRecoveryCode(userID uint, hash string) (bool, error) {
+	codes := m.recoveryCodes[userID]
+	for i := range codes {
+		if codes[i].CodeHash == hash && codes[i].UsedAt == nil {
+			now := time.Now()
+			codes[i].UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *mockUserRepository) SeedRoles(defaults []domain.DefaultRole) error {
+	for _, def := range defaults {
+		if _, ok := m.roles[def.Name]; !ok {
+			m.roles[def.Name] = append([]string{}, def.Permissions...)
+		}
+	}
+	return nil
+}
+
+func (m *mockUserRepository) ListRoles() ([]domain.Role, error) {
+	var roles []domain.Role
+	for name := range m.roles {
+		roles = append(roles, domain.Role{Name: name})
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+func (m *mockUserRepository) GetRolePermissions(role string) ([]string, error) {
+	return m.roles[role], nil
+}
+
+func (m *mockUserRepository) SetRolePermissions(role string, permissions []string) error {
+	if _, ok := m.roles[role]; !ok {
+		return errors.New("role not found")
+	}
+	m.roles[role] = permissions
+	return nil
+}
+
+func (m *mockUserRepository) CreateOIDCState(state *domain.OIDCState) error {
+	state.ID = uint(len(m.oidcStates) + 1)
+	m.oidcStates[state.ID] = state
+	return nil
+}
+
+func (m *mockUserRepository) GetOIDCStateByHash(hash string) (*domain.OIDCState, error) {
+	for _, s := range m.oidcStates {
+		if s.StateHash == hash {
+			return s, nil
+		}
+	}
+	return nil, errors.New("oidc state not found")
+}
+
+func (m *mockUserRepository) ConsumeOIDCState(id uint) (bool, error) {
+	s, ok := m.oidcStates[id]
+	if !ok || !s.IsUsable(time.Now()) {
+		return false, nil
+	}
+	now := time.Now()
+	s.UsedAt = &now
+	return true, nil
+}
+
+func (m *mockUserRepository) GetIdentity(provider, subject string) (*domain.Identity, error) {
+	for _, i := range m.identities {
+		if i.Provider == provider && i.Subject == subject {
+			return i, nil
+		}
+	}
+	return nil, errors.New("identity not found")
+}
+
+func (m *mockUserRepository) CreateIdentity(identity *domain.Identity) error {
+	identity.ID = uint(len(m.identities) + 1)
+	m.identities[identity.ID] = identity
+	return nil
+}
+
+func (m *mockUserRepository) CreateWithIdentity(user *domain.User, identity *domain.Identity) error {
+	if err := m.Create(user); err != nil {
+		return err
+	}
+	identity.UserID = user.ID
+	return m.CreateIdentity(identity)
+}
+
+func (m *mockUserRepository) TouchIdentity(id uint) error {
+	if i, ok := m.identities[id]; ok {
+		now := time.Now()
+		i.LastLoginAt = &now
+	}
+	return nil
+}
+
+func (m *mockUserRepository) CreateAuditEntry(entry *domain.AuditEntry) error {
+	entry.ID = uint(len(m.auditLog) + 1)
+	entry.CreatedAt = time.Now()
+	m.auditLog = append(m.auditLog, *entry)
+	return nil
+}
+
+func (m *mockUserRepository) ListAuditEntries(filter repositories.AuditFilter, offset, limit int) ([]domain.AuditEntry, int64, error) {
+	var matched []domain.AuditEntry
+	for i := len(m.auditLog) - 1; i >= 0; i-- {
+		e := m.auditLog[i]
+		if filter.ActorID != 0 && (e.ActorID == nil || *e.ActorID != filter.ActorID) {
+			continue
+		}
+		if filter.TargetID != 0 && (e.TargetID == nil || *e.TargetID != filter.TargetID) {
+			continue
+		}
+		if filter.Action != "" && e.Action != filter.Action {
+			continue
+		}
+		if filter.IP != "" && e.IP != filter.IP {
+			continue
+		}
+		if filter.From != nil && e.CreatedAt.Before(*filter.From) {
+			continue
+		}
+		if filter.To != nil && e.CreatedAt.After(*filter.To) {
+			continue
+		}
+		matched = append(matched, e)
+	}
+
+	total := int64(len(matched))
+	if offset >= len(matched) {
+		return nil, total, nil
+	}
+	end := offset + limit
+	if end > len(matched) {
+		end = len(matched)
+	}
+	return matched[offset:end], total, nil
+}
+
+// tokenFromMail extrae el token del link incluido en el último email enviado a "to".
+func tokenFromMail(t *testing.T, m *mailer.MemoryMailer, to string) string {
+	t.Helper()
+	msg, ok := m.Last(to)
+	if !ok {
+		t.Fatalf("no se envió ningún email a %s", to)
+	}
+	idx := strings.Index(msg.Body, "token=")
+	if idx < 0 {
+		t.Fatalf("el email no contiene un token: %q", msg.Body)
+	}
+	token, err := url.QueryUnescape(strings.Fields(msg.Body[idx+len("token="):])[0])
+	if err != nil {
+		t.Fatalf("token mal codificado: %v", err)
+	}
+	return token
+}
+
 func TestRegisterSuccess(t *testing.T) {
-	// Registro exitoso: hashea password y respeta rol
+	// Registro exitoso: hashea password y asigna rol normal
 	repo := newMockUserRepo()
-	config.AppConfig = &config.Config{JWTSecret: "test-secret"}
-	svc := NewUserService(repo)
+	config.AppConfig = &config.Config{JWTExpirationHours: 1}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
 
 	req := &dto.RegisterRequest{
 		Username:  "alice",
 		Email:     "alice@example.com",
-		Password:  "pass123",
+		Password:  "Pase-Largo-1234",
 		FirstName: "Alice",
 		LastName:  "Doe",
 	}
 
-	resp, err := svc.Register(req, "admin")
+	resp, err := svc.Register(req)
 	if err != nil {
 		t.Fatalf("se esperaba registro sin error, llegó: %v", err)
 	}
-	if resp.Username != req.Username || resp.Email != req.Email || resp.Role != "admin" {
+	if resp.Username != req.Username || resp.Email != req.Email || resp.Role != "normal" {
 		t.Fatalf("la respuesta no coincide con la solicitud: %+v", resp)
 	}
 	stored, _ := repo.GetByID(resp.ID)
@@ -138,7 +669,7 @@ func TestRegisterDuplicateUsername(t *testing.T) {
 		Email:    "bob@example.com",
 		Password: "hashed",
 	})
-	svc := NewUserService(repo)
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
 
 	req := &dto.RegisterRequest{
 		Username:  "bob",
@@ -148,7 +679,7 @@ func TestRegisterDuplicateUsername(t *testing.T) {
 		LastName:  "Smith",
 	}
 
-	if _, err := svc.Register(req, "normal"); err == nil {
+	if _, err := svc.Register(req); err == nil {
 		t.Fatalf("se esperaba error por username duplicado, llegó nil")
 	}
 }
@@ -161,7 +692,7 @@ func TestRegisterDuplicateEmail(t *testing.T) {
 		Email:    "charlie@example.com",
 		Password: "hashed",
 	})
-	svc := NewUserService(repo)
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
 
 	req := &dto.RegisterRequest{
 		Username:  "other",
@@ -171,7 +702,7 @@ func TestRegisterDuplicateEmail(t *testing.T) {
 		LastName:  "User",
 	}
 
-	if _, err := svc.Register(req, "normal"); err == nil {
+	if _, err := svc.Register(req); err == nil {
 		t.Fatalf("se esperaba error por email duplicado, llegó nil")
 	}
 }
@@ -179,7 +710,7 @@ func TestRegisterDuplicateEmail(t *testing.T) {
 func TestLoginSuccess(t *testing.T) {
 	// Login correcto debe devolver token y user
 	repo := newMockUserRepo()
-	config.AppConfig = &config.Config{JWTSecret: "test-secret"}
+	config.AppConfig = &config.Config{JWTExpirationHours: 1}
 	hash, _ := utils.HashPassword("pass123")
 	_ = repo.Create(&domain.User{
 		Username: "david",
@@ -187,12 +718,12 @@ func TestLoginSuccess(t *testing.T) {
 		Password: hash,
 		Role:     "normal",
 	})
-	svc := NewUserService(repo)
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
 
 	resp, err := svc.Login(&dto.LoginRequest{
 		Login:    "david",
 		Password: "pass123",
-	})
+	}, testMeta)
 	if err != nil {
 		t.Fatalf("login debería ser exitoso, error: %v", err)
 	}
@@ -204,7 +735,7 @@ func TestLoginSuccess(t *testing.T) {
 func TestLoginInvalidPassword(t *testing.T) {
 	// Login debe fallar con password incorrecta
 	repo := newMockUserRepo()
-	config.AppConfig = &config.Config{JWTSecret: "test-secret"}
+	config.AppConfig = &config.Config{JWTExpirationHours: 1}
 	hash, _ := utils.HashPassword("pass123")
 	_ = repo.Create(&domain.User{
 		Username: "eric",
@@ -212,9 +743,1122 @@ func TestLoginInvalidPassword(t *testing.T) {
 		Password: hash,
 		Role:     "normal",
 	})
-	svc := NewUserService(repo)
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
 
-	if _, err := svc.Login(&dto.LoginRequest{Login: "eric", Password: "wrong"}); err == nil {
+	if _, err := svc.Login(&dto.LoginRequest{Login: "eric", Password: "wrong"}, testMeta); err == nil {
 		t.Fatalf("se esperaba error por contraseña inválida, llegó nil")
 	}
 }
+
+// loginForTest crea un usuario y hace login para obtener un par de tokens.
+func loginForTest(t *testing.T, repo *mockUserRepository, svc UserService) *dto.LoginResponse {
+	t.Helper()
+	hash, _ := utils.HashPassword("pass123")
+	_ = repo.Create(&domain.User{
+		Username: "frank",
+		Email:    "frank@example.com",
+		Password: hash,
+		Role:     "normal",
+	})
+	resp, err := svc.Login(&dto.LoginRequest{Login: "frank", Password: "pass123"}, testMeta)
+	if err != nil {
+		t.Fatalf("login debería ser exitoso, error: %v", err)
+	}
+	if resp.RefreshToken == "" {
+		t.Fatalf("login debe devolver refresh token")
+	}
+	return resp
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	// Refresh válido devuelve tokens nuevos y revoca el anterior
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{JWTExpirationHours: 1}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+	login := loginForTest(t, repo, svc)
+
+	refreshed, err := svc.Refresh(&dto.RefreshRequest{RefreshToken: login.RefreshToken})
+	if err != nil {
+		t.Fatalf("refresh debería ser exitoso, error: %v", err)
+	}
+	if refreshed.Token == "" || refreshed.RefreshToken == "" || refreshed.RefreshToken == login.RefreshToken {
+		t.Fatalf("refresh debe rotar el token: %+v", refreshed)
+	}
+
+	old, _ := repo.GetRefreshTokenByHash(utils.HashToken(login.RefreshToken))
+	if !old.IsRevoked() || old.ReplacedBy == nil {
+		t.Fatalf("el token anterior debería quedar revocado y enlazado al nuevo")
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	// Reutilizar un token ya rotado revoca toda la familia
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{JWTExpirationHours: 1}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+	login := loginForTest(t, repo, svc)
+
+	refreshed, err := svc.Refresh(&dto.RefreshRequest{RefreshToken: login.RefreshToken})
+	if err != nil {
+		t.Fatalf("primer refresh debería ser exitoso, error: %v", err)
+	}
+
+	if _, err := svc.Refresh(&dto.RefreshRequest{RefreshToken: login.RefreshToken}); err == nil || err.Error() != "refresh token reuse detected" {
+		t.Fatalf("se esperaba detección de reutilización, llegó: %v", err)
+	}
+
+	if _, err := svc.Refresh(&dto.RefreshRequest{RefreshToken: refreshed.RefreshToken}); err == nil {
+		t.Fatalf("el token más nuevo de la familia también debería estar revocado")
+	}
+}
+
+func TestLogoutRevokesToken(t *testing.T) {
+	// Después del logout el refresh token no sirve
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{JWTExpirationHours: 1}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+	login := loginForTest(t, repo, svc)
+
+	if err := svc.Logout(&dto.RefreshRequest{RefreshToken: login.RefreshToken}); err != nil {
+		t.Fatalf("logout debería ser exitoso, error: %v", err)
+	}
+	if _, err := svc.Refresh(&dto.RefreshRequest{RefreshToken: login.RefreshToken}); err == nil {
+		t.Fatalf("no se debería poder refrescar después del logout")
+	}
+}
+
+func TestRegisterWithInvitationUsesInvitedRole(t *testing.T) {
+	// El rol admin solo se obtiene canjeando una invitación, y solo una vez
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{InvitationTTLHours: 1}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+
+	invitation, err := svc.CreateInvitation(&dto.CreateInvitationRequest{Role: "admin"}, 1)
+	if err != nil {
+		t.Fatalf("no se pudo crear la invitación: %v", err)
+	}
+
+	resp, err := svc.Register(&dto.RegisterRequest{
+		Username:       "grace",
+		Email:          "grace@example.com",
+		Password:       "Pase-Largo-1234",
+		InvitationCode: invitation.Code,
+	})
+	if err != nil {
+		t.Fatalf("se esperaba registro con invitación sin error, llegó: %v", err)
+	}
+	if resp.Role != "admin" {
+		t.Fatalf("se esperaba rol admin, llegó %q", resp.Role)
+	}
+
+	_, err = svc.Register(&dto.RegisterRequest{
+		Username:       "mallory",
+		Email:          "mallory@example.com",
+		Password:       "Pase-Largo-1234",
+		InvitationCode: invitation.Code,
+	})
+	if err == nil || err.Error() != "invitation already used" {
+		t.Fatalf("se esperaba error por invitación usada, llegó: %v", err)
+	}
+}
+
+func TestRegisterWithExpiredInvitation(t *testing.T) {
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+
+	invitation, _ := svc.CreateInvitation(&dto.CreateInvitationRequest{Role: "admin"}, 1)
+	repo.invitations[invitation.ID].ExpiresAt = time.Now().Add(-time.Minute)
+
+	_, err := svc.Register(&dto.RegisterRequest{
+		Username:       "henry",
+		Email:          "henry@example.com",
+		Password:       "Pase-Largo-1234",
+		InvitationCode: invitation.Code,
+	})
+	if err == nil || err.Error() != "invitation expired" {
+		t.Fatalf("se esperaba error por invitación vencida, llegó: %v", err)
+	}
+}
+
+func TestBootstrapAdminOnlyOnce(t *testing.T) {
+	// El bootstrap exige el token y solo funciona si no hay admins
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{BootstrapToken: "setup-123"}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+
+	req := &dto.BootstrapRequest{
+		SetupToken: "wrong",
+		RegisterRequest: dto.RegisterRequest{
+			Username: "root",
+			Email:    "root@example.com",
+			Password: "Pase-Largo-1234",
+		},
+	}
+	if _, err := svc.BootstrapAdmin(req); err == nil || err.Error() != "invalid setup token" {
+		t.Fatalf("se esperaba error por token inválido, llegó: %v", err)
+	}
+
+	req.SetupToken = "setup-123"
+	resp, err := svc.BootstrapAdmin(req)
+	if err != nil || resp.Role != "admin" {
+		t.Fatalf("se esperaba admin creado, llegó: %+v, %v", resp, err)
+	}
+
+	req.Username, req.Email = "root2", "root2@example.com"
+	if _, err := svc.BootstrapAdmin(req); err == nil || err.Error() != "admin already exists" {
+		t.Fatalf("se esperaba error porque ya existe un admin, llegó: %v", err)
+	}
+}
+
+func TestRegisterSendsVerificationEmail(t *testing.T) {
+	// El registro envía un link de verificación de un solo uso
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{FrontendURL: "http://front"}
+	m := mailer.NewMemoryMailer()
+	svc := NewUserService(repo, m, newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+
+	resp, err := svc.Register(&dto.RegisterRequest{
+		Username: "alice", Email: "alice@example.com", Password: "Pase-Largo-1234", FirstName: "Alice", LastName: "Doe",
+	})
+	if err != nil {
+		t.Fatalf("registro falló: %v", err)
+	}
+	if resp.VerifiedAt != nil {
+		t.Fatalf("el usuario no debería estar verificado todavía")
+	}
+
+	token := tokenFromMail(t, m, "alice@example.com")
+	if err := svc.VerifyEmail(&dto.VerifyEmailRequest{Token: token}); err != nil {
+		t.Fatalf("la verificación debería funcionar: %v", err)
+	}
+	if stored, _ := repo.GetByID(resp.ID); stored.VerifiedAt == nil {
+		t.Fatalf("se esperaba verified_at seteado")
+	}
+	if err := svc.VerifyEmail(&dto.VerifyEmailRequest{Token: token}); err == nil {
+		t.Fatalf("el token no debería poder usarse dos veces")
+	}
+}
+
+func TestNotifyWaitlistOfferEmailsActiveUsersOnly(t *testing.T) {
+	// El aviso de la lista de espera llega por email; cuentas inexistentes o desactivadas se descartan
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{FrontendURL: "http://front/"}
+	m := mailer.NewMemoryMailer()
+	svc := NewUserService(repo, m, newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+	repo.Create(&domain.User{Username: "alice", Email: "alice@example.com", FirstName: "Alice", Role: "normal"})
+	now := time.Now()
+	repo.Create(&domain.User{Username: "bob", Email: "bob@example.com", FirstName: "Bob", Role: "normal", DeactivatedAt: &now})
+	alice, _ := repo.GetByUsername("alice")
+	bob, _ := repo.GetByUsername("bob")
+
+	expiresAt := time.Now().Add(15 * time.Minute)
+	offer := func(userID uint) *dto.WaitlistOfferNotification {
+		return &dto.WaitlistOfferNotification{
+			UserID: userID, CanchaName: "Cancha Uno", Date: "2026-10-20", StartTime: "18:00", EndTime: "19:00",
+			HoldExpiresAt: expiresAt,
+		}
+	}
+
+	if err := svc.NotifyWaitlistOffer(offer(alice.ID)); err != nil {
+		t.Fatalf("el aviso debería enviarse: %v", err)
+	}
+	msg, ok := m.Last("alice@example.com")
+	if !ok || !strings.Contains(msg.Body, "Cancha Uno") || !strings.Contains(msg.Body, expiresAt.UTC().Format("15:04")) || !strings.Contains(msg.Body, "http://front/mis-reservas") {
+		t.Fatalf("el email debe indicar el turno, el vencimiento y dónde confirmarlo, llegó: %+v", msg)
+	}
+
+	if err := svc.NotifyWaitlistOffer(offer(bob.ID)); err != nil {
+		t.Fatalf("una cuenta desactivada no debe reencolar el aviso: %v", err)
+	}
+	if err := svc.NotifyWaitlistOffer(offer(999)); err != nil {
+		t.Fatalf("un usuario inexistente no debe reencolar el aviso: %v", err)
+	}
+	// Un aviso que llega después de vencida la retención se descarta sin reencolarlo
+	expiresAt = time.Now().Add(-time.Minute)
+	if err := svc.NotifyWaitlistOffer(offer(alice.ID)); err != nil {
+		t.Fatalf("una oferta vencida no debe reencolar el aviso: %v", err)
+	}
+	if len(m.Messages()) != 1 {
+		t.Fatalf("solo Alice debía recibir el aviso, una sola vez, se enviaron %d emails", len(m.Messages()))
+	}
+}
+
+func TestLoginRequiresVerifiedEmail(t *testing.T) {
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{JWTExpirationHours: 1, RequireEmailVerification: true}
+	hash, _ := utils.HashPassword("secret")
+	repo.Create(&domain.User{Username: "bob", Email: "bob@example.com", Password: hash, Role: "normal"})
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+
+	if _, err := svc.Login(&dto.LoginRequest{Login: "bob", Password: "secret"}, testMeta); err == nil || err.Error() != "email not verified" {
+		t.Fatalf("se esperaba email not verified, llegó: %v", err)
+	}
+}
+
+func TestForgotAndResetPassword(t *testing.T) {
+	// El reset cambia la contraseña, cierra las sesiones y el token es de un solo uso
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{JWTExpirationHours: 1, PasswordResetTTLMinutes: 15}
+	m := mailer.NewMemoryMailer()
+	svc := NewUserService(repo, m, newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+	login := loginForTest(t, repo, svc)
+
+	// Un email desconocido no revela nada ni envía emails
+	if err := svc.ForgotPassword(&dto.ForgotPasswordRequest{Email: "nobody@example.com"}); err != nil {
+		t.Fatalf("no debería fallar con un email desconocido: %v", err)
+	}
+	if len(m.Messages()) != 0 {
+		t.Fatalf("no se esperaban emails")
+	}
+
+	if err := svc.ForgotPassword(&dto.ForgotPasswordRequest{Email: "frank@example.com"}); err != nil {
+		t.Fatalf("forgot password falló: %v", err)
+	}
+	first := tokenFromMail(t, m, "frank@example.com")
+
+	// Un segundo pedido invalida el link anterior
+	if err := svc.ForgotPassword(&dto.ForgotPasswordRequest{Email: "frank@example.com"}); err != nil {
+		t.Fatalf("forgot password falló: %v", err)
+	}
+	token := tokenFromMail(t, m, "frank@example.com")
+	if err := svc.ResetPassword(&dto.ResetPasswordRequest{Token: first, NewPassword: "Nuevo-Secreto-99"}, testMeta); err == nil {
+		t.Fatalf("el token anterior debería estar invalidado")
+	}
+
+	if err := svc.ResetPassword(&dto.ResetPasswordRequest{Token: token, NewPassword: "Nuevo-Secreto-99"}, testMeta); err != nil {
+		t.Fatalf("reset falló: %v", err)
+	}
+	if err := svc.ResetPassword(&dto.ResetPasswordRequest{Token: token, NewPassword: "Otro-Secreto-99"}, testMeta); err == nil {
+		t.Fatalf("el token no debería poder usarse dos veces")
+	}
+
+	if _, err := svc.Login(&dto.LoginRequest{Login: "frank", Password: "pass123"}, testMeta); err == nil {
+		t.Fatalf("la contraseña vieja no debería funcionar")
+	}
+	if _, err := svc.Login(&dto.LoginRequest{Login: "frank", Password: "Nuevo-Secreto-99"}, testMeta); err != nil {
+		t.Fatalf("la contraseña nueva debería funcionar: %v", err)
+	}
+	if _, err := svc.Refresh(&dto.RefreshRequest{RefreshToken: login.RefreshToken}); err == nil {
+		t.Fatalf("las sesiones previas deberían estar revocadas")
+	}
+}
+
+func TestResetPasswordExpiredToken(t *testing.T) {
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+	repo.Create(&domain.User{Username: "bob", Email: "bob@example.com", Role: "normal"})
+	repo.CreateUserToken(&domain.UserToken{
+		UserID:    1,
+		Purpose:   domain.TokenPurposePasswordReset,
+		TokenHash: utils.HashToken("expired"),
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+
+	if err := svc.ResetPassword(&dto.ResetPasswordRequest{Token: "expired", NewPassword: "Nuevo-Secreto-99"}, testMeta); err == nil || err.Error() != "invalid or expired token" {
+		t.Fatalf("se esperaba invalid or expired token, llegó: %v", err)
+	}
+}
+
+// testTOTPKey es la clave con la que los tests cifran los secretos TOTP
+var testTOTPKey = []byte("0123456789abcdef0123456789abcdef")
+
+// enableTwoFactorForTest enrola y confirma 2FA para el usuario y retorna el secreto y los códigos de recuperación.
+func enableTwoFactorForTest(t *testing.T, svc UserService, userID uint) (string, []string) {
+	t.Helper()
+	if config.AppConfig.TOTPEncryptionKey == nil {
+		config.AppConfig.TOTPEncryptionKey = testTOTPKey
+	}
+	enroll, err := svc.EnrollTwoFactor(userID)
+	if err != nil {
+		t.Fatalf("enrolamiento falló: %v", err)
+	}
+	if !strings.HasPrefix(enroll.OTPAuthURI, "otpauth://totp/") || !strings.Contains(enroll.OTPAuthURI, enroll.Secret) {
+		t.Fatalf("URI otpauth inválida: %s", enroll.OTPAuthURI)
+	}
+
+	code, _ := utils.TOTPCode(enroll.Secret, time.Now())
+	codes, err := svc.ConfirmTwoFactor(userID, &dto.TwoFactorCodeRequest{Code: code}, testMeta)
+	if err != nil {
+		t.Fatalf("confirmación falló: %v", err)
+	}
+	if len(codes.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("se esperaban %d códigos de recuperación, llegaron %d", recoveryCodeCount, len(codes.RecoveryCodes))
+	}
+	return enroll.Secret, codes.RecoveryCodes
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	// Con 2FA el login devuelve un challenge y el access token final lleva amr "otp"
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{JWTExpirationHours: 1, TOTPIssuer: "Canchas"}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+	hash, _ := utils.HashPassword("secret")
+	repo.Create(&domain.User{Username: "root", Email: "root@example.com", Password: hash, Role: "admin"})
+	secret, recovery := enableTwoFactorForTest(t, svc, 1)
+
+	first, err := svc.Login(&dto.LoginRequest{Login: "root", Password: "secret"}, testMeta)
+	if err != nil {
+		t.Fatalf("login falló: %v", err)
+	}
+	if !first.TwoFactorRequired || first.ChallengeToken == "" || first.Token != "" {
+		t.Fatalf("se esperaba solo un challenge: %+v", first)
+	}
+
+	// El código usado al confirmar no puede reutilizarse
+	used, _ := utils.TOTPCode(secret, time.Now())
+	if _, err := svc.LoginTwoFactor(&dto.TwoFactorLoginRequest{ChallengeToken: first.ChallengeToken, Code: used}, testMeta); err == nil {
+		t.Fatalf("un código TOTP ya usado no debería aceptarse")
+	}
+
+	next, _ := utils.TOTPCode(secret, time.Now().Add(30*time.Second))
+	resp, err := svc.LoginTwoFactor(&dto.TwoFactorLoginRequest{ChallengeToken: first.ChallengeToken, Code: next}, testMeta)
+	if err != nil {
+		t.Fatalf("segundo paso falló: %v", err)
+	}
+	claims, err := utils.ValidateToken(resp.Token)
+	if err != nil || !claims.HasAdminAccess() {
+		t.Fatalf("el token debería habilitar acceso de admin con 2FA: %v", err)
+	}
+
+	// El refresh conserva el segundo factor
+	refreshed, err := svc.Refresh(&dto.RefreshRequest{RefreshToken: resp.RefreshToken})
+	if err != nil {
+		t.Fatalf("refresh falló: %v", err)
+	}
+	if claims, _ := utils.ValidateToken(refreshed.Token); claims == nil || !claims.HasMFA() {
+		t.Fatalf("el token rotado debería conservar amr otp")
+	}
+
+	// El challenge es de un solo uso; un código de recuperación sirve una sola vez
+	if _, err := svc.LoginTwoFactor(&dto.TwoFactorLoginRequest{ChallengeToken: first.ChallengeToken, Code: recovery[0]}, testMeta); err == nil {
+		t.Fatalf("el challenge no debería poder reutilizarse")
+	}
+	second, _ := svc.Login(&dto.LoginRequest{Login: "root", Password: "secret"}, testMeta)
+	if _, err := svc.LoginTwoFactor(&dto.TwoFactorLoginRequest{ChallengeToken: second.ChallengeToken, Code: strings.ToUpper(recovery[0])}, testMeta); err != nil {
+		t.Fatalf("el código de recuperación debería funcionar: %v", err)
+	}
+	third, _ := svc.Login(&dto.LoginRequest{Login: "root", Password: "secret"}, testMeta)
+	if _, err := svc.LoginTwoFactor(&dto.TwoFactorLoginRequest{ChallengeToken: third.ChallengeToken, Code: recovery[0]}, testMeta); err == nil {
+		t.Fatalf("el código de recuperación no debería poder reutilizarse")
+	}
+}
+
+func TestTOTPSecretEncryptedAtRest(t *testing.T) {
+	// El secreto se guarda cifrado y ligado al usuario; los secretos viejos en claro se cifran al usarlos
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{JWTExpirationHours: 1}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+	hash, _ := utils.HashPassword("secret")
+	repo.Create(&domain.User{Username: "ana", Email: "ana@example.com", Password: hash, Role: "normal"})
+	repo.Create(&domain.User{Username: "beto", Email: "beto@example.com", Password: hash, Role: "normal"})
+
+	if _, err := svc.EnrollTwoFactor(1); err == nil || err.Error() != "two-factor encryption key not configured" {
+		t.Fatalf("sin clave no se debe poder enrolar 2FA, llegó: %v", err)
+	}
+
+	secret, _ := enableTwoFactorForTest(t, svc, 1)
+	ana, _ := repo.GetByID(1)
+	if ana.TOTPSecret == secret || !strings.HasPrefix(ana.TOTPSecret, "enc:v1:") {
+		t.Fatalf("el secreto no debe guardarse en claro: %q", ana.TOTPSecret)
+	}
+
+	// Un secreto cifrado copiado a otro usuario no sirve
+	beto, _ := repo.GetByID(2)
+	beto.TOTPSecret = ana.TOTPSecret
+	now := time.Now()
+	beto.TOTPEnabledAt = &now
+	challenge, _ := svc.Login(&dto.LoginRequest{Login: "beto", Password: "secret"}, testMeta)
+	code, _ := utils.TOTPCode(secret, time.Now().Add(30*time.Second))
+	if _, err := svc.LoginTwoFactor(&dto.TwoFactorLoginRequest{ChallengeToken: challenge.ChallengeToken, Code: code}, testMeta); err == nil {
+		t.Fatalf("el secreto cifrado de otro usuario no debería descifrarse")
+	}
+
+	// Un secreto guardado en claro antes del cifrado sigue funcionando y queda cifrado
+	legacy, _ := utils.GenerateTOTPSecret()
+	beto.TOTPSecret = legacy
+	challenge, _ = svc.Login(&dto.LoginRequest{Login: "beto", Password: "secret"}, testMeta)
+	code, _ = utils.TOTPCode(legacy, time.Now())
+	if _, err := svc.LoginTwoFactor(&dto.TwoFactorLoginRequest{ChallengeToken: challenge.ChallengeToken, Code: code}, testMeta); err != nil {
+		t.Fatalf("el secreto en claro debería seguir sirviendo: %v", err)
+	}
+	if beto.TOTPSecret == legacy || !strings.HasPrefix(beto.TOTPSecret, "enc:v1:") {
+		t.Fatalf("el secreto en claro debería quedar cifrado después de usarlo: %q", beto.TOTPSecret)
+	}
+}
+
+func TestTwoFactorChallengeLocksAfterFailures(t *testing.T) {
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{JWTExpirationHours: 1}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+	hash, _ := utils.HashPassword("secret")
+	repo.Create(&domain.User{Username: "carol", Email: "carol@example.com", Password: hash, Role: "normal"})
+	secret, _ := enableTwoFactorForTest(t, svc, 1)
+
+	login, _ := svc.Login(&dto.LoginRequest{Login: "carol", Password: "secret"}, testMeta)
+	for i := 0; i < maxChallengeAttempts; i++ {
+		if _, err := svc.LoginTwoFactor(&dto.TwoFactorLoginRequest{ChallengeToken: login.ChallengeToken, Code: "000000"}, testMeta); err == nil {
+			t.Fatalf("un código incorrecto no debería aceptarse")
+		}
+	}
+
+	valid, _ := utils.TOTPCode(secret, time.Now().Add(30*time.Second))
+	_, err := svc.LoginTwoFactor(&dto.TwoFactorLoginRequest{ChallengeToken: login.ChallengeToken, Code: valid}, testMeta)
+	if err == nil || err.Error() != "invalid or expired challenge" {
+		t.Fatalf("el challenge debería quedar invalidado tras %d fallos, llegó: %v", maxChallengeAttempts, err)
+	}
+}
+
+func TestAdminWithoutTwoFactorHasNoAdminAccess(t *testing.T) {
+	// Política: un admin sin 2FA inicia sesión pero su token no habilita rutas de admin
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{JWTExpirationHours: 1}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+	hash, _ := utils.HashPassword("secret")
+	repo.Create(&domain.User{Username: "root", Email: "root@example.com", Password: hash, Role: "admin"})
+
+	resp, err := svc.Login(&dto.LoginRequest{Login: "root", Password: "secret"}, testMeta)
+	if err != nil {
+		t.Fatalf("login falló: %v", err)
+	}
+	if !resp.TwoFactorSetupRequired {
+		t.Fatalf("se esperaba two_factor_setup_required para un admin sin 2FA")
+	}
+	claims, err := utils.ValidateToken(resp.Token)
+	if err != nil || claims.HasAdminAccess() {
+		t.Fatalf("el token no debería habilitar acceso de admin sin 2FA")
+	}
+}
+
+func TestLoginBackoffAndAdminUnlock(t *testing.T) {
+	// Tras los fallos configurados la cuenta queda frenada aun con la contraseña correcta
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{JWTExpirationHours: 1}
+	policy := limiter.Policy{
+		BackoffAfter:    2,
+		BaseDelay:       time.Minute,
+		MaxDelay:        time.Hour,
+		LockoutAfter:    4,
+		LockoutDuration: time.Hour,
+		Window:          time.Hour,
+	}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), limiter.NewGuard(limiter.NewMemoryStore(), policy, limiter.Policy{Window: time.Hour}), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+	hash, _ := utils.HashPassword("secret")
+	repo.Create(&domain.User{Username: "dave", Email: "dave@example.com", Password: hash, Role: "normal"})
+
+	for i := 0; i < 2; i++ {
+		if _, err := svc.Login(&dto.LoginRequest{Login: "dave", Password: "wrong"}, testMeta); err == nil || err.Error() != "invalid credentials" {
+			t.Fatalf("intento %d: se esperaba invalid credentials, llegó: %v", i+1, err)
+		}
+	}
+
+	// El email resuelve a la misma cuenta que el username
+	_, err := svc.Login(&dto.LoginRequest{Login: "dave@example.com", Password: "secret"}, dto.RequestMeta{IP: "10.0.0.2"})
+	var throttled *ThrottledError
+	if !errors.As(err, &throttled) || throttled.RetryAfter <= 0 {
+		t.Fatalf("se esperaba ThrottledError, llegó: %v", err)
+	}
+
+	lockout, err := svc.LockoutStatus(1)
+	if err != nil || lockout.FailedAttempts != 2 || lockout.Locked || lockout.BlockedUntil == nil {
+		t.Fatalf("estado de bloqueo inesperado: %+v (%v)", lockout, err)
+	}
+
+	if err := svc.UnlockUser(1, testMeta); err != nil {
+		t.Fatalf("unlock falló: %v", err)
+	}
+	if _, err := svc.Login(&dto.LoginRequest{Login: "dave", Password: "secret"}, testMeta); err != nil {
+		t.Fatalf("tras el unlock el login debería funcionar: %v", err)
+	}
+	if lockout, _ := svc.LockoutStatus(1); lockout.FailedAttempts != 0 {
+		t.Fatalf("un login correcto debería limpiar los fallos")
+	}
+}
+
+func TestLoginLockoutPerIP(t *testing.T) {
+	// Una misma IP probando muchas cuentas distintas queda bloqueada
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{JWTExpirationHours: 1}
+	ipPolicy := limiter.Policy{LockoutAfter: 3, LockoutDuration: time.Hour, Window: time.Hour}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), limiter.NewGuard(limiter.NewMemoryStore(), limiter.Policy{Window: time.Hour}, ipPolicy), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+	hash, _ := utils.HashPassword("secret")
+	repo.Create(&domain.User{Username: "erin", Email: "erin@example.com", Password: hash, Role: "normal"})
+
+	for _, login := range []string{"ghost1", "ghost2", "ghost3"} {
+		svc.Login(&dto.LoginRequest{Login: login, Password: "x"}, testMeta)
+	}
+
+	var throttled *ThrottledError
+	if _, err := svc.Login(&dto.LoginRequest{Login: "erin", Password: "secret"}, testMeta); !errors.As(err, &throttled) {
+		t.Fatalf("la IP debería estar bloqueada, llegó: %v", err)
+	}
+	if _, err := svc.Login(&dto.LoginRequest{Login: "erin", Password: "secret"}, dto.RequestMeta{IP: "10.0.0.9"}); err != nil {
+		t.Fatalf("otra IP no debería verse afectada: %v", err)
+	}
+}
+
+// memcachedKeyStore rechaza las claves que Memcached no acepta: con espacios, de control o de más de 250 bytes
+type memcachedKeyStore struct {
+	*limiter.MemoryStore
+}
+
+func (s memcachedKeyStore) check(key string) error {
+	if len(key) > 250 {
+		return fmt.Errorf("memcache: key too long")
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return fmt.Errorf("memcache: unexpected key character")
+		}
+	}
+	return nil
+}
+
+func (s memcachedKeyStore) Incr(key string, ttl time.Duration) (int64, error) {
+	if err := s.check(key); err != nil {
+		return 0, err
+	}
+	return s.MemoryStore.Incr(key, ttl)
+}
+
+func (s memcachedKeyStore) Get(key string) (string, bool, error) {
+	if err := s.check(key); err != nil {
+		return "", false, err
+	}
+	return s.MemoryStore.Get(key)
+}
+
+func (s memcachedKeyStore) Set(key, value string, ttl time.Duration) error {
+	if err := s.check(key); err != nil {
+		return err
+	}
+	return s.MemoryStore.Set(key, value, ttl)
+}
+
+func (s memcachedKeyStore) Delete(key string) error {
+	if err := s.check(key); err != nil {
+		return err
+	}
+	return s.MemoryStore.Delete(key)
+}
+
+func TestLoginUnknownAccountKeyIsMemcachedSafe(t *testing.T) {
+	// Un login inexistente con espacios o muy largo se sigue contando y bloqueando
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{JWTExpirationHours: 1}
+	acctPolicy := limiter.Policy{LockoutAfter: 2, LockoutDuration: time.Hour, Window: time.Hour}
+	store := memcachedKeyStore{limiter.NewMemoryStore()}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), limiter.NewGuard(store, acctPolicy, limiter.Policy{Window: time.Hour}), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+
+	for _, login := range []string{"no existe con espacios", strings.Repeat("x", 300)} {
+		for i := 0; i < 2; i++ {
+			if _, err := svc.Login(&dto.LoginRequest{Login: login, Password: "x"}, testMeta); err == nil || err.Error() != "invalid credentials" {
+				t.Fatalf("se esperaban credenciales inválidas, llegó: %v", err)
+			}
+		}
+		var throttled *ThrottledError
+		if _, err := svc.Login(&dto.LoginRequest{Login: login, Password: "x"}, testMeta); !errors.As(err, &throttled) {
+			t.Fatalf("el login %.20q debería quedar bloqueado, llegó: %v", login, err)
+		}
+	}
+}
+
+// seedUsers crea n usuarios "userN" y dos admins "adminN", con fechas de alta crecientes por día.
+func seedUsers(repo *mockUserRepository, n int) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	for i := 1; i <= n+2; i++ {
+		role, name := "normal", fmt.Sprintf("user%02d", i)
+		if i > n {
+			role, name = "admin", fmt.Sprintf("admin%02d", i)
+		}
+		repo.Create(&domain.User{Username: name, Email: name + "@example.com", FirstName: "Name", Role: role})
+		repo.users[uint(i)].CreatedAt = base.AddDate(0, 0, i-1)
+	}
+}
+
+func TestGetAllPaginatesAndFilters(t *testing.T) {
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+	seedUsers(repo, 25)
+
+	page, err := svc.GetAll(&dto.UserListQuery{Page: 2, PageSize: 10, Sort: "username", UserFilterQuery: dto.UserFilterQuery{Role: "normal"}})
+	if err != nil {
+		t.Fatalf("listado falló: %v", err)
+	}
+	if page.Total != 25 || page.TotalPages != 3 || len(page.Users) != 10 || page.Users[0].Username != "user11" {
+		t.Fatalf("página inesperada: total=%d pages=%d len=%d first=%v", page.Total, page.TotalPages, len(page.Users), page.Users)
+	}
+
+	// Por defecto ordena por alta descendente
+	latest, _ := svc.GetAll(&dto.UserListQuery{PageSize: 1})
+	if latest.Users[0].Username != "admin27" || latest.PageSize != 1 || latest.Page != 1 {
+		t.Fatalf("se esperaba el usuario más reciente primero: %+v", latest.Users)
+	}
+
+	prefix, _ := svc.GetAll(&dto.UserListQuery{UserFilterQuery: dto.UserFilterQuery{Q: "user2"}})
+	if prefix.Total != 6 { // user20 a user25
+		t.Fatalf("se esperaban 6 usuarios con prefijo user2, llegaron %d", prefix.Total)
+	}
+
+	dates, _ := svc.GetAll(&dto.UserListQuery{UserFilterQuery: dto.UserFilterQuery{CreatedFrom: "2024-01-02", CreatedTo: "2024-01-03"}})
+	if dates.Total != 2 {
+		t.Fatalf("se esperaban 2 usuarios entre el 2 y el 3 de enero, llegaron %d", dates.Total)
+	}
+
+	for _, q := range []*dto.UserListQuery{
+		{Sort: "password"},
+		{UserFilterQuery: dto.UserFilterQuery{Role: "root"}},
+		{UserFilterQuery: dto.UserFilterQuery{CreatedFrom: "ayer"}},
+	} {
+		if _, err := svc.GetAll(q); err == nil {
+			t.Fatalf("se esperaba error de validación para %+v", q)
+		}
+	}
+}
+
+func TestExportWalksAllUsersWithCursor(t *testing.T) {
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+	seedUsers(repo, 10)
+
+	seen := map[uint]bool{}
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatalf("el cursor no termina")
+		}
+		resp, err := svc.Export(&dto.UserExportQuery{Cursor: cursor, Limit: 5})
+		if err != nil {
+			t.Fatalf("export falló: %v", err)
+		}
+		for _, u := range resp.Users {
+			if seen[u.ID] {
+				t.Fatalf("usuario %d repetido", u.ID)
+			}
+			seen[u.ID] = true
+		}
+		if resp.NextCursor == "" {
+			break
+		}
+		cursor = resp.NextCursor
+	}
+	if len(seen) != 12 {
+		t.Fatalf("se esperaban 12 usuarios exportados, llegaron %d", len(seen))
+	}
+
+	if _, err := svc.Export(&dto.UserExportQuery{Cursor: "%%%"}); err == nil || err.Error() != "invalid cursor" {
+		t.Fatalf("se esperaba invalid cursor, llegó: %v", err)
+	}
+}
+
+func TestDeactivateBlocksLoginAndRefresh(t *testing.T) {
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{}
+	publisher := &mockPublisher{}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, publisher, &mockReservaClient{}, nil)
+	session := loginForTest(t, repo, svc)
+	id := session.User.ID
+
+	if err := svc.Deactivate(id, testMeta); err != nil {
+		t.Fatalf("deactivate falló: %v", err)
+	}
+	if err := svc.Deactivate(id, testMeta); err == nil || err.Error() != "user already deactivated" {
+		t.Fatalf("se esperaba user already deactivated, llegó: %v", err)
+	}
+
+	if _, err := svc.Login(&dto.LoginRequest{Login: "frank", Password: "pass123"}, testMeta); err == nil || err.Error() != "account deactivated" {
+		t.Fatalf("se esperaba account deactivated, llegó: %v", err)
+	}
+	if _, err := svc.Refresh(&dto.RefreshRequest{RefreshToken: session.RefreshToken}); err == nil {
+		t.Fatalf("el refresh token debe quedar revocado al desactivar")
+	}
+
+	if err := svc.Reactivate(id, testMeta); err != nil {
+		t.Fatalf("reactivate falló: %v", err)
+	}
+	if _, err := svc.Login(&dto.LoginRequest{Login: "frank", Password: "pass123"}, testMeta); err != nil {
+		t.Fatalf("login tras reactivar debería ser exitoso: %v", err)
+	}
+
+	if len(publisher.events) != 2 ||
+		publisher.events[0].Type != messaging.EventUserDeactivated ||
+		publisher.events[1].Type != messaging.EventUserReactivated {
+		t.Fatalf("eventos publicados inesperados: %+v", publisher.events)
+	}
+}
+
+func TestDeleteIsSoftAndKeepsUsernameTaken(t *testing.T) {
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{}
+	publisher := &mockPublisher{}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, publisher, &mockReservaClient{}, nil)
+	session := loginForTest(t, repo, svc)
+	id := session.User.ID
+
+	if err := svc.Delete(id, testMeta); err != nil {
+		t.Fatalf("delete falló: %v", err)
+	}
+	if _, err := svc.GetByID(id); err == nil {
+		t.Fatalf("el usuario borrado no debe ser visible")
+	}
+	if u, err := repo.GetByIDUnscoped(id); err != nil || !u.DeletedAt.Valid {
+		t.Fatalf("el borrado debe ser lógico, usuario: %+v, error: %v", u, err)
+	}
+
+	_, err := svc.Register(&dto.RegisterRequest{
+		Username:  "frank",
+		Email:     "otro@example.com",
+		Password:  "password123",
+		FirstName: "Frank",
+		LastName:  "Otro",
+	})
+	if err == nil || err.Error() != "username already exists" {
+		t.Fatalf("el username de un usuario borrado no debe reutilizarse, error: %v", err)
+	}
+
+	if len(publisher.events) != 1 || publisher.events[0].Type != messaging.EventUserDeleted ||
+		publisher.events[0].EntityID != fmt.Sprint(id) {
+		t.Fatalf("se esperaba un evento user.deleted, llegó: %+v", publisher.events)
+	}
+}
+
+func TestEraseAnonymizesPersonalData(t *testing.T) {
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{}
+	publisher := &mockPublisher{}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, publisher, &mockReservaClient{}, nil)
+	session := loginForTest(t, repo, svc)
+	id := session.User.ID
+
+	if err := svc.Erase(id, testMeta); err != nil {
+		t.Fatalf("erase falló: %v", err)
+	}
+
+	u, err := repo.GetByIDUnscoped(id)
+	if err != nil {
+		t.Fatalf("el usuario borrado debe conservar su fila: %v", err)
+	}
+	if strings.Contains(u.Username, "frank") || strings.Contains(u.Email, "frank") || u.Password != "" {
+		t.Fatalf("los datos personales deben quedar anonimizados: %+v", u)
+	}
+	if u.ErasedAt == nil || !u.DeletedAt.Valid {
+		t.Fatalf("el usuario debe quedar marcado como borrado: %+v", u)
+	}
+	if len(repo.refreshTokens) != 0 {
+		t.Fatalf("las sesiones del usuario deben eliminarse")
+	}
+	if _, err := svc.Login(&dto.LoginRequest{Login: "frank", Password: "pass123"}, testMeta); err == nil {
+		t.Fatalf("un usuario borrado no debe poder loguearse")
+	}
+
+	if err := svc.Erase(id, testMeta); err == nil || err.Error() != "user already erased" {
+		t.Fatalf("se esperaba user already erased, llegó: %v", err)
+	}
+	if len(publisher.events) != 1 || publisher.events[0].Type != messaging.EventUserErased {
+		t.Fatalf("se esperaba un evento user.erased, llegó: %+v", publisher.events)
+	}
+}
+
+func TestUpdateProfilePartialAndUnique(t *testing.T) {
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{}
+	m := mailer.NewMemoryMailer()
+	svc := NewUserService(repo, m, newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+	session := loginForTest(t, repo, svc)
+	id := session.User.ID
+	now := time.Now()
+	repo.users[id].VerifiedAt = &now
+	repo.users[id].FirstName = "Frank"
+	_ = repo.Create(&domain.User{Username: "bob", Email: "bob@example.com"})
+
+	lastName := "Castle"
+	resp, err := svc.Update(id, &dto.UpdateProfileRequest{LastName: &lastName})
+	if err != nil {
+		t.Fatalf("update falló: %v", err)
+	}
+	if resp.LastName != "Castle" || resp.FirstName != "Frank" || resp.Email != "frank@example.com" {
+		t.Fatalf("solo debe cambiar el apellido: %+v", resp)
+	}
+
+	taken := "bob@example.com"
+	if _, err := svc.Update(id, &dto.UpdateProfileRequest{Email: &taken}); err == nil || err.Error() != "email already exists" {
+		t.Fatalf("se esperaba email already exists, llegó: %v", err)
+	}
+	takenUsername := "bob"
+	if _, err := svc.Update(id, &dto.UpdateProfileRequest{Username: &takenUsername}); err == nil || err.Error() != "username already exists" {
+		t.Fatalf("se esperaba username already exists, llegó: %v", err)
+	}
+
+	newEmail := "frank.castle@example.com"
+	resp, err = svc.Update(id, &dto.UpdateProfileRequest{Email: &newEmail})
+	if err != nil {
+		t.Fatalf("update de email falló: %v", err)
+	}
+	if resp.Email != newEmail || resp.VerifiedAt != nil {
+		t.Fatalf("el email nuevo debe quedar sin verificar: %+v", resp)
+	}
+	if _, ok := m.Last(newEmail); !ok {
+		t.Fatalf("debe enviarse la verificación al email nuevo")
+	}
+}
+
+func TestChangePasswordRequiresCurrentAndRevokesSessions(t *testing.T) {
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+	session := loginForTest(t, repo, svc)
+	id := session.User.ID
+
+	err := svc.ChangePassword(id, &dto.ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "Nueva-Clave-2024"}, testMeta)
+	if err == nil || err.Error() != "invalid current password" {
+		t.Fatalf("se esperaba invalid current password, llegó: %v", err)
+	}
+
+	if err := svc.ChangePassword(id, &dto.ChangePasswordRequest{CurrentPassword: "pass123", NewPassword: "Nueva-Clave-2024"}, testMeta); err != nil {
+		t.Fatalf("change password falló: %v", err)
+	}
+	if _, err := svc.Refresh(&dto.RefreshRequest{RefreshToken: session.RefreshToken}); err == nil {
+		t.Fatalf("las sesiones previas deben quedar revocadas")
+	}
+	if _, err := svc.Login(&dto.LoginRequest{Login: "frank", Password: "pass123"}, testMeta); err == nil {
+		t.Fatalf("la contraseña anterior no debe funcionar")
+	}
+	if _, err := svc.Login(&dto.LoginRequest{Login: "frank", Password: "Nueva-Clave-2024"}, testMeta); err != nil {
+		t.Fatalf("login con la contraseña nueva falló: %v", err)
+	}
+}
+
+func TestGetProfileIncludesReservationStats(t *testing.T) {
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{}
+	today := time.Now().UTC()
+	reservas := &mockReservaClient{reservas: []clients.ReservaResponse{
+		{Date: today.AddDate(0, 0, 3).Format("2006-01-02"), Status: "confirmed", TotalPrice: 100},
+		{Date: today.AddDate(0, 0, -3).Format("2006-01-02"), Status: "confirmed", TotalPrice: 50},
+		{Date: today.AddDate(0, 0, 1).Format("2006-01-02"), Status: "cancelled", TotalPrice: 80},
+	}}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, reservas, nil)
+	session := loginForTest(t, repo, svc)
+
+	profile, err := svc.GetProfile(session.User.ID, session.Token)
+	if err != nil {
+		t.Fatalf("get profile falló: %v", err)
+	}
+	if profile.User.Username != "frank" || reservas.token != session.Token {
+		t.Fatalf("perfil inesperado: %+v", profile)
+	}
+	stats := profile.Reservations
+	if stats == nil || stats.Total != 3 || stats.Upcoming != 1 || stats.Past != 1 || stats.Cancelled != 1 || stats.TotalSpent != 150 {
+		t.Fatalf("resumen de reservas inesperado: %+v", stats)
+	}
+
+	// Sin reservas-api el perfil se devuelve igual
+	reservas.err = errors.New("connection refused")
+	profile, err = svc.GetProfile(session.User.ID, session.Token)
+	if err != nil || profile.Reservations != nil {
+		t.Fatalf("se esperaba perfil sin resumen, perfil: %+v, error: %v", profile, err)
+	}
+}
+
+func TestTokensCarryRolePermissions(t *testing.T) {
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{JWTExpirationHours: 1}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+	session := loginForTest(t, repo, svc)
+
+	claims, err := utils.ValidateToken(session.Token)
+	if err != nil || len(claims.Permissions) != 0 {
+		t.Fatalf("un jugador no debería tener permisos, claims: %+v, error: %v", claims, err)
+	}
+
+	// El cambio de rol aplica al renovar el token
+	if _, err := svc.AssignRole(session.User.ID, &dto.AssignRoleRequest{Role: auth.RoleStaff}, testMeta); err != nil {
+		t.Fatalf("assign role falló: %v", err)
+	}
+	refreshed, err := svc.Refresh(&dto.RefreshRequest{RefreshToken: session.RefreshToken})
+	if err != nil {
+		t.Fatalf("refresh falló: %v", err)
+	}
+	claims, _ = utils.ValidateToken(refreshed.Token)
+	if claims.Role != auth.RoleStaff || !claims.HasPermission(auth.PermReservasCheckIn) || claims.HasPermission(auth.PermCanchasManage) {
+		t.Fatalf("permisos de staff inesperados: %+v", claims.Permissions)
+	}
+
+	// Los permisos editados también
+	if _, err := svc.SetRolePermissions(auth.RoleStaff, &dto.SetRolePermissionsRequest{Permissions: []string{auth.PermReservasReadAll}}, testMeta); err != nil {
+		t.Fatalf("set role permissions falló: %v", err)
+	}
+	refreshed, _ = svc.Refresh(&dto.RefreshRequest{RefreshToken: refreshed.RefreshToken})
+	claims, _ = utils.ValidateToken(refreshed.Token)
+	if claims.HasPermission(auth.PermReservasCheckIn) || !claims.HasPermission(auth.PermReservasReadAll) {
+		t.Fatalf("los permisos editados no se aplicaron: %+v", claims.Permissions)
+	}
+}
+
+func TestRoleManagementValidation(t *testing.T) {
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+	_ = repo.Create(&domain.User{Username: "root", Email: "root@example.com", Role: auth.RoleAdmin})
+
+	if _, err := svc.AssignRole(1, &dto.AssignRoleRequest{Role: "superuser"}, testMeta); err == nil || err.Error() != "invalid role" {
+		t.Fatalf("se esperaba invalid role, llegó: %v", err)
+	}
+	if _, err := svc.AssignRole(1, &dto.AssignRoleRequest{Role: auth.RoleNormal}, testMeta); !errors.Is(err, repositories.ErrLastAdmin) {
+		t.Fatalf("se esperaba cannot remove the last admin, llegó: %v", err)
+	}
+
+	// Con dos admins uno puede quitarle el rol al otro, pero no al que queda
+	_ = repo.Create(&domain.User{Username: "root2", Email: "root2@example.com", Role: auth.RoleAdmin})
+	if _, err := svc.AssignRole(2, &dto.AssignRoleRequest{Role: auth.RoleStaff}, testMeta); err != nil {
+		t.Fatalf("se esperaba poder quitar un admin de dos, llegó: %v", err)
+	}
+	if _, err := svc.AssignRole(1, &dto.AssignRoleRequest{Role: auth.RoleStaff}, testMeta); !errors.Is(err, repositories.ErrLastAdmin) {
+		t.Fatalf("se esperaba cannot remove the last admin, llegó: %v", err)
+	}
+	if repo.users[1].Role != auth.RoleAdmin || repo.users[2].Role != auth.RoleStaff {
+		t.Fatalf("el último admin debe conservar su rol, llegó %s y %s", repo.users[1].Role, repo.users[2].Role)
+	}
+	if _, err := svc.SetRolePermissions(auth.RoleStaff, &dto.SetRolePermissionsRequest{Permissions: []string{"reservas:teleport"}}, testMeta); err == nil {
+		t.Fatalf("se esperaba error por permiso desconocido")
+	}
+	if _, err := svc.SetRolePermissions(auth.RoleAdmin, &dto.SetRolePermissionsRequest{Permissions: []string{auth.PermUsersRead}}, testMeta); err == nil {
+		t.Fatalf("admin no debería poder perder roles:manage")
+	}
+
+	roles, err := svc.ListRoles()
+	if err != nil || len(roles.Roles) != len(domain.DefaultRoles) {
+		t.Fatalf("se esperaban los roles por defecto, llegó: %+v, error: %v", roles, err)
+	}
+}
+
+func TestAuditLogRecordsSecurityActions(t *testing.T) {
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+	session := loginForTest(t, repo, svc)
+	id := session.User.ID
+	admin := dto.RequestMeta{ActorID: 99, IP: "10.0.0.5", UserAgent: "admin-panel"}
+
+	_, _ = svc.Login(&dto.LoginRequest{Login: "nadie", Password: "x"}, testMeta)
+	_, _ = svc.Login(&dto.LoginRequest{Login: "frank", Password: "wrong"}, testMeta)
+	_ = svc.ChangePassword(id, &dto.ChangePasswordRequest{CurrentPassword: "pass123", NewPassword: "Nueva-Clave-2024"}, dto.RequestMeta{ActorID: id, IP: testIP})
+	if _, err := svc.AssignRole(id, &dto.AssignRoleRequest{Role: auth.RoleStaff}, admin); err != nil {
+		t.Fatalf("assign role falló: %v", err)
+	}
+	if err := svc.Deactivate(id, admin); err != nil {
+		t.Fatalf("deactivate falló: %v", err)
+	}
+
+	all, err := svc.ListAudit(&dto.AuditListQuery{})
+	if err != nil {
+		t.Fatalf("list audit falló: %v", err)
+	}
+	actions := make([]string, len(all.Entries))
+	for i, e := range all.Entries {
+		actions[i] = e.Action
+	}
+	expected := []string{
+		domain.AuditUserDeactivated,
+		domain.AuditRoleAssigned,
+		domain.AuditPasswordChanged,
+		domain.AuditLoginFailed,
+		domain.AuditLoginFailed,
+		domain.AuditLoginSucceeded,
+	}
+	if strings.Join(actions, " ") != strings.Join(expected, " ") {
+		t.Fatalf("se esperaba %v, del más nuevo al más viejo, llegó %v", expected, actions)
+	}
+
+	// El login fallido de una cuenta inexistente no tiene actor ni usuario afectado
+	unknown := all.Entries[4]
+	if unknown.ActorID != nil || unknown.TargetID != nil || unknown.IP != testIP || unknown.UserAgent != "go-test" {
+		t.Fatalf("registro de login fallido inesperado: %+v", unknown)
+	}
+	// El login exitoso registra al propio usuario como actor
+	if success := all.Entries[5]; success.ActorID == nil || *success.ActorID != id {
+		t.Fatalf("el login exitoso debería tener al usuario como actor: %+v", success)
+	}
+
+	byAdmin, _ := svc.ListAudit(&dto.AuditListQuery{ActorID: 99})
+	if byAdmin.Total != 2 || *byAdmin.Entries[1].TargetID != id || byAdmin.Entries[1].Details != "from=normal to=staff" {
+		t.Fatalf("acciones del admin inesperadas: %+v", byAdmin.Entries)
+	}
+
+	failed, _ := svc.ListAudit(&dto.AuditListQuery{TargetID: id, Action: domain.AuditLoginFailed, PageSize: 1})
+	if failed.Total != 1 || failed.TotalPages != 1 || failed.Entries[0].Details != "invalid credentials" {
+		t.Fatalf("filtro por usuario y acción inesperado: %+v", failed)
+	}
+
+	if _, err := svc.ListAudit(&dto.AuditListQuery{Action: "user.teleported"}); err == nil || err.Error() != "invalid action" {
+		t.Fatalf("se esperaba invalid action, llegó %v", err)
+	}
+	if _, err := svc.ListAudit(&dto.AuditListQuery{From: "ayer"}); err == nil || err.Error() != "invalid from" {
+		t.Fatalf("se esperaba invalid from, llegó %v", err)
+	}
+}
+
+func TestPasswordPolicyRejectsWeakPasswordsWithReasons(t *testing.T) {
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{JWTExpirationHours: 1, PasswordResetTTLMinutes: 15}
+	m := mailer.NewMemoryMailer()
+	svc := NewUserService(repo, m, newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+
+	_, err := svc.Register(&dto.RegisterRequest{Username: "gina", Email: "gina@example.com", Password: "qwerty123", FirstName: "Gina", LastName: "Ruiz"})
+	var policyErr *password.PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("se esperaba un rechazo por la política, llegó %v", err)
+	}
+	codes := make([]string, len(policyErr.Reasons))
+	for i, reason := range policyErr.Reasons {
+		codes[i] = reason.Code
+	}
+	if strings.Join(codes, ",") != "too_short,missing_uppercase,breached" {
+		t.Fatalf("motivos inesperados: %v", codes)
+	}
+	if len(repo.users) != 0 {
+		t.Fatalf("no debería crearse el usuario")
+	}
+
+	// Un reset rechazado no consume el token: se puede reintentar con otra contraseña
+	loginForTest(t, repo, svc)
+	_ = svc.ForgotPassword(&dto.ForgotPasswordRequest{Email: "frank@example.com"})
+	token := tokenFromMail(t, m, "frank@example.com")
+	if err := svc.ResetPassword(&dto.ResetPasswordRequest{Token: token, NewPassword: "Password123"}, testMeta); !errors.As(err, &policyErr) {
+		t.Fatalf("se esperaba un rechazo por contraseña filtrada, llegó %v", err)
+	}
+	if err := svc.ResetPassword(&dto.ResetPasswordRequest{Token: token, NewPassword: "Nuevo-Secreto-99"}, testMeta); err != nil {
+		t.Fatalf("el token debería seguir vigente: %v", err)
+	}
+}
+
+func TestLoginRehashesWhenBcryptCostChanges(t *testing.T) {
+	repo := newMockUserRepo()
+	config.AppConfig = &config.Config{JWTExpirationHours: 1, BcryptCost: bcrypt.MinCost}
+	svc := NewUserService(repo, mailer.NewMemoryMailer(), newTestGuard(), nil, &mockPublisher{}, &mockReservaClient{}, nil)
+	loginForTest(t, repo, svc)
+	user, _ := repo.GetByUsername("frank")
+	if cost, _ := bcrypt.Cost([]byte(user.Password)); cost != bcrypt.MinCost {
+		t.Fatalf("el hash debería usar el costo configurado, tiene %d", cost)
+	}
+
+	config.AppConfig.BcryptCost = bcrypt.MinCost + 1
+	if _, err := svc.Login(&dto.LoginRequest{Login: "frank", Password: "pass123"}, testMeta); err != nil {
+		t.Fatalf("login falló: %v", err)
+	}
+	user, _ = repo.GetByUsername("frank")
+	if cost, _ := bcrypt.Cost([]byte(user.Password)); cost != bcrypt.MinCost+1 {
+		t.Fatalf("el login debería actualizar el hash al costo nuevo, tiene %d", cost)
+	}
+
+	// Un login fallido no toca el hash
+	previous := user.Password
+	config.AppConfig.BcryptCost = bcrypt.MinCost
+	_, _ = svc.Login(&dto.LoginRequest{Login: "frank", Password: "wrong"}, testMeta)
+	if user, _ = repo.GetByUsername("frank"); user.Password != previous {
+		t.Fatalf("un login fallido no debería rehashear")
+	}
+}
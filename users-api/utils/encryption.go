@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// encryptedPrefix marca los valores cifrados con EncryptString; un valor sin prefijo se guardó en claro
+const encryptedPrefix = "enc:v1:"
+
+var (
+	ErrNoEncryptionKey = errors.New("encryption key not configured")
+	ErrDecryption      = errors.New("could not decrypt value")
+)
+
+// EncryptString cifra plaintext con AES-256-GCM y lo codifica como "enc:v1:" + base64(nonce || texto cifrado).
+// aad liga el valor a su contexto (por ejemplo el usuario): copiado a otra fila ya no se puede descifrar.
+func EncryptString(key []byte, plaintext string, aad []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), aad)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptString descifra un valor de EncryptString. Un valor sin el prefijo se guardó en claro antes
+// de que existiera el cifrado: se devuelve tal cual con legacy=true para que el llamador lo vuelva a guardar cifrado.
+func DecryptString(key []byte, value string, aad []byte) (plaintext string, legacy bool, err error) {
+	encoded, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return value, true, nil
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", false, err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return "", false, ErrDecryption
+	}
+
+	opened, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], aad)
+	if err != nil {
+		return "", false, ErrDecryption
+	}
+
+	return string(opened), false, nil
+}
+
+// newGCM arma el cifrador AES-256-GCM; la clave debe tener 32 bytes
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) == 0 {
+		return nil, ErrNoEncryptionKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
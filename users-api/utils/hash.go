@@ -1,12 +1,14 @@
 package utils
 
 import (
+	"users-api/config"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
-// HashPassword genera un hash de la contraseña
+// HashPassword genera un hash de la contraseña con el costo de BCRYPT_COST
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost())
 	return string(bytes), err
 }
 
@@ -15,3 +17,18 @@ func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// NeedsRehash indica si el hash se generó con un costo distinto del configurado
+func NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && cost != bcryptCost()
+}
+
+// bcryptCost es el costo configurado, o el de bcrypt por defecto si está fuera de rango
+func bcryptCost() int {
+	cost := config.AppConfig.BcryptCost
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return bcrypt.DefaultCost
+	}
+	return cost
+}
@@ -1,7 +1,8 @@
 package utils
 
 import (
-	"errors"
+	"crypto"
+	"shared/auth"
 	"time"
 	"users-api/config"
 	"users-api/internal/domain"
@@ -9,53 +10,62 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
-	jwt.RegisteredClaims
-}
-
-// GenerateToken genera un token JWT para un usuario
-func GenerateToken(user *domain.User) (string, error) {
-	// Token expira en 5 minutos
-	expirationTime := time.Now().Add(10 * time.Minute)
+// GenerateToken genera un token JWT para un usuario, firmado con la clave activa (RS256 o EdDSA).
+// amr indica los métodos con los que se autenticó (auth.AMRPassword, auth.AMROTP)
+// y permissions los permisos actuales de su rol.
+func GenerateToken(user *domain.User, amr, permissions []string) (string, error) {
+	// La duración del access token se toma de JWT_EXPIRATION_HOURS
+	hours := config.AppConfig.JWTExpirationHours
+	if hours <= 0 {
+		hours = 24
+	}
+	expirationTime := time.Now().Add(time.Duration(hours) * time.Hour)
 
-	claims := &Claims{
-		UserID:   user.ID,
-		Username: user.Username,
-		Role:     user.Role,
+	claims := &auth.Claims{
+		UserID:      user.ID,
+		Username:    user.Username,
+		Role:        user.Role,
+		AMR:         amr,
+		Permissions: permissions,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(config.AppConfig.JWTSecret))
-
+	keys, err := signingKeys()
 	if err != nil {
 		return "", err
 	}
 
-	return tokenString, nil
+	return keys.Sign(claims)
 }
 
 // ValidateToken valida un token JWT y retorna los claims
-func ValidateToken(tokenString string) (*Claims, error) {
-	claims := &Claims{}
+func ValidateToken(tokenString string) (*auth.Claims, error) {
+	return TokenValidator().Validate(tokenString)
+}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(config.AppConfig.JWTSecret), nil
+// TokenValidator retorna un validador que usa las claves públicas propias de users-api
+func TokenValidator() auth.Validator {
+	return auth.NewKeyValidator(func(kid string) (crypto.PublicKey, error) {
+		keys, err := signingKeys()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := keys.PublicKeys()[kid]
+		if !ok {
+			return nil, auth.ErrUnknownKID
+		}
+		return key, nil
 	})
+}
 
+// PublicJWKS retorna el JWKS con las claves públicas vigentes
+func PublicJWKS() (auth.JWKS, error) {
+	keys, err := signingKeys()
 	if err != nil {
-		return nil, err
-	}
-
-	if !token.Valid {
-		return nil, errors.New("invalid token")
+		return auth.JWKS{}, err
 	}
-
-	return claims, nil
+	return keys.JWKS(), nil
 }
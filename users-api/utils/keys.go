@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"shared/auth"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeySet contiene las claves privadas de firma de users-api indexadas por kid.
+// Solo la clave activa firma tokens; todas se publican en el JWKS para que los
+// tokens firmados antes de una rotación sigan siendo válidos hasta expirar.
+type KeySet struct {
+	activeKID string
+	keys      map[string]crypto.Signer
+}
+
+var (
+	keySetMu      sync.RWMutex
+	currentKeySet *KeySet
+)
+
+// LoadKeySet carga las claves PEM (PKCS#8 o PKCS#1) del directorio indicado.
+// El kid de cada clave es el nombre del archivo sin extensión. Si activeKID está
+// vacío se usa el último kid en orden alfabético, de modo que rotar es agregar un archivo nuevo.
+// Si el directorio no tiene claves se genera y guarda una Ed25519.
+func LoadKeySet(dir, activeKID string) (*KeySet, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		file, err := generateKeyFile(dir)
+		if err != nil {
+			return nil, fmt.Errorf("no signing keys found in %s and could not create one: %w", dir, err)
+		}
+		log.Printf("Generated new Ed25519 signing key %s", file)
+		files = []string{file}
+	}
+	sort.Strings(files)
+
+	set := &KeySet{keys: map[string]crypto.Signer{}}
+	for _, file := range files {
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		key, err := readPrivateKey(file)
+		if err != nil {
+			return nil, fmt.Errorf("error loading key %s: %w", kid, err)
+		}
+		set.keys[kid] = key
+		set.activeKID = kid
+	}
+
+	if activeKID != "" {
+		if _, ok := set.keys[activeKID]; !ok {
+			return nil, fmt.Errorf("active key %q not found in %s", activeKID, dir)
+		}
+		set.activeKID = activeKID
+	}
+
+	return set, nil
+}
+
+// NewEphemeralKeySet genera una clave Ed25519 en memoria. Solo apta para desarrollo y tests:
+// los tokens dejan de validar al reiniciar el servicio.
+func NewEphemeralKeySet() (*KeySet, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &KeySet{activeKID: "ephemeral", keys: map[string]crypto.Signer{"ephemeral": priv}}, nil
+}
+
+// SetKeySet define las claves que usa GenerateToken
+func SetKeySet(set *KeySet) {
+	keySetMu.Lock()
+	defer keySetMu.Unlock()
+	currentKeySet = set
+}
+
+// signingKeys retorna las claves actuales, generando unas efímeras si nadie las configuró
+func signingKeys() (*KeySet, error) {
+	keySetMu.RLock()
+	set := currentKeySet
+	keySetMu.RUnlock()
+	if set != nil {
+		return set, nil
+	}
+
+	keySetMu.Lock()
+	defer keySetMu.Unlock()
+	if currentKeySet == nil {
+		log.Println("Warning: no signing keys configured, using an ephemeral Ed25519 key")
+		set, err := NewEphemeralKeySet()
+		if err != nil {
+			return nil, err
+		}
+		currentKeySet = set
+	}
+	return currentKeySet, nil
+}
+
+// Sign firma los claims con la clave activa y agrega el header kid
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key := s.keys[s.activeKID]
+
+	var method jwt.SigningMethod
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		method = jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return "", errors.New("unsupported signing key")
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = s.activeKID
+	return token.SignedString(key)
+}
+
+// JWKS retorna las claves públicas en formato JSON Web Key Set
+func (s *KeySet) JWKS() auth.JWKS {
+	kids := make([]string, 0, len(s.keys))
+	for kid := range s.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := auth.JWKS{Keys: []auth.JWK{}}
+	for _, kid := range kids {
+		jwk, err := auth.NewJWK(kid, s.keys[kid].Public())
+		if err != nil {
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// PublicKeys retorna las claves públicas indexadas por kid
+func (s *KeySet) PublicKeys() map[string]crypto.PublicKey {
+	keys := make(map[string]crypto.PublicKey, len(s.keys))
+	for kid, key := range s.keys {
+		keys[kid] = key.Public()
+	}
+	return keys
+}
+
+// generateKeyFile crea una clave Ed25519 en formato PKCS#8 con un kid basado en la fecha
+func generateKeyFile(dir string) (string, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	file := filepath.Join(dir, time.Now().UTC().Format("20060102T150405")+".pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+func readPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM file")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T (use RSA or Ed25519)", parsed)
+	}
+}